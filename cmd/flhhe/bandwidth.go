@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/bandwidth"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+// runBandwidth sizes the messages exchanged by the roles from the artifacts of a round under the root,
+// and compares the client uploads with the plaintext weights and with the HE baseline
+func runBandwidth(args []string) error {
+	fs, common := newFlagSet("bandwidth")
+	clients := fs.String("clients", "", "comma separated client IDs of the round (default: the clients of the experiment)")
+	run := fs.String("run", "hhe", "name of the run, the report is saved as <results_dir>/bandwidth_<run>.json")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	cipher, err := common.symmetricCipher()
+	if err != nil {
+		return err
+	}
+	packing, err := common.packing()
+	if err != nil {
+		return err
+	}
+	// The parameters only locate the artifacts, keep their logs quiet
+	rubatoParams, err := keys_dealer.InitRubatoParams(utils.NewLogger(false), cipher, packing)
+	if err != nil {
+		return err
+	}
+
+	var round []experiment.Client
+	for _, id := range common.clientIDs(*clients) {
+		i := slices.IndexFunc(common.cfg.Clients, func(c experiment.Client) bool { return c.ID == id })
+		if i < 0 {
+			return fmt.Errorf("bandwidth: client %s is not in the experiment", id)
+		}
+		round = append(round, common.cfg.Clients[i])
+	}
+
+	logger := common.logger()
+	report, err := bandwidth.Account(logger, common.cfg.Root, rubatoParams, round, common.cfg.CKKSParams)
+	if err != nil {
+		return err
+	}
+	report.Print(logger)
+	path := common.cfg.BandwidthPath(*run)
+	if err = report.Save(path); err != nil {
+		return err
+	}
+	logger.PrintFormatted("Bandwidth report saved to %s", path)
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+)
+
+// timing the time spent by one role of the protocol
+type timing struct {
+	role     string
+	duration time.Duration
+}
+
+// runBench runs the whole protocol in one process, like `just run-hhe`, and reports the time spent by each role.
+// With -ciphers, the protocol runs once per cipher and the timings are compared side by side.
+func runBench(args []string) error {
+	fs, common := newFlagSet("bench")
+	clients := fs.String("clients", "", "comma separated client IDs (default: the clients of the experiment)")
+	weights := fs.String("weights", "", "comma separated weights files, one per client (default: the ones of the experiment)")
+	ciphers := fs.String("ciphers", "", "comma separated ciphers to compare (rubato,hera,pasta), each with the parameter set of the experiment (default: the cipher of the experiment)")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	clientIDs, weightFiles := common.clientIDs(*clients), splitList(*weights)
+	if *clients == "" && *weights == "" {
+		weightFiles = nil
+		for _, c := range common.cfg.Clients {
+			weightFiles = append(weightFiles, c.Weights)
+		}
+	}
+	if len(clientIDs) == 0 || len(clientIDs) != len(weightFiles) {
+		return fmt.Errorf("bench: %d clients for %d weights files", len(clientIDs), len(weightFiles))
+	}
+
+	packing, err := common.packing()
+	if err != nil {
+		return err
+	}
+
+	names := splitList(*ciphers)
+	if len(names) == 0 {
+		names = []string{common.cfg.Cipher}
+	}
+	var benchCiphers []RtF.SymmetricCipher
+	for _, name := range names {
+		cfg := *common.cfg
+		cfg.Cipher = name
+		cipher, err := cfg.SymmetricCipher()
+		if common.toy != 0 {
+			cipher, err = toyCipher(&cfg, common.toy)
+		}
+		if err != nil {
+			return fmt.Errorf("bench: %v", err)
+		}
+		benchCiphers = append(benchCiphers, cipher)
+	}
+
+	logger := common.logger()
+	if err := common.save(); err != nil {
+		return err
+	}
+
+	// The artifacts of a cipher are overwritten by the next one, the keys are kept per cipher
+	timings := make([][]timing, len(benchCiphers))
+	for c, cipher := range benchCiphers {
+		if timings[c], err = benchProtocol(logger, common.cfg.Root, cipher, packing, common.cfg.Bootstrap, clientIDs, weightFiles); err != nil {
+			return fmt.Errorf("bench %s: %w", cipher.Name(), err)
+		}
+	}
+
+	fmt.Printf("\n%-24s", "role")
+	for _, cipher := range benchCiphers {
+		fmt.Printf(" %16s", cipher.Name()+" (s)")
+	}
+	fmt.Println()
+	totals := make([]time.Duration, len(benchCiphers))
+	for r := range timings[0] {
+		fmt.Printf("%-24s", timings[0][r].role)
+		for c := range benchCiphers {
+			fmt.Printf(" %16.3f", timings[c][r].duration.Seconds())
+			totals[c] += timings[c][r].duration
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%-24s", "total")
+	for c := range benchCiphers {
+		fmt.Printf(" %16.3f", totals[c].Seconds())
+	}
+	fmt.Println()
+	return nil
+}
+
+// benchProtocol runs the keys dealer, the clients, the transciphering and the aggregation with the given cipher
+// and packing. Unlike the server, it fails on the first client error since its timings wouldn't compare.
+func benchProtocol(logger utils.Logger, rootPath string, cipher RtF.SymmetricCipher, packing keys_dealer.Packing, bootstrap bool, clientIDs []string, weightFiles []string) ([]timing, error) {
+	var timings []timing
+
+	t := time.Now()
+	rubatoParams, hheComponents, rubato, err := keys_dealer.RunKeysDealer(logger, rootPath, cipher, packing, bootstrap, false)
+	if err != nil {
+		return nil, err
+	}
+	timings = append(timings, timing{"keys dealer", time.Since(t)})
+
+	flClients := make([]*client.FLClient, len(clientIDs))
+	for i := range clientIDs {
+		if _, err = client.Register(logger, rootPath, clientIDs[i]); err != nil {
+			return nil, err
+		}
+		t = time.Now()
+		if flClients[i], err = client.RunFLClient(logger, rootPath, rubatoParams, hheComponents, weightFiles[i], clientIDs[i], 0); err != nil {
+			return nil, err
+		}
+		timings = append(timings, timing{"client " + clientIDs[i], time.Since(t)})
+	}
+
+	verifier, err := server.LoadVerifier(filepath.Join(rootPath, configs.Keys), 0, "")
+	if err != nil {
+		return nil, err
+	}
+	logger = logger.With(utils.LogKeyRole, utils.RoleServer)
+	t = time.Now()
+	if _, err = server.Transcipher(logger, rootPath, flClients, rubatoParams, hheComponents, rubato, verifier); err != nil {
+		return nil, err
+	}
+	timings = append(timings, timing{"server transcipher", time.Since(t)})
+
+	t = time.Now()
+	if err = server.HEFedAvg(logger, rootPath, clientIDs, rubatoParams, hheComponents); err != nil {
+		return nil, err
+	}
+	timings = append(timings, timing{"server aggregate", time.Since(t)})
+
+	return timings, nil
+}
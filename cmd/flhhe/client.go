@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+)
+
+// runClientRegister generates the identity key of one client, if it has none, and registers its public
+// key with the keys dealer. `flhhe client encrypt` signs the uploads with it.
+func runClientRegister(args []string) error {
+	fs, common := newFlagSet("client register")
+	clientID := fs.String("client", "", "client ID (e.g. do1)")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	if *clientID == "" {
+		return errors.New("client register: -client is required")
+	}
+	_, err := client.Register(common.logger(), common.cfg.Root, *clientID)
+	return err
+}
+
+// runClientEncrypt encrypts the weights of one client with the symmetric key. The client only
+// needs the CKKS encoder, none of the HE keys.
+func runClientEncrypt(args []string) error {
+	fs, common := newFlagSet("client encrypt")
+	clientID := fs.String("client", "", "client ID (e.g. do1)")
+	weights := fs.String("weights", "", "plaintext weights file in configs.PlaintextWeights (default: the one of the client in the experiment)")
+	round := fs.Int("round", 0, "round of the upload, from 0")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	if *clientID == "" {
+		return errors.New("client encrypt: -client is required")
+	}
+	if *round < 0 {
+		return fmt.Errorf("client encrypt: invalid round %d", *round)
+	}
+	for _, c := range common.cfg.Clients {
+		if c.ID == *clientID && *weights == "" {
+			*weights = c.Weights
+		}
+	}
+	if *weights == "" {
+		return fmt.Errorf("client encrypt: client %s isn't in the experiment, -weights is required", *clientID)
+	}
+	cipher, err := common.symmetricCipher()
+	if err != nil {
+		return err
+	}
+	packing, err := common.packing()
+	if err != nil {
+		return err
+	}
+
+	logger := common.logger()
+	if err = common.save(); err != nil {
+		return err
+	}
+	rubatoParams, err := keys_dealer.InitRubatoParams(logger, cipher, packing)
+	if err != nil {
+		return err
+	}
+	hheComponents := &keys_dealer.HHEComponents{CkksEncoder: RtF.NewCKKSEncoder(rubatoParams.Params)}
+	_, err = client.RunFLClient(logger, common.cfg.Root, rubatoParams, hheComponents, *weights, *clientID, *round)
+	return common.saveMetrics(err)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/diagnostics"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+)
+
+// runDecrypt decrypts the average ciphertexts into configs.DecryptedWeights/hhe_decrypted_avg_fc<i>.json,
+// which is what `just run-mnist-fed-avg-hhe` evaluates. It is run by the key holder.
+func runDecrypt(args []string) error {
+	fs, common := newFlagSet("decrypt")
+	compare := fs.Bool("compare", false, "compare with the plain HE average (he_decrypted_avg_fc<i>.json from `just run-he`)")
+	clients := fs.String("clients", "", "comma separated client IDs of the diagnostics to decrypt (default: the clients of the experiment)")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	cipher, err := common.symmetricCipher()
+	if err != nil {
+		return err
+	}
+	packing, err := common.packing()
+	if err != nil {
+		return err
+	}
+
+	logger := common.logger()
+	if err := common.save(); err != nil {
+		return err
+	}
+	rubatoParams, err := keys_dealer.InitRubatoParams(logger, cipher, packing)
+	if err != nil {
+		return err
+	}
+	params := rubatoParams.Params
+
+	sk := new(RtF.SecretKey)
+	if err := utils.Deserialize(sk, filepath.Join(common.cfg.Root, configs.Keys, configs.SecretKey)); err != nil {
+		return err
+	}
+	encoder := RtF.NewCKKSEncoder(params)
+	decryptor := RtF.NewCKKSDecryptor(params, sk)
+
+	decryptedWeightsDir := filepath.Join(common.cfg.Root, configs.DecryptedWeights)
+	if err := os.MkdirAll(decryptedWeightsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	avgCiphertextsDir := filepath.Join(common.cfg.Root, configs.HEEncryptedWeights, "avg")
+	for i := range rubatoParams.OutputSize {
+		logger.PrintHeader(fmt.Sprintf("Decrypting avgFC%d", i+1))
+		cts, err := server.LoadOutput(logger, i, packing, avgCiphertextsDir, params)
+		if err != nil {
+			return err
+		}
+		values := server.DecryptOutput(params, cts, decryptor, encoder)
+		logger.PrintFormatted("Level: %d (logQ = %d)", cts[0].Level(), params.LogQLvl(cts[0].Level()))
+		logger.PrintFormatted("Scale: 2^%f", math.Log2(cts[0].Scale()))
+
+		if *compare {
+			heValues, err := utils.LoadFromJSON(logger, decryptedWeightsDir, fmt.Sprintf("he_decrypted_avg_fc%d.json", i+1))
+			if err != nil {
+				return err
+			}
+			want := server.SplitHalves(heValues, params.Slots())
+			for half := range packing.NbHalves() {
+				have := values[half*params.Slots() : (half+1)*params.Slots()]
+				stats, err := RtF.GetPrecisionStats(params, encoder, nil, want[half], have, params.LogSlots(), params.Sigma())
+				if err != nil {
+					return err
+				}
+				fmt.Println(stats.String())
+			}
+		}
+
+		if err = utils.SaveComplexToJSON(logger, decryptedWeightsDir, fmt.Sprintf("hhe_decrypted_avg_fc%d.json", i+1), values); err != nil {
+			return err
+		}
+	}
+
+	diagnosticsDir := filepath.Join(common.cfg.Root, configs.Diagnostics)
+	if _, err := os.Stat(diagnosticsDir); err == nil {
+		d, err := diagnostics.Load(diagnosticsDir, common.clientIDs(*clients), params)
+		if err != nil {
+			return err
+		}
+		diagnostics.Decrypt(logger, params, encoder, decryptor, d)
+	}
+	return nil
+}
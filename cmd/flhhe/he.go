@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flhhe/src/he_fedavg/baseline"
+)
+
+// runHE runs the HE baseline in one process, like `just run-he`: the clients encrypt their weights with
+// CKKS and the server averages them. `flhhe decrypt -compare` compares the HHE average with its output.
+func runHE(args []string) error {
+	fs, common := newFlagSet("he")
+	ckksParams := fs.String("ckks", "", "CKKS parameter set of the HE scheme (N12QP109, ..., N16QP421), overrides the configuration")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	if *ckksParams != "" {
+		common.cfg.CKKSParams = *ckksParams
+		if err := common.cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := common.cfg.CheckWeights(); err != nil {
+		return err
+	}
+
+	// RunHEFedAvg saves the configuration itself
+	return common.saveMetrics(baseline.RunHEFedAvg(common.logger(), common.cfg))
+}
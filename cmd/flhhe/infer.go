@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flhhe/src/hhe_fedavg/inference"
+)
+
+// runInfer evaluates the aggregated model on the encrypted MNIST test set, like `just run-hhe-inference`.
+// It plays the data owner, the evaluator and the key holder (see package inference), after `flhhe server
+// aggregate` and `just export-mnist-test-set`.
+func runInfer(args []string) error {
+	fs, common := newFlagSet("infer")
+	bound := fs.Float64("bound", inference.DefaultActivationBound, "bound B of the interval [-B, B] on which the ReLU is approximated")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+
+	if err := common.save(); err != nil {
+		return err
+	}
+	_, err := inference.Run(common.logger(), common.cfg, *bound)
+	return common.saveMetrics(err)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/inspect"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+// runInspect describes the .bin artifacts given as arguments (files or directories, by default
+// the keys and the weights under the root). With -noise, the ciphertexts are decrypted with the
+// secret key to report their noise budget or precision.
+func runInspect(args []string) error {
+	flags, common := newFlagSet("inspect")
+	noise := flags.Bool("noise", false, "decrypt the ciphertexts with the secret key of the root to measure their noise")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	cipher, err := common.symmetricCipher()
+	if err != nil {
+		return err
+	}
+	packing, err := common.packing()
+	if err != nil {
+		return err
+	}
+	// The parameters are only used to name the moduli and rotations, keep their logs quiet
+	rubatoParams, err := keys_dealer.InitRubatoParams(utils.NewLogger(false), cipher, packing)
+	if err != nil {
+		return err
+	}
+	params := rubatoParams.Params
+
+	var sk *RtF.SecretKey
+	if *noise {
+		sk = new(RtF.SecretKey)
+		if err := utils.Deserialize(sk, filepath.Join(common.cfg.Root, configs.Keys, configs.SecretKey)); err != nil {
+			return err
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{
+			filepath.Join(common.cfg.Root, configs.Keys),
+			filepath.Join(common.cfg.Root, configs.SymmetricEncryptedWeights),
+			filepath.Join(common.cfg.Root, configs.HEEncryptedWeights),
+		}
+	}
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			fmt.Printf("%s: not found\n", path)
+			continue
+		}
+		err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, configs.CtFormat) {
+				return err
+			}
+			report, err := inspect.Inspect(path, params, sk)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				return nil
+			}
+			fmt.Print(report.Format(params))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
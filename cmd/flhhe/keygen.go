@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+)
+
+// runKeygen generates (or reuses) the HHE keys and the symmetric key, like the keys dealer of `just run-hhe`
+func runKeygen(args []string) error {
+	_, common := newFlagSet("keygen")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	// The keys of a seeded run could be regenerated by anyone holding the seed
+	if common.cfg.Seed != "" {
+		return fmt.Errorf("keygen: %w, remove the seed of the configuration", RtF.ErrSeededKeys)
+	}
+	cipher, err := common.symmetricCipher()
+	if err != nil {
+		return err
+	}
+	packing, err := common.packing()
+	if err != nil {
+		return err
+	}
+
+	if err = common.save(); err != nil {
+		return err
+	}
+	_, _, _, err = keys_dealer.RunKeysDealer(common.logger(), common.cfg.Root, cipher, packing, common.cfg.Bootstrap, false)
+	return common.saveMetrics(err)
+}
@@ -0,0 +1,274 @@
+// Command flhhe runs each role of the HHE FedAvg protocol as a separate command, so the keys dealer,
+// the clients and the server can be run as separate processes (or on separate machines that
+// share the artifacts under the root directory).
+//
+//	flhhe run
+//	flhhe keygen
+//	flhhe client register -client do1
+//	flhhe client encrypt -client do1 -weights weights_no_137.json
+//	flhhe server transcipher -clients do1,do2,do3
+//	flhhe server aggregate -clients do1,do2,do3
+//	flhhe he
+//	flhhe decrypt -compare
+//	flhhe infer
+//	flhhe inspect
+//	flhhe bandwidth
+//	flhhe moddown -margin 10
+//	flhhe bench -clients do1,do2,do3 -weights weights_no_137.json,weights_no_258.json,weights_no_469.json
+//	flhhe sweep -nclients 1,3,10 -hhe RUBATO128L,HERA128 -he N16QP421
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"flhhe/src/RtF"
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/metrics"
+	"flhhe/src/utils"
+)
+
+const usage = `Usage: flhhe <command> [flags]
+
+Commands:
+  run                   run the FedAvg of the scheme of the experiment (he or hhe) in one process
+  keygen                generate the HHE keys, the symmetric key and its FV encryption
+  client register       register the identity key of one client with the keys dealer
+  client encrypt        encrypt and sign the weights of one client with the symmetric key
+  server transcipher    transcipher the symmetric ciphertexts of the clients into CKKS ciphertexts
+  server aggregate      average the transciphered ciphertexts of the clients
+  he                    run the HE baseline (the clients encrypt with CKKS) in one process
+  decrypt               decrypt the average ciphertexts (and the client diagnostics, if any)
+  infer                 evaluate the aggregated model on the encrypted MNIST test set
+  inspect               list the artifacts under the root directory
+  bandwidth             report the bytes exchanged by the roles, against the plaintext weights and the HE baseline
+  bench                 run the whole protocol in one process and report the time of each role
+  sweep                 benchmark HE against HHE on synthetic weights for numbers of clients, models and parameter sets
+  moddown               search the mod down schedule of the cipher and report the noise budget of each round
+
+Run 'flhhe <command> -h' for the flags of a command.
+`
+
+// commonFlags the flags shared by all the commands. The experiment configuration gives the
+// defaults, -root, -cipher, -params and -log-format override it.
+type commonFlags struct {
+	fs        *flag.FlagSet
+	config    string
+	root      string
+	cipher    string
+	params    string
+	logFormat string
+	toy       int
+	debug     bool
+	cfg       *experiment.Experiment
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	common := &commonFlags{fs: fs}
+	fs.StringVar(&common.config, "config", "", "experiment configuration (default "+experiment.DefaultConfig+")")
+	fs.StringVar(&common.root, "root", "", "root directory of the keys and weights (layout of configs/paths.go), overrides the configuration")
+	fs.StringVar(&common.cipher, "cipher", "", "symmetric cipher (rubato, hera or pasta), overrides the configuration")
+	fs.StringVar(&common.params, "params", "", "parameter set of the cipher (RUBATO80S, ..., RUBATO128L, HERA80, HERA128, PASTA3, PASTA4), overrides the configuration")
+	fs.StringVar(&common.logFormat, "log-format", "", "format of the log records (text or json), overrides the configuration")
+	fs.IntVar(&common.toy, "toy", 0, "run the cipher on the INSECURE toy parameters of ring degree 2^toy (10 or 12), rubato only, for tests")
+	fs.BoolVar(&common.debug, "debug", utils.DEBUG, "print debug information")
+	return fs, common
+}
+
+// parse parses the flags and loads the experiment configuration
+func (c *commonFlags) parse(args []string) error {
+	if err := c.fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := experiment.LoadOrDefault(c.config)
+	if err != nil {
+		return err
+	}
+	if c.root != "" {
+		cfg.Root = c.root
+	}
+	if c.cipher != "" {
+		cfg.Cipher = c.cipher
+	}
+	if c.params != "" {
+		switch cfg.Cipher {
+		case experiment.CipherHera:
+			cfg.HeraParams = c.params
+		case experiment.CipherPasta:
+			cfg.PastaParams = c.params
+		default:
+			cfg.RubatoParams = c.params
+		}
+	}
+	if c.logFormat != "" {
+		cfg.LogFormat = c.logFormat
+	}
+	if err = cfg.Validate(); err != nil {
+		return err
+	}
+	if err = cfg.ApplyRuntime(); err != nil {
+		return err
+	}
+	// With -debug, the seeds of the RtF PRNGs are logged to replay a failing run
+	RtF.SetPRNGLogger(c.logger())
+	c.cfg = cfg
+	return nil
+}
+
+// symmetricCipher returns the symmetric cipher of the experiment, on the toy parameters with -toy
+func (c *commonFlags) symmetricCipher() (RtF.SymmetricCipher, error) {
+	if c.toy != 0 {
+		return toyCipher(c.cfg, c.toy)
+	}
+	return c.cfg.SymmetricCipher()
+}
+
+// packing returns the packing of the client data of the experiment
+func (c *commonFlags) packing() (keys_dealer.Packing, error) {
+	return keys_dealer.ParsePacking(c.cfg.Packing)
+}
+
+// toyCipher the Rubato parameter set of the experiment on the toy parameters of ring degree 2^logN
+func toyCipher(cfg *experiment.Experiment, logN int) (RtF.SymmetricCipher, error) {
+	if cfg.Cipher != experiment.CipherRubato {
+		return nil, fmt.Errorf("toy: the toy parameters are only defined for rubato, not %s", cfg.Cipher)
+	}
+	rubatoParam, err := cfg.RubatoParamIndex()
+	if err != nil {
+		return nil, err
+	}
+	for toyParam, params := range RtF.RtFToyParams {
+		if params.LogN == logN {
+			return cfg.WithConstantTime(RtF.NewToyRubatoCipher(rubatoParam, toyParam)), nil
+		}
+	}
+	return nil, fmt.Errorf("toy: no toy parameters of ring degree 2^%d", logN)
+}
+
+// clientIDs returns the clients of a -clients flag, or the ones of the experiment if it's empty
+func (c *commonFlags) clientIDs(value string) []string {
+	if value == "" {
+		return c.cfg.ClientIDs()
+	}
+	return splitList(value)
+}
+
+// save saves a copy of the experiment configuration with the results of the command
+func (c *commonFlags) save() error {
+	path, err := c.cfg.Save(strings.ReplaceAll(c.fs.Name(), " ", "_"))
+	if err != nil {
+		return err
+	}
+	c.logger().PrintFormatted("Experiment configuration saved to %s", path)
+	return nil
+}
+
+// saveMetrics saves the metrics report of the command with its results, joined to the error of the command
+func (c *commonFlags) saveMetrics(err error) error {
+	path, saveErr := c.cfg.SaveMetrics(strings.ReplaceAll(c.fs.Name(), " ", "_"))
+	if saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	c.logger().PrintFormatted("Metrics report saved to %s", path)
+	return err
+}
+
+// serveMetrics exposes the metrics of the command on the /metrics endpoint of addr, or of the
+// configuration if it's empty, and returns the function stopping it
+func (c *commonFlags) serveMetrics(addr string) (stop func(), err error) {
+	if addr == "" {
+		addr = c.cfg.MetricsAddr
+	}
+	if addr == "" {
+		return func() {}, nil
+	}
+	server, err := metrics.Default().Serve(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.logger().PrintFormatted("Serving the metrics on http://%s/metrics", addr)
+	return func() { server.Close() }, nil
+}
+
+func (c *commonFlags) logger() utils.Logger {
+	return utils.NewLogger(c.debug)
+}
+
+// splitList splits a comma separated flag value
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "run":
+		err = runRun(args)
+	case "keygen":
+		err = runKeygen(args)
+	case "client":
+		err = runSubcommand(cmd, args, map[string]func([]string) error{
+			"register": runClientRegister,
+			"encrypt":  runClientEncrypt,
+		})
+	case "server":
+		err = runSubcommand(cmd, args, map[string]func([]string) error{
+			"transcipher": runServerTranscipher,
+			"aggregate":   runServerAggregate,
+		})
+	case "he":
+		err = runHE(args)
+	case "decrypt":
+		err = runDecrypt(args)
+	case "infer":
+		err = runInfer(args)
+	case "inspect":
+		err = runInspect(args)
+	case "bandwidth":
+		err = runBandwidth(args)
+	case "bench":
+		err = runBench(args)
+	case "sweep":
+		err = runSweep(args)
+	case "moddown":
+		err = runModDown(args)
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "flhhe: %v\nRun 'flhhe help' for usage.\n", err)
+		os.Exit(1)
+	}
+}
+
+// runSubcommand dispatches the second word of a two words command (e.g. `server aggregate`)
+func runSubcommand(cmd string, args []string, subcommands map[string]func([]string) error) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing %s subcommand", cmd)
+	}
+	run, ok := subcommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q", cmd+" "+args[0])
+	}
+	return run(args[1:])
+}
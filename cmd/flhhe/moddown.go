@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"flhhe/src/RtF"
+)
+
+// runModDown searches the mod down schedule of the cipher of the experiment with fresh keys and prints
+// it as an entry of the tables of src/RtF/rtf_params.go, with the noise budget left at each stage
+func runModDown(args []string) error {
+	fs, common := newFlagSet("moddown")
+	margin := fs.Int("margin", 10, "noise budget (bits) the keystream must keep after SlotsToCoeffs")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	cipher, err := common.symmetricCipher()
+	if err != nil {
+		return err
+	}
+
+	logger := common.logger()
+	logger.PrintFormatted("Searching the mod down schedule of %s with a margin of %d bits", cipher.Name(), *margin)
+	_, report, err := RtF.SearchModDown(cipher, *margin)
+	if report != nil {
+		fmt.Print(report.String())
+		fmt.Print(report.Entry())
+	}
+	return err
+}
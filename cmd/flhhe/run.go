@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+
+	"flhhe/src/experiment"
+)
+
+// runRun runs the FedAvg of the scheme of the experiment in one process: the HE baseline like `flhhe he`,
+// or the HHE protocol like `flhhe bench`. It only takes the common flags.
+func runRun(args []string) error {
+	_, common := newFlagSet("run")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	switch common.cfg.Scheme {
+	case experiment.SchemeHE:
+		return runHE(args)
+	case experiment.SchemeHHE:
+		return runBench(args)
+	}
+	return fmt.Errorf("run: unknown scheme %q", common.cfg.Scheme)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"flhhe/configs"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+)
+
+// loadServer reads the keys saved by `flhhe keygen` and sets up the HHE scheme of the server
+func loadServer(logger utils.Logger, common *commonFlags) (*keys_dealer.RubatoParams, *keys_dealer.HHEComponents, error) {
+	cipher, err := common.symmetricCipher()
+	if err != nil {
+		return nil, nil, err
+	}
+	packing, err := common.packing()
+	if err != nil {
+		return nil, nil, err
+	}
+	rubatoParams, err := keys_dealer.InitRubatoParams(logger, cipher, packing)
+	if err != nil {
+		return nil, nil, err
+	}
+	if common.cfg.Bootstrap {
+		if err = keys_dealer.EnableBootstrapping(rubatoParams); err != nil {
+			return nil, nil, err
+		}
+	}
+	keysDir := filepath.Join(common.cfg.Root, configs.Keys)
+	hheComponents, err := keys_dealer.InitHHEScheme(logger, keysDir, rubatoParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rubatoParams, hheComponents, nil
+}
+
+// runServerTranscipher transciphers the symmetric ciphertexts saved by `flhhe client encrypt`
+func runServerTranscipher(args []string) error {
+	fs, common := newFlagSet("server transcipher")
+	clients := fs.String("clients", "", "comma separated client IDs (default: the clients of the experiment)")
+	round := fs.Int("round", 0, "round of the uploads, from 0")
+	metricsAddr := fs.String("metrics-addr", "", "address of the /metrics endpoint, overrides the configuration")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	clientIDs := common.clientIDs(*clients)
+	if len(clientIDs) == 0 {
+		return errors.New("server transcipher: -clients is empty")
+	}
+	if *round < 0 {
+		return fmt.Errorf("server transcipher: invalid round %d", *round)
+	}
+
+	logger := common.logger().With(utils.LogKeyRole, utils.RoleServer)
+	if err := common.save(); err != nil {
+		return err
+	}
+	stop, err := common.serveMetrics(*metricsAddr)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	rubatoParams, hheComponents, err := loadServer(logger, common)
+	if err != nil {
+		return err
+	}
+	rubato, err := keys_dealer.NewMFVCipher(rubatoParams, hheComponents)
+	if err != nil {
+		return err
+	}
+	// The ledger of the accepted uploads outlives the run, a replayed upload is rejected in the next ones
+	keysDir := filepath.Join(common.cfg.Root, configs.Keys)
+	verifier, err := server.LoadVerifier(keysDir, *round, filepath.Join(common.cfg.Root, configs.UploadLedger))
+	if err != nil {
+		return err
+	}
+
+	// The clients whose upload can't be loaded are skipped like those Transcipher can't transcipher
+	var skipped []error
+	flClients := make([]*client.FLClient, 0, len(clientIDs))
+	for _, clientID := range clientIDs {
+		flClient, err := client.LoadFLClient(logger, common.cfg.Root, clientID, rubatoParams.Params)
+		if err != nil {
+			logger.PrintFormatted("[Server] Skipping client %s: %v", clientID, err)
+			skipped = append(skipped, fmt.Errorf("%w: %w", server.ErrClientSkipped, err))
+			continue
+		}
+		flClients = append(flClients, flClient)
+	}
+	_, err = server.Transcipher(logger, common.cfg.Root, flClients, rubatoParams, hheComponents, rubato, verifier)
+	return common.saveMetrics(errors.Join(append(skipped, err)...))
+}
+
+// runServerAggregate averages the ciphertexts saved by `flhhe server transcipher` for the round. Only the
+// clients whose upload of the round the ledger recorded are averaged: the ciphertexts of the others are
+// stale, left by an earlier round.
+func runServerAggregate(args []string) error {
+	fs, common := newFlagSet("server aggregate")
+	clients := fs.String("clients", "", "comma separated client IDs (default: the clients of the experiment)")
+	round := fs.Int("round", 0, "round of the uploads, from 0")
+	metricsAddr := fs.String("metrics-addr", "", "address of the /metrics endpoint, overrides the configuration")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+	clientIDs := common.clientIDs(*clients)
+	if len(clientIDs) == 0 {
+		return errors.New("server aggregate: -clients is empty")
+	}
+	if *round < 0 {
+		return fmt.Errorf("server aggregate: invalid round %d", *round)
+	}
+
+	logger := common.logger().With(utils.LogKeyRole, utils.RoleServer)
+	if err := common.save(); err != nil {
+		return err
+	}
+	stop, err := common.serveMetrics(*metricsAddr)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	keysDir := filepath.Join(common.cfg.Root, configs.Keys)
+	verifier, err := server.LoadVerifier(keysDir, *round, filepath.Join(common.cfg.Root, configs.UploadLedger))
+	if err != nil {
+		return err
+	}
+	var skipped []error
+	transciphered := make([]string, 0, len(clientIDs))
+	for _, clientID := range clientIDs {
+		if !verifier.Recorded(clientID) {
+			logger.PrintFormatted("[Server] Skipping client %s: no upload transciphered in round %d", clientID, *round)
+			skipped = append(skipped, fmt.Errorf("%w %s: no upload transciphered in round %d", server.ErrClientSkipped, clientID, *round))
+			continue
+		}
+		transciphered = append(transciphered, clientID)
+	}
+	if len(transciphered) == 0 {
+		return fmt.Errorf("server aggregate: no client to aggregate: %w", errors.Join(skipped...))
+	}
+
+	rubatoParams, hheComponents, err := loadServer(logger, common)
+	if err != nil {
+		return err
+	}
+	err = server.HEFedAvg(logger, common.cfg.Root, transciphered, rubatoParams, hheComponents)
+	return common.saveMetrics(errors.Join(append(skipped, err)...))
+}
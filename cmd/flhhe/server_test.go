@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"flhhe/configs"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+)
+
+// TestServerAggregate runs two rounds of the protocol with the flhhe commands on the INSECURE toy
+// parameters. In the second round do3 sends its upload of the first one again: the server rejects it
+// as a replay, and the aggregate must not average the ciphertexts do3 left in the first round.
+func TestServerAggregate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the two rounds of the flhhe commands in short mode")
+	}
+	root := t.TempDir()
+	flags := []string{"-root", root, "-toy", "10", "-debug=false"}
+	run := func(command func([]string) error, args ...string) error {
+		return command(append(append([]string{}, flags...), args...))
+	}
+	writeWeights := func(clientID string, value float64) {
+		weights := map[string][][]float64{"fc1": utils.CreateMatrixFloat(12, 64), "fc2": utils.CreateMatrixFloat(10, 16)}
+		for _, layer := range weights {
+			for i := range layer {
+				for j := range layer[i] {
+					layer[i][j] = value
+				}
+			}
+		}
+		data, err := json.Marshal(weights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(root, configs.PlaintextWeights, clientID+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(root, configs.PlaintextWeights), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(runKeygen); err != nil {
+		t.Fatal(err)
+	}
+	clientIDs := []string{"do1", "do2", "do3"}
+	for _, id := range clientIDs {
+		if err := run(runClientRegister, "-client", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Round 0: the three clients upload 0.5, round 1: do1 and do2 upload 0.25
+	for round, value := range []float64{0.5, 0.25} {
+		r := strconv.Itoa(round)
+		for _, id := range clientIDs {
+			if round == 1 && id == "do3" {
+				continue
+			}
+			writeWeights(id, value)
+			if err := run(runClientEncrypt, "-client", id, "-weights", id+".json", "-round", r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := run(runServerTranscipher, "-clients", "do1,do2,do3", "-round", r)
+		if round == 0 && err != nil {
+			t.Fatal(err)
+		}
+		if round == 1 && (!errors.Is(err, server.ErrClientSkipped) || !errors.Is(err, server.ErrReplay)) {
+			t.Fatalf("server transcipher of round 1: got error %v, want the replay of do3 skipped", err)
+		}
+		err = run(runServerAggregate, "-clients", "do1,do2,do3", "-round", r)
+		if round == 0 && err != nil {
+			t.Fatal(err)
+		}
+		if round == 1 && !errors.Is(err, server.ErrClientSkipped) {
+			t.Fatalf("server aggregate of round 1: got error %v, want do3 skipped", err)
+		}
+	}
+
+	// The average of round 1 is the one of do1 and do2 only
+	_, common := newFlagSet("decrypt")
+	if err := common.parse(flags); err != nil {
+		t.Fatal(err)
+	}
+	logger := common.logger()
+	rubatoParams, hheComponents, err := loadServer(logger, common)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := server.LoadOutput(logger, 0, rubatoParams.Packing, filepath.Join(root, configs.HEEncryptedWeights, "avg"), rubatoParams.Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := server.DecryptOutput(rubatoParams.Params, output, hheComponents.CkksDecryptor, hheComponents.CkksEncoder)
+	for i, v := range have[:12*64] {
+		if math.Abs(real(v)-0.25) > 1e-3 {
+			t.Fatalf("avgFC1[%d] = %f, want 0.25 (the average of do1 and do2 in round 1)", i, real(v))
+		}
+	}
+
+	// Without a client transciphered in the round, nothing is aggregated
+	if err = run(runServerAggregate, "-clients", "do3", "-round", "1"); !errors.Is(err, server.ErrClientSkipped) {
+		t.Errorf("server aggregate of round 1 for do3: got error %v, want %v", err, server.ErrClientSkipped)
+	}
+}
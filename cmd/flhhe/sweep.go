@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"flhhe/src/RtF"
+	"flhhe/src/benchmark"
+	"flhhe/src/experiment"
+)
+
+// runSweep benchmarks the HE and HHE schemes for every number of clients, model and parameter set, on
+// synthetic weights, and saves the CSV and JSON reports with the results of the experiment
+func runSweep(args []string) error {
+	fs, common := newFlagSet("sweep")
+	nbClients := fs.String("nclients", "", "comma separated numbers of clients (default: the number of clients of the experiment)")
+	models := fs.String("models", benchmark.MNISTModel.String(), "comma separated models, <fc1 rows>x<fc1 cols>+<fc2 rows>x<fc2 cols>")
+	hhe := fs.String("hhe", "", "comma separated parameter sets of the HHE scheme (RUBATO80S, ..., HERA128, PASTA4), none if \"-\" (default: the one of the experiment)")
+	he := fs.String("he", "", "comma separated CKKS parameter sets of the HE scheme (N12QP109, ..., N16QP421), none if \"-\" (default: the one of the experiment)")
+	toy := fs.Bool("toy", false, "run the HHE scheme on the INSECURE toy parameters (N = 1024, Rubato only), to try the sweep quickly")
+	dir := fs.String("dir", "", "directory of the keys and artifacts of the sweep (default: <root>/bench)")
+	out := fs.String("out", "benchmark", "name of the reports saved in the results directory, <out>.csv and <out>.json")
+	if err := common.parse(args); err != nil {
+		return err
+	}
+
+	packing, err := common.packing()
+	if err != nil {
+		return err
+	}
+	sweep := benchmark.Sweep{Root: *dir, Packing: packing}
+	if sweep.Root == "" {
+		sweep.Root = filepath.Join(common.cfg.Root, "bench")
+	}
+	if *nbClients == "" {
+		sweep.Clients = []int{len(common.cfg.Clients)}
+	}
+	for _, value := range splitList(*nbClients) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("sweep: invalid number of clients %q", value)
+		}
+		sweep.Clients = append(sweep.Clients, n)
+	}
+	for _, value := range splitList(*models) {
+		model, err := benchmark.ParseModel(value)
+		if err != nil {
+			return fmt.Errorf("sweep: %v", err)
+		}
+		sweep.Models = append(sweep.Models, model)
+	}
+	switch *he {
+	case "":
+		sweep.CKKSParams = []string{common.cfg.CKKSParams}
+	case "-":
+	default:
+		sweep.CKKSParams = splitList(*he)
+	}
+	hheParams := splitList(*hhe)
+	switch *hhe {
+	case "":
+		hheParams = []string{map[string]string{
+			experiment.CipherRubato: common.cfg.RubatoParams,
+			experiment.CipherHera:   common.cfg.HeraParams,
+			experiment.CipherPasta:  common.cfg.PastaParams,
+		}[common.cfg.Cipher]}
+	case "-":
+		hheParams = nil
+	}
+	for _, name := range hheParams {
+		cipher, err := hheCipher(name, *toy)
+		if err != nil {
+			return fmt.Errorf("sweep: %v", err)
+		}
+		sweep.Ciphers = append(sweep.Ciphers, cipher)
+	}
+
+	if err = common.save(); err != nil {
+		return err
+	}
+	report, err := benchmark.Run(common.logger(), sweep)
+	if err != nil {
+		return err
+	}
+	csvPath, jsonPath, err := report.Save(filepath.Join(common.cfg.Root, common.cfg.ResultsDir), *out)
+	if err != nil {
+		return err
+	}
+	if err = report.WriteCSV(os.Stdout); err != nil {
+		return err
+	}
+	common.logger().PrintFormatted("Benchmark reports saved to %s and %s", csvPath, jsonPath)
+	return nil
+}
+
+// hheCipher returns the symmetric cipher of a Rubato, HERA or Pasta parameter set (case insensitive),
+// on the toy parameters with toy
+func hheCipher(name string, toy bool) (RtF.SymmetricCipher, error) {
+	for i, p := range RtF.RubatoParams {
+		if strings.EqualFold(p.Name, name) {
+			if toy {
+				return RtF.NewToyRubatoCipher(i, RtF.RtFToyN10), nil
+			}
+			return RtF.NewRubatoCipher(i), nil
+		}
+	}
+	if toy {
+		return nil, fmt.Errorf("no toy parameters for %s, only for Rubato", name)
+	}
+	for i, p := range RtF.HeraParams {
+		if strings.EqualFold(p.Name, name) {
+			return RtF.NewHeraCipher(i), nil
+		}
+	}
+	for i, p := range RtF.PastaParams {
+		if strings.EqualFold(p.Name, name) {
+			return RtF.NewPastaCipher(i), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown parameter set %q", name)
+}
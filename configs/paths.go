@@ -18,8 +18,27 @@ const RelinearizationKeys = "re.bin"
 const SymmetricKey = "symmetric_key.bin"
 const SymmetricKeyCipherDir = "he_encrypted_symmetric_key"
 
+// ClientRegistry the Ed25519 identity keys of the clients registered with the keys dealer, in Keys
+const ClientRegistry = "clients.json"
+
+// ClientIdentityKeys the private identity keys of the clients, one <clientID>_identity.key per client
+const ClientIdentityKeys = "keys/clients"
+
 //const HalfBootKeys = "hbst.bin"
 
 // Ciphertexts the result CKKS ciphertexts after transciphering
 const CtNameFix = "ctx_"
 const CtFormat = ".bin"
+
+// MNIST the processed MNIST datasets (exported test sets for encrypted inference)
+const MNIST = "data/MNIST/processed"
+const MNISTTestSet = "test_all.json"
+
+// InferenceRotationKeys the extra rotation keys needed by the encrypted inference engine
+const InferenceRotationKeys = "inference_rot.bin"
+
+// Diagnostics the encrypted per-client diagnostics released by the server to the key holder
+const Diagnostics = "weights/MNIST/he_encrypted/diagnostics"
+
+// UploadLedger the client uploads the server accepted, to reject their replays
+const UploadLedger = "weights/MNIST/he_encrypted/ledger.json"
@@ -135,7 +135,9 @@ func benchmarkRtFHera(b *testing.B, name string, numRound int, paramIndex int, r
 	// Scheme context and keys
 	kgen = NewKeyGenerator(params)
 
-	sk, pk = kgen.GenKeyPairSparse(hbtpParams.H)
+	if sk, pk, err = kgen.GenKeyPairSparse(hbtpParams.H); err != nil {
+		panic(err)
+	}
 
 	fvEncoder = NewMFVEncoder(params)
 	ckksEncoder = NewCKKSEncoder(params)
@@ -150,8 +152,14 @@ func benchmarkRtFHera(b *testing.B, name string, numRound int, paramIndex int, r
 	if !fullCoeffs {
 		rotations = append(rotations, params.Slots()/2)
 	}
-	rotkeys := kgen.GenRotationKeysForRotations(rotations, true, sk)
-	rlk := kgen.GenRelinearizationKey(sk)
+	rotkeys, err := kgen.GenRotationKeysForRotations(rotations, true, sk)
+	if err != nil {
+		panic(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		panic(err)
+	}
 	hbtpKey := BootstrappingKey{Rlk: rlk, Rtks: rotkeys}
 
 	if hbtp, err = NewHalfBootstrapper(params, hbtpParams, hbtpKey); err != nil {
@@ -254,15 +262,24 @@ func benchmarkRtFHera(b *testing.B, name string, numRound int, paramIndex int, r
 		fvEncoder.FVScaleUp(plainCKKSRingTs[s], plaintexts[s])
 	}
 
-	hera = NewMFVHera(numRound, params, fvEncoder, fvEncryptor, fvEvaluator, heraModDown[0])
-	kCt := hera.EncKey(key)
+	if hera, err = NewMFVHera(numRound, params, fvEncoder, fvEncryptor, fvEvaluator, heraModDown[0]); err != nil {
+		panic(err)
+	}
+	kCt, err := hera.EncKey(key)
+	if err != nil {
+		panic(err)
+	}
 
 	// FV Keystream
 	benchOffLat := fmt.Sprintf("RtF HERA Offline Latency")
 	b.Run(benchOffLat, func(b *testing.B) {
-		fvKeystreams = hera.Crypt(nonces, kCt, heraModDown)
+		if fvKeystreams, err = hera.Crypt(nonces, kCt, heraModDown); err != nil {
+			panic(err)
+		}
 		for i := 0; i < 1; i++ {
-			fvKeystreams[i] = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown)
+			if fvKeystreams[i], err = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown); err != nil {
+				panic(err)
+			}
 			fvEvaluator.ModSwitchMany(fvKeystreams[i], fvKeystreams[i], fvKeystreams[i].Level())
 		}
 	})
@@ -270,7 +287,9 @@ func benchmarkRtFHera(b *testing.B, name string, numRound int, paramIndex int, r
 	benchOffThrput := fmt.Sprintf("RtF HERA Offline Throughput")
 	b.Run(benchOffThrput, func(b *testing.B) {
 		for i := 1; i < 16; i++ {
-			fvKeystreams[i] = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown)
+			if fvKeystreams[i], err = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown); err != nil {
+				panic(err)
+			}
 			fvEvaluator.ModSwitchMany(fvKeystreams[i], fvKeystreams[i], fvKeystreams[i].Level())
 		}
 	})
@@ -292,9 +311,13 @@ func benchmarkRtFHera(b *testing.B, name string, numRound int, paramIndex int, r
 		// CAUTION: the scale of the ciphertext MUST be equal (or very close) to params.Scale
 		// To equalize the scale, the function evaluator.SetScale(ciphertext, parameters.Scale) can be used at the expense of one level.
 		if fullCoeffs {
-			ctBoot, _ = hbtp.HalfBoot(ciphertext, false)
+			if ctBoot, _, err = hbtp.HalfBoot(ciphertext, false); err != nil {
+				panic(err)
+			}
 		} else {
-			ctBoot, _ = hbtp.HalfBoot(ciphertext, true)
+			if ctBoot, _, err = hbtp.HalfBoot(ciphertext, true); err != nil {
+				panic(err)
+			}
 		}
 	})
 	valuesWant := make([]complex128, params.Slots())
@@ -351,7 +374,9 @@ func benchmarkRtFRubato(b *testing.B, rubatoParam int) {
 
 	// Scheme context and keys
 	kgen = NewKeyGenerator(params)
-	sk, pk = kgen.GenKeyPairSparse(hbtpParams.H)
+	if sk, pk, err = kgen.GenKeyPairSparse(hbtpParams.H); err != nil {
+		panic(err)
+	}
 
 	fvEncoder = NewMFVEncoder(params)
 	ckksEncoder = NewCKKSEncoder(params)
@@ -364,8 +389,14 @@ func benchmarkRtFRubato(b *testing.B, rubatoParam int) {
 	rotationsStC := kgen.GenRotationIndexesForSlotsToCoeffsMat(pDcds)
 	rotations := append(rotationsHalfBoot, rotationsStC...)
 	// needed to be saved
-	rotkeys := kgen.GenRotationKeysForRotations(rotations, true, sk)
-	rlk := kgen.GenRelinearizationKey(sk)
+	rotkeys, err := kgen.GenRotationKeysForRotations(rotations, true, sk)
+	if err != nil {
+		panic(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		panic(err)
+	}
 	hbtpKey := BootstrappingKey{Rlk: rlk, Rtks: rotkeys}
 
 	if hbtp, err = NewHalfBootstrapper(params, hbtpParams, hbtpKey); err != nil {
@@ -405,7 +436,9 @@ func benchmarkRtFRubato(b *testing.B, rubatoParam int) {
 	// Get keystream
 	keystream = make([][]uint64, params.N())
 	for i := 0; i < params.N(); i++ {
-		keystream[i] = PlainRubato(blocksize, numRound, nonces[i], counter, key, plainModulus, sigma)
+		if keystream[i], err = PlainRubato(blocksize, numRound, nonces[i], counter, key, plainModulus, sigma); err != nil {
+			panic(err)
+		}
 	}
 
 	for s := 0; s < outputsize; s++ {
@@ -435,14 +468,23 @@ func benchmarkRtFRubato(b *testing.B, rubatoParam int) {
 	}
 
 	// FV Keystream
-	rubato = NewMFVRubato(rubatoParam, params, fvEncoder, fvEncryptor, fvEvaluator, rubatoModDown[0])
-	kCt := rubato.EncKey(key)
+	if rubato, err = NewMFVRubato(rubatoParam, params, fvEncoder, fvEncryptor, fvEvaluator, rubatoModDown[0]); err != nil {
+		panic(err)
+	}
+	kCt, err := rubato.EncKey(key)
+	if err != nil {
+		panic(err)
+	}
 
 	benchOffLat := fmt.Sprintf("RtF Rubato Offline Latency")
 	b.Run(benchOffLat, func(b *testing.B) {
-		fvKeystreams = rubato.Crypt(nonces, counter, kCt, rubatoModDown)
+		if fvKeystreams, err = rubato.Crypt(nonces, counter, kCt, rubatoModDown); err != nil {
+			panic(err)
+		}
 		for i := 0; i < 1; i++ {
-			fvKeystreams[i] = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown)
+			if fvKeystreams[i], err = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown); err != nil {
+				panic(err)
+			}
 			fvEvaluator.ModSwitchMany(fvKeystreams[i], fvKeystreams[i], fvKeystreams[i].Level())
 		}
 	})
@@ -450,7 +492,9 @@ func benchmarkRtFRubato(b *testing.B, rubatoParam int) {
 	benchOffThrput := fmt.Sprintf("RtF Rubato Offline Throughput")
 	b.Run(benchOffThrput, func(b *testing.B) {
 		for i := 1; i < outputsize; i++ {
-			fvKeystreams[i] = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown)
+			if fvKeystreams[i], err = fvEvaluator.SlotsToCoeffs(fvKeystreams[i], stcModDown); err != nil {
+				panic(err)
+			}
 			fvEvaluator.ModSwitchMany(fvKeystreams[i], fvKeystreams[i], fvKeystreams[i].Level())
 		}
 	})
@@ -471,7 +515,9 @@ func benchmarkRtFRubato(b *testing.B, rubatoParam int) {
 		// Difference from the bootstrapping is that the last StC is missing.
 		// CAUTION: the scale of the ciphertext MUST be equal (or very close) to params.Scale
 		// To equalize the scale, the function evaluator.SetScale(ciphertext, parameters.Scale) can be used at the expense of one level.
-		ctBoot, _ = hbtp.HalfBoot(ciphertext, false)
+		if ctBoot, _, err = hbtp.HalfBoot(ciphertext, false); err != nil {
+			panic(err)
+		}
 	})
 	valuesWant := make([]complex128, params.Slots())
 	for i := 0; i < params.Slots(); i++ {
@@ -493,7 +539,10 @@ func printDebug(params *Parameters, ciphertext *Ciphertext, valuesWant []complex
 	fmt.Printf("ValuesTest: %6.10f %6.10f %6.10f %6.10f...\n", valuesTest[0], valuesTest[1], valuesTest[2], valuesTest[3])
 	fmt.Printf("ValuesWant: %6.10f %6.10f %6.10f %6.10f...\n", valuesWant[0], valuesWant[1], valuesWant[2], valuesWant[3])
 
-	precStats := GetPrecisionStats(params, encoder, nil, valuesWant, valuesTest, logSlots, sigma)
+	precStats, err := GetPrecisionStats(params, encoder, nil, valuesWant, valuesTest, logSlots, sigma)
+	if err != nil {
+		panic(err)
+	}
 
 	fmt.Println(precStats.String())
 }
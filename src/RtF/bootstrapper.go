@@ -113,7 +113,7 @@ func (btp *Bootstrapper) CheckKeys() (err error) {
 	}
 
 	if len(rotMissing) != 0 {
-		return fmt.Errorf("rotation key(s) missing: %d", rotMissing)
+		return fmt.Errorf("%w: rotation key(s) missing: %d", ErrParamMismatch, rotMissing)
 	}
 
 	return nil
@@ -10,6 +10,18 @@ type Ciphertext struct {
 	*Element
 }
 
+// NoiseEstimate returns the noise predicted by the MFVNoiseModel of the evaluator which computed the
+// ciphertext, ok is false if it wasn't computed with a noise model
+func (ct *Ciphertext) NoiseEstimate() (noise NoiseEstimate, ok bool) {
+	return ct.noise, ct.hasNoise
+}
+
+// SetNoiseEstimate attaches the bounds on its noise to the ciphertext (e.g. after a deserialization), see
+// MFVNoiseModel
+func (ct *Ciphertext) SetNoiseEstimate(noise NoiseEstimate) {
+	ct.noise, ct.hasNoise = noise, true
+}
+
 // NewCiphertextFV creates a new FV ciphertext parameterized by degree, level and scale.
 func NewCiphertextFV(params *Parameters, degree int) (ciphertext *Ciphertext) {
 	return &Ciphertext{newCiphertextElement(params, degree)}
@@ -26,10 +38,12 @@ func NewCiphertextFVLvl(params *Parameters, degree int, level int) (ciphertext *
 }
 
 // NewCiphertextFVRandom generates a new uniformly distributed FV ciphertext of degree, level and scale.
-func NewCiphertextFVRandom(prng sampling.PRNG, params *Parameters, degree int) (ciphertext *Ciphertext) {
+func NewCiphertextFVRandom(prng sampling.PRNG, params *Parameters, degree int) (ciphertext *Ciphertext, err error) {
 	ciphertext = &Ciphertext{newCiphertextElement(params, degree)}
-	populateElementRandom(prng, params, ciphertext.Element)
-	return
+	if err = populateElementRandom(prng, params, ciphertext.Element); err != nil {
+		return nil, err
+	}
+	return ciphertext, nil
 }
 
 // NewCiphertextCKKS creates a new CKKS Ciphertext parameterized by degree, level and scale.
@@ -49,7 +63,7 @@ func NewCiphertextCKKS(params *Parameters, degree, level int, scale float64) (ci
 }
 
 // NewCiphertextCKKSRandom generates a new uniformly distributed Ciphertext of degree, level and scale.
-func NewCiphertextCKKSRandom(prng sampling.PRNG, params *Parameters, degree, level int, scale float64) (ciphertext *Ciphertext) {
+func NewCiphertextCKKSRandom(prng sampling.PRNG, params *Parameters, degree, level int, scale float64) (ciphertext *Ciphertext, err error) {
 
 	ringQ, err := ring.NewRing(params.N(), params.qi[:level+1])
 	if err != nil {
@@ -59,8 +73,10 @@ func NewCiphertextCKKSRandom(prng sampling.PRNG, params *Parameters, degree, lev
 	sampler := ring.NewUniformSampler(prng, ringQ)
 	ciphertext = NewCiphertextCKKS(params, degree, level, scale)
 	for i := 0; i < degree+1; i++ {
-		sampler.Read(ciphertext.value[i])
+		if err = sampler.Read(ciphertext.value[i]); err != nil {
+			return nil, err
+		}
 	}
 
-	return ciphertext
+	return ciphertext, nil
 }
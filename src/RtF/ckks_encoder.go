@@ -7,8 +7,6 @@ import (
 	"math"
 	"math/big"
 
-	"github.com/tuneinsight/lattigo/v6/utils/sampling"
-
 	"flhhe/src/RtF/ring"
 )
 
@@ -34,7 +32,7 @@ type CKKSEncoder interface {
 	EncodeComplexRingTNew(values []complex128, logSlots int) (ptRt *PlaintextRingT)
 
 	DecodeComplex(plaintext *Plaintext, logSlots int) (res []complex128)
-	DecodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) []complex128
+	DecodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) ([]complex128, error)
 
 	EmbedComplex(values []complex128, logSlots int)
 	CKKSScaleUp(pol *ring.Poly, scale float64, moduli []uint64)
@@ -43,7 +41,7 @@ type CKKSEncoder interface {
 
 	EncodeCoeffs(values []float64, plaintext *Plaintext)
 	DecodeCoeffs(plaintext *Plaintext) (res []float64)
-	DecodeCoeffsPublic(plaintext *Plaintext, bound float64) (res []float64)
+	DecodeCoeffsPublic(plaintext *Plaintext, bound float64) (res []float64, err error)
 
 	EncodeCoeffsRingT(values []float64, ptRt *PlaintextRingT)
 	EncodeCoeffsRingTNew(values []float64, scale float64) (ptRt *PlaintextRingT)
@@ -126,12 +124,7 @@ func newCKKSEncoder(params *Parameters) ckksEncoder {
 		fivePows &= m - 1
 	}
 
-	prng, err := sampling.NewPRNG()
-	if err != nil {
-		panic(err)
-	}
-
-	gaussianSampler := ring.NewGaussianSampler(prng)
+	gaussianSampler := ring.NewGaussianSampler(newPRNG())
 
 	return ckksEncoder{
 		params:          params.Copy(),
@@ -301,13 +294,14 @@ func (encoder *encoderComplex128) WipeInternalMemory() {
 
 // DecodeComplexPublic decodes the Plaintext values to a slice of complex128 values of size at most N/2.
 // Rounds the decimal part of the output (the bits under the scale) to "logPrecision" bits of precision.
-func (encoder *encoderComplex128) DecodeComplexPublic(plaintext *Plaintext, logSlots int, bound float64) (res []complex128) {
+func (encoder *encoderComplex128) DecodeComplexPublic(plaintext *Plaintext, logSlots int, bound float64) (res []complex128, err error) {
 	return encoder.decodeComplexPublic(plaintext, logSlots, bound)
 }
 
 // DecodeComplex decodes the Plaintext values to a slice of complex128 values of size at most N/2.
 func (encoder *encoderComplex128) DecodeComplex(plaintext *Plaintext, logSlots int) (res []complex128) {
-	return encoder.decodeComplexPublic(plaintext, logSlots, 0)
+	res, _ = encoder.decodeComplexPublic(plaintext, logSlots, 0) // no noise, nothing is read from the PRNG
+	return res
 }
 
 func polyToComplexNoCRT(coeffs []uint64, values []complex128, scale float64, logSlots int, Q uint64) {
@@ -591,7 +585,7 @@ func findbestbabygiantstepsplit(diagMatrix interface{}, maxN int, maxRatio float
 	return 1
 }
 
-func (encoder *encoderComplex128) decodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) (res []complex128) {
+func (encoder *encoderComplex128) decodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) (res []complex128, err error) {
 
 	if logSlots > encoder.params.LogN()-1 {
 		panic("cannot Decode: too many slots for the given ring degree")
@@ -605,8 +599,12 @@ func (encoder *encoderComplex128) decodeComplexPublic(plaintext *Plaintext, logS
 		encoder.ringQ.CopyLvl(plaintext.Level(), plaintext.value, encoder.polypool)
 	}
 
-	// B = floor(sigma * sqrt(2*pi))
-	encoder.gaussianSampler.ReadAndAddLvl(plaintext.Level(), encoder.polypool, encoder.ringQ, sigma, int(2.5066282746310002*sigma))
+	if sigma != 0 {
+		// B = floor(sigma * sqrt(2*pi))
+		if err = encoder.gaussianSampler.ReadAndAddLvl(plaintext.Level(), encoder.polypool, encoder.ringQ, sigma, int(2.5066282746310002*sigma)); err != nil {
+			return nil, err
+		}
+	}
 
 	encoder.plaintextToComplex(plaintext.Level(), plaintext.Scale(), logSlots, encoder.polypool, encoder.values)
 
@@ -622,7 +620,7 @@ func (encoder *encoderComplex128) decodeComplexPublic(plaintext *Plaintext, logS
 		encoder.values[i] = 0
 	}
 
-	return
+	return res, nil
 }
 
 func invfft(values []complex128, N, M int, rotGroup []int, roots []complex128) {
@@ -722,16 +720,17 @@ func (encoder *encoderComplex128) EncodeCoeffsRingTNew(values []float64, scale f
 
 // DecodeCoeffsPublic takes as input a plaintext and returns the scaled down coefficient of the plaintext in float64.
 // Rounds the decimal part of the output (the bits under the scale) to "logPrecision" bits of precision.
-func (encoder *encoderComplex128) DecodeCoeffsPublic(plaintext *Plaintext, sigma float64) (res []float64) {
+func (encoder *encoderComplex128) DecodeCoeffsPublic(plaintext *Plaintext, sigma float64) (res []float64, err error) {
 	return encoder.decodeCoeffsPublic(plaintext, sigma)
 }
 
 func (encoder *encoderComplex128) DecodeCoeffs(plaintext *Plaintext) (res []float64) {
-	return encoder.decodeCoeffsPublic(plaintext, 0)
+	res, _ = encoder.decodeCoeffsPublic(plaintext, 0) // no noise, nothing is read from the PRNG
+	return res
 }
 
 // DecodeCoeffs takes as input a plaintext and returns the scaled down coefficient of the plaintext in float64.
-func (encoder *encoderComplex128) decodeCoeffsPublic(plaintext *Plaintext, sigma float64) (res []float64) {
+func (encoder *encoderComplex128) decodeCoeffsPublic(plaintext *Plaintext, sigma float64) (res []float64, err error) {
 
 	if plaintext.isNTT {
 		encoder.ringQ.InvNTTLvl(plaintext.Level(), plaintext.value, encoder.polypool)
@@ -741,7 +740,9 @@ func (encoder *encoderComplex128) decodeCoeffsPublic(plaintext *Plaintext, sigma
 
 	if sigma != 0 {
 		// B = floor(sigma * sqrt(2*pi))
-		encoder.gaussianSampler.ReadAndAddLvl(plaintext.Level(), encoder.polypool, encoder.ringQ, sigma, int(2.5066282746310002*sigma))
+		if err = encoder.gaussianSampler.ReadAndAddLvl(plaintext.Level(), encoder.polypool, encoder.ringQ, sigma, int(2.5066282746310002*sigma)); err != nil {
+			return nil, err
+		}
 	}
 
 	res = make([]float64, encoder.params.N())
@@ -788,7 +789,7 @@ func (encoder *encoderComplex128) decodeCoeffsPublic(plaintext *Plaintext, sigma
 		}
 	}
 
-	return
+	return res, nil
 }
 
 type encoderBigComplex struct {
@@ -931,16 +932,17 @@ func (encoder *encoderBigComplex) EncodeComplex(plaintext *Plaintext, values []*
 
 // DecodeComplexPublic decodes the Plaintext values to a slice of complex128 values of size at most N/2.
 // Rounds the decimal part of the output (the bits under the scale) to "logPrecision" bits of precision.
-func (encoder *encoderBigComplex) DecodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) (res []*ring.Complex) {
+func (encoder *encoderBigComplex) DecodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) (res []*ring.Complex, err error) {
 	return encoder.decodeComplexPublic(plaintext, logSlots, sigma)
 }
 
 func (encoder *encoderBigComplex) DecodeComplex(plaintext *Plaintext, logSlots int) (res []*ring.Complex) {
-	return encoder.decodeComplexPublic(plaintext, logSlots, 0)
+	res, _ = encoder.decodeComplexPublic(plaintext, logSlots, 0) // no noise, nothing is read from the PRNG
+	return res
 }
 
 // Decode decodes the Plaintext values to a slice of complex128 values of size at most N/2.
-func (encoder *encoderBigComplex) decodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) (res []*ring.Complex) {
+func (encoder *encoderBigComplex) decodeComplexPublic(plaintext *Plaintext, logSlots int, sigma float64) (res []*ring.Complex, err error) {
 
 	slots := 1 << logSlots
 
@@ -952,7 +954,9 @@ func (encoder *encoderBigComplex) decodeComplexPublic(plaintext *Plaintext, logS
 
 	if sigma != 0 {
 		// B = floor(sigma * sqrt(2*pi))
-		encoder.gaussianSampler.ReadAndAddLvl(plaintext.Level(), encoder.polypool, encoder.ringQ, sigma, int(2.5066282746310002*sigma+0.5))
+		if err = encoder.gaussianSampler.ReadAndAddLvl(plaintext.Level(), encoder.polypool, encoder.ringQ, sigma, int(2.5066282746310002*sigma+0.5)); err != nil {
+			return nil, err
+		}
 	}
 
 	encoder.ringQ.PolyToBigint(encoder.polypool, encoder.bigintCoeffs)
@@ -1006,7 +1010,7 @@ func (encoder *encoderBigComplex) decodeComplexPublic(plaintext *Plaintext, logS
 		encoder.values[i].Imag().Set(encoder.zero)
 	}
 
-	return
+	return res, nil
 }
 
 // InvFFT evaluates the encoding matrix on a slice fo ring.Complex values.
@@ -16,39 +16,39 @@ type CKKSEncryptor interface {
 	// the result on a newly created ciphertext. The encryption is done by first
 	// encrypting zero in QP, dividing by P and then adding the plaintext.
 	// The level of the output ciphertext is plaintext.Level().
-	EncryptNew(plaintext *Plaintext) *Ciphertext
+	EncryptNew(plaintext *Plaintext) (*Ciphertext, error)
 
 	// Encrypt encrypts the input plaintext using the stored key, and returns
 	// the result on the receiver ciphertext. The encryption is done by first
 	// encrypting zero in QP, dividing by P and then adding the plaintext.
 	// The level of the output ciphertext is min(plaintext.Level(), ciphertext.Level()).
-	Encrypt(plaintext *Plaintext, ciphertext *Ciphertext)
+	Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) error
 
 	// EncryptFastNew encrypts the input plaintext using the stored key and returns
 	// the result on a newly created ciphertext. The encryption is done by first
 	// encrypting zero in Q and then adding the plaintext.
 	// The level of the output ciphertext is plaintext.Level().
-	EncryptFastNew(plaintext *Plaintext) *Ciphertext
+	EncryptFastNew(plaintext *Plaintext) (*Ciphertext, error)
 
 	// EncryptFast encrypts the input plaintext using the stored-key, and returns
 	// the result on the receiver ciphertext. The encryption is done by first
 	// encrypting zero in Q and then adding the plaintext.
 	// The level of the output ciphertext is min(plaintext.Level(), ciphertext.Level()).
-	EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext)
+	EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) error
 
 	// EncryptFromCRPNew encrypts the input plaintext using the stored key and returns
 	// the result on a newly created ciphertext. The encryption is done by first encrypting
 	// zero in QP, using the provided polynomial as the uniform polynomial, dividing by P and
 	// then adding the plaintext.
 	// The level of the output ciphertext is min(plaintext.Level(), len(CRP.Coeffs)-1).
-	EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext
+	EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error)
 
 	// EncryptFromCRP encrypts the input plaintext using the stored key and returns
 	// the result tge receiver ciphertext. The encryption is done by first encrypting
 	// zero in QP, using the provided polynomial as the uniform polynomial, dividing by P and
 	// then adding the plaintext.
 	// The level of the output ciphertext is min(plaintext.Level(), ciphertext.Level(), len(CRP.Coeffs)-1).
-	EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly)
+	EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error
 }
 
 // encryptor is a struct used to encrypt Plaintexts. It stores the public-key and/or secret-key.
@@ -80,7 +80,13 @@ type skCKKSEncryptor struct {
 // NewCKKSEncryptorFromPk creates a new Encryptor with the provided public-key.
 // This Encryptor can be used to encrypt Plaintexts, using the stored key.
 func NewCKKSEncryptorFromPk(params *Parameters, pk *PublicKey) CKKSEncryptor {
-	enc := newCKKSEncryptor(params)
+	return NewCKKSEncryptorFromPkWithPRNG(params, pk, newPRNG())
+}
+
+// NewCKKSEncryptorFromPkWithPRNG creates a new Encryptor with the provided public-key, drawing the
+// randomness of the encryptions from prng.
+func NewCKKSEncryptorFromPkWithPRNG(params *Parameters, pk *PublicKey, prng sampling.PRNG) CKKSEncryptor {
+	enc := newCKKSEncryptor(params, prng)
 
 	if pk.Value[0].Degree() != params.N() || pk.Value[1].Degree() != params.N() {
 		panic("cannot newEncryptor: pk ring degree does not match params ring degree")
@@ -92,7 +98,13 @@ func NewCKKSEncryptorFromPk(params *Parameters, pk *PublicKey) CKKSEncryptor {
 // NewCKKSEncryptorFromSk creates a new Encryptor with the provided secret-key.
 // This Encryptor can be used to encrypt Plaintexts, using the stored key.
 func NewCKKSEncryptorFromSk(params *Parameters, sk *SecretKey) CKKSEncryptor {
-	enc := newCKKSEncryptor(params)
+	return NewCKKSEncryptorFromSkWithPRNG(params, sk, newPRNG())
+}
+
+// NewCKKSEncryptorFromSkWithPRNG creates a new Encryptor with the provided secret-key, drawing the
+// randomness of the encryptions from prng.
+func NewCKKSEncryptorFromSkWithPRNG(params *Parameters, sk *SecretKey, prng sampling.PRNG) CKKSEncryptor {
+	enc := newCKKSEncryptor(params, prng)
 
 	if sk.Value.Degree() != params.N() {
 		panic("cannot newEncryptor: sk ring degree does not match params ring degree")
@@ -101,7 +113,7 @@ func NewCKKSEncryptorFromSk(params *Parameters, sk *SecretKey) CKKSEncryptor {
 	return &skCKKSEncryptor{enc, sk}
 }
 
-func newCKKSEncryptor(params *Parameters) ckksEncryptor {
+func newCKKSEncryptor(params *Parameters, prng sampling.PRNG) ckksEncryptor {
 
 	var q, p *ring.Ring
 	var err error
@@ -109,11 +121,6 @@ func newCKKSEncryptor(params *Parameters) ckksEncryptor {
 		panic(err)
 	}
 
-	prng, err := sampling.NewPRNG()
-	if err != nil {
-		panic(err)
-	}
-
 	var baseconverter *ring.FastBasisExtender
 	var poolP [3]*ring.Poly
 	if params.PiCount() != 0 {
@@ -145,43 +152,47 @@ func newCKKSEncryptor(params *Parameters) ckksEncryptor {
 //
 // encrypt with pk: ciphertext = [pk[0]*u + m + e_0, pk[1]*u + e_1]
 // encrypt with sk: ciphertext = [-a*sk + m + e, a]
-func (encryptor *pkCKKSEncryptor) EncryptNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *pkCKKSEncryptor) EncryptNew(plaintext *Plaintext) (*Ciphertext, error) {
 
 	if encryptor.baseconverter == nil {
 		panic("Cannot EncryptNew : modulus P is empty -> use instead EncryptFastNew")
 	}
 
 	ciphertext := NewCiphertextCKKS(encryptor.params, 1, plaintext.Level(), plaintext.Scale())
-	encryptor.encrypt(plaintext, ciphertext, false)
+	if err := encryptor.encrypt(plaintext, ciphertext, false); err != nil {
+		return nil, err
+	}
 
-	return ciphertext
+	return ciphertext, nil
 }
 
-func (encryptor *pkCKKSEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) {
+func (encryptor *pkCKKSEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) error {
 
 	if encryptor.baseconverter == nil {
 		panic("Cannot Encrypt : modulus P is empty -> use instead EncryptFast")
 	}
 
-	encryptor.encrypt(plaintext, ciphertext, false)
+	return encryptor.encrypt(plaintext, ciphertext, false)
 }
 
-func (encryptor *pkCKKSEncryptor) EncryptFastNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *pkCKKSEncryptor) EncryptFastNew(plaintext *Plaintext) (*Ciphertext, error) {
 	ciphertext := NewCiphertextCKKS(encryptor.params, 1, plaintext.Level(), plaintext.Scale())
-	encryptor.encrypt(plaintext, ciphertext, true)
+	if err := encryptor.encrypt(plaintext, ciphertext, true); err != nil {
+		return nil, err
+	}
 
-	return ciphertext
+	return ciphertext, nil
 }
 
-func (encryptor *pkCKKSEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) {
-	encryptor.encrypt(plaintext, ciphertext, true)
+func (encryptor *pkCKKSEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) error {
+	return encryptor.encrypt(plaintext, ciphertext, true)
 }
 
-func (encryptor *pkCKKSEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *pkCKKSEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 	panic("Cannot encrypt with CRP using an encryptor created with the public-key")
 }
 
-func (encryptor *pkCKKSEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext {
+func (encryptor *pkCKKSEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error) {
 	panic("Cannot encrypt with CRP using an encryptor created with the public-key")
 }
 
@@ -190,7 +201,7 @@ func (encryptor *pkCKKSEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *r
 //
 // encrypt with pk: ciphertext = [pk[0]*u + m + e_0, pk[1]*u + e_1]
 // encrypt with sk: ciphertext = [-a*sk + m + e, a]
-func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciphertext, fast bool) {
+func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciphertext, fast bool) error {
 
 	lvl := utils.MinInt(plaintext.Level(), ciphertext.Level())
 
@@ -207,7 +218,9 @@ func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 
 	if fast {
 
-		encryptor.ternarySampler.ReadLvl(lvl, poolQ2)
+		if err := encryptor.ternarySampler.ReadLvl(lvl, poolQ2); err != nil {
+			return err
+		}
 		ringQ.NTTLvl(lvl, poolQ2, poolQ2)
 		ringQ.MFormLvl(lvl, poolQ2, poolQ2)
 
@@ -217,14 +230,18 @@ func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 		ringQ.MulCoeffsMontgomeryLvl(lvl, poolQ2, encryptor.pk.Value[1], ciphertext.value[1])
 
 		// ct1 = u*pk1 + e1
-		encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+		if err := encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+			return err
+		}
 		ringQ.NTTLvl(lvl, poolQ0, poolQ0)
 		ringQ.AddLvl(lvl, ciphertext.value[1], poolQ0, ciphertext.value[1])
 
 		if !plaintext.isNTT {
 
 			// ct0 = u*pk0 + e0
-			encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+			if err := encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+				return err
+			}
 			// ct0 = (u*pk0 + e0)/P + m
 			ringQ.AddLvl(lvl, poolQ0, plaintext.value, poolQ0)
 			ringQ.NTTLvl(lvl, poolQ0, poolQ0)
@@ -232,7 +249,9 @@ func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 
 		} else {
 			// ct0 = u*pk0 + e0
-			encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+			if err := encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+				return err
+			}
 			ringQ.NTTLvl(lvl, poolQ0, poolQ0)
 			ringQ.AddLvl(lvl, ciphertext.value[0], poolQ0, ciphertext.value[0])
 			ringQ.AddLvl(lvl, ciphertext.value[0], plaintext.value, ciphertext.value[0])
@@ -242,7 +261,9 @@ func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 
 		ringP := encryptor.ringP
 
-		encryptor.ternarySampler.ReadLvl(lvl, poolQ2)
+		if err := encryptor.ternarySampler.ReadLvl(lvl, poolQ2); err != nil {
+			return err
+		}
 
 		extendBasisSmallNormAndCenter(ringQ, ringP, poolQ2, poolP2)
 
@@ -272,13 +293,17 @@ func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 		ringP.InvNTT(poolP1, poolP1)
 
 		// ct0 = u*pk0 + e0
-		encryptor.gaussianSampler.ReadLvl(lvl, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+		if err := encryptor.gaussianSampler.ReadLvl(lvl, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+			return err
+		}
 		extendBasisSmallNormAndCenter(ringQ, ringP, poolQ2, poolP2)
 		ringQ.AddLvl(lvl, poolQ0, poolQ2, poolQ0)
 		ringP.Add(poolP0, poolP2, poolP0)
 
 		// ct1 = u*pk1 + e1
-		encryptor.gaussianSampler.ReadLvl(lvl, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+		if err := encryptor.gaussianSampler.ReadLvl(lvl, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+			return err
+		}
 		extendBasisSmallNormAndCenter(ringQ, ringP, poolQ2, poolP2)
 		ringQ.AddLvl(lvl, poolQ1, poolQ2, poolQ1)
 		ringP.Add(poolP1, poolP2, poolP1)
@@ -307,45 +332,52 @@ func (encryptor *pkCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 	ciphertext.value[1].Coeffs = ciphertext.value[1].Coeffs[:lvl+1]
 
 	ciphertext.isNTT = true
+	return nil
 }
 
-func (encryptor *skCKKSEncryptor) EncryptNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *skCKKSEncryptor) EncryptNew(plaintext *Plaintext) (*Ciphertext, error) {
 	ciphertext := NewCiphertextCKKS(encryptor.params, 1, plaintext.Level(), plaintext.Scale())
-	encryptor.Encrypt(plaintext, ciphertext)
-	return ciphertext
+	if err := encryptor.Encrypt(plaintext, ciphertext); err != nil {
+		return nil, err
+	}
+	return ciphertext, nil
 }
 
-func (encryptor *skCKKSEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) {
-	encryptor.encryptSample(plaintext, ciphertext)
+func (encryptor *skCKKSEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) error {
+	return encryptor.encryptSample(plaintext, ciphertext)
 }
 
-func (encryptor *skCKKSEncryptor) EncryptFastNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *skCKKSEncryptor) EncryptFastNew(plaintext *Plaintext) (*Ciphertext, error) {
 	panic("Cannot Encrypt : SkEncryptor doesn't support EncryptFastNew() -> use instead EncryptNew()")
 }
 
-func (encryptor *skCKKSEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) {
+func (encryptor *skCKKSEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) error {
 	panic("Cannot Encrypt : SkEncryptor doesn't support EncryptFast() -> use instead Encrypt()")
 }
 
-func (encryptor *skCKKSEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext {
+func (encryptor *skCKKSEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error) {
 	ciphertext := NewCiphertextCKKS(encryptor.params, 1, plaintext.Level(), plaintext.Scale())
-	encryptor.EncryptFromCRP(plaintext, ciphertext, crp)
-	return ciphertext
+	if err := encryptor.EncryptFromCRP(plaintext, ciphertext, crp); err != nil {
+		return nil, err
+	}
+	return ciphertext, nil
 }
 
-func (encryptor *skCKKSEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *skCKKSEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 	encryptor.ringQ.Copy(crp, ciphertext.value[1])
 	ciphertext.value[0].Coeffs = ciphertext.value[0].Coeffs[:len(crp.Coeffs)]
 	ciphertext.value[1].Coeffs = ciphertext.value[1].Coeffs[:len(crp.Coeffs)]
-	encryptor.encrypt(plaintext, ciphertext, ciphertext.value[1])
+	return encryptor.encrypt(plaintext, ciphertext, ciphertext.value[1])
 }
 
-func (encryptor *skCKKSEncryptor) encryptSample(plaintext *Plaintext, ciphertext *Ciphertext) {
-	encryptor.uniformSampler.Readlvl(utils.MinInt(plaintext.Level(), ciphertext.Level()), ciphertext.value[1])
-	encryptor.encrypt(plaintext, ciphertext, ciphertext.value[1])
+func (encryptor *skCKKSEncryptor) encryptSample(plaintext *Plaintext, ciphertext *Ciphertext) error {
+	if err := encryptor.uniformSampler.Readlvl(utils.MinInt(plaintext.Level(), ciphertext.Level()), ciphertext.value[1]); err != nil {
+		return err
+	}
+	return encryptor.encrypt(plaintext, ciphertext, ciphertext.value[1])
 }
 
-func (encryptor *skCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *skCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 
 	ringQ := encryptor.ringQ
 
@@ -357,12 +389,16 @@ func (encryptor *skCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 	ringQ.NegLvl(lvl, ciphertext.value[0], ciphertext.value[0])
 
 	if plaintext.isNTT {
-		encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+		if err := encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+			return err
+		}
 		ringQ.NTTLvl(lvl, poolQ0, poolQ0)
 		ringQ.AddLvl(lvl, ciphertext.value[0], poolQ0, ciphertext.value[0])
 		ringQ.AddLvl(lvl, ciphertext.value[0], plaintext.value, ciphertext.value[0])
 	} else {
-		encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+		if err := encryptor.gaussianSampler.ReadLvl(lvl, poolQ0, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+			return err
+		}
 		ringQ.AddLvl(lvl, poolQ0, plaintext.value, poolQ0)
 		ringQ.NTTLvl(lvl, poolQ0, poolQ0)
 		ringQ.AddLvl(lvl, ciphertext.value[0], poolQ0, ciphertext.value[0])
@@ -372,6 +408,7 @@ func (encryptor *skCKKSEncryptor) encrypt(plaintext *Plaintext, ciphertext *Ciph
 	ciphertext.value[1].Coeffs = ciphertext.value[1].Coeffs[:lvl+1]
 
 	ciphertext.isNTT = true
+	return nil
 }
 
 func extendBasisSmallNormAndCenter(ringQ, ringP *ring.Ring, polQ, polP *ring.Poly) {
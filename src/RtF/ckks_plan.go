@@ -0,0 +1,205 @@
+package RtF
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CKKSOpKind the kind of a CKKSOp
+type CKKSOpKind int
+
+const (
+	// CKKSAdd sums Count ciphertexts in the same state
+	CKKSAdd CKKSOpKind = iota
+	// CKKSMultByConst multiplies by Constant as CKKSEvaluator.MultByConst: the scale is multiplied by the
+	// current modulus if the constant isn't an integer, the level is kept
+	CKKSMultByConst
+	// CKKSMulRelin multiplies by a ciphertext in the same state and relinearizes, the scales multiply
+	CKKSMulRelin
+	// CKKSRotate rotates the slots
+	CKKSRotate
+	// CKKSRescale rescales as CKKSEvaluator.Rescale with the default scale as minimum scale
+	CKKSRescale
+)
+
+var ckksOpNames = []string{"Add", "MultByConst", "MulRelin", "Rotate", "Rescale"}
+
+func (kind CKKSOpKind) String() string {
+	if kind < 0 || int(kind) >= len(ckksOpNames) {
+		return fmt.Sprintf("CKKSOpKind(%d)", int(kind))
+	}
+	return ckksOpNames[kind]
+}
+
+// CKKSOp an operation intended on the CKKS ciphertexts
+type CKKSOp struct {
+	Kind     CKKSOpKind
+	Count    int     // number of summed ciphertexts (CKKSAdd)
+	Constant float64 // CKKSMultByConst
+}
+
+func (op CKKSOp) String() string {
+	switch op.Kind {
+	case CKKSAdd:
+		return fmt.Sprintf("Add(%d)", op.Count)
+	case CKKSMultByConst:
+		return fmt.Sprintf("MultByConst(%.4g)", op.Constant)
+	}
+	return op.Kind.String()
+}
+
+// CKKSState the predicted state of a CKKS ciphertext
+type CKKSState struct {
+	Level     int
+	LogScale  float64
+	LogBound  float64 // log2 of the largest magnitude of the values
+	Precision float64 // log2 of 1/(standard deviation of the error), close to the mean precision of GetPrecisionStats
+}
+
+// CKKSStep the state after an operation of a CKKSPlan
+type CKKSStep struct {
+	Op CKKSOp
+	CKKSState
+}
+
+// CKKSPlan the predicted level consumption, scale and precision of a sequence of CKKS operations
+type CKKSPlan struct {
+	Initial CKKSState
+	Steps   []CKKSStep
+}
+
+// Final returns the state after the last operation
+func (plan *CKKSPlan) Final() CKKSState {
+	if len(plan.Steps) == 0 {
+		return plan.Initial
+	}
+	return plan.Steps[len(plan.Steps)-1].CKKSState
+}
+
+// LevelsLeft returns the number of rescales left after the plan
+func (plan *CKKSPlan) LevelsLeft() int {
+	return plan.Final().Level
+}
+
+func (plan *CKKSPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-20s %5s %9s %9s %9s\n", "operation", "level", "log scale", "log bound", "precision")
+	row := func(name string, s CKKSState) {
+		fmt.Fprintf(&b, "%-20s %5d %9.2f %9.2f %9.2f\n", name, s.Level, s.LogScale, s.LogBound, s.Precision)
+	}
+	row("initial", plan.Initial)
+	for _, step := range plan.Steps {
+		row(step.Op.String(), step.CKKSState)
+	}
+	fmt.Fprintf(&b, "%d levels left", plan.LevelsLeft())
+	return b.String()
+}
+
+// HalfBootOutput returns the state of the ciphertexts output by HalfBoot with hb, for values of magnitude
+// at most MessageRatio/2 (the largest the symmetric ciphertexts encode) quantized by the message scaling
+// plainModulus/MessageRatio. The precision only accounts for this quantization, HalfBoot itself loses a
+// few bits (about 4 on the toy parameters): set Precision to the one measured with GetPrecisionStats for
+// an accurate plan.
+func HalfBootOutput(hb *HalfBootParameters, plainModulus uint64) CKKSState {
+	logScaling := math.Log2(float64(plainModulus) / hb.MessageRatio)
+	return CKKSState{
+		Level:     len(hb.ResidualModuli) - 1,
+		LogScale:  math.Round(math.Log2(hb.Scale)),
+		LogBound:  math.Log2(hb.MessageRatio / 2),
+		Precision: logScaling + 0.5*math.Log2(12), // rounding error uniform in [-1/2, 1/2]/scaling
+	}
+}
+
+// PlanCKKS predicts the level, scale and precision of the CKKS ciphertexts of the parameters hb after each
+// of the operations, starting from initial (e.g. HalfBootOutput(hb)). It fails on the first operation
+// that needs a level which is not left, lets the values overflow the modulus, or brings the precision
+// under minPrecision (0 to not check it).
+//
+// The errors of the summed or multiplied ciphertexts are taken as independent, the rounding of a rescale
+// and the key switching of a relinearization or rotation add the error of the noise model of the FV
+// ciphertexts (MFVNoiseModel), times sqrt(N) in the slots.
+func PlanCKKS(hb *HalfBootParameters, initial CKKSState, ops []CKKSOp, minPrecision float64) (*CKKSPlan, error) {
+	params, err := hb.Params()
+	if err != nil {
+		return nil, err
+	}
+	model := NewMFVNoiseModel(params, hb.H)
+	logSqrtN := 0.5 * model.logN
+	logRound := math.Log2((1 + model.h) / 12)
+
+	// addError adds an error of log2 variance logVar to the values
+	addError := func(s *CKKSState, logVar float64) {
+		s.Precision = -0.5 * logAdd(-2*s.Precision, logVar)
+	}
+	// addCoeffsError adds an error of log2 variance logVar to the coefficients, N times larger in the slots
+	addCoeffsError := func(s *CKKSState, logVar float64) {
+		addError(s, logVar+2*logSqrtN-2*s.LogScale)
+	}
+
+	plan := &CKKSPlan{Initial: initial}
+	s := initial
+	for i, op := range ops {
+		level := s.Level
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("cannot plan the CKKS operation %d (%s) at level %d: %s", i, op, level, fmt.Sprintf(format, args...))
+		}
+		// short the parameters have no level or scale left for the operation
+		short := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: %w", ErrInsufficientLevels, fail(format, args...))
+		}
+		switch op.Kind {
+		case CKKSAdd:
+			if op.Count < 1 {
+				return nil, fail("the number of ciphertexts must be positive")
+			}
+			s.LogBound += math.Log2(float64(op.Count))
+			s.Precision -= 0.5 * math.Log2(float64(op.Count))
+		case CKKSMultByConst:
+			if op.Constant == 0 {
+				return nil, fail("the constant must not be zero")
+			}
+			logC := math.Log2(math.Abs(op.Constant))
+			s.LogBound += logC
+			s.Precision -= logC
+			if op.Constant != math.Trunc(op.Constant) {
+				// the constant is rounded at the scale of the current modulus
+				addError(&s, 2*(s.LogBound-logC-model.logQi[s.Level])-math.Log2(12))
+				s.LogScale += model.logQi[s.Level]
+			}
+		case CKKSMulRelin:
+			// x*ey + y*ex for two ciphertexts bounded by 2^LogBound with errors of 2^-Precision
+			s.Precision = s.Precision - s.LogBound - 0.5
+			s.LogBound *= 2
+			s.LogScale *= 2
+			addCoeffsError(&s, model.logKS[s.Level])
+		case CKKSRotate:
+			addCoeffsError(&s, model.logKS[s.Level])
+		case CKKSRescale:
+			if s.Level == 0 {
+				return nil, short("no level left")
+			}
+			nbRescale := 0
+			for s.Level > 0 && s.LogScale-model.logQi[s.Level] >= math.Log2(params.Scale()/2) {
+				s.LogScale -= model.logQi[s.Level]
+				s.Level--
+				nbRescale++
+			}
+			if nbRescale == 0 {
+				return nil, short("the scale 2^%.2f is under the modulus", s.LogScale)
+			}
+			addCoeffsError(&s, logRound)
+		default:
+			return nil, fail("unknown operation")
+		}
+		if s.LogScale+s.LogBound+1 >= model.logQ[s.Level] {
+			return nil, short("the values overflow the modulus: log2 scale %.2f + log2 bound %.2f >= log2 Q %.2f",
+				s.LogScale, s.LogBound, model.logQ[s.Level])
+		}
+		if minPrecision > 0 && s.Precision < minPrecision {
+			return nil, fail("precision of %.2f bits under %.2f", s.Precision, minPrecision)
+		}
+		plan.Steps = append(plan.Steps, CKKSStep{Op: op, CKKSState: s})
+	}
+	return plan, nil
+}
@@ -0,0 +1,147 @@
+package RtF
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"flhhe/src/utils"
+)
+
+func TestPlanCKKSBudget(t *testing.T) {
+	cipher := NewRubatoCipher(RUBATO128L)
+	hb := cipher.HalfBootParams()
+	initial := HalfBootOutput(hb, cipher.PlainModulus())
+
+	fedAvg := []CKKSOp{{Kind: CKKSAdd, Count: 10}, {Kind: CKKSMultByConst, Constant: 0.1}}
+	plan, err := PlanCKKS(hb, initial, fedAvg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("\n%s", plan)
+	if plan.LevelsLeft() != len(hb.ResidualModuli)-1 {
+		t.Errorf("got %d levels left, the average shouldn't consume any", plan.LevelsLeft())
+	}
+	if plan.Final().LogScale <= initial.LogScale {
+		t.Error("MultByConst by a non-integer constant should increase the scale")
+	}
+
+	for _, tc := range []struct {
+		name string
+		ops  []CKKSOp
+		want string
+	}{
+		{"levels", append(repeatOps(initial.Level, CKKSOp{Kind: CKKSMultByConst, Constant: 0.5}, CKKSOp{Kind: CKKSRescale}), CKKSOp{Kind: CKKSRescale}), "no level left"},
+		{"overflow", repeatOps(6, CKKSOp{Kind: CKKSMulRelin}), "overflow"},
+	} {
+		if _, err := PlanCKKS(hb, initial, tc.ops, 0); !errors.Is(err, ErrInsufficientLevels) || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.name, err, tc.want)
+		}
+	}
+	if _, err := PlanCKKS(hb, initial, fedAvg, plan.Final().Precision+1); err == nil || !strings.Contains(err.Error(), "precision") {
+		t.Errorf("got error %v for a precision out of reach", err)
+	}
+}
+
+// repeatOps returns the sequence ops repeated n times
+func repeatOps(n int, ops ...CKKSOp) (repeated []CKKSOp) {
+	for range n {
+		repeated = append(repeated, ops...)
+	}
+	return
+}
+
+// TestPlanCKKSEvaluation evaluates a plan on the toy parameters and compares the level, scale and
+// precision of the result with the predicted ones
+func TestPlanCKKSEvaluation(t *testing.T) {
+	cipher := NewToyRubatoCipher(RUBATO128L, RtFToyN10)
+	hb := cipher.HalfBootParams()
+	params, err := hb.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+	kgen := NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPairSparse(hb.H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := NewCKKSEncoder(params)
+	encryptor := NewCKKSEncryptorFromPk(params, pk)
+	decryptor := NewCKKSDecryptor(params, sk)
+	evaluator := NewCKKSEvaluator(params, EvaluationKey{Rlk: rlk})
+
+	initial := HalfBootOutput(hb, cipher.PlainModulus())
+	bound := math.Exp2(initial.LogBound)
+	nbClients := 3
+	values := make([][]complex128, nbClients)
+	cts := make([]*Ciphertext, nbClients)
+	for i := range cts {
+		values[i] = make([]complex128, params.Slots())
+		for j := range values[i] {
+			values[i][j] = complex(utils.RandFloat64(-bound, bound), 0)
+		}
+		pt := NewPlaintextCKKS(params, initial.Level, math.Exp2(initial.LogScale))
+		encoder.EncodeComplex(pt, values[i], params.LogSlots())
+		if cts[i], err = encryptor.EncryptNew(pt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := GetPrecisionStats(params, encoder, decryptor, values[0], cts[0], params.LogSlots(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial.Precision = real(stats.MeanPrecision)
+
+	ops := []CKKSOp{
+		{Kind: CKKSAdd, Count: nbClients},
+		{Kind: CKKSMultByConst, Constant: 1 / float64(nbClients)},
+		{Kind: CKKSRescale},
+		{Kind: CKKSMulRelin},
+		{Kind: CKKSRescale},
+	}
+	plan, err := PlanCKKS(hb, initial, ops, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("\n%s", plan)
+
+	ct := cts[0].CopyNew().Ciphertext()
+	for _, other := range cts[1:] {
+		evaluator.Add(ct, other, ct)
+	}
+	ct = evaluator.MultByConstNew(ct, 1/float64(nbClients))
+	if err := evaluator.Rescale(ct, params.Scale(), ct); err != nil {
+		t.Fatal(err)
+	}
+	ct = evaluator.MulRelinNew(ct, ct)
+	if err := evaluator.Rescale(ct, params.Scale(), ct); err != nil {
+		t.Fatal(err)
+	}
+
+	want := make([]complex128, params.Slots())
+	for j := range want {
+		var avg complex128
+		for i := range values {
+			avg += values[i][j]
+		}
+		avg /= complex(float64(nbClients), 0)
+		want[j] = avg * avg
+	}
+	if stats, err = GetPrecisionStats(params, encoder, decryptor, want, ct, params.LogSlots(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	final := plan.Final()
+	if ct.Level() != final.Level || math.Abs(math.Log2(ct.Scale())-final.LogScale) > 0.01 {
+		t.Errorf("got level %d and scale 2^%.2f, planned %d and 2^%.2f", ct.Level(), math.Log2(ct.Scale()), final.Level, final.LogScale)
+	}
+	if got := real(stats.MeanPrecision); math.Abs(got-final.Precision) > 3 {
+		t.Errorf("got a mean precision of %.2f bits, planned %.2f", got, final.Precision)
+	}
+}
@@ -0,0 +1,99 @@
+package RtF
+
+import (
+	"errors"
+	"fmt"
+
+	"flhhe/src/utils"
+)
+
+// The errors of the public APIs wrap one of these with %w, errors.Is tells the caller what went wrong,
+// for instance which client upload to skip.
+var (
+	// ErrParamMismatch the input was made for other parameters: ring degree, level, plaintext modulus,
+	// cipher parameter set or missing evaluation keys
+	ErrParamMismatch = errors.New("parameter mismatch")
+	// ErrCorruptArtifact a serialized key, ciphertext or plaintext can't be decoded, it is
+	// utils.ErrCorruptArtifact so that the JSON files of utils report the same error
+	ErrCorruptArtifact = utils.ErrCorruptArtifact
+	// ErrInsufficientLevels the ciphertext or the parameters have no level or scale left for the
+	// operation
+	ErrInsufficientLevels = errors.New("insufficient levels")
+)
+
+// CheckCiphertext returns an ErrParamMismatch error if ct doesn't live in the ring of params: its
+// degree and level must match the ciphertext modulus Q.
+func CheckCiphertext(params *Parameters, ct *Ciphertext) error {
+	if ct == nil || ct.El() == nil || len(ct.Value()) == 0 {
+		return fmt.Errorf("%w: empty ciphertext", ErrParamMismatch)
+	}
+	for _, pol := range ct.Value() {
+		if pol == nil || len(pol.Coeffs) == 0 {
+			return fmt.Errorf("%w: empty ciphertext polynomial", ErrParamMismatch)
+		}
+		if len(pol.Coeffs) > params.MaxLevel()+1 {
+			return fmt.Errorf("%w: ciphertext level %d > maximum level %d", ErrParamMismatch, len(pol.Coeffs)-1, params.MaxLevel())
+		}
+		for _, coeffs := range pol.Coeffs {
+			if len(coeffs) != params.N() {
+				return fmt.Errorf("%w: ciphertext ring degree %d != %d", ErrParamMismatch, len(coeffs), params.N())
+			}
+		}
+	}
+	return nil
+}
+
+// checkPlainModulus returns an ErrParamMismatch error if the plaintext modulus of params isn't the one
+// of the cipher
+func checkPlainModulus(name string, params *Parameters, plainModulus uint64) error {
+	if params.PlainModulus() != plainModulus {
+		return fmt.Errorf("%w: %s needs the plaintext modulus %d, the parameters have %d", ErrParamMismatch, name, plainModulus, params.PlainModulus())
+	}
+	return nil
+}
+
+// checkKeystreamInputs returns an ErrParamMismatch error if there are fewer nonces than slots or if kCt
+// isn't blocksize ciphertexts of params of degree 1 and at least at the given level
+func checkKeystreamInputs(params *Parameters, nonce [][]byte, slots int, kCt []*Ciphertext, blocksize, level int) error {
+	if len(nonce) < slots {
+		return fmt.Errorf("%w: %d nonces for %d slots", ErrParamMismatch, len(nonce), slots)
+	}
+	if len(kCt) != blocksize {
+		return fmt.Errorf("%w: %d encrypted key words, the cipher has %d", ErrParamMismatch, len(kCt), blocksize)
+	}
+	for i, ct := range kCt {
+		if err := CheckCiphertext(params, ct); err != nil {
+			return fmt.Errorf("encrypted key word %d: %w", i, err)
+		}
+		if ct.Degree() != 1 {
+			return fmt.Errorf("%w: encrypted key word %d of degree %d", ErrParamMismatch, i, ct.Degree())
+		}
+		if ct.Level() < level {
+			return fmt.Errorf("%w: encrypted key word %d at level %d, the state is at level %d", ErrParamMismatch, i, ct.Level(), level)
+		}
+	}
+	return nil
+}
+
+// checkModDown returns an ErrParamMismatch error if modDown doesn't give the nbInitModDown initial mod
+// downs followed by those of each of the numRound rounds, and an ErrInsufficientLevels error if the
+// rounds drop more levels than the state at the given level has
+func checkModDown(modDown []int, numRound, nbInitModDown, level int) error {
+	if len(modDown) != numRound+1 {
+		return fmt.Errorf("%w: %d mod downs for %d rounds", ErrParamMismatch, len(modDown), numRound)
+	}
+	if modDown[0] != nbInitModDown {
+		return fmt.Errorf("%w: nbInitModDown expected %d but %d given", ErrParamMismatch, nbInitModDown, modDown[0])
+	}
+	total := 0
+	for _, nb := range modDown[1:] {
+		if nb < 0 {
+			return fmt.Errorf("%w: negative mod down %d", ErrParamMismatch, nb)
+		}
+		total += nb
+	}
+	if total > level {
+		return fmt.Errorf("%w: %d mod downs at level %d", ErrInsufficientLevels, total, level)
+	}
+	return nil
+}
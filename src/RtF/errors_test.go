@@ -0,0 +1,118 @@
+package RtF
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestErrors checks that the inputs made for other parameters and the corrupt encodings return errors
+// instead of panicking
+func TestErrors(t *testing.T) {
+	cipher := NewToyRubatoCipher(RUBATO80S, RtFToyN10)
+	params, err := cipher.HalfBootParams().Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+	params.SetLogFVSlots(params.LogN())
+
+	// A ciphertext of another ring degree or with too many moduli
+	other := DefaultParams[PN14QP438]
+	if err = CheckCiphertext(params, NewCiphertextFVLvl(params, 1, 0)); err != nil {
+		t.Errorf("CheckCiphertext: %v", err)
+	}
+	if err = CheckCiphertext(params, NewCiphertextFVLvl(other, 1, 0)); !errors.Is(err, ErrParamMismatch) {
+		t.Errorf("CheckCiphertext of another ring degree: got error %v, want %v", err, ErrParamMismatch)
+	}
+	ct := NewCiphertextFVLvl(params, 1, 0)
+	for _, pol := range ct.Value() {
+		for range params.MaxLevel() + 1 {
+			pol.Coeffs = append(pol.Coeffs, make([]uint64, params.N()))
+		}
+	}
+	if err = CheckCiphertext(params, ct); !errors.Is(err, ErrParamMismatch) {
+		t.Errorf("CheckCiphertext of too many moduli: got error %v, want %v", err, ErrParamMismatch)
+	}
+
+	// The Rubato parameter set of another plaintext modulus
+	kgen := NewKeyGenerator(params)
+	_, pk, err := kgen.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := NewMFVEncoder(params)
+	evaluator := NewMFVEvaluator(params, EvaluationKey{}, nil)
+	for _, rubatoParam := range []int{RUBATO128L, -1} {
+		if RubatoParams[RUBATO80S].PlainModulus == RubatoParams[RUBATO128L].PlainModulus {
+			t.Fatal("RUBATO80S and RUBATO128L have the same plaintext modulus")
+		}
+		if _, err = NewMFVRubato(rubatoParam, params, encoder, NewMFVEncryptorFromPk(params, pk), evaluator, 0); !errors.Is(err, ErrParamMismatch) {
+			t.Errorf("NewMFVRubato(%d): got error %v, want %v", rubatoParam, err, ErrParamMismatch)
+		}
+	}
+
+	// The keystream evaluation of malformed inputs
+	modDown := cipher.ModDownParams()
+	mfvCipher, err := cipher.NewMFVCipher(params, encoder, NewMFVEncryptorFromPk(params, pk), evaluator, modDown.CipherModDown[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mfvCipher.EncKey(rubatoTestKey(cipher.BlockSize() - 1)); !errors.Is(err, ErrParamMismatch) {
+		t.Errorf("EncKey of a short key: got error %v, want %v", err, ErrParamMismatch)
+	}
+	kCt, err := mfvCipher.EncKey(rubatoTestKey(cipher.BlockSize()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces := make([][]byte, params.FVSlots())
+	for i := range nonces {
+		nonces[i] = make([]byte, 8)
+	}
+	counter := make([]byte, 8)
+	tooManyModDowns := append([]int{}, modDown.CipherModDown...)
+	tooManyModDowns[len(tooManyModDowns)-1] += params.MaxLevel()
+	for _, tc := range []struct {
+		name    string
+		nonces  [][]byte
+		kCt     []*Ciphertext
+		modDown []int
+		want    error
+	}{
+		{"too few nonces", nonces[:1], kCt, modDown.CipherModDown, ErrParamMismatch},
+		{"a missing key word", nonces, kCt[1:], modDown.CipherModDown, ErrParamMismatch},
+		{"a key word of another ring", nonces, append([]*Ciphertext{NewCiphertextFVLvl(other, 1, 0)}, kCt[1:]...), modDown.CipherModDown, ErrParamMismatch},
+		{"the mod downs of another round number", nonces, kCt, modDown.CipherModDown[1:], ErrParamMismatch},
+		{"too many mod downs", nonces, kCt, tooManyModDowns, ErrInsufficientLevels},
+	} {
+		if _, err = mfvCipher.Crypt(tc.nonces, counter, tc.kCt, tc.modDown); !errors.Is(err, tc.want) {
+			t.Errorf("Crypt with %s: got error %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if _, err = evaluator.SlotsToCoeffs(kCt[0], modDown.StCModDown); !errors.Is(err, ErrParamMismatch) {
+		t.Errorf("SlotsToCoeffs without the matrices: got error %v, want %v", err, ErrParamMismatch)
+	}
+
+	// Every truncation of an encoded ciphertext fails to decode
+	data, err := NewCiphertextFVLvl(params, 1, 0).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{0, 7, 8, 16, 17, len(data) / 2, len(data) - 1} {
+		if err = NewCiphertextFVLvl(params, 1, 0).UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("UnmarshalBinary of %d of the %d bytes: no error", n, len(data))
+		}
+	}
+	// A huge number of polynomials
+	huge := append([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, data[8:]...)
+	if err = NewCiphertextFVLvl(params, 1, 0).UnmarshalBinary(huge); err == nil {
+		t.Error("UnmarshalBinary of 2^63 polynomials: no error")
+	}
+
+	// The decoding matrices need a plaintext modulus with the roots of unity of the slots
+	if _, err = GenDecodingMats(params.LogFVSlots(), cipher.PlainModulus()); err != nil {
+		t.Errorf("GenDecodingMats: %v", err)
+	}
+	if _, err = GenDecodingMats(params.LogFVSlots(), 65539); err == nil {
+		t.Error("GenDecodingMats modulo 65539: no error")
+	}
+}
@@ -6,12 +6,13 @@ import (
 	"golang.org/x/crypto/sha3"
 )
 
+// MFVHera evaluates the keystream of HERA homomorphically, with the errors of MFVRubato
 type MFVHera interface {
-	Crypt(nonce [][]byte, kCt []*Ciphertext, heraModDown []int) []*Ciphertext
-	CryptNoModSwitch(nonce [][]byte, kCt []*Ciphertext) []*Ciphertext
-	CryptAutoModSwitch(nonce [][]byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) (res []*Ciphertext, heraModDown []int)
-	Reset(nbInitModDown int)
-	EncKey(key []uint64) (res []*Ciphertext)
+	Crypt(nonce [][]byte, kCt []*Ciphertext, heraModDown []int) ([]*Ciphertext, error)
+	CryptNoModSwitch(nonce [][]byte, kCt []*Ciphertext) ([]*Ciphertext, error)
+	CryptAutoModSwitch(nonce [][]byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) (res []*Ciphertext, heraModDown []int, err error)
+	Reset(nbInitModDown int) error
+	EncKey(key []uint64) (res []*Ciphertext, err error)
 }
 
 type mfvHera struct {
@@ -30,14 +31,18 @@ type mfvHera struct {
 	rc   [][][]uint64    // RoundConstants[round][state][slot]
 	rcPt []*PlaintextMul // Buffer for round constants
 	xof  []sha3.ShakeHash
+
+	trace roundTrace // called by Crypt after each round, see SearchModDown
 }
 
-func NewMFVHera(numRound int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) MFVHera {
+// NewMFVHera creates the homomorphic evaluator of HERA with numRound rounds. It returns an
+// ErrParamMismatch error if nbInitModDown exceeds the levels of params, and the error of the encryptor
+// if the initial state can't be encrypted.
+func NewMFVHera(numRound int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) (MFVHera, error) {
 	hera := new(mfvHera)
 
 	hera.numRound = numRound
 	hera.slots = params.FVSlots()
-	hera.nbInitModDown = nbInitModDown
 
 	hera.params = params
 	hera.encoder = encoder
@@ -58,25 +63,19 @@ func NewMFVHera(numRound int, params *Parameters, encoder MFVEncoder, encryptor
 		}
 	}
 
-	// Precompute Initial States
-	state := make([]uint64, hera.slots)
-
-	for i := 0; i < 16; i++ {
-		for j := 0; j < hera.slots; j++ {
-			state[j] = uint64(i + 1) // ic = 1, ..., 16
-		}
-		icPT := NewPlaintextFV(params)
-		encoder.EncodeUintSmall(state, icPT)
-		encryptor.EncryptNew(icPT)
-		hera.stCt[i] = encryptor.EncryptNew(icPT)
-		if nbInitModDown > 0 {
-			evaluator.ModSwitchMany(hera.stCt[i], hera.stCt[i], nbInitModDown)
-		}
+	if err := hera.Reset(nbInitModDown); err != nil {
+		return nil, err
 	}
-	return hera
+	return hera, nil
 }
 
-func (hera *mfvHera) Reset(nbInitModDown int) {
+// Reset encrypts the initial state again, switched down nbInitModDown levels. It returns an
+// ErrParamMismatch error if nbInitModDown exceeds the levels of the parameters.
+func (hera *mfvHera) Reset(nbInitModDown int) error {
+	if nbInitModDown < 0 || nbInitModDown > hera.params.MaxLevel() {
+		return fmt.Errorf("%w: %d initial mod downs, the parameters have %d levels", ErrParamMismatch, nbInitModDown, hera.params.MaxLevel())
+	}
+
 	// Precompute Initial States
 	hera.nbInitModDown = nbInitModDown
 	state := make([]uint64, hera.slots)
@@ -87,12 +86,16 @@ func (hera *mfvHera) Reset(nbInitModDown int) {
 		}
 		icPT := NewPlaintextFV(hera.params)
 		hera.encoder.EncodeUintSmall(state, icPT)
-		hera.encryptor.EncryptNew(icPT)
-		hera.stCt[i] = hera.encryptor.EncryptNew(icPT)
+		ct, err := hera.encryptor.EncryptNew(icPT)
+		if err != nil {
+			return err
+		}
+		hera.stCt[i] = ct
 		if nbInitModDown > 0 {
 			hera.evaluator.ModSwitchMany(hera.stCt[i], hera.stCt[i], nbInitModDown)
 		}
 	}
+	return nil
 }
 
 // Compute Round Constants
@@ -189,8 +192,17 @@ func (hera *mfvHera) modSwitch(nbSwitch int) {
 	}
 }
 
+// checkInputs returns an ErrParamMismatch error if there are fewer nonces than slots or if kCt isn't
+// the encrypted key at the level of the state
+func (hera *mfvHera) checkInputs(nonce [][]byte, kCt []*Ciphertext) error {
+	return checkKeystreamInputs(hera.params, nonce, hera.slots, kCt, 16, hera.stCt[0].Level())
+}
+
 // CryptNoModSwitch Compute ciphertexts without modulus switching
-func (hera *mfvHera) CryptNoModSwitch(nonce [][]byte, kCt []*Ciphertext) []*Ciphertext {
+func (hera *mfvHera) CryptNoModSwitch(nonce [][]byte, kCt []*Ciphertext) ([]*Ciphertext, error) {
+	if err := hera.checkInputs(nonce, kCt); err != nil {
+		return nil, err
+	}
 	for st := 0; st < 16; st++ {
 		hera.mkCt[st] = kCt[st].CopyNew().Ciphertext()
 	}
@@ -206,11 +218,14 @@ func (hera *mfvHera) CryptNoModSwitch(nonce [][]byte, kCt []*Ciphertext) []*Ciph
 	hera.cube()
 	hera.linLayer()
 	hera.addRoundKey(hera.numRound, true)
-	return hera.stCt
+	return hera.stCt, nil
 }
 
 // CryptAutoModSwitch Compute ciphertexts with automatic modulus switching
-func (hera *mfvHera) CryptAutoModSwitch(nonce [][]byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) ([]*Ciphertext, []int) {
+func (hera *mfvHera) CryptAutoModSwitch(nonce [][]byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) ([]*Ciphertext, []int, error) {
+	if err := hera.checkInputs(nonce, kCt); err != nil {
+		return nil, nil, err
+	}
 	heraModDown := make([]int, hera.numRound+1)
 	heraModDown[0] = hera.nbInitModDown
 	for st := 0; st < 16; st++ {
@@ -230,14 +245,17 @@ func (hera *mfvHera) CryptAutoModSwitch(nonce [][]byte, kCt []*Ciphertext, noise
 	hera.modSwitchAuto(hera.numRound, noiseEstimator, heraModDown)
 	hera.linLayer()
 	hera.addRoundKey(hera.numRound, true)
-	return hera.stCt, heraModDown
+	return hera.stCt, heraModDown, nil
 }
 
-// Crypt Compute ciphertexts with modulus switching as given in heraModDown
-func (hera *mfvHera) Crypt(nonce [][]byte, kCt []*Ciphertext, heraModDown []int) []*Ciphertext {
-	if heraModDown[0] != hera.nbInitModDown {
-		errorString := fmt.Sprintf("nbInitModDown expected %d but %d given", hera.nbInitModDown, heraModDown[0])
-		panic(errorString)
+// Crypt Compute ciphertexts with modulus switching as given in heraModDown, see MFVRubato.Crypt for
+// the errors
+func (hera *mfvHera) Crypt(nonce [][]byte, kCt []*Ciphertext, heraModDown []int) ([]*Ciphertext, error) {
+	if err := checkModDown(heraModDown, hera.numRound, hera.nbInitModDown, hera.stCt[0].Level()); err != nil {
+		return nil, err
+	}
+	if err := hera.checkInputs(nonce, kCt); err != nil {
+		return nil, err
 	}
 
 	for st := 0; st < 16; st++ {
@@ -246,18 +264,25 @@ func (hera *mfvHera) Crypt(nonce [][]byte, kCt []*Ciphertext, heraModDown []int)
 	hera.init(nonce)
 
 	hera.addRoundKey(0, false)
+	hera.trace.call(0, hera.stCt)
 	for r := 1; r < hera.numRound; r++ {
 		hera.linLayer()
 		hera.cube()
 		hera.modSwitch(heraModDown[r])
+		hera.trace.call(r, hera.stCt)
 		hera.addRoundKey(r, false)
 	}
 	hera.linLayer()
 	hera.cube()
 	hera.modSwitch(heraModDown[hera.numRound])
+	hera.trace.call(hera.numRound, hera.stCt)
 	hera.linLayer()
 	hera.addRoundKey(hera.numRound, true)
-	return hera.stCt
+	return hera.stCt, nil
+}
+
+func (hera *mfvHera) setTrace(trace roundTrace) {
+	hera.trace = trace
 }
 
 func (hera *mfvHera) addRoundKey(round int, reduce bool) {
@@ -349,7 +374,12 @@ func (hera *mfvHera) cube() {
 	}
 }
 
-func (hera *mfvHera) EncKey(key []uint64) (res []*Ciphertext) {
+// EncKey encrypts each word of the key in all the slots, it returns an ErrParamMismatch error if the
+// key is shorter than the block size
+func (hera *mfvHera) EncKey(key []uint64) (res []*Ciphertext, err error) {
+	if len(key) < 16 {
+		return nil, fmt.Errorf("%w: key of %d words, the block size is 16", ErrParamMismatch, len(key))
+	}
 	slots := hera.slots
 	res = make([]*Ciphertext, 16)
 
@@ -361,10 +391,12 @@ func (hera *mfvHera) EncKey(key []uint64) (res []*Ciphertext) {
 
 		keyPt := NewPlaintextFV(hera.params)
 		hera.encoder.EncodeUintSmall(dupKey, keyPt)
-		res[i] = hera.encryptor.EncryptNew(keyPt)
+		if res[i], err = hera.encryptor.EncryptNew(keyPt); err != nil {
+			return nil, err
+		}
 		if hera.nbInitModDown > 0 {
 			hera.evaluator.ModSwitchMany(res[i], res[i], hera.nbInitModDown)
 		}
 	}
-	return
+	return res, nil
 }
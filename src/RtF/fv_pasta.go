@@ -0,0 +1,296 @@
+package RtF
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+type PastaParam struct {
+	Name     string
+	T        int // number of words of each half of the state, and of the keystream
+	NumRound int
+}
+
+const (
+	PASTA3 = iota
+	PASTA4
+)
+
+var PastaParams = []PastaParam{
+	{
+		// PASTA3
+		Name:     "PASTA3",
+		T:        128,
+		NumRound: 3,
+	},
+	{
+		// PASTA4
+		Name:     "PASTA4",
+		T:        32,
+		NumRound: 4,
+	},
+}
+
+// MFVPasta evaluates the keystream of Pasta homomorphically, with the errors of MFVRubato
+type MFVPasta interface {
+	Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, pastaModDown []int) ([]*Ciphertext, error)
+	CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) ([]*Ciphertext, error)
+	Reset(nbInitModDown int) error
+	EncKey(key []uint64) (res []*Ciphertext, err error)
+}
+
+type mfvPasta struct {
+	t             int
+	numRound      int
+	slots         int
+	nbInitModDown int
+
+	params    *Parameters
+	encoder   MFVEncoder
+	encryptor MFVEncryptor
+	evaluator MFVEvaluator
+
+	stCt []*Ciphertext
+	xof  []sha3.ShakeHash
+
+	trace roundTrace // called by Crypt after each round, see SearchModDown
+}
+
+func NewMFVPasta(pastaParam int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) MFVPasta {
+	return newMFVPasta(PastaParams[pastaParam].T, PastaParams[pastaParam].NumRound, params, encoder, encryptor, evaluator, nbInitModDown)
+}
+
+func newMFVPasta(t int, numRound int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) *mfvPasta {
+	pasta := new(mfvPasta)
+
+	pasta.t = t
+	pasta.numRound = numRound
+	pasta.slots = params.FVSlots()
+	pasta.nbInitModDown = nbInitModDown
+
+	pasta.params = params
+	pasta.encoder = encoder
+	pasta.encryptor = encryptor
+	pasta.evaluator = evaluator
+
+	pasta.stCt = make([]*Ciphertext, 2*pasta.t)
+	pasta.xof = make([]sha3.ShakeHash, pasta.slots)
+	return pasta
+}
+
+// Reset the state of Pasta is the key, only the number of initial mod down of the key is kept. It
+// returns an ErrParamMismatch error if nbInitModDown exceeds the levels of the parameters.
+func (pasta *mfvPasta) Reset(nbInitModDown int) error {
+	if nbInitModDown < 0 || nbInitModDown > pasta.params.MaxLevel() {
+		return fmt.Errorf("%w: %d initial mod downs, the parameters have %d levels", ErrParamMismatch, nbInitModDown, pasta.params.MaxLevel())
+	}
+	pasta.nbInitModDown = nbInitModDown
+	return nil
+}
+
+// checkInputs returns an ErrParamMismatch error if there are fewer nonces than slots or if kCt isn't
+// the encrypted key, at least at the level EncKey leaves it
+func (pasta *mfvPasta) checkInputs(nonce [][]byte, kCt []*Ciphertext) error {
+	return checkKeystreamInputs(pasta.params, nonce, pasta.slots, kCt, 2*pasta.t, pasta.params.MaxLevel()-pasta.nbInitModDown)
+}
+
+func (pasta *mfvPasta) init(nonce [][]byte, counter []byte, kCt []*Ciphertext) {
+	for i := 0; i < pasta.slots; i++ {
+		pasta.xof[i] = sha3.NewShake256()
+		pasta.xof[i].Write(nonce[i])
+		pasta.xof[i].Write(counter)
+	}
+
+	for i := 0; i < 2*pasta.t; i++ {
+		pasta.stCt[i] = kCt[i].CopyNew().Ciphertext()
+	}
+}
+
+func (pasta *mfvPasta) modSwitch(nbSwitch int) {
+	if nbSwitch <= 0 {
+		return
+	}
+	for i := 0; i < 2*pasta.t; i++ {
+		pasta.evaluator.ModSwitchMany(pasta.stCt[i], pasta.stCt[i], nbSwitch)
+	}
+}
+
+// CryptNoModSwitch Compute ciphertexts without modulus switching
+func (pasta *mfvPasta) CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) ([]*Ciphertext, error) {
+	if err := pasta.checkInputs(nonce, kCt); err != nil {
+		return nil, err
+	}
+	pasta.init(nonce, counter, kCt)
+
+	for r := 1; r <= pasta.numRound; r++ {
+		pasta.round(r)
+	}
+	pasta.affineLayer()
+	pasta.mix()
+	return pasta.stCt[:pasta.t], nil
+}
+
+// Crypt compute ciphertexts with modulus switching as given in pastaModDown
+// using the homomorphically encrypted secret key `kCt`, `nonce`, `counter`, see MFVRubato.Crypt for
+// the errors
+func (pasta *mfvPasta) Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, pastaModDown []int) ([]*Ciphertext, error) {
+	if err := checkModDown(pastaModDown, pasta.numRound, pasta.nbInitModDown, pasta.params.MaxLevel()-pasta.nbInitModDown); err != nil {
+		return nil, err
+	}
+	if err := pasta.checkInputs(nonce, kCt); err != nil {
+		return nil, err
+	}
+	pasta.init(nonce, counter, kCt)
+	pasta.trace.call(0, pasta.stCt)
+
+	for r := 1; r <= pasta.numRound; r++ {
+		pasta.round(r)
+		pasta.modSwitch(pastaModDown[r])
+		pasta.trace.call(r, pasta.stCt)
+	}
+	pasta.affineLayer()
+	pasta.mix()
+	return pasta.stCt[:pasta.t], nil
+}
+
+func (pasta *mfvPasta) setTrace(trace roundTrace) {
+	pasta.trace = trace
+}
+
+func (pasta *mfvPasta) round(r int) {
+	pasta.affineLayer()
+	pasta.mix()
+	if r < pasta.numRound {
+		pasta.feistel(pasta.stCt[:pasta.t])
+		pasta.feistel(pasta.stCt[pasta.t:])
+	} else {
+		pasta.cube()
+	}
+}
+
+// affineLayer samples the matrices and the round constants of every slot in the order of PlainPasta,
+// the rows of the matrices are computed one at a time to bound the memory
+func (pasta *mfvPasta) affineLayer() {
+	ev := pasta.evaluator
+	t, slots := pasta.t, pasta.slots
+	plainModulus := pasta.params.PlainModulus()
+
+	// firstRows[half][col][slot], rcs[half][row][slot]
+	var firstRows, rcs [2][][]uint64
+	for h := 0; h < 2; h++ {
+		firstRows[h] = make([][]uint64, t)
+		rcs[h] = make([][]uint64, t)
+		for j := 0; j < t; j++ {
+			firstRows[h][j] = make([]uint64, slots)
+			rcs[h][j] = make([]uint64, slots)
+		}
+	}
+	for slot := 0; slot < slots; slot++ {
+		for h := 0; h < 2; h++ {
+			for j := 0; j < t; j++ {
+				firstRows[h][j][slot] = sampleNonZeroZqx(pasta.xof[slot], plainModulus)
+			}
+		}
+		for h := 0; h < 2; h++ {
+			for j := 0; j < t; j++ {
+				rcs[h][j][slot] = SampleZqx(pasta.xof[slot], plainModulus)
+			}
+		}
+	}
+
+	row := make([][]uint64, t)
+	for j := 0; j < t; j++ {
+		row[j] = make([]uint64, slots)
+	}
+	buf := make([]*Ciphertext, t)
+	for h := 0; h < 2; h++ {
+		half := pasta.stCt[h*t : (h+1)*t]
+		for j := 0; j < t; j++ {
+			copy(row[j], firstRows[h][j])
+		}
+
+		for i := 0; i < t; i++ {
+			if i > 0 {
+				for slot := 0; slot < slots; slot++ {
+					last := row[t-1][slot]
+					for j := t - 1; j > 0; j-- {
+						row[j][slot] = (firstRows[h][j][slot]*last + row[j-1][slot]) % plainModulus
+					}
+					row[0][slot] = firstRows[h][0][slot] * last % plainModulus
+				}
+			}
+
+			level := half[0].Level()
+			for j := 0; j < t; j++ {
+				rowPt := NewPlaintextMulLvl(pasta.params, level)
+				pasta.encoder.EncodeUintMulSmall(row[j], rowPt)
+				if j == 0 {
+					buf[i] = ev.MulNew(half[j], rowPt)
+				} else {
+					ev.Add(buf[i], ev.MulNew(half[j], rowPt), buf[i])
+				}
+			}
+
+			rcPt := NewPlaintextFVLvl(pasta.params, level)
+			pasta.encoder.EncodeUintSmall(rcs[h][i], rcPt)
+			ev.Add(buf[i], rcPt, buf[i])
+		}
+		copy(half, buf)
+	}
+}
+
+func (pasta *mfvPasta) mix() {
+	ev := pasta.evaluator
+	for i := 0; i < pasta.t; i++ {
+		sum := ev.AddNew(pasta.stCt[i], pasta.stCt[pasta.t+i])
+		ev.Add(pasta.stCt[i], sum, pasta.stCt[i])
+		ev.Add(pasta.stCt[pasta.t+i], sum, pasta.stCt[pasta.t+i])
+	}
+}
+
+func (pasta *mfvPasta) feistel(half []*Ciphertext) {
+	ev := pasta.evaluator
+	for i := len(half) - 1; i > 0; i-- {
+		tmp := ev.MulNew(half[i-1], half[i-1])
+		ev.Relinearize(tmp, tmp)
+		ev.Add(half[i], tmp, half[i])
+	}
+}
+
+func (pasta *mfvPasta) cube() {
+	ev := pasta.evaluator
+	for st := 0; st < 2*pasta.t; st++ {
+		x2 := ev.MulNew(pasta.stCt[st], pasta.stCt[st])
+		y2 := ev.RelinearizeNew(x2)
+		x3 := ev.MulNew(y2, pasta.stCt[st])
+		pasta.stCt[st] = ev.RelinearizeNew(x3)
+	}
+}
+
+// EncKey encrypts each word of the key in all the slots, it returns an ErrParamMismatch error if the
+// key is shorter than the state
+func (pasta *mfvPasta) EncKey(key []uint64) (res []*Ciphertext, err error) {
+	if len(key) < 2*pasta.t {
+		return nil, fmt.Errorf("%w: key of %d words, the state has %d", ErrParamMismatch, len(key), 2*pasta.t)
+	}
+	slots := pasta.slots
+	res = make([]*Ciphertext, 2*pasta.t)
+
+	for i := 0; i < 2*pasta.t; i++ {
+		dupKey := make([]uint64, slots)
+		for j := 0; j < slots; j++ {
+			dupKey[j] = key[i]
+		}
+
+		keyPt := NewPlaintextFV(pasta.params)
+		pasta.encoder.EncodeUintSmall(dupKey, keyPt)
+		if res[i], err = pasta.encryptor.EncryptNew(keyPt); err != nil {
+			return nil, err
+		}
+		if pasta.nbInitModDown > 0 {
+			pasta.evaluator.ModSwitchMany(res[i], res[i], pasta.nbInitModDown)
+		}
+	}
+	return res, nil
+}
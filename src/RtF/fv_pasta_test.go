@@ -0,0 +1,96 @@
+package RtF
+
+import (
+	"crypto/rand"
+	"reflect"
+	"testing"
+)
+
+func TestPlainPasta(t *testing.T) {
+	p := PastaParams[PASTA4]
+	plainModulus := NewPastaCipher(PASTA4).PlainModulus()
+	nonce, counter := make([]byte, 8), make([]byte, 8)
+	rand.Read(nonce)
+	rand.Read(counter)
+	key := make([]uint64, 2*p.T)
+	for i := range key {
+		key[i] = uint64(i + 1)
+	}
+
+	keystream := PlainPasta(p.T, p.NumRound, nonce, counter, key, plainModulus)
+	if len(keystream) != p.T {
+		t.Fatalf("got %d words, want %d", len(keystream), p.T)
+	}
+	for i, z := range keystream {
+		if z >= plainModulus {
+			t.Errorf("keystream[%d] = %d is not reduced modulo %d", i, z, plainModulus)
+		}
+	}
+	if again := PlainPasta(p.T, p.NumRound, nonce, counter, key, plainModulus); !reflect.DeepEqual(again, keystream) {
+		t.Error("the keystream is not deterministic")
+	}
+
+	counter[0] ^= 1
+	if other := PlainPasta(p.T, p.NumRound, nonce, counter, key, plainModulus); reflect.DeepEqual(other, keystream) {
+		t.Error("the keystream doesn't depend on the counter")
+	}
+}
+
+// TestMFVPasta evaluates a toy Pasta instance homomorphically and compares it with PlainPasta, slot by slot
+func TestMFVPasta(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the homomorphic evaluation in short mode")
+	}
+	pastaT, numRound := 4, 2
+	params := DefaultParams[PN14QP438].WithPlainModulus(NewPastaCipher(PASTA4).PlainModulus())
+	params.SetLogFVSlots(params.LogN())
+
+	kgen := NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := NewMFVEncoder(params)
+	encryptor := NewMFVEncryptorFromPk(params, pk)
+	decryptor := NewMFVDecryptor(params, sk)
+	evaluator := NewMFVEvaluator(params, EvaluationKey{Rlk: rlk}, nil)
+
+	key := make([]uint64, 2*pastaT)
+	for i := range key {
+		key[i] = uint64(i + 1)
+	}
+	nonces := make([][]byte, params.FVSlots())
+	for i := range nonces {
+		nonces[i] = make([]byte, 8)
+		rand.Read(nonces[i])
+	}
+	counter := make([]byte, 8)
+	rand.Read(counter)
+
+	pasta := newMFVPasta(pastaT, numRound, params, encoder, encryptor, evaluator, 0)
+	kCt, err := pasta.EncKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystreamCt, err := pasta.CryptNoModSwitch(nonces, counter, kCt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	have := make([][]uint64, pastaT)
+	for i := range have {
+		have[i] = encoder.DecodeUintSmallNew(decryptor.DecryptNew(keystreamCt[i]))
+	}
+	for slot := range nonces {
+		want := PlainPasta(pastaT, numRound, nonces[slot], counter, key, params.PlainModulus())
+		for i := range want {
+			if have[i][slot] != want[i] {
+				t.Fatalf("slot %d, word %d: got %d, want %d", slot, i, have[i][slot], want[i])
+			}
+		}
+	}
+}
@@ -75,12 +75,15 @@ var RubatoParams = []RubatoParam{
 	},
 }
 
+// MFVRubato evaluates the keystream of Rubato homomorphically. The Crypt methods return an
+// ErrParamMismatch error if there are fewer nonces than slots or if kCt isn't the encrypted key of the
+// parameters, EncKey and Reset return the error of the encryptor.
 type MFVRubato interface {
-	Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, rubatoModDown []int) []*Ciphertext
-	CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) []*Ciphertext
-	CryptAutoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) (res []*Ciphertext, rubatoModDown []int)
-	Reset(nbInitModDown int)
-	EncKey(key []uint64) (res []*Ciphertext)
+	Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, rubatoModDown []int) ([]*Ciphertext, error)
+	CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) ([]*Ciphertext, error)
+	CryptAutoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) (res []*Ciphertext, rubatoModDown []int, err error)
+	Reset(nbInitModDown int) error
+	EncKey(key []uint64) (res []*Ciphertext, err error)
 }
 
 type mfvRubato struct {
@@ -101,16 +104,41 @@ type mfvRubato struct {
 	rc   [][][]uint64    // RoundConstants[round][state][slot]
 	rcPt []*PlaintextMul // Buffer for round constants
 	xof  []sha3.ShakeHash
+
+	constantTimeRoundConstants bool // draw the round constants with ring.BoundedUniformSampler, see PlainRubatoConstantTime
+
+	trace roundTrace // called by Crypt after each round, see SearchModDown
+}
+
+// NewMFVRubato creates the homomorphic evaluator of Rubato with the parameter set rubatoParam (an index
+// of RubatoParams). It returns an ErrParamMismatch error if params doesn't have the plaintext modulus of
+// the parameter set or if nbInitModDown exceeds its levels, and the error of the encryptor if the
+// initial state can't be encrypted.
+func NewMFVRubato(rubatoParam int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) (MFVRubato, error) {
+	return newMFVRubato(rubatoParam, params, encoder, encryptor, evaluator, nbInitModDown, false)
+}
+
+// NewMFVRubatoConstantTime creates the homomorphic evaluator of the keystream of PlainRubatoConstantTime
+// with its round constants drawn in constant time, which the clients of a cipher built by
+// WithConstantTimeRoundConstants compute. It returns the errors of NewMFVRubato.
+func NewMFVRubatoConstantTime(rubatoParam int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) (MFVRubato, error) {
+	return newMFVRubato(rubatoParam, params, encoder, encryptor, evaluator, nbInitModDown, true)
 }
 
-func NewMFVRubato(rubatoParam int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) MFVRubato {
+func newMFVRubato(rubatoParam int, params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int, constantTimeRoundConstants bool) (MFVRubato, error) {
+	if rubatoParam < 0 || rubatoParam >= len(RubatoParams) {
+		return nil, fmt.Errorf("%w: unknown Rubato parameter set %d", ErrParamMismatch, rubatoParam)
+	}
+	if err := checkPlainModulus(RubatoParams[rubatoParam].Name, params, RubatoParams[rubatoParam].PlainModulus); err != nil {
+		return nil, err
+	}
 	rubato := new(mfvRubato)
 
 	rubato.rubatoParam = rubatoParam
+	rubato.constantTimeRoundConstants = constantTimeRoundConstants
 	rubato.blocksize = RubatoParams[rubatoParam].Blocksize
 	rubato.numRound = RubatoParams[rubatoParam].NumRound
 	rubato.slots = params.FVSlots()
-	rubato.nbInitModDown = nbInitModDown
 
 	rubato.params = params
 	rubato.encoder = encoder
@@ -131,25 +159,19 @@ func NewMFVRubato(rubatoParam int, params *Parameters, encoder MFVEncoder, encry
 		}
 	}
 
-	// Precompute Initial States
-	state := make([]uint64, rubato.slots)
-
-	for i := 0; i < rubato.blocksize; i++ {
-		for j := 0; j < rubato.slots; j++ {
-			state[j] = uint64(i + 1) // ic = 1, ..., blocksize
-		}
-		icPT := NewPlaintextFV(params)
-		encoder.EncodeUintSmall(state, icPT)
-		encryptor.EncryptNew(icPT)
-		rubato.stCt[i] = encryptor.EncryptNew(icPT)
-		if nbInitModDown > 0 {
-			evaluator.ModSwitchMany(rubato.stCt[i], rubato.stCt[i], nbInitModDown)
-		}
+	if err := rubato.Reset(nbInitModDown); err != nil {
+		return nil, err
 	}
-	return rubato
+	return rubato, nil
 }
 
-func (rubato *mfvRubato) Reset(nbInitModDown int) {
+// Reset encrypts the initial state again, switched down nbInitModDown levels. It returns an
+// ErrParamMismatch error if nbInitModDown exceeds the levels of the parameters.
+func (rubato *mfvRubato) Reset(nbInitModDown int) error {
+	if nbInitModDown < 0 || nbInitModDown > rubato.params.MaxLevel() {
+		return fmt.Errorf("%w: %d initial mod downs, the parameters have %d levels", ErrParamMismatch, nbInitModDown, rubato.params.MaxLevel())
+	}
+
 	// Precompute Initial States
 	rubato.nbInitModDown = nbInitModDown
 	state := make([]uint64, rubato.slots)
@@ -160,27 +182,36 @@ func (rubato *mfvRubato) Reset(nbInitModDown int) {
 		}
 		icPT := NewPlaintextFV(rubato.params)
 		rubato.encoder.EncodeUintSmall(state, icPT)
-		rubato.encryptor.EncryptNew(icPT)
-		rubato.stCt[i] = rubato.encryptor.EncryptNew(icPT)
+		ct, err := rubato.encryptor.EncryptNew(icPT)
+		if err != nil {
+			return err
+		}
+		rubato.stCt[i] = ct
 		if nbInitModDown > 0 {
 			rubato.evaluator.ModSwitchMany(rubato.stCt[i], rubato.stCt[i], nbInitModDown)
 		}
 	}
+	return nil
 }
 
 // Compute Round Constants
-func (rubato *mfvRubato) init(nonce [][]byte, counter []byte) {
+func (rubato *mfvRubato) init(nonce [][]byte, counter []byte) error {
 	slots := rubato.slots
+	sampleRoundConstant := make([]func() (uint64, error), slots)
 	for i := 0; i < slots; i++ {
 		rubato.xof[i] = sha3.NewShake256()
 		rubato.xof[i].Write(nonce[i])
 		rubato.xof[i].Write(counter)
+		sampleRoundConstant[i] = newRoundConstantSampler(rubato.xof[i], rubato.params.PlainModulus(), rubato.constantTimeRoundConstants)
 	}
 
+	var err error
 	for r := 0; r <= rubato.numRound; r++ {
 		for i := 0; i < rubato.blocksize; i++ {
 			for slot := 0; slot < slots; slot++ {
-				rubato.rc[r][i][slot] = SampleZqx(rubato.xof[slot], rubato.params.PlainModulus())
+				if rubato.rc[r][i][slot], err = sampleRoundConstant[slot](); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -191,6 +222,7 @@ func (rubato *mfvRubato) init(nonce [][]byte, counter []byte) {
 			rubato.evaluator.ModSwitchMany(rubato.mkCt[i], rubato.mkCt[i], nbSwitch)
 		}
 	}
+	return nil
 }
 
 func (rubato *mfvRubato) findBudgetInfo(noiseEstimator MFVNoiseEstimator) (maxInvBudget, minErrorBits int) {
@@ -263,76 +295,124 @@ func (rubato *mfvRubato) modSwitch(nbSwitch int) {
 	}
 }
 
+// checkInputs returns an ErrParamMismatch error if there are fewer nonces than slots or if kCt isn't
+// the encrypted key at the level of the state
+func (rubato *mfvRubato) checkInputs(nonce [][]byte, kCt []*Ciphertext) error {
+	return checkKeystreamInputs(rubato.params, nonce, rubato.slots, kCt, rubato.blocksize, rubato.stCt[0].Level())
+}
+
 // CryptNoModSwitch Compute ciphertexts without modulus switching
-func (rubato *mfvRubato) CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) []*Ciphertext {
+func (rubato *mfvRubato) CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) ([]*Ciphertext, error) {
+	if err := rubato.checkInputs(nonce, kCt); err != nil {
+		return nil, err
+	}
 	for i := 0; i < rubato.blocksize; i++ {
 		rubato.mkCt[i] = kCt[i].CopyNew().Ciphertext()
 	}
-	rubato.init(nonce, counter)
+	if err := rubato.init(nonce, counter); err != nil {
+		return nil, err
+	}
 
 	rubato.addRoundKey(0, false)
 	for r := 1; r < rubato.numRound; r++ {
-		rubato.linearLayer()
+		if err := rubato.linearLayer(); err != nil {
+			return nil, err
+		}
 		rubato.feistel()
 		rubato.addRoundKey(r, false)
 	}
-	rubato.linearLayer()
+	if err := rubato.linearLayer(); err != nil {
+		return nil, err
+	}
 	rubato.feistel()
-	rubato.finLinLayer()
+	if err := rubato.finLinLayer(); err != nil {
+		return nil, err
+	}
 	rubato.finAddRoundKey(rubato.blocksize - 4)
-	return rubato.stCt
+	return rubato.stCt, nil
 }
 
 // CryptAutoModSwitch Compute ciphertexts with automatic modulus switching
-func (rubato *mfvRubato) CryptAutoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) ([]*Ciphertext, []int) {
+func (rubato *mfvRubato) CryptAutoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext, noiseEstimator MFVNoiseEstimator) ([]*Ciphertext, []int, error) {
+	if err := rubato.checkInputs(nonce, kCt); err != nil {
+		return nil, nil, err
+	}
 	rubatoModDown := make([]int, rubato.numRound+1)
 	rubatoModDown[0] = rubato.nbInitModDown
 	for i := 0; i < rubato.blocksize; i++ {
 		rubato.mkCt[i] = kCt[i].CopyNew().Ciphertext()
 	}
-	rubato.init(nonce, counter)
+	if err := rubato.init(nonce, counter); err != nil {
+		return nil, nil, err
+	}
 
 	rubato.addRoundKey(0, false)
 	for r := 1; r < rubato.numRound; r++ {
-		rubato.linearLayer()
+		if err := rubato.linearLayer(); err != nil {
+			return nil, nil, err
+		}
 		rubato.feistel()
 		rubato.modSwitchAuto(r, noiseEstimator, rubatoModDown)
 		rubato.addRoundKey(r, false)
 	}
-	rubato.linearLayer()
+	if err := rubato.linearLayer(); err != nil {
+		return nil, nil, err
+	}
 	rubato.feistel()
 	rubato.modSwitchAuto(rubato.numRound, noiseEstimator, rubatoModDown)
-	rubato.finLinLayer()
+	if err := rubato.finLinLayer(); err != nil {
+		return nil, nil, err
+	}
 	rubato.finAddRoundKey(rubato.blocksize - 4)
-	return rubato.stCt, rubatoModDown
+	return rubato.stCt, rubatoModDown, nil
 }
 
 // Crypt compute ciphertexts with modulus switching as given in rubatoModDown
-// using the homomorphically encrypted secret key `kCt`, `nonce`, `counter`
-func (rubato *mfvRubato) Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, rubatoModDown []int) []*Ciphertext {
-	if rubatoModDown[0] != rubato.nbInitModDown {
-		errorString := fmt.Sprintf("nbInitModDown expected %d but %d given", rubato.nbInitModDown, rubatoModDown[0])
-		panic(errorString)
+// using the homomorphically encrypted secret key `kCt`, `nonce`, `counter`. It returns an
+// ErrParamMismatch error if rubatoModDown doesn't start with the initial mod downs of the state or
+// doesn't have one entry per round, an ErrInsufficientLevels error if it drops more levels than the
+// state has.
+func (rubato *mfvRubato) Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, rubatoModDown []int) ([]*Ciphertext, error) {
+	if err := checkModDown(rubatoModDown, rubato.numRound, rubato.nbInitModDown, rubato.stCt[0].Level()); err != nil {
+		return nil, err
+	}
+	if err := rubato.checkInputs(nonce, kCt); err != nil {
+		return nil, err
 	}
 
 	for i := 0; i < rubato.blocksize; i++ {
 		rubato.mkCt[i] = kCt[i].CopyNew().Ciphertext()
 	}
-	rubato.init(nonce, counter)
+	if err := rubato.init(nonce, counter); err != nil {
+		return nil, err
+	}
 
 	rubato.addRoundKey(0, false)
+	rubato.trace.call(0, rubato.stCt)
 	for r := 1; r < rubato.numRound; r++ {
-		rubato.linearLayer()
+		if err := rubato.linearLayer(); err != nil {
+			return nil, err
+		}
 		rubato.feistel()
 		rubato.modSwitch(rubatoModDown[r])
+		rubato.trace.call(r, rubato.stCt)
 		rubato.addRoundKey(r, false)
 	}
-	rubato.linearLayer()
+	if err := rubato.linearLayer(); err != nil {
+		return nil, err
+	}
 	rubato.feistel()
 	rubato.modSwitch(rubatoModDown[rubato.numRound])
-	rubato.finLinLayer()
+	rubato.trace.call(rubato.numRound, rubato.stCt)
+	if err := rubato.finLinLayer(); err != nil {
+		return nil, err
+	}
 	rubato.finAddRoundKey(rubato.blocksize - 4)
-	return rubato.stCt
+	return rubato.stCt, nil
+}
+
+func (rubato *mfvRubato) setTrace(trace roundTrace) {
+	rubato.trace = trace
 }
 
 func (rubato *mfvRubato) addRoundKey(round int, reduce bool) {
@@ -373,7 +453,7 @@ func (rubato *mfvRubato) finAddRoundKey(outputSize int) {
 	}
 }
 
-func (rubato *mfvRubato) linearLayer() {
+func (rubato *mfvRubato) linearLayer() error {
 	ev := rubato.evaluator
 	buf := make([]*Ciphertext, rubato.blocksize)
 
@@ -523,11 +603,12 @@ func (rubato *mfvRubato) linearLayer() {
 			}
 		}
 	} else {
-		panic("Invalid block size!")
+		return fmt.Errorf("%w: invalid Rubato block size %d", ErrParamMismatch, rubato.blocksize)
 	}
+	return nil
 }
 
-func (rubato *mfvRubato) finLinLayer() {
+func (rubato *mfvRubato) finLinLayer() error {
 	ev := rubato.evaluator
 	buf := make([]*Ciphertext, rubato.blocksize)
 
@@ -735,8 +816,9 @@ func (rubato *mfvRubato) finLinLayer() {
 			}
 		}
 	} else {
-		panic("Invalid blocksize")
+		return fmt.Errorf("%w: invalid Rubato block size %d", ErrParamMismatch, rubato.blocksize)
 	}
+	return nil
 }
 
 func (rubato *mfvRubato) feistel() {
@@ -748,7 +830,12 @@ func (rubato *mfvRubato) feistel() {
 	}
 }
 
-func (rubato *mfvRubato) EncKey(key []uint64) (res []*Ciphertext) {
+// EncKey encrypts each word of the key in all the slots, it returns an ErrParamMismatch error if the
+// key is shorter than the block size
+func (rubato *mfvRubato) EncKey(key []uint64) (res []*Ciphertext, err error) {
+	if len(key) < rubato.blocksize {
+		return nil, fmt.Errorf("%w: key of %d words, the block size is %d", ErrParamMismatch, len(key), rubato.blocksize)
+	}
 	slots := rubato.slots
 	res = make([]*Ciphertext, rubato.blocksize)
 
@@ -760,10 +847,12 @@ func (rubato *mfvRubato) EncKey(key []uint64) (res []*Ciphertext) {
 
 		keyPt := NewPlaintextFV(rubato.params)
 		rubato.encoder.EncodeUintSmall(dupKey, keyPt)
-		res[i] = rubato.encryptor.EncryptNew(keyPt)
+		if res[i], err = rubato.encryptor.EncryptNew(keyPt); err != nil {
+			return nil, err
+		}
 		if rubato.nbInitModDown > 0 {
 			rubato.evaluator.ModSwitchMany(res[i], res[i], rubato.nbInitModDown)
 		}
 	}
-	return
+	return res, nil
 }
@@ -0,0 +1,527 @@
+package RtF
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"flhhe/src/RtF/ring"
+
+	"github.com/tuneinsight/lattigo/v6/utils/sampling"
+	"golang.org/x/crypto/sha3"
+)
+
+var rubatoKATFile = filepath.Join("testdata", "rubato_kat.json")
+
+// rubatoKAT a known-answer test vector of PlainRubato, the key is (1, ..., blocksize)
+type rubatoKAT struct {
+	Name      string   `json:"name"`
+	Nonce     string   `json:"nonce"`     // hex
+	Counter   string   `json:"counter"`   // hex
+	Seed      string   `json:"seed"`      // hex, key of the PRNG of the Gaussian noise
+	Noiseless []uint64 `json:"noiseless"` // keystream with sigma = 0
+	Noisy     []uint64 `json:"noisy"`     // keystream with the sigma of the parameter set
+}
+
+func rubatoTestKey(blocksize int) []uint64 {
+	key := make([]uint64, blocksize)
+	for i := range key {
+		key[i] = uint64(i + 1)
+	}
+	return key
+}
+
+// loadRubatoKATs reads the vectors of testdata/rubato_kat.json, one per parameter set of RubatoParams
+func loadRubatoKATs(t *testing.T) []rubatoKAT {
+	data, err := os.ReadFile(rubatoKATFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kats []rubatoKAT
+	if err = json.Unmarshal(data, &kats); err != nil {
+		t.Fatal(err)
+	}
+	if len(kats) != len(RubatoParams) {
+		t.Fatalf("got %d vectors, want one per parameter set (%d)", len(kats), len(RubatoParams))
+	}
+	for i, p := range RubatoParams {
+		if kats[i].Name != p.Name {
+			t.Fatalf("vector %d: got %s, want %s", i, kats[i].Name, p.Name)
+		}
+	}
+	return kats
+}
+
+// decode returns the nonce, the counter and the seed of the vector
+func (kat *rubatoKAT) decode(t *testing.T) (nonce, counter, seed []byte) {
+	var err error
+	if nonce, err = hex.DecodeString(kat.Nonce); err != nil {
+		t.Fatal(err)
+	}
+	if counter, err = hex.DecodeString(kat.Counter); err != nil {
+		t.Fatal(err)
+	}
+	if seed, err = hex.DecodeString(kat.Seed); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// rubatoCirculants the first rows of the circulant matrices of MixColumns and MixRows in the Rubato
+// specification, by the side of the square state
+var rubatoCirculants = map[int][]uint64{
+	4: {2, 3, 1, 1},
+	6: {4, 2, 4, 3, 1, 1},
+	8: {5, 3, 4, 3, 6, 2, 1, 1},
+}
+
+// rubatoSpec the noiseless keystream of Rubato written from its specification, independently of
+// PlainRubato: the state is a v x v matrix X in row-major order, the linear layer is M*X*M^T for the
+// circulant M, the round keys are the key times constants drawn from SHAKE256(nonce || counter) by
+// rejection sampling of little-endian integers of the bit length of q-1
+func rubatoSpec(p RubatoParam, nonce, counter []byte, key []uint64) []uint64 {
+	q := p.PlainModulus
+	v := int(math.Sqrt(float64(p.Blocksize)))
+	row := rubatoCirculants[v]
+	m := func(i, j int) uint64 { return row[((j-i)%v+v)%v] }
+	mulMod := func(a, b uint64) uint64 {
+		hi, lo := bits.Mul64(a, b)
+		_, rem := bits.Div64(hi%q, lo, q)
+		return rem
+	}
+
+	xof := sha3.NewShake256()
+	xof.Write(nonce)
+	xof.Write(counter)
+	bitLen := bits.Len64(q - 1)
+	buf := make([]byte, 8)
+	constant := func() uint64 {
+		for {
+			if _, err := xof.Read(buf[:(bitLen+7)/8]); err != nil {
+				panic(err)
+			}
+			if c := binary.LittleEndian.Uint64(buf) & (1<<bitLen - 1); c < q {
+				return c
+			}
+		}
+	}
+	roundKeys := make([][]uint64, p.NumRound+1)
+	for r := range roundKeys {
+		roundKeys[r] = make([]uint64, p.Blocksize)
+		for i := range roundKeys[r] {
+			roundKeys[r][i] = mulMod(constant(), key[i])
+		}
+	}
+
+	state := make([]uint64, p.Blocksize)
+	for i := range state {
+		state[i] = uint64(i + 1)
+	}
+	addRoundKey := func(r int) {
+		for i := range state {
+			state[i] = (state[i] + roundKeys[r][i]) % q
+		}
+	}
+	linear := func() {
+		mx := make([]uint64, p.Blocksize)
+		for i := 0; i < v; i++ {
+			for j := 0; j < v; j++ {
+				for k := 0; k < v; k++ {
+					mx[i*v+j] = (mx[i*v+j] + mulMod(m(i, k), state[k*v+j])) % q
+				}
+			}
+		}
+		for i := 0; i < v; i++ {
+			for j := 0; j < v; j++ {
+				state[i*v+j] = 0
+				for k := 0; k < v; k++ {
+					state[i*v+j] = (state[i*v+j] + mulMod(mx[i*v+k], m(j, k))) % q
+				}
+			}
+		}
+	}
+	feistel := func() {
+		for i := p.Blocksize - 1; i > 0; i-- {
+			state[i] = (state[i] + mulMod(state[i-1], state[i-1])) % q
+		}
+	}
+
+	addRoundKey(0)
+	for r := 1; r < p.NumRound; r++ {
+		linear()
+		feistel()
+		addRoundKey(r)
+	}
+	linear()
+	feistel()
+	linear()
+	addRoundKey(p.NumRound)
+	return state[:p.Blocksize-4]
+}
+
+// TestRubatoKAT checks the vectors of testdata/rubato_kat.json: the noiseless keystream against the one
+// written from the specification and against PlainRubato, the noisy one against PlainRubato with the
+// seeded noise, whose difference to the noiseless one is within the bound of the noise
+func TestRubatoKAT(t *testing.T) {
+	kats := loadRubatoKATs(t)
+	for i, p := range RubatoParams {
+		kat := kats[i]
+		nonce, counter, seed := kat.decode(t)
+		key := rubatoTestKey(p.Blocksize)
+		if len(kat.Noiseless) != p.Blocksize-4 || len(kat.Noisy) != p.Blocksize-4 {
+			t.Fatalf("%s: got %d and %d words, want %d", p.Name, len(kat.Noiseless), len(kat.Noisy), p.Blocksize-4)
+		}
+		if spec := rubatoSpec(p, nonce, counter, key); !reflect.DeepEqual(spec, kat.Noiseless) {
+			t.Errorf("%s noiseless keystream of the specification:\ngot  %v\nwant %v", p.Name, spec, kat.Noiseless)
+		}
+
+		prng, err := sampling.NewKeyedPRNG(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		noiseless, err := PlainRubatoWithPRNG(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, 0, prng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(noiseless, kat.Noiseless) {
+			t.Errorf("%s noiseless keystream:\ngot  %v\nwant %v", p.Name, noiseless, kat.Noiseless)
+		}
+		noisy, err := PlainRubatoWithPRNG(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, p.Sigma, prng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(noisy, kat.Noisy) {
+			t.Errorf("%s noisy keystream:\ngot  %v\nwant %v", p.Name, noisy, kat.Noisy)
+		}
+		bound := uint64(6 * p.Sigma)
+		for j := range kat.Noisy {
+			if e := (kat.Noisy[j] + p.PlainModulus - kat.Noiseless[j] + bound) % p.PlainModulus; e > 2*bound {
+				t.Errorf("%s word %d: noise out of [-%d, %d]", p.Name, j, bound, bound)
+			}
+		}
+	}
+}
+
+func TestPlainRubatoWithPRNG(t *testing.T) {
+	p := RubatoParams[RUBATO128S]
+	key := rubatoTestKey(p.Blocksize)
+	nonce, counter := make([]byte, 8), make([]byte, 8)
+	rand.Read(nonce)
+
+	keystream := func(seed string, sigma float64) []uint64 {
+		prng, err := sampling.NewKeyedPRNG([]byte(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ks, err := PlainRubatoWithPRNG(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, sigma, prng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ks
+	}
+
+	if !reflect.DeepEqual(keystream("a", p.Sigma), keystream("a", p.Sigma)) {
+		t.Error("the keystream differs with the same seed")
+	}
+	if reflect.DeepEqual(keystream("a", p.Sigma), keystream("b", p.Sigma)) {
+		t.Error("the noise doesn't depend on the seed")
+	}
+	if !reflect.DeepEqual(keystream("a", 0), keystream("b", 0)) {
+		t.Error("the noiseless keystream depends on the seed")
+	}
+}
+
+// TestPlainRubatoConstantTime checks the noise of PlainRubatoConstantTime, the difference with the
+// noiseless keystream, against the moments of the discrete Gaussian of each parameter set
+func TestPlainRubatoConstantTime(t *testing.T) {
+	for _, p := range RubatoParams {
+		key := rubatoTestKey(p.Blocksize)
+		prng, err := sampling.NewKeyedPRNG([]byte(p.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bound := int64(6 * p.Sigma)
+		sampler := ring.NewCDTGaussianSamplerFromTable(prng, ring.NewCDTTable(p.Sigma, int(bound)))
+
+		var n, mean, sq float64
+		nonce, counter := make([]byte, 8), make([]byte, 8)
+		for i := 0; i < 1024; i++ {
+			nonce[0], nonce[1] = byte(i), byte(i>>8)
+			noiseless, err := PlainRubatoWithPRNG(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, 0, prng)
+			if err != nil {
+				t.Fatal(err)
+			}
+			noisy, err := PlainRubatoConstantTime(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, sampler, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := range noisy {
+				e := int64((noisy[j] + p.PlainModulus - noiseless[j]) % p.PlainModulus)
+				if e > int64(p.PlainModulus/2) {
+					e -= int64(p.PlainModulus)
+				}
+				if e < -bound || e > bound {
+					t.Fatalf("%s: noise %d out of [-%d, %d]", p.Name, e, bound, bound)
+				}
+				n++
+				mean += float64(e)
+				sq += float64(e * e)
+			}
+		}
+
+		// The variance of the truncated discrete Gaussian, close to sigma^2
+		var sum, variance float64
+		for k := -bound; k <= bound; k++ {
+			rho := math.Exp(-float64(k*k) / (2 * p.Sigma * p.Sigma))
+			sum += rho
+			variance += rho * float64(k*k)
+		}
+		variance /= sum
+		if mean /= n; math.Abs(mean) > 6*math.Sqrt(variance/n) {
+			t.Errorf("%s: noise mean %f, want 0", p.Name, mean)
+		}
+		if sq /= n; math.Abs(sq-variance) > 6*variance*math.Sqrt(2/n) {
+			t.Errorf("%s: noise variance %f, want %f", p.Name, sq, variance)
+		}
+
+		// The round constants drawn in constant time give another keystream than the specification
+		noiseless, err := PlainRubatoWithPRNG(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, 0, prng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, constantTimeRoundConstants := range []bool{false, true} {
+			ks, err := PlainRubatoConstantTime(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, nil, constantTimeRoundConstants)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.DeepEqual(ks, noiseless) == constantTimeRoundConstants {
+				t.Errorf("%s: the noiseless keystream with constant-time round constants %t is equal to the specification: %t",
+					p.Name, constantTimeRoundConstants, !constantTimeRoundConstants)
+			}
+		}
+	}
+}
+
+// TestMFVRubatoConstantTime evaluates the keystream of a cipher with constant-time round constants
+// homomorphically on the toy ring, and compares every decrypted slot with the noiseless
+// PlainRubatoConstantTime
+func TestMFVRubatoConstantTime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the homomorphic evaluation in short mode")
+	}
+	p := RubatoParams[RUBATO128L]
+	cipher := WithConstantTimeRoundConstants(NewToyRubatoCipher(RUBATO128L, RtFToyN10))
+	params, err := cipher.HalfBootParams().Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+	params.SetLogFVSlots(params.LogN())
+
+	kgen := NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPairSparse(cipher.HalfBootParams().H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := NewMFVEncoder(params)
+	decryptor := NewMFVDecryptor(params, sk)
+	evaluator := NewMFVEvaluator(params, EvaluationKey{Rlk: rlk}, nil)
+	nonces := make([][]byte, params.FVSlots())
+	for i := range nonces {
+		nonces[i] = make([]byte, 8)
+		rand.Read(nonces[i])
+	}
+	counter := make([]byte, 8)
+	rand.Read(counter)
+
+	key := rubatoTestKey(p.Blocksize)
+	modDown := cipher.ModDownParams()
+	mfvCipher, err := cipher.NewMFVCipher(params, encoder, NewMFVEncryptorFromPk(params, pk), evaluator, modDown.CipherModDown[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kCt, err := mfvCipher.EncKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystream, err := mfvCipher.Crypt(nonces, counter, kCt, modDown.CipherModDown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := make([][]uint64, p.Blocksize-4)
+	for i := range have {
+		have[i] = encoder.DecodeUintSmallNew(decryptor.DecryptNew(keystream[i]))
+	}
+	for slot := range nonces {
+		want, err := PlainRubatoConstantTime(p.Blocksize, p.NumRound, nonces[slot], counter, key, p.PlainModulus, nil, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if have[i][slot] != want[i] {
+				t.Fatalf("slot %d, word %d: got %d, want %d", slot, i, have[i][slot], want[i])
+			}
+		}
+	}
+}
+
+// TestMFVRubato evaluates every Rubato parameter set homomorphically on a small ring and compares the
+// decrypted keystream with the noiseless PlainRubato, word for word and slot by slot
+func TestMFVRubato(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the homomorphic evaluation in short mode")
+	}
+	nonces := make([][]byte, DefaultParams[PN14QP438].N())
+	for i := range nonces {
+		nonces[i] = make([]byte, 8)
+		rand.Read(nonces[i])
+	}
+	counter := make([]byte, 8)
+	rand.Read(counter)
+
+	for rubatoParam, p := range RubatoParams {
+		t.Run(p.Name, func(t *testing.T) {
+			params := DefaultParams[PN14QP438].WithPlainModulus(p.PlainModulus)
+			params.SetLogFVSlots(params.LogN())
+
+			kgen := NewKeyGenerator(params)
+			sk, pk, err := kgen.GenKeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rlk, err := kgen.GenRelinearizationKey(sk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoder := NewMFVEncoder(params)
+			decryptor := NewMFVDecryptor(params, sk)
+			evaluator := NewMFVEvaluator(params, EvaluationKey{Rlk: rlk}, nil)
+
+			key := rubatoTestKey(p.Blocksize)
+			rubato, err := NewMFVRubato(rubatoParam, params, encoder, NewMFVEncryptorFromPk(params, pk), evaluator, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kCt, err := rubato.EncKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keystreamCt, err := rubato.CryptNoModSwitch(nonces, counter, kCt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			have := make([][]uint64, p.Blocksize-4)
+			for i := range have {
+				have[i] = encoder.DecodeUintSmallNew(decryptor.DecryptNew(keystreamCt[i]))
+			}
+			prng, err := sampling.NewPRNG()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for slot := range nonces {
+				want, err := PlainRubatoWithPRNG(p.Blocksize, p.NumRound, nonces[slot], counter, key, p.PlainModulus, 0, prng)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := range want {
+					if have[i][slot] != want[i] {
+						t.Fatalf("slot %d, word %d: got %d, want %d", slot, i, have[i][slot], want[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestMFVRubatoKAT evaluates the vectors of testdata/rubato_kat.json homomorphically on the toy ring of
+// each Rubato parameter set, with the mod downs of its schedule (Crypt) and with the ones found by the
+// key-based noise estimator (CryptAutoModSwitch), and compares every decrypted slot with the noiseless
+// keystream
+func TestMFVRubatoKAT(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the homomorphic evaluation in short mode")
+	}
+	kats := loadRubatoKATs(t)
+	for rubatoParam, p := range RubatoParams {
+		t.Run(p.Name, func(t *testing.T) {
+			kat := kats[rubatoParam]
+			nonce, counter, _ := kat.decode(t)
+			cipher := NewToyRubatoCipher(rubatoParam, RtFToyN10)
+			params, err := cipher.HalfBootParams().Params()
+			if err != nil {
+				t.Fatal(err)
+			}
+			params.SetPlainModulus(cipher.PlainModulus())
+			params.SetLogFVSlots(params.LogN())
+
+			kgen := NewKeyGenerator(params)
+			sk, pk, err := kgen.GenKeyPairSparse(cipher.HalfBootParams().H)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rlk, err := kgen.GenRelinearizationKey(sk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoder := NewMFVEncoder(params)
+			encryptor := NewMFVEncryptorFromPk(params, pk)
+			decryptor := NewMFVDecryptor(params, sk)
+			evaluator := NewMFVEvaluator(params, EvaluationKey{Rlk: rlk}, nil)
+			nonces := make([][]byte, params.FVSlots())
+			for i := range nonces {
+				nonces[i] = nonce
+			}
+			modDown := cipher.ModDownParams()
+
+			check := func(name string, keystream []*Ciphertext) {
+				// the state keeps the 4 words truncated from the keystream
+				if len(keystream) < len(kat.Noiseless) {
+					t.Fatalf("%s: got %d words, want %d", name, len(keystream), len(kat.Noiseless))
+				}
+				for i, ct := range keystream[:len(kat.Noiseless)] {
+					for slot, z := range encoder.DecodeUintSmallNew(decryptor.DecryptNew(ct)) {
+						if z != kat.Noiseless[i] {
+							t.Fatalf("%s: slot %d, word %d: got %d, want %d", name, slot, i, z, kat.Noiseless[i])
+						}
+					}
+				}
+			}
+
+			mfvCipher, err := cipher.NewMFVCipher(params, encoder, encryptor, evaluator, modDown.CipherModDown[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			kCt, err := mfvCipher.EncKey(rubatoTestKey(p.Blocksize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			keystream, err := mfvCipher.Crypt(nonces, counter, kCt, modDown.CipherModDown)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("Crypt", keystream)
+
+			rubato, err := NewMFVRubato(rubatoParam, params, encoder, encryptor, evaluator, modDown.CipherModDown[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			keystream, _, err = rubato.CryptAutoModSwitch(nonces, counter, kCt, NewMFVNoiseEstimator(params, sk))
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("CryptAutoModSwitch", keystream)
+		})
+	}
+}
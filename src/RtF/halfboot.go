@@ -1,6 +1,7 @@
 package RtF
 
 import (
+	"fmt"
 	"math"
 
 	"flhhe/src/RtF/ring"
@@ -10,7 +11,14 @@ import (
 // If the input ciphertext level is zero, the input scale must be an exact power of two smaller or equal to round(Q0/2^{10}).
 // If the input ciphertext is at level one or more, the input scale does not need to be an exact power of two as one level
 // can be used to do a scale matching.
-func (hbtp *HalfBootstrapper) HalfBoot(ct *Ciphertext, repack bool) (ct0, ct1 *Ciphertext) {
+// It returns an ErrParamMismatch error if ct isn't a ciphertext of the CKKS parameters of the
+// half-bootstrapper, and an ErrInsufficientLevels error if its scale or the levels of the sine
+// evaluation don't fit.
+func (hbtp *HalfBootstrapper) HalfBoot(ct *Ciphertext, repack bool) (ct0, ct1 *Ciphertext, err error) {
+
+	if err = CheckCiphertext(hbtp.params, ct); err != nil {
+		return nil, nil, fmt.Errorf("cannot HalfBoot: %w", err)
+	}
 
 	//var t time.Time
 	// var ct0, ct1 *Ciphertext
@@ -41,7 +49,7 @@ func (hbtp *HalfBootstrapper) HalfBoot(ct *Ciphertext, repack bool) (ct0, ct1 *C
 		// and does an integer constant mult by round((Q0/Delta_m)/ctscle)
 
 		if hbtp.prescale < ct.Scale() {
-			panic("ciphetext scale > Q[0]/(Q[0]/Delta_m)")
+			return nil, nil, fmt.Errorf("cannot HalfBoot: %w: ciphertext scale %g > Q[0]/(Q[0]/Delta_m) = %g", ErrInsufficientLevels, ct.Scale(), hbtp.prescale)
 		}
 		hbtp.ckksEvaluator.ScaleUp(ct, math.Round(hbtp.prescale/ct.Scale()), ct)
 	}
@@ -70,31 +78,34 @@ func (hbtp *HalfBootstrapper) HalfBoot(ct *Ciphertext, repack bool) (ct0, ct1 *C
 	if repack {
 		hbtp.ckksEvaluator.Rotate(ct1, hbtp.params.Slots()/2, ct1)
 		hbtp.ckksEvaluator.Add(ct0, ct1, ct0)
-		ct0, _ = hbtp.evaluateSine(ct0, nil)
+		ct0, _, err = hbtp.evaluateSine(ct0, nil)
 		ct1 = nil
 	} else {
-		ct0, ct1 = hbtp.evaluateSine(ct0, ct1)
+		ct0, ct1, err = hbtp.evaluateSine(ct0, ct1)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot HalfBoot: %w: %w", ErrInsufficientLevels, err)
 	}
 	//log.Println("After Sine   :", time.Now().Sub(t), ct0.Level(), ct0.Scale())
 
 	// Part 3 : Fix scale using diffScaleAfterEvalSine
 	hbtp.ckksEvaluator.MultByConst(ct0, hbtp.diffScaleAfterSineEval, ct0)
-	if err := hbtp.ckksEvaluator.RescaleMany(ct0, 1, ct0); err != nil {
-		panic(err)
+	if err = hbtp.ckksEvaluator.RescaleMany(ct0, 1, ct0); err != nil {
+		return nil, nil, fmt.Errorf("cannot HalfBoot: %w: %w", ErrInsufficientLevels, err)
 	}
 	// Rounds to the nearest power of two
 	ct0.SetScale(math.Exp2(math.Round(math.Log2(ct0.Scale()))))
 
 	if ct1 != nil {
 		hbtp.ckksEvaluator.MultByConst(ct1, hbtp.diffScaleAfterSineEval, ct1)
-		if err := hbtp.ckksEvaluator.RescaleMany(ct1, 1, ct1); err != nil {
-			panic(err)
+		if err = hbtp.ckksEvaluator.RescaleMany(ct1, 1, ct1); err != nil {
+			return nil, nil, fmt.Errorf("cannot HalfBoot: %w: %w", ErrInsufficientLevels, err)
 		}
 		// Rounds to the nearest power of two
 		ct1.SetScale(math.Exp2(math.Round(math.Log2(ct1.Scale()))))
 	}
 
-	return ct0, ct1
+	return ct0, ct1, nil
 }
 
 func (hbtp *HalfBootstrapper) subSum(ct *Ciphertext) *Ciphertext {
@@ -175,28 +186,37 @@ func CoeffsToSlotsWithoutRepack(vec *Ciphertext, pDFTInv []*PtDiagMatrix, eval C
 }
 
 // Sine Evaluation ct0 = Q/(2pi) * sin((2pi/Q) * ct0)
-func (hbtp *HalfBootstrapper) evaluateSine(ct0, ct1 *Ciphertext) (*Ciphertext, *Ciphertext) {
+func (hbtp *HalfBootstrapper) evaluateSine(ct0, ct1 *Ciphertext) (*Ciphertext, *Ciphertext, error) {
+
+	var err error
 
 	ct0.MulScale(hbtp.MessageRatio)
+	scale := hbtp.ckksEvaluator.scale
 	hbtp.ckksEvaluator.scale = hbtp.sinescale // Reference scale is changed to the Qi used for the SineEval (which is also close to the new ciphetext scale)
 
-	ct0 = hbtp.evaluateCheby(ct0)
+	if ct0, err = hbtp.evaluateCheby(ct0); err != nil {
+		hbtp.ckksEvaluator.scale = scale
+		return nil, nil, err
+	}
 
 	ct0.DivScale(hbtp.MessageRatio * hbtp.postscale / hbtp.params.scale)
 
 	if ct1 != nil {
 		ct1.MulScale(hbtp.MessageRatio)
-		ct1 = hbtp.evaluateCheby(ct1)
+		if ct1, err = hbtp.evaluateCheby(ct1); err != nil {
+			hbtp.ckksEvaluator.scale = scale
+			return nil, nil, err
+		}
 		ct1.DivScale(hbtp.MessageRatio * hbtp.postscale / hbtp.params.scale)
 	}
 
 	// Reference scale is changed back to the current ciphertext's scale.
 	hbtp.ckksEvaluator.scale = ct0.Scale()
 
-	return ct0, ct1
+	return ct0, ct1, nil
 }
 
-func (hbtp *HalfBootstrapper) evaluateCheby(ct *Ciphertext) *Ciphertext {
+func (hbtp *HalfBootstrapper) evaluateCheby(ct *Ciphertext) (*Ciphertext, error) {
 
 	var err error
 
@@ -218,7 +238,7 @@ func (hbtp *HalfBootstrapper) evaluateCheby(ct *Ciphertext) *Ciphertext {
 
 	// Chebyshev evaluation
 	if ct, err = hbtp.EvaluateCheby(ct, cheby, targetScale); err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	// Double angle
@@ -228,17 +248,17 @@ func (hbtp *HalfBootstrapper) evaluateCheby(ct *Ciphertext) *Ciphertext {
 		hbtp.MulRelin(ct, ct, ct)
 		hbtp.Add(ct, ct, ct)
 		hbtp.AddConst(ct, -sqrt2pi, ct)
-		if err := hbtp.Rescale(ct, hbtp.ckksEvaluator.scale, ct); err != nil {
-			panic(err)
+		if err = hbtp.Rescale(ct, hbtp.ckksEvaluator.scale, ct); err != nil {
+			return nil, err
 		}
 	}
 
 	// ArcSine
 	if hbtp.ArcSineDeg > 0 {
 		if ct, err = hbtp.EvaluatePoly(ct, hbtp.arcSinePoly, ct.Scale()); err != nil {
-			panic(err)
+			return nil, err
 		}
 	}
 
-	return ct
+	return ct, nil
 }
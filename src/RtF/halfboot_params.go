@@ -1,8 +1,8 @@
 package RtF
 
 import (
+	"fmt"
 	"math"
-	//"fmt"
 )
 
 // HalfBootParameters is a struct for the default half-boot parameters
@@ -91,6 +91,45 @@ func (hb *HalfBootParameters) Copy() *HalfBootParameters {
 	return paramsCopy
 }
 
+// BootstrappingParams returns the parameters of a full bootstrapping on the moduli chain of hb, so that it
+// runs with the keys of HalfBoot and the rotation keys of GenRotationIndexesForBootstrapping. Its
+// SlotsToCoeffs consumes stcDepth moduli: the DiffScale modulus and the stcDepth-1 last residual moduli,
+// the bootstrapped ciphertexts are output at the level len(hb.ResidualModuli)-stcDepth.
+func (hb *HalfBootParameters) BootstrappingParams(stcDepth int) (*BootstrappingParameters, error) {
+	if stcDepth < 1 || stcDepth > len(hb.ResidualModuli) {
+		return nil, fmt.Errorf("the SlotsToCoeffs depth must be in [1, %d], got %d", len(hb.ResidualModuli), stcDepth)
+	}
+
+	hbCopy := hb.Copy()
+	nbResidual := len(hb.ResidualModuli) - stcDepth + 1
+	stc := append(hbCopy.ResidualModuli[nbResidual:], hbCopy.DiffScaleModulus...)
+
+	b := &BootstrappingParameters{
+		ResidualModuli:      hbCopy.ResidualModuli[:nbResidual],
+		KeySwitchModuli:     hbCopy.KeySwitchModuli,
+		SlotsToCoeffsModuli: SlotsToCoeffsModuli{Qi: stc, ScalingFactor: make([][]float64, len(stc))},
+		SineEvalModuli:      hbCopy.SineEvalModuli,
+		CoeffsToSlotsModuli: hbCopy.CoeffsToSlotsModuli,
+		LogN:                hb.LogN,
+		LogSlots:            hb.LogSlots,
+		t:                   hb.PlainModulus,
+		Scale:               hb.Scale,
+		Sigma:               hb.Sigma,
+		H:                   hb.H,
+		SinType:             hb.SinType,
+		MessageRatio:        hb.MessageRatio,
+		SinRange:            hb.SinRange,
+		SinDeg:              hb.SinDeg,
+		SinRescal:           hb.SinRescal,
+		ArcSineDeg:          hb.ArcSineDeg,
+		MaxN1N2Ratio:        hb.MaxN1N2Ratio,
+	}
+	for i, qi := range stc {
+		b.SlotsToCoeffsModuli.ScalingFactor[i] = []float64{float64(qi)}
+	}
+	return b, nil
+}
+
 // DiffScaleModulus is used to set scale after the SineEval step.
 type DiffScaleModulus []uint64
 
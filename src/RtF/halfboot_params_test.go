@@ -0,0 +1,82 @@
+package RtF
+
+import (
+	"math"
+	"testing"
+
+	"flhhe/src/utils"
+)
+
+// TestHalfBootBootstrappingParams bootstraps a ciphertext of the toy parameters with the keys of HalfBoot
+func TestHalfBootBootstrappingParams(t *testing.T) {
+	cipher := NewToyRubatoCipher(RUBATO128L, RtFToyN10)
+	hb := cipher.HalfBootParams()
+	params, err := hb.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+
+	for _, stcDepth := range []int{0, len(hb.ResidualModuli) + 1} {
+		if _, err := hb.BootstrappingParams(stcDepth); err == nil {
+			t.Errorf("no error for a SlotsToCoeffs depth of %d", stcDepth)
+		}
+	}
+	stcDepth := 3
+	btpParams, err := hb.BootstrappingParams(stcDepth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	btpChain, err := btpParams.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	btpChain.SetPlainModulus(cipher.PlainModulus())
+	if !btpChain.Equals(params) {
+		t.Fatal("the bootstrapping parameters don't have the moduli chain of HalfBoot")
+	}
+
+	kgen := NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPairSparse(hb.H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotKeys, err := kgen.GenRotationKeysForRotations(kgen.GenRotationIndexesForBootstrapping(params.LogSlots(), btpParams), true, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	btpKey := BootstrappingKey{Rlk: rlk, Rtks: rotKeys}
+	btp, err := NewBootstrapper(params, btpParams, btpKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoder := NewCKKSEncoder(params)
+	values := make([]complex128, params.Slots())
+	for i := range values {
+		values[i] = complex(utils.RandFloat64(-1, 1), 0)
+	}
+	pt := NewPlaintextCKKS(params, 0, params.Scale())
+	encoder.EncodeComplex(pt, values, params.LogSlots())
+	ct, err := NewCKKSEncryptorFromPk(params, pk).EncryptNew(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct = btp.Bootstrapp(ct)
+
+	if want := len(hb.ResidualModuli) - stcDepth; ct.Level() != want || ct.Scale() != params.Scale() {
+		t.Errorf("got level %d and scale 2^%.2f, want %d and 2^%.2f", ct.Level(), math.Log2(ct.Scale()), want, math.Log2(params.Scale()))
+	}
+	stats, err := GetPrecisionStats(params, encoder, NewCKKSDecryptor(params, sk), values, ct, params.LogSlots(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("mean precision %.2f, min precision %.2f", real(stats.MeanPrecision), real(stats.MinPrecision))
+	if real(stats.MeanPrecision) < 16 || real(stats.MinPrecision) < 13 {
+		t.Errorf("precision (min %.2f, mean %.2f) below (13, 16)", real(stats.MinPrecision), real(stats.MeanPrecision))
+	}
+}
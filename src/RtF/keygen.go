@@ -13,20 +13,20 @@ import (
 
 // KeyGenerator is an interface implementing the methods of the KeyGenerator.
 type KeyGenerator interface {
-	GenSecretKey() (sk *SecretKey)
-	GenSecretKeyGaussian() (sk *SecretKey)
-	GenSecretKeyWithDistrib(p float64) (sk *SecretKey)
-	GenSecretKeySparse(hw int) (sk *SecretKey)
-	GenPublicKey(sk *SecretKey) (pk *PublicKey)
-	GenKeyPair() (sk *SecretKey, pk *PublicKey)
-	GenKeyPairSparse(hw int) (sk *SecretKey, pk *PublicKey)
-	GenSwitchingKey(skInput, skOutput *SecretKey) (newevakey *SwitchingKey)
-	GenRelinearizationKey(sk *SecretKey) (evakey *RelinearizationKey)
-	GenSwitchingKeyForGalois(galEl uint64, sk *SecretKey) (swk *SwitchingKey)
+	GenSecretKey() (sk *SecretKey, err error)
+	GenSecretKeyGaussian() (sk *SecretKey, err error)
+	GenSecretKeyWithDistrib(p float64) (sk *SecretKey, err error)
+	GenSecretKeySparse(hw int) (sk *SecretKey, err error)
+	GenPublicKey(sk *SecretKey) (pk *PublicKey, err error)
+	GenKeyPair() (sk *SecretKey, pk *PublicKey, err error)
+	GenKeyPairSparse(hw int) (sk *SecretKey, pk *PublicKey, err error)
+	GenSwitchingKey(skInput, skOutput *SecretKey) (newevakey *SwitchingKey, err error)
+	GenRelinearizationKey(sk *SecretKey) (evakey *RelinearizationKey, err error)
+	GenSwitchingKeyForGalois(galEl uint64, sk *SecretKey) (swk *SwitchingKey, err error)
 
-	GenRotationKeys(galEls []uint64, sk *SecretKey) (rks *RotationKeySet)
+	GenRotationKeys(galEls []uint64, sk *SecretKey) (rks *RotationKeySet, err error)
 
-	GenRotationKeysForRotations(ks []int, includeConjugate bool, sk *SecretKey) (rks *RotationKeySet)
+	GenRotationKeysForRotations(ks []int, includeConjugate bool, sk *SecretKey) (rks *RotationKeySet, err error)
 
 	GenRotationIndexesForBootstrapping(logSlots int, btpParams *BootstrappingParameters) []int
 	GenRotationIndexesForHalfBoot(logSlots int, hbtpParams *HalfBootParameters) []int
@@ -46,6 +46,7 @@ type keyGenerator struct {
 	ringQP          *ring.Ring
 	pBigInt         *big.Int
 	polypool        [2]*ring.Poly
+	prng            sampling.PRNG
 	gaussianSampler *ring.GaussianSampler
 	uniformSampler  *ring.UniformSampler
 }
@@ -53,6 +54,12 @@ type keyGenerator struct {
 // NewKeyGenerator creates a new KeyGenerator, from which the secret and public keys, as well as the evaluation,
 // rotation and switching keys can be generated.
 func NewKeyGenerator(params *Parameters) KeyGenerator {
+	return NewKeyGeneratorWithPRNG(params, newKeyPRNG())
+}
+
+// NewKeyGeneratorWithPRNG creates a new KeyGenerator drawing all its randomness from prng, a keyed PRNG
+// (NewSeededPRNG) makes the keys deterministic.
+func NewKeyGeneratorWithPRNG(params *Parameters, prng sampling.PRNG) KeyGenerator {
 
 	var qp *ring.Ring
 	var err error
@@ -68,64 +75,58 @@ func NewKeyGenerator(params *Parameters) KeyGenerator {
 		}
 	}
 
-	prng, err := sampling.NewPRNG()
-	if err != nil {
-		panic(err)
-	}
-
 	return &keyGenerator{
 		params:          params.Copy(),
 		ringQP:          qp,
 		pBigInt:         pBigInt,
 		polypool:        [2]*ring.Poly{qp.NewPoly(), qp.NewPoly()},
+		prng:            prng,
 		gaussianSampler: ring.NewGaussianSampler(prng),
 		uniformSampler:  ring.NewUniformSampler(prng, qp),
 	}
 }
 
 // GenSecretKey generates a new SecretKey with the distribution [1/3, 1/3, 1/3].
-func (keygen *keyGenerator) GenSecretKey() (sk *SecretKey) {
+func (keygen *keyGenerator) GenSecretKey() (sk *SecretKey, err error) {
 	return keygen.GenSecretKeyWithDistrib(1.0 / 3)
 }
 
-func (keygen *keyGenerator) GenSecretKeyGaussian() (sk *SecretKey) {
+func (keygen *keyGenerator) GenSecretKeyGaussian() (sk *SecretKey, err error) {
 	sk = new(SecretKey)
 
-	sk.Value = keygen.gaussianSampler.ReadNew(keygen.ringQP, keygen.params.sigma, int(6*keygen.params.sigma))
+	if sk.Value, err = keygen.gaussianSampler.ReadNew(keygen.ringQP, keygen.params.sigma, int(6*keygen.params.sigma)); err != nil {
+		return nil, err
+	}
 	keygen.ringQP.NTT(sk.Value, sk.Value)
-	return sk
+	return sk, nil
 }
 
 // GenSecretKeyWithDistrib generates a new SecretKey with the distribution [(p-1)/2, p, (p-1)/2].
-func (keygen *keyGenerator) GenSecretKeyWithDistrib(p float64) (sk *SecretKey) {
-	prng, err := sampling.NewPRNG()
-	if err != nil {
-		panic(err)
-	}
-	ternarySamplerMontgomery := ring.NewTernarySampler(prng, keygen.ringQP, p, true)
+func (keygen *keyGenerator) GenSecretKeyWithDistrib(p float64) (sk *SecretKey, err error) {
+	ternarySamplerMontgomery := ring.NewTernarySampler(keygen.prng, keygen.ringQP, p, true)
 
 	sk = new(SecretKey)
-	sk.Value = ternarySamplerMontgomery.ReadNew()
+	if sk.Value, err = ternarySamplerMontgomery.ReadNew(); err != nil {
+		return nil, err
+	}
 	keygen.ringQP.NTT(sk.Value, sk.Value)
-	return sk
+	return sk, nil
 }
 
 // GenSecretKeySparse generates a new SecretKey with exactly hw non-zero coefficients.
-func (keygen *keyGenerator) GenSecretKeySparse(hw int) (sk *SecretKey) {
-	prng, err := sampling.NewPRNG()
-	if err != nil {
-		panic(err)
-	}
-	ternarySamplerMontgomery := ring.NewTernarySamplerSparse(prng, keygen.ringQP, hw, true)
+func (keygen *keyGenerator) GenSecretKeySparse(hw int) (sk *SecretKey, err error) {
+	ternarySamplerMontgomery := ring.NewTernarySamplerSparse(keygen.prng, keygen.ringQP, hw, true)
 
 	sk = new(SecretKey)
-	sk.Value = ternarySamplerMontgomery.ReadNew()
+	if sk.Value, err = ternarySamplerMontgomery.ReadNew(); err != nil {
+		return nil, err
+	}
 	keygen.ringQP.NTT(sk.Value, sk.Value)
-	return sk
+	return sk, nil
 }
 
 // GenPublicKey generates a new public key from the provided SecretKey.
-func (keygen *keyGenerator) GenPublicKey(sk *SecretKey) (pk *PublicKey) {
+func (keygen *keyGenerator) GenPublicKey(sk *SecretKey) (pk *PublicKey, err error) {
 
 	pk = new(PublicKey)
 
@@ -134,29 +135,43 @@ func (keygen *keyGenerator) GenPublicKey(sk *SecretKey) (pk *PublicKey) {
 	//pk[0] = [-(a*s + e)]
 	//pk[1] = [a]
 
-	pk.Value[0] = keygen.gaussianSampler.ReadNew(keygen.ringQP, keygen.params.sigma, int(6*keygen.params.sigma))
+	if pk.Value[0], err = keygen.gaussianSampler.ReadNew(keygen.ringQP, keygen.params.sigma, int(6*keygen.params.sigma)); err != nil {
+		return nil, err
+	}
 	ringQP.NTT(pk.Value[0], pk.Value[0])
-	pk.Value[1] = keygen.uniformSampler.ReadNew()
+	if pk.Value[1], err = keygen.uniformSampler.ReadNew(); err != nil {
+		return nil, err
+	}
 
 	ringQP.MulCoeffsMontgomeryAndSub(sk.Value, pk.Value[1], pk.Value[0])
 
-	return pk
+	return pk, nil
 }
 
 // GenKeyPair generates a new SecretKey with distribution [1/3, 1/3, 1/3] and a corresponding public key.
-func (keygen *keyGenerator) GenKeyPair() (sk *SecretKey, pk *PublicKey) {
-	sk = keygen.GenSecretKey()
-	return sk, keygen.GenPublicKey(sk)
+func (keygen *keyGenerator) GenKeyPair() (sk *SecretKey, pk *PublicKey, err error) {
+	if sk, err = keygen.GenSecretKey(); err != nil {
+		return nil, nil, err
+	}
+	if pk, err = keygen.GenPublicKey(sk); err != nil {
+		return nil, nil, err
+	}
+	return sk, pk, nil
 }
 
 // GenKeyPairSparse generates a new SecretKey with exactly hw non zero coefficients [1/2, 0, 1/2].
-func (keygen *keyGenerator) GenKeyPairSparse(hw int) (sk *SecretKey, pk *PublicKey) {
-	sk = keygen.GenSecretKeySparse(hw)
-	return sk, keygen.GenPublicKey(sk)
+func (keygen *keyGenerator) GenKeyPairSparse(hw int) (sk *SecretKey, pk *PublicKey, err error) {
+	if sk, err = keygen.GenSecretKeySparse(hw); err != nil {
+		return nil, nil, err
+	}
+	if pk, err = keygen.GenPublicKey(sk); err != nil {
+		return nil, nil, err
+	}
+	return sk, pk, nil
 }
 
 // GenRelinKey generates a new EvaluationKey that will be used to relinearize Ciphertexts during multiplication.
-func (keygen *keyGenerator) GenRelinearizationKey(sk *SecretKey) (rlk *RelinearizationKey) {
+func (keygen *keyGenerator) GenRelinearizationKey(sk *SecretKey) (rlk *RelinearizationKey, err error) {
 
 	if len(keygen.params.pi) == 0 {
 		panic("Cannot GenRelinKey: modulus P is empty")
@@ -165,14 +180,16 @@ func (keygen *keyGenerator) GenRelinearizationKey(sk *SecretKey) (rlk *Relineari
 	rlk = NewRelinearizationKey(keygen.params)
 	keygen.ringQP.MulCoeffsMontgomery(sk.Value, sk.Value, keygen.polypool[0])
 	rlk.Keys[0] = &NewSwitchingKey(keygen.params).SwitchingKey
-	keygen.newSwitchingKey(keygen.polypool[0], sk.Value, rlk.Keys[0])
+	err = keygen.newSwitchingKey(keygen.polypool[0], sk.Value, rlk.Keys[0])
 	keygen.polypool[0].Zero()
-
-	return
+	if err != nil {
+		return nil, err
+	}
+	return rlk, nil
 }
 
 // GenSwitchingKey generates a new key-switching key, that will re-encrypt a Ciphertext encrypted under the input key into the output key.
-func (keygen *keyGenerator) GenSwitchingKey(skInput, skOutput *SecretKey) (newevakey *SwitchingKey) {
+func (keygen *keyGenerator) GenSwitchingKey(skInput, skOutput *SecretKey) (newevakey *SwitchingKey, err error) {
 
 	if len(keygen.params.pi) == 0 {
 		panic("Cannot GenSwitchingKey: modulus P is empty")
@@ -180,31 +197,40 @@ func (keygen *keyGenerator) GenSwitchingKey(skInput, skOutput *SecretKey) (newev
 
 	keygen.ringQP.Copy(skInput.Value, keygen.polypool[0])
 	newevakey = NewSwitchingKey(keygen.params)
-	keygen.newSwitchingKey(keygen.polypool[0], skOutput.Value, &newevakey.SwitchingKey)
+	err = keygen.newSwitchingKey(keygen.polypool[0], skOutput.Value, &newevakey.SwitchingKey)
 	keygen.polypool[0].Zero()
-	return
+	if err != nil {
+		return nil, err
+	}
+	return newevakey, nil
 }
 
-func (keygen *keyGenerator) GenSwitchingKeyForGalois(galoisEl uint64, sk *SecretKey) (swk *SwitchingKey) {
+func (keygen *keyGenerator) GenSwitchingKeyForGalois(galoisEl uint64, sk *SecretKey) (swk *SwitchingKey, err error) {
 	swk = NewSwitchingKey(keygen.params)
-	keygen.genrotKey(sk.Value, keygen.params.InverseGaloisElement(galoisEl), &swk.SwitchingKey)
-	return
+	if err = keygen.genrotKey(sk.Value, keygen.params.InverseGaloisElement(galoisEl), &swk.SwitchingKey); err != nil {
+		return nil, err
+	}
+	return swk, nil
 }
 
-func (keygen *keyGenerator) GenSwitchingKeyForRotationBy(k int, sk *SecretKey) (swk *SwitchingKey) {
+func (keygen *keyGenerator) GenSwitchingKeyForRotationBy(k int, sk *SecretKey) (swk *SwitchingKey, err error) {
 	swk = NewSwitchingKey(keygen.params)
 	galElInv := keygen.params.GaloisElementForColumnRotationBy(-int(k))
-	keygen.genrotKey(sk.Value, galElInv, &swk.SwitchingKey)
-	return
+	if err = keygen.genrotKey(sk.Value, galElInv, &swk.SwitchingKey); err != nil {
+		return nil, err
+	}
+	return swk, nil
 }
 
-func (keygen *keyGenerator) GenSwitchingKeyForConjugate(sk *SecretKey) (swk *SwitchingKey) {
+func (keygen *keyGenerator) GenSwitchingKeyForConjugate(sk *SecretKey) (swk *SwitchingKey, err error) {
 	swk = NewSwitchingKey(keygen.params)
-	keygen.genrotKey(sk.Value, keygen.params.GaloisElementForRowRotation(), &swk.SwitchingKey)
-	return
+	if err = keygen.genrotKey(sk.Value, keygen.params.GaloisElementForRowRotation(), &swk.SwitchingKey); err != nil {
+		return nil, err
+	}
+	return swk, nil
 }
 
-func (keygen *keyGenerator) genrotKey(sk *ring.Poly, galEl uint64, swk *rlwe.SwitchingKey) {
+func (keygen *keyGenerator) genrotKey(sk *ring.Poly, galEl uint64, swk *rlwe.SwitchingKey) (err error) {
 
 	skIn := sk
 	skOut := keygen.polypool[1]
@@ -212,15 +238,15 @@ func (keygen *keyGenerator) genrotKey(sk *ring.Poly, galEl uint64, swk *rlwe.Swi
 	index := ring.PermuteNTTIndex(galEl, uint64(keygen.ringQP.N))
 	ring.PermuteNTTWithIndexLvl(keygen.params.QPiCount()-1, skIn, index, skOut)
 
-	keygen.newSwitchingKey(skIn, skOut, swk)
+	err = keygen.newSwitchingKey(skIn, skOut, swk)
 
 	keygen.polypool[0].Zero()
 	keygen.polypool[1].Zero()
 
-	return
+	return err
 }
 
-func (keygen *keyGenerator) newSwitchingKey(skIn, skOut *ring.Poly, swk *rlwe.SwitchingKey) {
+func (keygen *keyGenerator) newSwitchingKey(skIn, skOut *ring.Poly, swk *rlwe.SwitchingKey) (err error) {
 
 	ringQP := keygen.ringQP
 
@@ -235,12 +261,16 @@ func (keygen *keyGenerator) newSwitchingKey(skIn, skOut *ring.Poly, swk *rlwe.Sw
 
 		// e
 
-		keygen.gaussianSampler.Read(swk.Value[i][0], keygen.ringQP, keygen.params.sigma, int(6*keygen.params.sigma))
+		if err = keygen.gaussianSampler.Read(swk.Value[i][0], keygen.ringQP, keygen.params.sigma, int(6*keygen.params.sigma)); err != nil {
+			return err
+		}
 		ringQP.NTTLazy(swk.Value[i][0], swk.Value[i][0])
 		ringQP.MForm(swk.Value[i][0], swk.Value[i][0])
 
 		// a (since a is uniform, we consider we already sample it in the NTT and Montgomery domain)
-		keygen.uniformSampler.Read(swk.Value[i][1])
+		if err = keygen.uniformSampler.Read(swk.Value[i][1]); err != nil {
+			return err
+		}
 
 		// e + (skIn * P) * (q_star * q_tild) mod QP
 		//
@@ -271,23 +301,25 @@ func (keygen *keyGenerator) newSwitchingKey(skIn, skOut *ring.Poly, swk *rlwe.Sw
 		ringQP.MulCoeffsMontgomeryAndSub(swk.Value[i][1], skOut, swk.Value[i][0])
 	}
 
-	return
+	return nil
 }
 
 // GenRotationKeys generates a RotationKeySet from a list of galois element corresponding to the desired rotations
 // See also GenRotationKeysForRotations.
-func (keygen *keyGenerator) GenRotationKeys(galEls []uint64, sk *SecretKey) (rks *RotationKeySet) {
+func (keygen *keyGenerator) GenRotationKeys(galEls []uint64, sk *SecretKey) (rks *RotationKeySet, err error) {
 	rks = NewRotationKeySet(keygen.params, galEls)
 	for _, galEl := range galEls {
-		keygen.genrotKey(sk.Value, keygen.params.InverseGaloisElement(galEl), rks.Keys[galEl])
+		if err = keygen.genrotKey(sk.Value, keygen.params.InverseGaloisElement(galEl), rks.Keys[galEl]); err != nil {
+			return nil, err
+		}
 	}
-	return rks
+	return rks, nil
 }
 
 // GenRotationKeysForRotations generates a RotationKeySet supporting left rotations by k positions for all k in ks.
 // Negative k is equivalent to a right rotation by k positions
 // If includeConjugate is true, the resulting set contains the conjugation key.
-func (keygen *keyGenerator) GenRotationKeysForRotations(ks []int, includeConjugate bool, sk *SecretKey) (rks *RotationKeySet) {
+func (keygen *keyGenerator) GenRotationKeysForRotations(ks []int, includeConjugate bool, sk *SecretKey) (rks *RotationKeySet, err error) {
 	galEls := make([]uint64, len(ks), len(ks)+1)
 	for i, k := range ks {
 		galEls[i] = keygen.params.GaloisElementForColumnRotationBy(k)
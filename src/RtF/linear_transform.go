@@ -333,6 +333,7 @@ func (eval *mfvEvaluator) MultiplyByDiabMatrix(vecNTT, res *Ciphertext, matrix *
 	} else {
 		eval.MultiplyByDiabMatrixBSGS(vecNTT, res, matrix, c2QiQDecomp, c2QiPDecomp)
 	}
+	eval.trackNoise(res, func(m *MFVNoiseModel) NoiseEstimate { return m.linearTransform(vecNTT, vecNTT.Level(), matrix) })
 }
 
 func (eval *ckksEvaluator) MultiplyByDiabMatrix(vec, res *Ciphertext, matrix *PtDiagMatrix, c2QiQDecomp, c2QiPDecomp []*ring.Poly) {
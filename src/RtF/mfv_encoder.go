@@ -4,6 +4,7 @@ package RtF
 import (
 	"fmt"
 	"github.com/tuneinsight/lattigo/v6/utils"
+	"math"
 	"math/big"
 	"unsafe"
 
@@ -511,6 +512,7 @@ type PtDiagMatrixT struct {
 	N1         int                   // N1 is the number of inner loops of the baby-step giant-step algo used in the evaluation
 	Vec        map[int][2]*ring.Poly // Vec is the matrix, in diagonal form, where each entry of vec is an indexed non zero diagonal
 	naive      bool
+	coeffs     NoiseEstimate // bounds on the mean square and the mean of the coefficients of the diagonals, see MFVNoiseModel
 }
 
 // EncodeDiagMatrixT encodes a diagonalized plaintext matrix into PtDiagMatrixT struct.
@@ -520,6 +522,7 @@ type PtDiagMatrixT struct {
 func (encoder *mfvEncoder) EncodeDiagMatrixT(level int, diagMatrix map[int][]uint64, maxN1N2Ratio float64, logFVSlots int) (matrix *PtDiagMatrixT) {
 	matrix = new(PtDiagMatrixT)
 	matrix.LogFVSlots = logFVSlots
+	matrix.coeffs = noNoise
 	fvSlots := 1 << logFVSlots
 
 	if len(diagMatrix) > 2 {
@@ -539,7 +542,7 @@ func (encoder *mfvEncoder) EncodeDiagMatrixT(level int, diagMatrix map[int][]uin
 					v = diagMatrix[(N1*j+i)-fvSlots]
 				}
 
-				matrix.Vec[N1*j+i] = encoder.encodeDiagonalT(level, logFVSlots, rotateSmallT(v, -N1*j))
+				matrix.Vec[N1*j+i] = encoder.encodeDiagonalT(level, logFVSlots, rotateSmallT(v, -N1*j), &matrix.coeffs)
 			}
 		}
 	} else {
@@ -550,7 +553,7 @@ func (encoder *mfvEncoder) EncodeDiagMatrixT(level int, diagMatrix map[int][]uin
 			if idx < 0 {
 				idx += fvSlots
 			}
-			matrix.Vec[idx] = encoder.encodeDiagonalT(level, logFVSlots, diagMatrix[i])
+			matrix.Vec[idx] = encoder.encodeDiagonalT(level, logFVSlots, diagMatrix[i], &matrix.coeffs)
 		}
 
 		matrix.naive = true
@@ -559,7 +562,8 @@ func (encoder *mfvEncoder) EncodeDiagMatrixT(level int, diagMatrix map[int][]uin
 	return
 }
 
-func (encoder *mfvEncoder) encodeDiagonalT(level, logFVSlots int, m []uint64) [2]*ring.Poly {
+// encodeDiagonalT encodes the diagonal m and raises coeffs to the mean square and the mean of its coefficients
+func (encoder *mfvEncoder) encodeDiagonalT(level, logFVSlots int, m []uint64, coeffs *NoiseEstimate) [2]*ring.Poly {
 	ringQ := encoder.ringQs[level]
 	ringP := encoder.ringP
 	ringT := encoder.ringT
@@ -577,6 +581,14 @@ func (encoder *mfvEncoder) encodeDiagonalT(level, logFVSlots int, m []uint64) [2
 	for i := 0; i < (1 << logFVSlots); i++ {
 		mT.Coeffs[0][i*gap] = tmp.Coeffs[0][i]
 	}
+	var meanSquare, mean float64
+	for _, c := range mT.Coeffs[0] {
+		meanSquare += float64(c) * float64(c)
+		mean += float64(c)
+	}
+	logN := float64(encoder.params.logN)
+	coeffs.LogMeanSquare = math.Max(coeffs.LogMeanSquare, math.Log2(meanSquare)-logN)
+	coeffs.LogMean = math.Max(coeffs.LogMean, math.Log2(mean)-logN)
 
 	// RingTToMulRingQ
 	mQ := ringQ.NewPoly()
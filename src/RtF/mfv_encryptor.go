@@ -13,46 +13,46 @@ type MFVEncryptor interface {
 	// EncryptNew encrypts the input plaintext using the stored key and returns
 	// the result on a newly created ciphertext. The encryption is done by first
 	// encrypting zero in QP, dividing by P and then adding the plaintext.
-	EncryptNew(plaintext *Plaintext) *Ciphertext
+	EncryptNew(plaintext *Plaintext) (*Ciphertext, error)
 
 	// Encrypt encrypts the input plaintext using the stored key, and returns
 	// the result on the receiver ciphertext. The encryption is done by first
 	// encrypting zero in QP, dividing by P and then adding the plaintext.
-	Encrypt(plaintext *Plaintext, ciphertext *Ciphertext)
+	Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) error
 
 	// EncryptFastNew encrypts the input plaintext using the stored key and returns
 	// the result on a newly created ciphertext. The encryption is done by first
 	// encrypting zero in Q and then adding the plaintext.
-	EncryptFastNew(plaintext *Plaintext) *Ciphertext
+	EncryptFastNew(plaintext *Plaintext) (*Ciphertext, error)
 
 	// EncryptFast encrypts the input plaintext using the stored-key, and returns
 	// the result on the receiver ciphertext. The encryption is done by first
 	// encrypting zero in Q and then adding the plaintext.
-	EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext)
+	EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) error
 
 	// EncryptFromCRPNew encrypts the input plaintext using the stored key and returns
 	// the result on a newly created ciphertext. The encryption is done by first encrypting
 	// zero in QP, using the provided polynomial as the uniform polynomial, dividing by P and
 	// then adding the plaintext.
-	EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext
+	EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error)
 
 	// EncryptFromCRP encrypts the input plaintext using the stored key and returns
 	// the result tge receiver ciphertext. The encryption is done by first encrypting
 	// zero in QP, using the provided polynomial as the uniform polynomial, dividing by P and
 	// then adding the plaintext.
-	EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly)
+	EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error
 
 	// EncryptFromCRPNew encrypts the input plaintext using the stored key and returns
 	// the result on a newly created ciphertext. The encryption is done by first encrypting
 	// zero in Q, using the provided polynomial as the uniform polynomial, and
 	// then adding the plaintext.
-	EncryptFromCRPFastNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext
+	EncryptFromCRPFastNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error)
 
 	// EncryptFromCRP encrypts the input plaintext using the stored key and returns
 	// the result tge receiver ciphertext. The encryption is done by first encrypting
 	// zero in Q, using the provided polynomial as the uniform polynomial, and
 	// then adding the plaintext.
-	EncryptFromCRPFast(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly)
+	EncryptFromCRPFast(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error
 }
 
 // encryptor is a structure that holds the parameters needed to encrypt plaintexts.
@@ -86,16 +86,28 @@ type skEncryptor struct {
 // NewMFVEncryptorFromPk creates a new Encryptor with the provided public-key.
 // This encryptor can be used to encrypt plaintexts, using the stored key.
 func NewMFVEncryptorFromPk(params *Parameters, pk *PublicKey) MFVEncryptor {
-	return &pkEncryptor{newMFVEncryptor(params), pk}
+	return NewMFVEncryptorFromPkWithPRNG(params, pk, newPRNG())
+}
+
+// NewMFVEncryptorFromPkWithPRNG creates a new Encryptor with the provided public-key, drawing the
+// randomness of the encryptions from prng.
+func NewMFVEncryptorFromPkWithPRNG(params *Parameters, pk *PublicKey, prng sampling.PRNG) MFVEncryptor {
+	return &pkEncryptor{newMFVEncryptor(params, prng), pk}
 }
 
 // NewMFVEncryptorFromSk creates a new Encryptor with the provided secret-key.
 // This encryptor can be used to encrypt plaintexts, using the stored key.
 func NewMFVEncryptorFromSk(params *Parameters, sk *SecretKey) MFVEncryptor {
-	return &skEncryptor{newMFVEncryptor(params), sk}
+	return NewMFVEncryptorFromSkWithPRNG(params, sk, newPRNG())
+}
+
+// NewMFVEncryptorFromSkWithPRNG creates a new Encryptor with the provided secret-key, drawing the
+// randomness of the encryptions from prng.
+func NewMFVEncryptorFromSkWithPRNG(params *Parameters, sk *SecretKey, prng sampling.PRNG) MFVEncryptor {
+	return &skEncryptor{newMFVEncryptor(params, prng), sk}
 }
 
-func newMFVEncryptor(params *Parameters) encryptor {
+func newMFVEncryptor(params *Parameters, prng sampling.PRNG) encryptor {
 
 	var ringQ, ringP *ring.Ring
 	var ringQPs []*ring.Ring
@@ -105,11 +117,6 @@ func newMFVEncryptor(params *Parameters) encryptor {
 		panic(err)
 	}
 
-	prng, err := sampling.NewPRNG()
-	if err != nil {
-		panic(err)
-	}
-
 	var baseconverter *ring.FastBasisExtender
 	var polypool, poolQ, poolP [3]*ring.Poly
 	var ternarySamplerMontgomeryQP *ring.TernarySampler
@@ -157,49 +164,53 @@ func newMFVEncryptor(params *Parameters) encryptor {
 	}
 }
 
-func (encryptor *pkEncryptor) EncryptNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *pkEncryptor) EncryptNew(plaintext *Plaintext) (*Ciphertext, error) {
 	ciphertext := NewCiphertextFVLvl(encryptor.params, 1, plaintext.Level())
-	encryptor.encrypt(plaintext, ciphertext, false)
-	return ciphertext
+	if err := encryptor.encrypt(plaintext, ciphertext, false); err != nil {
+		return nil, err
+	}
+	return ciphertext, nil
 }
 
-func (encryptor *pkEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) {
+func (encryptor *pkEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) error {
 
 	if encryptor.baseconverter == nil {
 		panic("Cannot Encrypt : modulus P is empty -> use instead EncryptFast")
 	}
 
-	encryptor.encrypt(plaintext, ciphertext, false)
+	return encryptor.encrypt(plaintext, ciphertext, false)
 }
 
-func (encryptor *pkEncryptor) EncryptFastNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *pkEncryptor) EncryptFastNew(plaintext *Plaintext) (*Ciphertext, error) {
 	ciphertext := NewCiphertextFVLvl(encryptor.params, 1, plaintext.Level())
-	encryptor.encrypt(plaintext, ciphertext, true)
+	if err := encryptor.encrypt(plaintext, ciphertext, true); err != nil {
+		return nil, err
+	}
 
-	return ciphertext
+	return ciphertext, nil
 }
 
-func (encryptor *pkEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) {
-	encryptor.encrypt(plaintext, ciphertext, true)
+func (encryptor *pkEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) error {
+	return encryptor.encrypt(plaintext, ciphertext, true)
 }
 
-func (encryptor *pkEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *pkEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 	panic("Cannot encrypt with CRP using an encryptor created with the public-key")
 }
 
-func (encryptor *pkEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext {
+func (encryptor *pkEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error) {
 	panic("Cannot encrypt with CRP using an encryptor created with the public-key")
 }
 
-func (encryptor *pkEncryptor) EncryptFromCRPFast(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *pkEncryptor) EncryptFromCRPFast(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 	panic("Cannot encrypt with CRP using an encryptor created with the public-key")
 }
 
-func (encryptor *pkEncryptor) EncryptFromCRPFastNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext {
+func (encryptor *pkEncryptor) EncryptFromCRPFastNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error) {
 	panic("Cannot encrypt with CRP using an encryptor created with the public-key")
 }
 
-func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast bool) {
+func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast bool) (err error) {
 
 	if p.Level() != ciphertext.Level() {
 		panic("cannot encrypt: input and output should have the same level")
@@ -209,7 +220,9 @@ func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast
 
 	if fast {
 
-		encryptor.ternarySamplerMontgomeryQ.ReadLvl(levelQ, encryptor.polypool[2])
+		if err = encryptor.ternarySamplerMontgomeryQ.ReadLvl(levelQ, encryptor.polypool[2]); err != nil {
+			return err
+		}
 		ringQ.NTTLazyLvl(levelQ, encryptor.polypool[2], encryptor.polypool[2])
 
 		ringQ.MulCoeffsMontgomeryLvl(levelQ, encryptor.polypool[2], encryptor.pk.Value[0], encryptor.polypool[0])
@@ -219,10 +232,14 @@ func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast
 		ringQ.InvNTTLvl(levelQ, encryptor.polypool[1], ciphertext.value[1])
 
 		// ct[0] = pk[0]*u + e0
-		encryptor.gaussianSampler.ReadAndAddLvl(levelQ, ciphertext.value[0], ringQ, encryptor.params.Sigma(), int(6*encryptor.params.Sigma()))
+		if err = encryptor.gaussianSampler.ReadAndAddLvl(levelQ, ciphertext.value[0], ringQ, encryptor.params.Sigma(), int(6*encryptor.params.Sigma())); err != nil {
+			return err
+		}
 
 		// ct[1] = pk[1]*u + e1
-		encryptor.gaussianSampler.ReadAndAddLvl(levelQ, ciphertext.value[1], ringQ, encryptor.params.Sigma(), int(6*encryptor.params.Sigma()))
+		if err = encryptor.gaussianSampler.ReadAndAddLvl(levelQ, ciphertext.value[1], ringQ, encryptor.params.Sigma(), int(6*encryptor.params.Sigma())); err != nil {
+			return err
+		}
 
 	} else {
 
@@ -242,7 +259,9 @@ func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast
 
 		if levelQ == len(encryptor.params.qi)-1 {
 			// u
-			encryptor.ternarySamplerMontgomeryQP.Read(polypool[2])
+			if err = encryptor.ternarySamplerMontgomeryQP.Read(polypool[2]); err != nil {
+				return err
+			}
 			ringQP.NTTLazy(polypool[2], polypool[2])
 
 			// ct[0] = pk[0]*u
@@ -254,10 +273,14 @@ func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast
 			ringQP.InvNTTLazy(polypool[1], polypool[1])
 
 			// ct[0] = pk[0]*u + e0
-			encryptor.gaussianSampler.ReadAndAdd(polypool[0], ringQP, encryptor.params.Sigma(), int(6*encryptor.params.Sigma()))
+			if err = encryptor.gaussianSampler.ReadAndAdd(polypool[0], ringQP, encryptor.params.Sigma(), int(6*encryptor.params.Sigma())); err != nil {
+				return err
+			}
 
 			// ct[1] = pk[1]*u + e1
-			encryptor.gaussianSampler.ReadAndAdd(polypool[1], ringQP, encryptor.params.Sigma(), int(6*encryptor.params.Sigma()))
+			if err = encryptor.gaussianSampler.ReadAndAdd(polypool[1], ringQP, encryptor.params.Sigma(), int(6*encryptor.params.Sigma())); err != nil {
+				return err
+			}
 
 			// We rescale the encryption of zero by the special prime, dividing the error by this prime
 			encryptor.baseconverter.ModDownPQ(levelQ, polypool[0], ciphertext.value[0])
@@ -276,7 +299,9 @@ func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast
 			poolP1 := encryptor.poolP[1]
 			poolP2 := encryptor.poolP[2]
 
-			encryptor.ternarySamplerQ.ReadLvl(levelQ, poolQ2)
+			if err = encryptor.ternarySamplerQ.ReadLvl(levelQ, poolQ2); err != nil {
+				return err
+			}
 			extendBasisSmallNormAndCenter(ringQ, ringP, poolQ2, poolP2)
 
 			// (#Q + #P) NTT
@@ -305,13 +330,17 @@ func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast
 			ringP.InvNTT(poolP1, poolP1)
 
 			// ct0 = u*pk0 + e0
-			encryptor.gaussianSampler.ReadLvl(levelQ, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+			if err = encryptor.gaussianSampler.ReadLvl(levelQ, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+				return err
+			}
 			extendBasisSmallNormAndCenter(ringQ, ringP, poolQ2, poolP2)
 			ringQ.AddLvl(levelQ, poolQ0, poolQ2, poolQ0)
 			ringP.Add(poolP0, poolP2, poolP0)
 
 			// ct1 = u*pk1 + e1
-			encryptor.gaussianSampler.ReadLvl(levelQ, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma))
+			if err = encryptor.gaussianSampler.ReadLvl(levelQ, poolQ2, ringQ, encryptor.params.sigma, int(6*encryptor.params.sigma)); err != nil {
+				return err
+			}
 			extendBasisSmallNormAndCenter(ringQ, ringP, poolQ2, poolP2)
 			ringQ.AddLvl(levelQ, poolQ1, poolQ2, poolQ1)
 			ringP.Add(poolP1, poolP2, poolP1)
@@ -324,58 +353,65 @@ func (encryptor *pkEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, fast
 			encryptor.baseconverter.ModDownSplitPQ(levelQ, poolQ1, poolP1, ciphertext.value[1])
 		}
 	}
+	return nil
 }
 
-func (encryptor *skEncryptor) EncryptNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *skEncryptor) EncryptNew(plaintext *Plaintext) (*Ciphertext, error) {
 	ciphertext := NewCiphertextFVLvl(encryptor.params, 1, plaintext.Level())
-	encryptor.Encrypt(plaintext, ciphertext)
-	return ciphertext
+	if err := encryptor.Encrypt(plaintext, ciphertext); err != nil {
+		return nil, err
+	}
+	return ciphertext, nil
 }
 
-func (encryptor *skEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) {
+func (encryptor *skEncryptor) Encrypt(plaintext *Plaintext, ciphertext *Ciphertext) error {
 	if plaintext.Level() != ciphertext.Level() {
 		panic("cannot Encrypt: input and output should have the same level")
 	}
-	encryptor.encryptSample(plaintext, ciphertext)
+	return encryptor.encryptSample(plaintext, ciphertext)
 }
 
-func (encryptor *skEncryptor) EncryptFastNew(plaintext *Plaintext) *Ciphertext {
+func (encryptor *skEncryptor) EncryptFastNew(plaintext *Plaintext) (*Ciphertext, error) {
 	panic("Cannot EncryptFastNew: not supported by sk encryptor -> use EncryptFastNew instead")
 }
 
-func (encryptor *skEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) {
+func (encryptor *skEncryptor) EncryptFast(plaintext *Plaintext, ciphertext *Ciphertext) error {
 	panic("Cannot EncryptFast: not supported by sk encryptor -> use Encrypt instead")
 }
 
-func (encryptor *skEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext {
+func (encryptor *skEncryptor) EncryptFromCRPNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error) {
 	ciphertext := NewCiphertextFV(encryptor.params, 1)
-	encryptor.EncryptFromCRP(plaintext, ciphertext, crp)
-	return ciphertext
+	if err := encryptor.EncryptFromCRP(plaintext, ciphertext, crp); err != nil {
+		return nil, err
+	}
+	return ciphertext, nil
 }
 
-func (encryptor *skEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
-	encryptor.encryptFromCRP(plaintext, ciphertext, crp)
+func (encryptor *skEncryptor) EncryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
+	return encryptor.encryptFromCRP(plaintext, ciphertext, crp)
 }
 
-func (encryptor *skEncryptor) EncryptFromCRPFastNew(plaintext *Plaintext, crp *ring.Poly) *Ciphertext {
+func (encryptor *skEncryptor) EncryptFromCRPFastNew(plaintext *Plaintext, crp *ring.Poly) (*Ciphertext, error) {
 	panic("Cannot EncryptFromCRPFastNew: not supported by sk encryptor -> use EncryptFromCRPNew instead")
 }
 
-func (encryptor *skEncryptor) EncryptFromCRPFast(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *skEncryptor) EncryptFromCRPFast(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 	panic("Cannot EncryptFromCRPFast: not supported by sk encryptor -> use EncryptFromCRP instead")
 }
 
-func (encryptor *skEncryptor) encryptSample(plaintext *Plaintext, ciphertext *Ciphertext) {
-	encryptor.uniformSamplerQ.Read(encryptor.polypool[1])
-	encryptor.encrypt(plaintext, ciphertext, encryptor.polypool[1])
+func (encryptor *skEncryptor) encryptSample(plaintext *Plaintext, ciphertext *Ciphertext) error {
+	if err := encryptor.uniformSamplerQ.Read(encryptor.polypool[1]); err != nil {
+		return err
+	}
+	return encryptor.encrypt(plaintext, ciphertext, encryptor.polypool[1])
 }
 
-func (encryptor *skEncryptor) encryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *skEncryptor) encryptFromCRP(plaintext *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 	encryptor.ringQ.Copy(crp, encryptor.polypool[1])
-	encryptor.encrypt(plaintext, ciphertext, encryptor.polypool[1])
+	return encryptor.encrypt(plaintext, ciphertext, encryptor.polypool[1])
 }
 
-func (encryptor *skEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) {
+func (encryptor *skEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, crp *ring.Poly) error {
 
 	if p.Level() != ciphertext.Level() {
 		panic("cannot encrypt: input and output should have the same level")
@@ -390,8 +426,11 @@ func (encryptor *skEncryptor) encrypt(p *Plaintext, ciphertext *Ciphertext, crp
 	ringQ.InvNTTLvl(level, ciphertext.value[0], ciphertext.value[0])
 	ringQ.InvNTTLvl(level, crp, ciphertext.value[1])
 
-	encryptor.gaussianSampler.ReadAndAddLvl(level, ciphertext.value[0], ringQ, encryptor.params.Sigma(), int(6*encryptor.params.Sigma()))
+	if err := encryptor.gaussianSampler.ReadAndAddLvl(level, ciphertext.value[0], ringQ, encryptor.params.Sigma(), int(6*encryptor.params.Sigma())); err != nil {
+		return err
+	}
 
 	// ct = [-a*s + m + e , a]
 	encryptor.ringQ.AddLvl(level, ciphertext.value[0], p.value, ciphertext.value[0])
+	return nil
 }
@@ -41,6 +41,7 @@ type MFVEvaluator interface {
 	InnerSum(ct0 *Ciphertext, ctOut *Ciphertext)
 	ShallowCopy() MFVEvaluator
 	WithKey(EvaluationKey) MFVEvaluator
+	WithNoiseModel(model *MFVNoiseModel) MFVEvaluator
 
 	// Modulus Switch
 	ModSwitch(ct0, ctOut *Ciphertext)
@@ -50,9 +51,9 @@ type MFVEvaluator interface {
 	TransformToNTT(ct0, ctOut *Ciphertext)
 
 	// Linear Transformation
-	SlotsToCoeffs(ct *Ciphertext, stcModDown []int) (ctOut *Ciphertext)
-	SlotsToCoeffsNoModSwitch(ct *Ciphertext) (ctOut *Ciphertext)
-	SlotsToCoeffsAutoModSwitch(ct *Ciphertext, noiseEstimator MFVNoiseEstimator) (ctOut *Ciphertext, stcModDown []int)
+	SlotsToCoeffs(ct *Ciphertext, stcModDown []int) (ctOut *Ciphertext, err error)
+	SlotsToCoeffsNoModSwitch(ct *Ciphertext) (ctOut *Ciphertext, err error)
+	SlotsToCoeffsAutoModSwitch(ct *Ciphertext, noiseEstimator MFVNoiseEstimator) (ctOut *Ciphertext, stcModDown []int, err error)
 	LinearTransform(vec *Ciphertext, linearTransform interface{}) (res []*Ciphertext)
 	MultiplyByDiabMatrix(vec, res *Ciphertext, matrix *PtDiagMatrixT, c2QiQDecomp, c2QiPDecomp []*ring.Poly)
 	MultiplyByDiabMatrixNaive(vec, res *Ciphertext, matrix *PtDiagMatrixT, c2QiQDecomp, c2QiPDecomp []*ring.Poly)
@@ -72,6 +73,8 @@ type mfvEvaluator struct {
 
 	baseconverterQ1Q2s []*ring.FastBasisExtender
 	baseconverterQ1P   *ring.FastBasisExtender
+
+	noiseModel *MFVNoiseModel // predicts the noise of the outputs if not nil
 }
 
 type mfvEvaluatorBase struct {
@@ -256,12 +259,18 @@ func (eval *mfvEvaluator) ShallowCopy() MFVEvaluator {
 		rlk:                 eval.rlk,
 		rtks:                eval.rtks,
 		pDcds:               eval.pDcds,
+		permuteNTTIndex:     eval.permuteNTTIndex,
+		noiseModel:          eval.noiseModel,
 	}
 }
 
-// ShallowCopyWithKey creates a shallow copy of this evaluator in which the read-only data-structures are
+// WithKey creates a shallow copy of this evaluator in which the read-only data-structures are
 // shared with the receiver but the EvaluationKey is evaluationKey.
 func (eval *mfvEvaluator) WithKey(evaluationKey EvaluationKey) MFVEvaluator {
+	indexes := eval.permuteNTTIndex
+	if evaluationKey.Rtks != eval.rtks {
+		indexes = *eval.permuteNTTIndexesForKeys(evaluationKey.Rtks)
+	}
 	return &mfvEvaluator{
 		mfvEvaluatorBase:    eval.mfvEvaluatorBase,
 		mfvEvaluatorBuffers: eval.mfvEvaluatorBuffers,
@@ -269,6 +278,32 @@ func (eval *mfvEvaluator) WithKey(evaluationKey EvaluationKey) MFVEvaluator {
 		baseconverterQ1P:    eval.baseconverterQ1P,
 		rlk:                 evaluationKey.Rlk,
 		rtks:                evaluationKey.Rtks,
+		pDcds:               eval.pDcds,
+		permuteNTTIndex:     indexes,
+		noiseModel:          eval.noiseModel,
+	}
+}
+
+// WithNoiseModel creates a shallow copy of this evaluator which attaches the noise predicted by model
+// to the ciphertexts it outputs, see MFVNoiseModel
+func (eval *mfvEvaluator) WithNoiseModel(model *MFVNoiseModel) MFVEvaluator {
+	return &mfvEvaluator{
+		mfvEvaluatorBase:    eval.mfvEvaluatorBase,
+		mfvEvaluatorBuffers: eval.mfvEvaluatorBuffers,
+		baseconverterQ1Q2s:  eval.baseconverterQ1Q2s,
+		baseconverterQ1P:    eval.baseconverterQ1P,
+		rlk:                 eval.rlk,
+		rtks:                eval.rtks,
+		pDcds:               eval.pDcds,
+		permuteNTTIndex:     eval.permuteNTTIndex,
+		noiseModel:          model,
+	}
+}
+
+// trackNoise attaches the noise predicted by the noise model of the evaluator, if any, to ctOut
+func (eval *mfvEvaluator) trackNoise(ctOut *Ciphertext, predict func(model *MFVNoiseModel) NoiseEstimate) {
+	if eval.noiseModel != nil {
+		ctOut.SetNoiseEstimate(predict(eval.noiseModel))
 	}
 }
 
@@ -281,6 +316,7 @@ func (eval *mfvEvaluator) Add(op0, op1 Operand, ctOut *Ciphertext) {
 	level := op0.Level()
 	el0, el1, elOut := eval.getElemAndCheckBinary(op0, op1, ctOut, utils.MaxInt(op0.Degree(), op1.Degree()), true)
 	eval.evaluateInPlaceBinaryLvl(level, el0, el1, elOut, eval.ringQ.AddLvl)
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.add(op0, op1) })
 }
 
 // AddNew adds op0 to op1 and creates a new element ctOut to store the result.
@@ -303,6 +339,7 @@ func (eval *mfvEvaluator) AddNoMod(op0, op1 Operand, ctOut *Ciphertext) {
 	}
 	el0, el1, elOut := eval.getElemAndCheckBinary(op0, op1, ctOut, utils.MaxInt(op0.Degree(), op1.Degree()), true)
 	eval.evaluateInPlaceBinaryLvl(level, el0, el1, elOut, eval.ringQ.AddNoModLvl)
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.add(op0, op1) })
 }
 
 // AddNoModNew adds op0 to op1 without modular reduction and creates a new element ctOut to store the result.
@@ -331,6 +368,7 @@ func (eval *mfvEvaluator) Sub(op0, op1 Operand, ctOut *Ciphertext) {
 			eval.ringQ.NegLvl(level, ctOut.Value()[i], ctOut.Value()[i])
 		}
 	}
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.add(op0, op1) })
 }
 
 // SubNew subtracts op1 from op0 and creates a new element ctOut to store the result.
@@ -361,6 +399,7 @@ func (eval *mfvEvaluator) SubNoMod(op0, op1 Operand, ctOut *Ciphertext) {
 			eval.ringQ.NegLvl(level, ctOut.Value()[i], ctOut.Value()[i])
 		}
 	}
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.add(op0, op1) })
 }
 
 // SubNoModNew subtracts op1 from op0 without modular reduction and creates a new element ctOut to store the result.
@@ -384,6 +423,7 @@ func (eval *mfvEvaluator) Neg(op Operand, ctOut *Ciphertext) {
 	level := op.Level()
 	el0, elOut := eval.getElemAndCheckUnary(op, ctOut, op.Degree())
 	evaluateInPlaceUnaryLvl(level, el0, elOut, eval.ringQ.NegLvl)
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.noiseOf(op) })
 }
 
 // NegNew negates op and creates a new element to store the result.
@@ -403,6 +443,7 @@ func (eval *mfvEvaluator) Reduce(op Operand, ctOut *Ciphertext) {
 	level := op.Level()
 	el0, elOut := eval.getElemAndCheckUnary(op, ctOut, op.Degree())
 	evaluateInPlaceUnaryLvl(level, el0, elOut, eval.ringQ.ReduceLvl)
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.noiseOf(op) })
 }
 
 // ReduceNew applies a modular reduction to op and creates a new element ctOut to store the result.
@@ -423,6 +464,7 @@ func (eval *mfvEvaluator) MulScalar(op Operand, scalar uint64, ctOut *Ciphertext
 	el0, elOut := eval.getElemAndCheckUnary(op, ctOut, op.Degree())
 	fun := func(lvl int, el, elOut *ring.Poly) { eval.ringQ.MulScalarLvl(lvl, el, scalar, elOut) }
 	evaluateInPlaceUnaryLvl(level, el0, elOut, fun)
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.mulScalar(op, scalar) })
 }
 
 // MulScalarNew multiplies op by a uint64 scalar and creates a new element ctOut to store the result.
@@ -633,6 +675,7 @@ func (eval *mfvEvaluator) quantize(ctOut *Element) {
 // Mul multiplies op0 by op1 and returns the result in ctOut.
 func (eval *mfvEvaluator) Mul(op0 *Ciphertext, op1 Operand, ctOut *Ciphertext) {
 	el0, el1, elOut := eval.getElemAndCheckBinary(op0, op1, ctOut, op0.Degree()+op1.Degree(), false)
+	noise := func(m *MFVNoiseModel) NoiseEstimate { return m.mulPlaintext(op0) }
 	switch op1 := op1.(type) {
 	case *PlaintextMul:
 		eval.mulPlaintextMul(op0, op1, ctOut)
@@ -640,10 +683,11 @@ func (eval *mfvEvaluator) Mul(op0 *Ciphertext, op1 Operand, ctOut *Ciphertext) {
 		eval.mulPlaintextRingT(op0, op1, ctOut)
 	case *Plaintext, *Ciphertext:
 		eval.tensorAndRescale(el0, el1, elOut)
+		noise = func(m *MFVNoiseModel) NoiseEstimate { return m.tensor(op0, op1, op0.Level()) }
 	default:
 		panic(fmt.Errorf("invalid operand type for Mul: %T", op1))
 	}
-
+	eval.trackNoise(ctOut, noise)
 }
 
 func (eval *mfvEvaluator) mulPlaintextMul(ct0 *Ciphertext, ptRt *PlaintextMul, ctOut *Ciphertext) {
@@ -765,6 +809,7 @@ func (eval *mfvEvaluator) Relinearize(ct0 *Ciphertext, ctOut *Ciphertext) {
 		}
 	} else {
 		eval.relinearize(ct0, ctOut)
+		eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.keySwitch(ct0, ct0.Level()) })
 	}
 }
 
@@ -800,6 +845,7 @@ func (eval *mfvEvaluator) SwitchKeys(ct0 *Ciphertext, switchKey *SwitchingKey, c
 
 	eval.ringQ.AddLvl(level, ct0.value[0], eval.poolQKS[1], ctOut.value[0])
 	eval.ringQ.CopyLvl(level, eval.poolQKS[2], ctOut.value[1])
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.keySwitch(ct0, level) })
 }
 
 // SwitchKeysNew applies the key-switching procedure to the ciphertext ct0 and creates a new ciphertext to store the result. It requires as an additional input a valid switching-key:
@@ -838,6 +884,7 @@ func (eval *mfvEvaluator) RotateColumns(ct0 *Ciphertext, k int, ctOut *Ciphertex
 		if swk, inSet := eval.rtks.GetRotationKey(galElL); inSet {
 
 			eval.permute(ct0, galElL, swk, ctOut)
+			eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.rotate(ct0, ct0.Level()) })
 
 		} else {
 			panic(fmt.Errorf("evaluator has no rotation key for rotation by %d", k))
@@ -868,6 +915,7 @@ func (eval *mfvEvaluator) RotateRows(ct0 *Ciphertext, ctOut *Ciphertext) {
 
 	if key, inSet := eval.rtks.GetRotationKey(galEl); inSet {
 		eval.permute(ct0, galEl, key, ctOut)
+		eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.keySwitch(ct0, ct0.Level()) })
 	} else {
 		panic("evaluator has no rotation key for row rotation")
 	}
@@ -1332,6 +1380,7 @@ func (eval *mfvEvaluator) ModSwitch(ct0 *Ciphertext, ctOut *Ciphertext) {
 		ringQ.DivRoundByLastModulus(ct0.value[i], ctOut.value[i])
 		ctOut.value[i].Coeffs = ctOut.value[i].Coeffs[:level]
 	}
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.modSwitch(ct0, level, 1) })
 }
 
 // ModSwitchMany switches modulus of ct0 nbModSwitch levels down and returns the result in ctOut
@@ -1354,6 +1403,7 @@ func (eval *mfvEvaluator) ModSwitchMany(ct0, ctOut *Ciphertext, nbModSwitch int)
 		ringQ.DivRoundByLastModulusMany(ct0.value[i], ctOut.value[i], nbModSwitch)
 		ctOut.value[i].Coeffs = ctOut.value[i].Coeffs[:level+1-nbModSwitch]
 	}
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.modSwitch(ct0, level, nbModSwitch) })
 }
 
 // TransformToNTT transforms ct0 into NTT form and returns the result in ctOut
@@ -1372,49 +1422,101 @@ func (eval *mfvEvaluator) TransformToNTT(ct0 *Ciphertext, ctOut *Ciphertext) {
 	}
 
 	ctOut.SetIsNTT(true)
+	eval.trackNoise(ctOut, func(m *MFVNoiseModel) NoiseEstimate { return m.noiseOf(ct0) })
 }
 
 // SlotsToCoeffs returns ctOut whose coefficients are data stored in slots of ct
-// with dropping modulus as given in stcModDown
-func (eval *mfvEvaluator) SlotsToCoeffs(ct *Ciphertext, stcModDown []int) (ctOut *Ciphertext) {
-	if eval.pDcds == nil {
-		panic("cannot SlotsToCoeffs: evaluator does not have StC matrices")
+// with dropping modulus as given in stcModDown. It returns an ErrParamMismatch error if the evaluator
+// has no StC matrices for the levels of ct or if stcModDown is shorter than their depth, and an
+// ErrInsufficientLevels error if stcModDown drops more levels than ct has.
+func (eval *mfvEvaluator) SlotsToCoeffs(ct *Ciphertext, stcModDown []int) (ctOut *Ciphertext, err error) {
+	return eval.slotsToCoeffsTrace(ct, stcModDown, nil)
+}
+
+// slotsToCoeffsTrace is SlotsToCoeffs calling trace after the mod down of each depth
+func (eval *mfvEvaluator) slotsToCoeffsTrace(ct *Ciphertext, stcModDown []int, trace roundTrace) (ctOut *Ciphertext, err error) {
+	depth, err := eval.stcDepth(ct.Level())
+	if err != nil {
+		return nil, err
+	}
+	if len(stcModDown) < depth {
+		return nil, fmt.Errorf("%w: %d StC mod downs for a depth of %d", ErrParamMismatch, len(stcModDown), depth)
 	}
 
 	ctOut = ct.CopyNew().Ciphertext()
 
-	level := ctOut.Level()
-	depth := len(eval.pDcds[level]) - 1
+	var matrix *PtDiagMatrixT
 	for i := 0; i < depth-1; i++ {
-		if stcModDown[i] > 0 {
-			eval.ModSwitchMany(ctOut, ctOut, stcModDown[i])
+		if err = eval.stcModSwitch(ctOut, stcModDown[i]); err != nil {
+			return nil, err
 		}
-		level = ctOut.Level()
-		ctOut = eval.LinearTransform(ctOut, eval.pDcds[level][i])[0]
+		trace.call(i, []*Ciphertext{ctOut})
+		if matrix, err = eval.stcMatrix(ctOut.Level(), i); err != nil {
+			return nil, err
+		}
+		ctOut = eval.LinearTransform(ctOut, matrix)[0]
+	}
+	if err = eval.stcModSwitch(ctOut, stcModDown[depth-1]); err != nil {
+		return nil, err
 	}
-	if stcModDown[depth-1] > 0 {
-		eval.ModSwitchMany(ctOut, ctOut, stcModDown[depth-1])
+	trace.call(depth-1, []*Ciphertext{ctOut})
+	level := ctOut.Level()
+	matrixRot, err := eval.stcMatrix(level, depth)
+	if err != nil {
+		return nil, err
+	}
+	if matrix, err = eval.stcMatrix(level, depth-1); err != nil {
+		return nil, err
 	}
-	level = ctOut.Level()
 	tmp := eval.RotateRowsNew(ctOut)
-	ctOut = eval.LinearTransform(ctOut, eval.pDcds[level][depth-1])[0]
-	tmp = eval.LinearTransform(tmp, eval.pDcds[level][depth])[0]
+	ctOut = eval.LinearTransform(ctOut, matrix)[0]
+	tmp = eval.LinearTransform(tmp, matrixRot)[0]
 
 	ctOut = eval.AddNew(tmp, ctOut)
-	return
+	return ctOut, nil
+}
+
+// stcDepth returns the depth of the StC matrices of the level, an ErrParamMismatch error if the
+// evaluator has none for it
+func (eval *mfvEvaluator) stcDepth(level int) (int, error) {
+	if level < 0 || level >= len(eval.pDcds) || len(eval.pDcds[level]) < 2 {
+		return 0, fmt.Errorf("%w: evaluator does not have StC matrices at level %d", ErrParamMismatch, level)
+	}
+	return len(eval.pDcds[level]) - 1, nil
+}
+
+// stcMatrix returns the StC matrix i of the level, an ErrParamMismatch error if the evaluator doesn't
+// have it
+func (eval *mfvEvaluator) stcMatrix(level, i int) (*PtDiagMatrixT, error) {
+	if level < 0 || level >= len(eval.pDcds) || i >= len(eval.pDcds[level]) || eval.pDcds[level][i] == nil {
+		return nil, fmt.Errorf("%w: evaluator does not have the StC matrix %d at level %d", ErrParamMismatch, i, level)
+	}
+	return eval.pDcds[level][i], nil
+}
+
+// stcModSwitch drops nbSwitch levels of ct, it returns an ErrInsufficientLevels error if ct has fewer
+func (eval *mfvEvaluator) stcModSwitch(ct *Ciphertext, nbSwitch int) error {
+	if nbSwitch > ct.Level() {
+		return fmt.Errorf("%w: %d StC mod downs at level %d", ErrInsufficientLevels, nbSwitch, ct.Level())
+	}
+	if nbSwitch > 0 {
+		eval.ModSwitchMany(ct, ct, nbSwitch)
+	}
+	return nil
 }
 
 // SlotsToCoeffsNoModSwitch returns ctOut whose coefficients are data stored in slots of ct
-// without modulus switching
-func (eval *mfvEvaluator) SlotsToCoeffsNoModSwitch(ct *Ciphertext) (ctOut *Ciphertext) {
-	if eval.pDcds == nil {
-		panic("cannot SlotsToCoeffs: evaluator does not have StC matrices")
+// without modulus switching. It returns an ErrParamMismatch error if the evaluator has no StC
+// matrices for the level of ct.
+func (eval *mfvEvaluator) SlotsToCoeffsNoModSwitch(ct *Ciphertext) (ctOut *Ciphertext, err error) {
+	level := ct.Level()
+	depth, err := eval.stcDepth(level)
+	if err != nil {
+		return nil, err
 	}
 
 	ctOut = ct.CopyNew().Ciphertext()
 
-	level := ct.Level()
-	depth := len(eval.pDcds[level]) - 1
 	for i := 0; i < depth-1; i++ {
 		ctOut = eval.LinearTransform(ctOut, eval.pDcds[level][i])[0]
 	}
@@ -1424,7 +1526,7 @@ func (eval *mfvEvaluator) SlotsToCoeffsNoModSwitch(ct *Ciphertext) (ctOut *Ciphe
 	tmp = eval.LinearTransform(tmp, eval.pDcds[level][depth])[0]
 
 	ctOut = eval.AddNew(tmp, ctOut)
-	return
+	return ctOut, nil
 }
 
 func (eval *mfvEvaluator) findBudgetInfo(ct *Ciphertext, noiseEstimator MFVNoiseEstimator) (invBudget, errorBits int) {
@@ -1476,30 +1578,37 @@ func (eval *mfvEvaluator) modSwitchAuto(ct *Ciphertext, noiseEstimator MFVNoiseE
 
 // SlotsToCoeffs returns ctOut whose coefficients are data stored in slots of ct
 // with automatic modulus switching as written in stcModDown
-func (eval *mfvEvaluator) SlotsToCoeffsAutoModSwitch(ct *Ciphertext, noiseEstimator MFVNoiseEstimator) (ctOut *Ciphertext, stcModDown []int) {
-	if eval.pDcds == nil {
-		panic("cannot SlotsToCoeffs: evaluator does not have StC matrices")
+func (eval *mfvEvaluator) SlotsToCoeffsAutoModSwitch(ct *Ciphertext, noiseEstimator MFVNoiseEstimator) (ctOut *Ciphertext, stcModDown []int, err error) {
+	depth, err := eval.stcDepth(ct.Level())
+	if err != nil {
+		return nil, nil, err
 	}
 
 	ctOut = ct.CopyNew().Ciphertext()
-	level := ct.Level()
-	depth := len(eval.pDcds[level]) - 1
 
+	var matrix, matrixRot *PtDiagMatrixT
 	stcModDown = make([]int, depth)
 	for i := 0; i < depth-1; i++ {
 		eval.modSwitchAuto(ctOut, noiseEstimator, i, stcModDown)
-		level = ctOut.Level()
-		ctOut = eval.LinearTransform(ctOut, eval.pDcds[level][i])[0]
+		if matrix, err = eval.stcMatrix(ctOut.Level(), i); err != nil {
+			return nil, nil, err
+		}
+		ctOut = eval.LinearTransform(ctOut, matrix)[0]
 	}
 	eval.modSwitchAuto(ctOut, noiseEstimator, depth-1, stcModDown)
-	level = ctOut.Level()
+	if matrix, err = eval.stcMatrix(ctOut.Level(), depth-1); err != nil {
+		return nil, nil, err
+	}
+	if matrixRot, err = eval.stcMatrix(ctOut.Level(), depth); err != nil {
+		return nil, nil, err
+	}
 	tmp := eval.RotateRowsNew(ctOut)
-	ctOut = eval.LinearTransform(ctOut, eval.pDcds[level][depth-1])[0]
-	tmp = eval.LinearTransform(tmp, eval.pDcds[level][depth])[0]
+	ctOut = eval.LinearTransform(ctOut, matrix)[0]
+	tmp = eval.LinearTransform(tmp, matrixRot)[0]
 
 	ctOut = eval.AddNew(tmp, ctOut)
 	invBudget, errorBits := eval.findBudgetInfo(ctOut, noiseEstimator)
 	fmt.Printf("StC Final [Budget | Error] : [%v | %v]\n", invBudget, errorBits)
 	fmt.Printf("StC modDown : %v\n\n", stcModDown)
-	return
+	return ctOut, stcModDown, nil
 }
@@ -0,0 +1,70 @@
+package RtF
+
+import (
+	"crypto/rand"
+	"reflect"
+	"testing"
+)
+
+// TestMFVEvaluatorCopies checks that ShallowCopy, WithKey and NewMFVEvaluators keep the permutations
+// of the rotation keys and the SlotsToCoeffs matrices of the receiver
+func TestMFVEvaluatorCopies(t *testing.T) {
+	cipher := NewToyRubatoCipher(RUBATO80S, RtFToyN10)
+	params, err := cipher.HalfBootParams().Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+	params.SetLogFVSlots(params.LogN())
+
+	kgen := NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := NewMFVEncoder(params)
+	ptDiagMats := encoder.GenSlotToCoeffMatFV(2) // radix = 2
+	rotKeys, err := kgen.GenRotationKeysForRotations(append(kgen.GenRotationIndexesForSlotsToCoeffsMat(ptDiagMats), 1), true, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evk := EvaluationKey{Rtks: rotKeys}
+	evaluator := NewMFVEvaluator(params, evk, ptDiagMats)
+
+	msg := make([]uint64, params.FVSlots())
+	for i := range msg {
+		msg[i] = SampleZqx(rand.Reader, params.PlainModulus())
+	}
+	pt := NewPlaintextFV(params)
+	encoder.EncodeUintSmall(msg, pt)
+	ct, err := NewMFVEncryptorFromPk(params, pk).EncryptNew(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptor := NewMFVDecryptor(params, sk)
+	eval := func(evaluator MFVEvaluator) (rotated, coeffs []uint64) {
+		stc, err := evaluator.SlotsToCoeffs(ct, make([]int, len(ptDiagMats[ct.Level()])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoder.DecodeUintSmallNew(decryptor.DecryptNew(evaluator.RotateColumnsNew(ct, 1))),
+			decryptor.DecryptNew(stc).Value()[0].Coeffs[0]
+	}
+
+	wantRotated, wantCoeffs := eval(evaluator)
+	for name, copied := range map[string]MFVEvaluator{
+		"ShallowCopy":      evaluator.ShallowCopy(),
+		"WithKey":          evaluator.WithKey(evk),
+		"WithKey(new key)": evaluator.WithKey(EvaluationKey{Rtks: &RotationKeySet{rotKeys.RotationKeySet}}),
+		"NewMFVEvaluators": NewMFVEvaluators(params, evk, ptDiagMats, 2)[1],
+		"WithNoiseModel":   evaluator.WithNoiseModel(NewMFVNoiseModel(params, 0)),
+	} {
+		rotated, coeffs := eval(copied)
+		if !reflect.DeepEqual(rotated, wantRotated) {
+			t.Errorf("%s: RotateColumns differs from the one of the receiver", name)
+		}
+		if !reflect.DeepEqual(coeffs, wantCoeffs) {
+			t.Errorf("%s: SlotsToCoeffs differs from the one of the receiver", name)
+		}
+	}
+}
@@ -96,6 +96,21 @@ func NewMFVNoiseEstimator(params *Parameters, sk *SecretKey) MFVNoiseEstimator {
 
 func (mfvNoiseEstimator *mfvNoiseEstimator) InvariantNoiseBudget(ciphertext *Ciphertext) int {
 
+	norm := mfvNoiseEstimator.noiseNorm(ciphertext)
+	modulusbigint := mfvNoiseEstimator.ringQs[ciphertext.Level()].ModulusBigint
+
+	// Step 5. Compute noise budget
+	bitCountDiff := getSignificantBitsCount(modulusbigint) - getSignificantBitsCount(norm) - 1
+	if bitCountDiff < 0 {
+		bitCountDiff = 0
+	}
+
+	return bitCountDiff
+}
+
+// noiseNorm returns the infinity norm of t*(c0 + c1*s) mod Q, centered, in a buffer of the estimator
+func (mfvNoiseEstimator *mfvNoiseEstimator) noiseNorm(ciphertext *Ciphertext) *big.Int {
+
 	if ciphertext.Degree() != 1 {
 		panic("Ciphertext degree should be 1")
 	}
@@ -156,13 +171,7 @@ func (mfvNoiseEstimator *mfvNoiseEstimator) InvariantNoiseBudget(ciphertext *Cip
 		}
 	}
 
-	// Step 5. Compute noise budget
-	bitCountDiff := getSignificantBitsCount(modulusbigint) - getSignificantBitsCount(norm) - 1
-	if bitCountDiff < 0 {
-		bitCountDiff = 0
-	}
-
-	return bitCountDiff
+	return norm
 }
 
 func getSignificantBitsCount(x *big.Int) int {
@@ -0,0 +1,289 @@
+package RtF
+
+import (
+	"math"
+	"math/big"
+)
+
+// MFVNoiseModel predicts the noise of FV ciphertexts without the secret key. An evaluator given a noise
+// model (MFVEvaluator.WithNoiseModel) attaches the predicted noise to the ciphertexts it outputs
+// (Ciphertext.NoiseEstimate), the ciphertexts without prediction are taken as fresh encryptions with a
+// public key (MFVEncryptor.Encrypt). MFVNoiseModel implements MFVNoiseEstimator, so it can replace the one
+// of NewMFVNoiseEstimator in CryptAutoModSwitch and SlotsToCoeffsAutoModSwitch.
+//
+// The model is conservative: it bounds the mean square and the absolute mean of the coefficients of the
+// noise, so that the predicted budget is below the measured one. The operands of a sum may be correlated
+// (e.g. the words of the linear layers of the ciphers), so their root mean squares add up; the noise of a
+// key switching or of a rounding is independent of the operand, so its mean square adds up. The
+// coefficients of the messages and of the plaintexts are taken as uniform in [0, t), those of the
+// negacyclic products as independent, and the infinity norm is the mean plus the Gaussian tail of the
+// root mean square at a probability 2^-tailLogProbability.
+type MFVNoiseModel struct {
+	params *Parameters
+	h      float64 // Hamming weight of the secret key
+
+	logN     float64
+	logT     float64
+	logTail  float64   // log2 of the ratio between the tail of the noise and its root mean square
+	logQ     []float64 // log2(Q) at each level
+	sigQ     []int     // significant bits of Q at each level, see InvariantNoiseBudget
+	logR     []float64 // log2(Q mod t) at each level
+	logQi    []float64
+	logKS    []float64 // log2 of the mean square added by a key switching at each level
+	logFloor float64   // log2 of the mean square of the flooring by the division by P, see keySwitch
+	logFresh float64
+}
+
+// NewMFVNoiseModel returns the noise model of params with a secret key of Hamming weight h, or of the
+// ternary secret of GenSecretKey if h <= 0
+func NewMFVNoiseModel(params *Parameters, h int) *MFVNoiseModel {
+	model := &MFVNoiseModel{params: params.Copy()}
+	N := float64(params.N())
+	if model.h = float64(h); h <= 0 {
+		model.h = 2 * N / 3 // GenSecretKey, uniform in {-1, 0, 1}
+	}
+	model.logN = math.Log2(N)
+	model.logT = math.Log2(float64(params.PlainModulus()))
+	// P(|x| > c*sigma) < 2*exp(-c^2/2) for a Gaussian x, over the N coefficients
+	model.logTail = 0.5 * math.Log2(2*(math.Log(2*N)+tailLogProbability*math.Ln2))
+
+	levels := params.QiCount()
+	model.logQ = make([]float64, levels)
+	model.sigQ = make([]int, levels)
+	model.logR = make([]float64, levels)
+	model.logQi = make([]float64, levels)
+	model.logKS = make([]float64, levels)
+
+	t := new(big.Int).SetUint64(params.PlainModulus())
+	var logP float64
+	for _, pi := range params.Pi() {
+		logP += math.Log2(float64(pi))
+	}
+	logSigma2 := 2 * math.Log2(params.Sigma())
+	// The division by P floors c0 and c1, so the error on c0 + c1*s is of mean square (1+h)/3 and its
+	// mean, 1/2 plus the one of s/2, is bounded by its root mean square
+	model.logFloor = math.Log2((1 + model.h) / 3)
+
+	qi := params.Qi()
+	alpha := params.Alpha()
+	for level := range qi {
+		model.logQi[level] = math.Log2(float64(qi[level]))
+		model.logQ[level] = model.logQi[level]
+		if level > 0 {
+			model.logQ[level] += model.logQ[level-1]
+		}
+		model.sigQ[level] = getSignificantBitsCount(params.QLvl(level))
+		model.logR[level] = math.Inf(-1)
+		if t.Sign() > 0 { // the parameters of CKKS have no plaintext modulus, see PlanCKKS
+			r := new(big.Int).Mod(params.QLvl(level), t)
+			model.logR[level] = math.Log2(float64(r.Uint64()))
+		}
+
+		// sum over the digits Q_j of N * Q_j^2/12 * sigma^2 / P^2
+		logKS := math.Inf(-1)
+		for start := 0; start <= level; start += alpha {
+			var logQj float64
+			for i := start; i < min(start+alpha, level+1); i++ {
+				logQj += model.logQi[i]
+			}
+			logKS = logAdd(logKS, model.logN+2*logQj-math.Log2(12)+logSigma2-2*logP)
+		}
+		model.logKS[level] = logAdd(logKS, model.logFloor)
+	}
+
+	// EncryptNew encrypts zero in QP and divides by P: (u*e + e0 + e1*s)/P plus the flooring
+	model.logFresh = logAdd(math.Log2(N/2+1+model.h)+logSigma2-2*logP, model.logFloor)
+	return model
+}
+
+// NoiseEstimate the noise predicted by an MFVNoiseModel, as bounds on the coefficients e_i of the invariant
+// noise e of a ciphertext, c0 + c1*s = Q/t*m + e mod Q
+type NoiseEstimate struct {
+	LogMeanSquare float64 // log2 of a bound on the mean square E[e_i^2]
+	LogMean       float64 // log2 of a bound on the absolute mean |E[e_i]|
+}
+
+// noNoise the noise of an exact operand
+var noNoise = NoiseEstimate{LogMeanSquare: math.Inf(-1), LogMean: math.Inf(-1)}
+
+// tailLogProbability is the log2 of the probability that a coefficient of the noise exceeds the bound
+// of the infinity norm, see MFVNoiseModel
+const tailLogProbability = 30
+
+// significantBitsDrop is the mean number of bits getSignificantBitsCount drops from an integer of more
+// than 32 bits: the bits after its leading one until the sixth one among the next 12 bits
+const significantBitsDrop = 10.03
+
+// InvariantNoiseBudget returns the predicted invariant noise budget of the ciphertext, in bits, counted
+// like the one of NewMFVNoiseEstimator: getSignificantBitsCount of Q minus the one of ||t*e|| minus 1
+func (model *MFVNoiseModel) InvariantNoiseBudget(ciphertext *Ciphertext) int {
+	logNorm := model.logNoiseNorm(ciphertext)
+	sigNorm := math.Round(logNorm)
+	if logNorm >= 32 {
+		sigNorm = math.Round(logNorm + 0.5 - significantBitsDrop)
+	}
+	return max(0, model.sigQ[ciphertext.Level()]-int(sigNorm)-1)
+}
+
+// Budget returns the predicted invariant noise budget of the ciphertext: log2(Q) - log2(||t*e||) - 1
+func (model *MFVNoiseModel) Budget(ciphertext *Ciphertext) float64 {
+	return max(0, model.logQ[ciphertext.Level()]-model.logNoiseNorm(ciphertext)-1)
+}
+
+// logNoiseNorm returns the log2 of the bound on the infinity norm of t*e
+func (model *MFVNoiseModel) logNoiseNorm(ciphertext *Ciphertext) float64 {
+	noise := model.noiseOf(ciphertext)
+	return model.logT + logAdd(noise.LogMean, 0.5*noise.LogMeanSquare+model.logTail)
+}
+
+// noiseOf returns the noise of an operand. The invariant noise of a fresh ciphertext, as the one of a
+// plaintext Q/t*m rounded to Delta*m, includes -(Q mod t)/t*m, which the operations carry along with the
+// rest of the noise.
+func (model *MFVNoiseModel) noiseOf(op Operand) NoiseEstimate {
+	message := model.message(op.Level())
+	ct, isCiphertext := op.(*Ciphertext)
+	if !isCiphertext {
+		return message
+	}
+	if noise, ok := ct.NoiseEstimate(); ok {
+		return noise
+	}
+	return addIndependent(message, model.divisionByP(model.logFresh))
+}
+
+// message the noise -(Q mod t)/t*m of a message uniform in [0, t) at the level
+func (model *MFVNoiseModel) message(level int) NoiseEstimate {
+	return NoiseEstimate{LogMeanSquare: 2*model.logR[level] - math.Log2(3), LogMean: model.logR[level] - 1}
+}
+
+func (model *MFVNoiseModel) add(op0, op1 Operand) NoiseEstimate {
+	return addCorrelated(model.noiseOf(op0), model.noiseOf(op1))
+}
+
+func (model *MFVNoiseModel) mulScalar(op Operand, scalar uint64) NoiseEstimate {
+	if scalar == 0 {
+		return noNoise
+	}
+	return scaleNoise(model.noiseOf(op), math.Log2(float64(scalar)))
+}
+
+// mulPlaintext the product by a plaintext of R_t, see mulUniform
+func (model *MFVNoiseModel) mulPlaintext(op Operand) NoiseEstimate {
+	return model.mulUniform(model.noiseOf(op), 2*model.logT-math.Log2(3), model.logT-1)
+}
+
+// mulUniform the negacyclic product of the noise by a polynomial of independent coefficients of mean
+// square 2^logMeanSquare and mean 2^logMean: the N products of a coefficient sum up with their means,
+// which add up coherently, and so do the signed partial sums of the noise, of about sqrt(N) times its root
+// mean square even if it is of mean zero
+func (model *MFVNoiseModel) mulUniform(noise NoiseEstimate, logMeanSquare, logMean float64) NoiseEstimate {
+	return NoiseEstimate{
+		LogMeanSquare: logAdd(noise.LogMeanSquare+logMeanSquare+model.logN, 2*(noise.LogMean+logMean+model.logN)),
+		LogMean:       logAdd(noise.LogMean, 0.5*(noise.LogMeanSquare-model.logN)) + logMean + model.logN,
+	}
+}
+
+// tensor the product of two ciphertexts scaled by t/Q: t/Q*C0*C1 = Q/t*m0*m1 + e0*u1 + e1*u0 - t/Q*e0*e1
+// mod Q, C = Q/t*m + e + Q*k being c0 + c1*s over the integers and u = t/Q*C, plus the rounding on
+// (1, s, s^2). The basis extension lifts c0 and c1 to [0, Q), so the coefficients of u are of mean square
+// t^2*(1+h)/3, and of mean t/2*(1 + sum of h signed coefficients of the secret), bounded by the root mean
+// square.
+func (model *MFVNoiseModel) tensor(op0, op1 Operand, level int) NoiseEstimate {
+	n0, n1 := model.noiseOf(op0), model.noiseOf(op1)
+	logMeanSquare := 2*model.logT + math.Log2((1+model.h)/3)
+	res := addCorrelated(model.mulUniform(n0, logMeanSquare, 0.5*logMeanSquare), model.mulUniform(n1, logMeanSquare, 0.5*logMeanSquare))
+	logTQ := model.logT - model.logQ[level]
+	res = addCorrelated(res, model.mulUniform(n0, n1.LogMeanSquare+2*logTQ, n1.LogMean+logTQ))
+	return addIndependent(res, zeroMean(math.Log2((1+model.h+model.h*model.h)/12)))
+}
+
+// divisionByP the noise of mean square 2^logMeanSquare added by a division by P, whose mean is the one of
+// the flooring
+func (model *MFVNoiseModel) divisionByP(logMeanSquare float64) NoiseEstimate {
+	return NoiseEstimate{LogMeanSquare: logMeanSquare, LogMean: 0.5 * model.logFloor}
+}
+
+func (model *MFVNoiseModel) keySwitch(op Operand, level int) NoiseEstimate {
+	return addIndependent(model.noiseOf(op), model.divisionByP(model.logKS[level]))
+}
+
+// modSwitch dividing by the last nbModSwitch moduli and rounding
+func (model *MFVNoiseModel) modSwitch(op Operand, level, nbModSwitch int) NoiseEstimate {
+	noise := model.noiseOf(op)
+	for i := 0; i < nbModSwitch; i++ {
+		noise = addIndependent(scaleNoise(noise, -model.logQi[level-i]), zeroMean(math.Log2((1+model.h)/12)))
+	}
+	return noise
+}
+
+// rotate the key switching of the automorphism X -> X^(5^k) of a column rotation, which permutes the
+// coefficients of the noise up to their sign: their mean square is kept and their mean is the one of N
+// signed coefficients. The one of a row rotation, X -> X^-1, only reverses and negates them.
+func (model *MFVNoiseModel) rotate(op Operand, level int) NoiseEstimate {
+	noise := model.keySwitch(op, level)
+	noise.LogMean = 0.5 * (noise.LogMeanSquare - model.logN)
+	return noise
+}
+
+// linearTransform the sum of the products by the diagonals of the matrix. The baby-step giant-step algorithm
+// rotates some products after the multiplication, so each product is of the mean square of the one of the
+// diagonal 0, but only the ones of the diagonals of the first giant step keep its mean, see rotate. The
+// products are divided by P once per giant step, after the multiplication.
+func (model *MFVNoiseModel) linearTransform(op Operand, level int, matrix *PtDiagMatrixT) NoiseEstimate {
+	coeffs := matrix.coeffs
+	if coeffs == (NoiseEstimate{}) { // not encoded by EncodeDiagMatrixT, taken as uniform in [0, t)
+		coeffs = NoiseEstimate{LogMeanSquare: 2*model.logT - math.Log2(3), LogMean: model.logT - 1}
+	}
+	product := model.mulUniform(addIndependent(model.noiseOf(op), zeroMean(model.logKS[level])), coeffs.LogMeanSquare, coeffs.LogMean)
+	logDiagonals := math.Log2(float64(len(matrix.Vec)))
+	noise := NoiseEstimate{
+		LogMeanSquare: product.LogMeanSquare + 2*logDiagonals,
+		LogMean:       logAdd(product.LogMean, 0.5*(product.LogMeanSquare-model.logN)) + logDiagonals,
+	}
+
+	giantSteps := map[int]bool{0: true}
+	if !matrix.naive {
+		for k := range matrix.Vec {
+			giantSteps[k/matrix.N1] = true
+		}
+	}
+	return addIndependent(noise, model.divisionByP(model.logKS[level]+math.Log2(float64(len(giantSteps)))))
+}
+
+// addCorrelated the noise of a sum of possibly correlated operands: the root mean squares add up
+func addCorrelated(n0, n1 NoiseEstimate) NoiseEstimate {
+	return NoiseEstimate{
+		LogMeanSquare: 2 * logAdd(0.5*n0.LogMeanSquare, 0.5*n1.LogMeanSquare),
+		LogMean:       logAdd(n0.LogMean, n1.LogMean),
+	}
+}
+
+// addIndependent the noise of a sum of independent operands: the mean squares add up, with the product of
+// the means
+func addIndependent(n0, n1 NoiseEstimate) NoiseEstimate {
+	return NoiseEstimate{
+		LogMeanSquare: logAdd(logAdd(n0.LogMeanSquare, n1.LogMeanSquare), 1+n0.LogMean+n1.LogMean),
+		LogMean:       logAdd(n0.LogMean, n1.LogMean),
+	}
+}
+
+// zeroMean a noise of mean zero and of variance 2^logVar
+func zeroMean(logVar float64) NoiseEstimate {
+	return NoiseEstimate{LogMeanSquare: logVar, LogMean: math.Inf(-1)}
+}
+
+// scaleNoise multiplies the noise by 2^logScalar
+func scaleNoise(noise NoiseEstimate, logScalar float64) NoiseEstimate {
+	return NoiseEstimate{LogMeanSquare: noise.LogMeanSquare + 2*logScalar, LogMean: noise.LogMean + logScalar}
+}
+
+// logAdd returns log2(2^a + 2^b)
+func logAdd(a, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	return a + math.Log2(1+math.Exp2(b-a))
+}
@@ -0,0 +1,186 @@
+package RtF
+
+import (
+	"crypto/rand"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// noiseModelTest a toy Rubato instance whose evaluator attaches the noise predicted by a MFVNoiseModel,
+// along with the key-based estimator to check it
+type noiseModelTest struct {
+	params    *Parameters
+	encoder   MFVEncoder
+	encryptor MFVEncryptor
+	evaluator MFVEvaluator
+	model     *MFVNoiseModel
+	estimator MFVNoiseEstimator
+}
+
+// newNoiseModelTest generates the keys with the secret of Hamming weight h, or the dense one if h <= 0
+func newNoiseModelTest(cipher SymmetricCipher, h int) *noiseModelTest {
+	params, err := cipher.HalfBootParams().Params()
+	if err != nil {
+		panic(err)
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+	params.SetLogFVSlots(params.LogN())
+
+	kgen := NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPair()
+	if h > 0 {
+		sk, pk, err = kgen.GenKeyPairSparse(h)
+	}
+	if err != nil {
+		panic(err)
+	}
+	encoder := NewMFVEncoder(params)
+	ptDiagMats := encoder.GenSlotToCoeffMatFV(2) // radix = 2
+	rotations := append(kgen.GenRotationIndexesForSlotsToCoeffsMat(ptDiagMats), 1)
+	rotKeys, err := kgen.GenRotationKeysForRotations(rotations, true, sk)
+	if err != nil {
+		panic(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		panic(err)
+	}
+	model := NewMFVNoiseModel(params, h)
+	evaluator := NewMFVEvaluator(params, EvaluationKey{Rlk: rlk, Rtks: rotKeys}, ptDiagMats)
+
+	return &noiseModelTest{
+		params:    params,
+		encoder:   encoder,
+		encryptor: NewMFVEncryptorFromPk(params, pk),
+		evaluator: evaluator.WithNoiseModel(model),
+		model:     model,
+		estimator: NewMFVNoiseEstimator(params, sk),
+	}
+}
+
+func (nt *noiseModelTest) randomMessage() []uint64 {
+	msg := make([]uint64, nt.params.FVSlots())
+	for i := range msg {
+		msg[i] = SampleZqx(rand.Reader, nt.params.PlainModulus())
+	}
+	return msg
+}
+
+func (nt *noiseModelTest) encryptRandom() *Ciphertext {
+	pt := NewPlaintextFV(nt.params)
+	nt.encoder.EncodeUintSmall(nt.randomMessage(), pt)
+	ct, err := nt.encryptor.EncryptNew(pt)
+	if err != nil {
+		panic(err)
+	}
+	return ct
+}
+
+// measuredBudget returns the invariant noise budget of ct measured with the secret key, log2(Q) -
+// log2(||t*e||) - 1, without the rounding of the significant bits of InvariantNoiseBudget
+func (nt *noiseModelTest) measuredBudget(ct *Ciphertext) float64 {
+	norm := nt.estimator.(*mfvNoiseEstimator).noiseNorm(ct)
+	shift := max(0, norm.BitLen()-53)
+	top, _ := new(big.Float).SetInt(new(big.Int).Rsh(norm, uint(shift))).Float64()
+	return nt.model.logQ[ct.Level()] - math.Log2(top) - float64(shift) - 1
+}
+
+// check asserts that the predicted budget of ct is at most the measured one, by less than tolerance bits
+func (nt *noiseModelTest) check(t *testing.T, name string, ct *Ciphertext, tolerance float64) {
+	t.Helper()
+	if _, ok := ct.NoiseEstimate(); !ok {
+		t.Errorf("%s: no noise estimate attached", name)
+	}
+	predicted, measured := nt.model.Budget(ct), nt.measuredBudget(ct)
+	if predicted > measured || measured-predicted > tolerance {
+		t.Errorf("%s: predicted budget of %.2f bits, measured %.2f bits", name, predicted, measured)
+	}
+}
+
+// TestMFVNoiseModel checks the predicted budget after each operation against the key-based estimator
+func TestMFVNoiseModel(t *testing.T) {
+	cipher := NewToyRubatoCipher(RUBATO80S, RtFToyN10)
+	for _, h := range []int{cipher.HalfBootParams().H, 0} {
+		nt := newNoiseModelTest(cipher, h)
+		eval := nt.evaluator
+		// the tail bound of the norm alone is about 2 bits above the largest of the N coefficients, the bound
+		// of a square up to 9 bits
+		tolerance := 9.0
+
+		ct0, ct1 := nt.encryptRandom(), nt.encryptRandom()
+		if _, ok := ct0.NoiseEstimate(); ok {
+			t.Error("a fresh ciphertext has a noise estimate")
+		}
+		nt.check(t, "Add", eval.AddNew(ct0, ct1), tolerance)
+		nt.check(t, "Sub", eval.SubNew(ct0, ct1), tolerance)
+		nt.check(t, "MulScalar", eval.MulScalarNew(ct0, 1<<20), tolerance)
+
+		ptMul := NewPlaintextMul(nt.params)
+		nt.encoder.EncodeUintMulSmall(nt.randomMessage(), ptMul)
+		nt.check(t, "Mul PlaintextMul", eval.MulNew(ct0, ptMul), tolerance)
+
+		prod := eval.RelinearizeNew(eval.MulNew(ct0, ct1))
+		nt.check(t, "Mul", prod, tolerance)
+		nt.check(t, "Square", eval.RelinearizeNew(eval.MulNew(prod, prod)), tolerance)
+		nt.check(t, "RotateColumns", eval.RotateColumnsNew(prod, 1), tolerance)
+		nt.check(t, "RotateRows", eval.RotateRowsNew(prod), tolerance)
+
+		switched := prod.CopyNew().Ciphertext()
+		eval.ModSwitch(switched, switched)
+		nt.check(t, "ModSwitch", switched, tolerance)
+		eval.ModSwitchMany(switched, switched, 10)
+		nt.check(t, "ModSwitchMany", switched, tolerance)
+		nt.check(t, "Mul after ModSwitch", eval.RelinearizeNew(eval.MulNew(switched, switched)), tolerance)
+
+		stc, err := eval.SlotsToCoeffs(prod, []int{0, 1, 1, 1, 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nt.check(t, "SlotsToCoeffs", stc, tolerance)
+	}
+}
+
+// TestMFVNoiseModelRubato checks the predicted budget of the keystream of a toy Rubato and of its
+// SlotsToCoeffs: the model sums the words of the linear layers as if they were fully correlated, so it
+// underestimates the budget of the keystream by 4 to 13 bits, and the one of its SlotsToCoeffs by about
+// 23 bits, whose mod switches would bring the measured noise down to their rounding
+func TestMFVNoiseModelRubato(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the homomorphic evaluation in short mode")
+	}
+	cipher := NewToyRubatoCipher(RUBATO80S, RtFToyN10)
+	nt := newNoiseModelTest(cipher, cipher.HalfBootParams().H)
+	tolerance, stcTolerance := 13.0, 26.0
+
+	nonces := make([][]byte, nt.params.FVSlots())
+	for i := range nonces {
+		nonces[i] = make([]byte, 8)
+		rand.Read(nonces[i])
+	}
+	counter := make([]byte, 8)
+	rand.Read(counter)
+	key := rubatoTestKey(cipher.BlockSize())
+
+	modDown := cipher.ModDownParams()
+	mfvCipher, err := cipher.NewMFVCipher(nt.params, nt.encoder, nt.encryptor, nt.evaluator, modDown.CipherModDown[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kCt, err := mfvCipher.EncKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystream, err := mfvCipher.Crypt(nonces, counter, kCt, modDown.CipherModDown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range keystream {
+		nt.check(t, "Rubato", keystream[i], tolerance)
+	}
+	stc, err := nt.evaluator.SlotsToCoeffs(keystream[0], modDown.StCModDown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nt.check(t, "Rubato SlotsToCoeffs", stc, stcTolerance)
+}
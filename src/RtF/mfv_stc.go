@@ -5,7 +5,7 @@
 package RtF
 
 import (
-	"flhhe/src/utils"
+	"fmt"
 
 	"github.com/tuneinsight/lattigo/v6/ring"
 	"github.com/tuneinsight/lattigo/v6/utils/structs"
@@ -31,14 +31,15 @@ type PlaintextDiagMatrixT struct {
 	naive      bool
 }
 
-// GenSTCMatrix generates slot to coefficients matrix
-func (encoder *mfvEncoder) GenSTCMatrix(radix int) (pDcds [][]*PlaintextDiagMatrixT) {
+// GenSTCMatrix generates slot to coefficients matrix. It returns an error if the plaintext modulus has no
+// primitive root.
+func (encoder *mfvEncoder) GenSTCMatrix(radix int) (pDcds [][]*PlaintextDiagMatrixT, err error) {
 	params := encoder.params
 	//modCount := len(params.qi)
 	modCount := params.QiCount()
 	pDcds = make([][]*PlaintextDiagMatrixT, modCount)
 
-	var genDcdFunc func(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64)
+	var genDcdFunc func(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64, err error)
 	switch radix {
 	case 0:
 		genDcdFunc = GenDecodingMatsInOne
@@ -48,14 +49,17 @@ func (encoder *mfvEncoder) GenSTCMatrix(radix int) (pDcds [][]*PlaintextDiagMatr
 		genDcdFunc = GenDecodingMats
 	}
 	for level := 0; level < modCount; level++ {
-		pVecDcd := genDcdFunc(params.logFVSlots, params.plainModulus)
+		pVecDcd, err := genDcdFunc(params.logFVSlots, params.plainModulus)
+		if err != nil {
+			return nil, err
+		}
 		pDcds[level] = make([]*PlaintextDiagMatrixT, len(pVecDcd))
 
 		for i := 0; i < len(pDcds[level]); i++ {
 			pDcds[level][i] = encoder.EncodeStCDiagMatrixT(level, pVecDcd[i], 16.0, params.logFVSlots)
 		}
 	}
-	return
+	return pDcds, nil
 }
 
 // EncodeStCDiagMatrixT encodes a diagonalized plaintext matrix into PtDiagMatrixT struct.
@@ -145,8 +149,11 @@ func (encoder *mfvEncoder) encodeStCDiagonalT(level, logFVSlots int, m []uint64)
 }
 
 // GenDecodingMats generates decoding matrix that is factorized into sparse block diagonal matrices with radix 1
-func GenDecodingMats(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64) {
-	roots := ComputePrimitiveRoots(1<<(logSlots+1), plainModulus)
+func GenDecodingMats(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64, err error) {
+	roots, err := ComputePrimitiveRoots(1<<(logSlots+1), plainModulus)
+	if err != nil {
+		return nil, err
+	}
 	diabMats := GenDiagDecMatrix(logSlots, roots)
 	depth := len(diabMats) - 1
 
@@ -160,8 +167,11 @@ func GenDecodingMats(logSlots int, plainModulus uint64) (plainVector []map[int][
 }
 
 // GenDecodingMatsRad2 generates decoding matrix that is factorized into sparse block diagonal matrices with radix 2
-func GenDecodingMatsRad2(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64) {
-	roots := ComputePrimitiveRoots(1<<(logSlots+1), plainModulus)
+func GenDecodingMatsRad2(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64, err error) {
+	roots, err := ComputePrimitiveRoots(1<<(logSlots+1), plainModulus)
+	if err != nil {
+		return nil, err
+	}
 	diabMats := GenDiagDecMatrix(logSlots, roots)
 	depth := len(diabMats) - 1
 
@@ -182,11 +192,14 @@ func GenDecodingMatsRad2(logSlots int, plainModulus uint64) (plainVector []map[i
 }
 
 // GenDecodingMatsInOne generates decoding matrix which is not factorized
-func GenDecodingMatsInOne(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64) {
+func GenDecodingMatsInOne(logSlots int, plainModulus uint64) (plainVector []map[int][]uint64, err error) {
 	if logSlots != 4 {
 		panic("cannot GenDecodingMatsInOne: logSlots should be 4")
 	}
-	roots := ComputePrimitiveRoots(1<<(logSlots+1), plainModulus)
+	roots, err := ComputePrimitiveRoots(1<<(logSlots+1), plainModulus)
+	if err != nil {
+		return nil, err
+	}
 	diabMats := GenDiagDecMatrix(logSlots, roots)
 
 	plainVector = make([]map[int][]uint64, 2)
@@ -321,10 +334,16 @@ func GenDiagDecMatrix(logN int, roots []uint64) (res []map[int][]uint64) {
 	return
 }
 
-// ComputePrimitiveRoots compute M-th root of unity
-func ComputePrimitiveRoots(M int, plainModulus uint64) (roots []uint64) {
+// ComputePrimitiveRoots compute M-th root of unity. It returns an error if plainModulus has no primitive M-th
+// root of unity, i.e. M doesn't divide plainModulus-1.
+func ComputePrimitiveRoots(M int, plainModulus uint64) (roots []uint64, err error) {
+	if M <= 0 || (plainModulus-1)%uint64(M) != 0 {
+		return nil, fmt.Errorf("no primitive %d-th root of unity modulo %d", M, plainModulus)
+	}
 	g, _, err := ring.PrimitiveRoot(plainModulus, nil)
-	utils.HandleError(err)
+	if err != nil {
+		return nil, fmt.Errorf("no primitive root modulo %d: %w", plainModulus, err)
+	}
 	e := uint64((int(plainModulus) - 1) / M)
 	w := ring.ModExp(g, e, plainModulus)
 
@@ -333,5 +352,5 @@ func ComputePrimitiveRoots(M int, plainModulus uint64) (roots []uint64) {
 	for i := 1; i < M; i++ {
 		roots[i] = (roots[i-1] * w) % plainModulus
 	}
-	return
+	return roots, nil
 }
@@ -0,0 +1,359 @@
+package RtF
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// roundTrace is called with the state of a cipher after the mod down of each round, or with the
+// ciphertext of SlotsToCoeffs after the mod down of each depth
+type roundTrace func(round int, state []*Ciphertext)
+
+func (trace roundTrace) call(round int, state []*Ciphertext) {
+	if trace != nil {
+		trace(round, state)
+	}
+}
+
+// roundTracer is implemented by the MFV ciphers whose Crypt reports each round to a roundTrace
+type roundTracer interface {
+	setTrace(trace roundTrace)
+}
+
+// ModDownStage the noise budget of one stage of a mod down schedule: a round of the cipher (the
+// round 0 is the initial mod down of the key) or a depth of SlotsToCoeffs
+type ModDownStage struct {
+	Name    string
+	ModDown int // number of moduli dropped by the stage
+	Level   int // level after the mod down
+	LogQ    int
+	Budget  int // minimal invariant noise budget of the state after the mod down (bits)
+}
+
+// ModDownReport the result of SearchModDown
+type ModDownReport struct {
+	Cipher  string
+	Margin  int
+	ModDown ModDownParams
+	Stages  []ModDownStage
+
+	// FinalBudget the budget of the keystream after Crypt and SlotsToCoeffs with ModDown,
+	// PipelineBudget the one of the server pipeline: CryptNoModSwitch, SlotsToCoeffs with
+	// ModDown.StCModDown and a mod switch to the level 0 before HalfBoot
+	FinalBudget    int
+	PipelineBudget int
+
+	Evaluations int
+	Duration    time.Duration
+}
+
+// Entry formats the schedule as an entry of the mod down tables of rtf_params.go
+func (report *ModDownReport) Entry() string {
+	ints := func(values []int) string {
+		s := make([]string, len(values))
+		for i, v := range values {
+			s[i] = fmt.Sprint(v)
+		}
+		return strings.Join(s, ", ")
+	}
+	return fmt.Sprintf("{\n\t// %s with radix 2, found by SearchModDown with a margin of %d bits\n"+
+		"\tCipherModDown: []int{%s},\n\tStCModDown:    []int{%s},\n},\n",
+		report.Cipher, report.Margin, ints(report.ModDown.CipherModDown), ints(report.ModDown.StCModDown))
+}
+
+// String the remaining budget at each stage of the schedule
+func (report *ModDownReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s mod down schedule (margin %d bits, %d evaluations in %s)\n",
+		report.Cipher, report.Margin, report.Evaluations, report.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&sb, "%-12s %8s %6s %6s %7s\n", "stage", "mod down", "level", "logQ", "budget")
+	for _, stage := range report.Stages {
+		fmt.Fprintf(&sb, "%-12s %8d %6d %6d %7d\n", stage.Name, stage.ModDown, stage.Level, stage.LogQ, stage.Budget)
+	}
+	fmt.Fprintf(&sb, "budget after SlotsToCoeffs: %d bits, in the server pipeline (level 0): %d bits\n",
+		report.FinalBudget, report.PipelineBudget)
+	return sb.String()
+}
+
+// modDownSearch the keys and the inputs of the evaluations of SearchModDown
+type modDownSearch struct {
+	params         *Parameters
+	mfvCipher      MFVCipher
+	evaluator      *mfvEvaluator
+	noiseEstimator MFVNoiseEstimator
+
+	nonces  [][]byte
+	counter []byte
+	kCt     []*Ciphertext
+
+	worst       int // output word of least budget without mod down, the one followed by the search
+	stages      []ModDownStage
+	evaluations int
+}
+
+// modDownTrial the evaluation of a schedule: the keystream word after the cipher and the budget of
+// each stage, the rounds of the cipher followed by the depths of SlotsToCoeffs
+type modDownTrial struct {
+	keystream *Ciphertext
+	stages    []ModDownStage
+	level     int // level after SlotsToCoeffs
+	budget    int // least budget after SlotsToCoeffs and after the mod switch to the level 0
+}
+
+// SearchModDown searches the mod down schedule of cipher on its RtF parameters with fresh keys. The
+// stages are visited in the order of the evaluation and each one drops as many moduli as possible
+// while the keystream keeps a noise budget of at least margin bits after SlotsToCoeffs and after the
+// mod switch to the level 0 of the server, so the moduli are dropped as early as possible. The budget
+// is measured with the secret key (MFVNoiseEstimator), the search is meant to be run offline once
+// per parameter set: the cipher is evaluated on all the slots a few times per round (binary search).
+func SearchModDown(cipher SymmetricCipher, margin int) (ModDownParams, *ModDownReport, error) {
+	start := time.Now()
+	s, err := newModDownSearch(cipher)
+	if err != nil {
+		return ModDownParams{}, nil, err
+	}
+	maxLevel := s.params.MaxLevel()
+
+	schedule := ModDownParams{
+		CipherModDown: make([]int, len(cipher.ModDownParams().CipherModDown)),
+		StCModDown:    make([]int, len(s.evaluator.pDcds[maxLevel])-1),
+	}
+
+	// Without any mod down the only budget lost is the one of the switch to the level 0
+	s.evaluations++
+	if err = s.mfvCipher.Reset(0); err != nil {
+		return ModDownParams{}, nil, err
+	}
+	outputs, err := s.mfvCipher.Crypt(s.nonces, s.counter, s.kCt, schedule.CipherModDown)
+	if err != nil {
+		return ModDownParams{}, nil, err
+	}
+	s.worst = s.minBudget(outputs)
+	trial, err := s.slotsToCoeffs(outputs[s.worst], s.stages, schedule.StCModDown)
+	if err != nil {
+		return ModDownParams{}, nil, err
+	}
+	if trial.budget < margin {
+		return ModDownParams{}, nil, fmt.Errorf("%s: budget of %d bits without mod down, below the margin of %d bits",
+			cipher.Name(), trial.budget, margin)
+	}
+
+	// An evaluation error makes the mod down infeasible and stops the search after the stage
+	dropped := 0
+	for i := range schedule.CipherModDown {
+		trials := map[int]*modDownTrial{0: trial}
+		schedule.CipherModDown[i] = largest(maxLevel-dropped, func(d int) bool {
+			schedule.CipherModDown[i] = d
+			next, cryptErr := s.crypt(schedule)
+			if cryptErr != nil {
+				err = cryptErr
+				return false
+			}
+			trials[d] = next
+			// A cipher may ignore a mod down (e.g. the initial one of Pasta), keep it at 0
+			return next.budget >= margin && next.level == maxLevel-dropped-d
+		})
+		if err != nil {
+			return ModDownParams{}, nil, err
+		}
+		trial = trials[schedule.CipherModDown[i]]
+		dropped += schedule.CipherModDown[i]
+	}
+	for i := range schedule.StCModDown {
+		trials := map[int]*modDownTrial{0: trial}
+		schedule.StCModDown[i] = largest(maxLevel-dropped, func(d int) bool {
+			schedule.StCModDown[i] = d
+			next, stcErr := s.slotsToCoeffs(trial.keystream, trial.stages[:len(schedule.CipherModDown)], schedule.StCModDown)
+			if stcErr != nil {
+				err = stcErr
+				return false
+			}
+			trials[d] = next
+			return next.budget >= margin
+		})
+		if err != nil {
+			return ModDownParams{}, nil, err
+		}
+		trial = trials[schedule.StCModDown[i]]
+		dropped += schedule.StCModDown[i]
+	}
+
+	report := &ModDownReport{
+		Cipher:      cipher.Name(),
+		Margin:      margin,
+		ModDown:     schedule,
+		Stages:      trial.stages,
+		FinalBudget: trial.budget,
+	}
+	for i := range report.Stages {
+		if i < len(schedule.CipherModDown) {
+			report.Stages[i].Name = fmt.Sprintf("round %d", i)
+			report.Stages[i].ModDown = schedule.CipherModDown[i]
+		} else {
+			report.Stages[i].Name = fmt.Sprintf("StC depth %d", i-len(schedule.CipherModDown))
+			report.Stages[i].ModDown = schedule.StCModDown[i-len(schedule.CipherModDown)]
+		}
+	}
+
+	// The server evaluates the cipher without mod down and only applies the one of SlotsToCoeffs
+	s.evaluations++
+	if err = s.mfvCipher.Reset(schedule.CipherModDown[0]); err != nil {
+		return ModDownParams{}, nil, err
+	}
+	keystream, err := s.mfvCipher.CryptNoModSwitch(s.nonces, s.counter, s.kCt)
+	if err != nil {
+		return ModDownParams{}, nil, err
+	}
+	pipeline, err := s.slotsToCoeffs(keystream[s.worst], nil, schedule.StCModDown)
+	if err != nil {
+		return ModDownParams{}, nil, err
+	}
+	report.PipelineBudget = pipeline.budget
+	report.Evaluations = s.evaluations
+	report.Duration = time.Since(start)
+	if report.PipelineBudget < margin {
+		return schedule, report, fmt.Errorf("%s: budget of %d bits in the server pipeline, below the margin of %d bits",
+			cipher.Name(), report.PipelineBudget, margin)
+	}
+	return schedule, report, nil
+}
+
+// largest returns the largest d of [0, max] such that feasible(d), feasible(0) must hold and
+// feasible must be monotonic
+func largest(max int, feasible func(d int) bool) int {
+	lo, hi := 0, max
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if feasible(mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+func newModDownSearch(cipher SymmetricCipher) (*modDownSearch, error) {
+	hbtParams := cipher.HalfBootParams()
+	params, err := hbtParams.Params()
+	if err != nil {
+		return nil, err
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+	params.SetLogFVSlots(params.LogN())
+
+	kgen := NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPairSparse(hbtParams.H)
+	if err != nil {
+		return nil, err
+	}
+	encoder := NewMFVEncoder(params)
+	ptDiagMats := encoder.GenSlotToCoeffMatFV(2) // radix = 2
+	rotKeys, err := kgen.GenRotationKeysForRotations(kgen.GenRotationIndexesForSlotsToCoeffsMat(ptDiagMats), true, sk)
+	if err != nil {
+		return nil, err
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	evaluator := NewMFVEvaluator(params, EvaluationKey{Rlk: rlk, Rtks: rotKeys}, ptDiagMats)
+
+	mfvCipher, err := cipher.NewMFVCipher(params, encoder, NewMFVEncryptorFromPk(params, pk), evaluator, 0)
+	if err != nil {
+		return nil, err
+	}
+	tracer, ok := mfvCipher.(roundTracer)
+	if !ok {
+		return nil, fmt.Errorf("%s: the MFV cipher doesn't report its rounds", cipher.Name())
+	}
+
+	key := make([]uint64, cipher.BlockSize())
+	for i := range key {
+		key[i] = uint64(i + 1)
+	}
+	prng, err := NewPRNG()
+	if err != nil {
+		return nil, err
+	}
+	nonces := make([][]byte, params.FVSlots())
+	for i := range nonces {
+		nonces[i] = make([]byte, 8)
+		if _, err = prng.Read(nonces[i]); err != nil {
+			return nil, err
+		}
+	}
+	counter := make([]byte, 8)
+	if _, err = prng.Read(counter); err != nil {
+		return nil, err
+	}
+	kCt, err := mfvCipher.EncKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &modDownSearch{
+		params:         params,
+		mfvCipher:      mfvCipher,
+		evaluator:      evaluator.(*mfvEvaluator),
+		noiseEstimator: NewMFVNoiseEstimator(params, sk),
+		nonces:         nonces,
+		counter:        counter,
+		kCt:            kCt,
+	}
+	tracer.setTrace(func(round int, state []*Ciphertext) {
+		s.stages = append(s.stages, s.stage(state[s.minBudget(state)]))
+	})
+	return s, nil
+}
+
+// crypt evaluates the cipher and SlotsToCoeffs with the schedule, on the output word followed by the
+// search: the budgets of the words are close and SlotsToCoeffs on all of them would dominate the search
+func (s *modDownSearch) crypt(schedule ModDownParams) (*modDownTrial, error) {
+	s.evaluations++
+	s.stages = nil
+	if err := s.mfvCipher.Reset(schedule.CipherModDown[0]); err != nil {
+		return nil, err
+	}
+	keystream, err := s.mfvCipher.Crypt(s.nonces, s.counter, s.kCt, schedule.CipherModDown)
+	if err != nil {
+		return nil, err
+	}
+	return s.slotsToCoeffs(keystream[s.worst], s.stages, schedule.StCModDown)
+}
+
+// slotsToCoeffs evaluates SlotsToCoeffs after the rounds of the cipher given by keystream and stages
+func (s *modDownSearch) slotsToCoeffs(keystream *Ciphertext, stages []ModDownStage, stcModDown []int) (*modDownTrial, error) {
+	trial := &modDownTrial{keystream: keystream, stages: append([]ModDownStage{}, stages...)}
+	ct, err := s.evaluator.slotsToCoeffsTrace(keystream, stcModDown, func(depth int, state []*Ciphertext) {
+		trial.stages = append(trial.stages, s.stage(state[0]))
+	})
+	if err != nil {
+		return nil, err
+	}
+	trial.level, trial.budget = ct.Level(), s.noiseEstimator.InvariantNoiseBudget(ct)
+	if trial.level > 0 {
+		s.evaluator.ModSwitchMany(ct, ct, trial.level)
+		trial.budget = min(trial.budget, s.noiseEstimator.InvariantNoiseBudget(ct))
+	}
+	return trial, nil
+}
+
+func (s *modDownSearch) stage(ct *Ciphertext) ModDownStage {
+	return ModDownStage{
+		Level:  ct.Level(),
+		LogQ:   s.params.LogQLvl(ct.Level()),
+		Budget: s.noiseEstimator.InvariantNoiseBudget(ct),
+	}
+}
+
+func (s *modDownSearch) minBudget(state []*Ciphertext) (index int) {
+	minBudget := s.noiseEstimator.InvariantNoiseBudget(state[0])
+	for i, ct := range state[1:] {
+		if budget := s.noiseEstimator.InvariantNoiseBudget(ct); budget < minBudget {
+			index, minBudget = i+1, budget
+		}
+	}
+	return
+}
@@ -0,0 +1,58 @@
+package RtF
+
+import (
+	"testing"
+)
+
+// TestSearchModDown searches the schedule of a toy Rubato and checks that every stage keeps the margin
+func TestSearchModDown(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the mod down search in short mode")
+	}
+	margin := 10
+	cipher := NewToyRubatoCipher(RUBATO80S, RtFToyN10)
+	schedule, report, err := SearchModDown(cipher, margin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("\n%s\n%s", report, report.Entry())
+
+	if want := len(cipher.ModDownParams().CipherModDown); len(schedule.CipherModDown) != want {
+		t.Errorf("got %d cipher mod downs, want %d", len(schedule.CipherModDown), want)
+	}
+	if want := len(cipher.ModDownParams().StCModDown); len(schedule.StCModDown) != want {
+		t.Errorf("got %d StC mod downs, want %d", len(schedule.StCModDown), want)
+	}
+	if len(report.Stages) != len(schedule.CipherModDown)+len(schedule.StCModDown) {
+		t.Errorf("got %d stages in the report, want one per mod down", len(report.Stages))
+	}
+	dropped := 0
+	for _, stage := range report.Stages {
+		dropped += stage.ModDown
+		if stage.Budget < margin {
+			t.Errorf("%s: budget of %d bits below the margin", stage.Name, stage.Budget)
+		}
+	}
+	if dropped == 0 {
+		t.Error("no modulus dropped")
+	}
+	if report.FinalBudget < margin || report.PipelineBudget < margin {
+		t.Errorf("final budgets (%d, %d) below the margin", report.FinalBudget, report.PipelineBudget)
+	}
+}
+
+func TestLargest(t *testing.T) {
+	for _, threshold := range []int{0, 1, 7, 16} {
+		evaluations := 0
+		got := largest(16, func(d int) bool {
+			evaluations++
+			return d <= threshold
+		})
+		if got != threshold {
+			t.Errorf("threshold %d: got %d", threshold, got)
+		}
+		if evaluations > 5 {
+			t.Errorf("threshold %d: %d evaluations, want at most 5", threshold, evaluations)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+
 	"github.com/tuneinsight/lattigo/v6/utils/sampling"
 
 	"flhhe/src/RtF/ring"
@@ -22,6 +24,10 @@ type Element struct {
 	value []*ring.Poly
 	scale float64
 	isNTT bool
+
+	// noise the noise predicted by an MFVNoiseModel, if hasNoise. Not serialized.
+	noise    NoiseEstimate
+	hasNoise bool
 }
 
 // MarshalBinary encodes the Element struct into a byte slice
@@ -76,6 +82,11 @@ func (e *Element) UnmarshalBinary(data []byte) error {
 		return err
 	}
 
+	// Each *ring.Poly takes at least its 8 bytes length, the lengths are bounded by the data left
+	if numPolys > uint64(buf.Len())/8 {
+		return fmt.Errorf("invalid element encoding: %d polynomials in %d bytes", numPolys, buf.Len())
+	}
+
 	// Read each *ring.Poly
 	e.value = make([]*ring.Poly, numPolys)
 	for i := uint64(0); i < numPolys; i++ {
@@ -83,9 +94,12 @@ func (e *Element) UnmarshalBinary(data []byte) error {
 		if err := binary.Read(buf, binary.LittleEndian, &length); err != nil {
 			return err
 		}
+		if length > uint64(buf.Len()) {
+			return fmt.Errorf("invalid element encoding: polynomial of %d bytes, %d left", length, buf.Len())
+		}
 
 		polyData := make([]byte, length)
-		if _, err := buf.Read(polyData); err != nil {
+		if _, err := io.ReadFull(buf, polyData); err != nil {
 			return err
 		}
 
@@ -237,6 +251,7 @@ func (el *Element) Copy(ctxCopy *Element) {
 func (el *Element) CopyParams(Element *Element) {
 	el.SetScale(Element.Scale())
 	el.SetIsNTT(Element.IsNTT())
+	el.noise, el.hasNoise = Element.noise, Element.hasNoise
 }
 
 // El sets the target element type to Element.
@@ -291,8 +306,8 @@ func newPlaintextMulElement(params *Parameters) *Element {
 	return el
 }
 
-// NewElementRandom creates a new Element with random coefficients
-func populateElementRandom(prng sampling.PRNG, params *Parameters, el *Element) {
+// populateElementRandom samples the coefficients of the Element uniformly
+func populateElementRandom(prng sampling.PRNG, params *Parameters, el *Element) error {
 
 	ringQ, err := ring.NewRing(params.N(), params.qi)
 	if err != nil {
@@ -300,6 +315,9 @@ func populateElementRandom(prng sampling.PRNG, params *Parameters, el *Element)
 	}
 	sampler := ring.NewUniformSampler(prng, ringQ)
 	for i := range el.value {
-		sampler.Read(el.value[i])
+		if err = sampler.Read(el.value[i]); err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -0,0 +1,108 @@
+package RtF
+
+import "golang.org/x/crypto/sha3"
+
+// PlainPasta computes the keystream of a Pasta-like cipher over Z_p. The state of 2t words is the key,
+// each round applies a random affine layer to both halves, mixes them and applies the S-box (a Feistel
+// S-box on every round but the last one, which cubes each word). A final affine layer and mix give the
+// left half of the state as keystream. The matrices and round constants are derived from the XOF.
+func PlainPasta(t int, numRound int, nonce []byte, counter []byte, key []uint64, plainModulus uint64) (state []uint64) {
+	xof := sha3.NewShake256()
+	xof.Write(nonce)
+	xof.Write(counter)
+	state = make([]uint64, 2*t)
+
+	for i := 0; i < 2*t; i++ {
+		state[i] = key[i] % plainModulus
+	}
+	left, right := state[:t], state[t:]
+
+	// Round Functions
+	for r := 1; r <= numRound; r++ {
+		pastaAffineLayer(xof, left, right, plainModulus)
+		pastaMix(left, right, plainModulus)
+		if r < numRound {
+			rubatoFeistel(left, plainModulus)
+			rubatoFeistel(right, plainModulus)
+		} else {
+			pastaCube(state, plainModulus)
+		}
+	}
+
+	// Finalization
+	pastaAffineLayer(xof, left, right, plainModulus)
+	pastaMix(left, right, plainModulus)
+	state = state[0:t]
+
+	return
+}
+
+// pastaAffineLayer samples the first rows of the matrices of both halves, then their round constants,
+// and computes half = M * half + rc. Each row of M is derived from the previous one and the first row.
+func pastaAffineLayer(xof sha3.ShakeHash, left []uint64, right []uint64, plainModulus uint64) {
+	t := len(left)
+	halves := [2][]uint64{left, right}
+
+	var firstRows, rcs [2][]uint64
+	for h := range halves {
+		firstRows[h] = make([]uint64, t)
+		for j := 0; j < t; j++ {
+			firstRows[h][j] = sampleNonZeroZqx(xof, plainModulus)
+		}
+	}
+	for h := range halves {
+		rcs[h] = make([]uint64, t)
+		for j := 0; j < t; j++ {
+			rcs[h][j] = SampleZqx(xof, plainModulus)
+		}
+	}
+
+	buf := make([]uint64, t)
+	row := make([]uint64, t)
+	for h, half := range halves {
+		copy(row, firstRows[h])
+		for i := 0; i < t; i++ {
+			if i > 0 {
+				pastaNextRow(firstRows[h], row, plainModulus)
+			}
+			buf[i] = rcs[h][i]
+			for j := 0; j < t; j++ {
+				buf[i] = (buf[i] + row[j]*half[j]) % plainModulus
+			}
+		}
+		copy(half, buf)
+	}
+}
+
+// pastaNextRow computes in place row[j] = first[j] * row[t-1] + row[j-1]
+func pastaNextRow(first []uint64, row []uint64, plainModulus uint64) {
+	t := len(row)
+	last := row[t-1]
+	for j := t - 1; j > 0; j-- {
+		row[j] = (first[j]*last + row[j-1]) % plainModulus
+	}
+	row[0] = first[0] * last % plainModulus
+}
+
+// pastaMix computes (left, right) = (2 * left + right, left + 2 * right)
+func pastaMix(left []uint64, right []uint64, plainModulus uint64) {
+	for i := range left {
+		sum := left[i] + right[i]
+		left[i] = (left[i] + sum) % plainModulus
+		right[i] = (right[i] + sum) % plainModulus
+	}
+}
+
+func pastaCube(state []uint64, plainModulus uint64) {
+	for i := range state {
+		state[i] = (state[i] * state[i] % plainModulus) * state[i] % plainModulus
+	}
+}
+
+// sampleNonZeroZqx samples from the XOF until a non zero element of Z_q is found, so that the matrices are invertible
+func sampleNonZeroZqx(xof sha3.ShakeHash, q uint64) (res uint64) {
+	for res == 0 {
+		res = SampleZqx(xof, q)
+	}
+	return
+}
@@ -2,30 +2,74 @@ package RtF
 
 import (
 	"flhhe/src/RtF/ring"
+	"fmt"
 	"github.com/tuneinsight/lattigo/v6/utils/sampling"
 	"golang.org/x/crypto/sha3"
+	"io"
 )
 
-func PlainRubato(blocksize int, numRound int, nonce []byte, counter []byte, key []uint64, plainModulus uint64, sigma float64) (state []uint64) {
+// PlainRubato computes the keystream of Rubato, the Gaussian noise is sampled from a new PRNG (see NewPRNG)
+func PlainRubato(blocksize int, numRound int, nonce []byte, counter []byte, key []uint64, plainModulus uint64, sigma float64) (state []uint64, err error) {
+	prng, err := NewPRNG()
+	if err != nil {
+		return nil, err
+	}
+	return PlainRubatoWithPRNG(blocksize, numRound, nonce, counter, key, plainModulus, sigma, prng)
+}
+
+// PlainRubatoWithPRNG computes the keystream of Rubato with the Gaussian noise sampled from prng,
+// a keyed PRNG (NewSeededPRNG) makes the keystream deterministic and sigma = 0 disables the noise.
+// It returns an ErrParamMismatch error if the key is shorter than the block size and the error of
+// prng if the noise can't be sampled.
+func PlainRubatoWithPRNG(blocksize int, numRound int, nonce []byte, counter []byte, key []uint64, plainModulus uint64, sigma float64, prng sampling.PRNG) (state []uint64, err error) {
+	gaussianSampler := ring.NewGaussianSampler(prng)
+	return plainRubato(blocksize, numRound, nonce, counter, key, plainModulus, false, func(state []uint64) error {
+		if sigma > 0 {
+			return rubatoAddGaussianNoise(state, plainModulus, gaussianSampler, sigma)
+		}
+		return nil
+	})
+}
+
+// PlainRubatoConstantTime computes the keystream of Rubato with the Gaussian noise drawn in constant time
+// by sampler, for the clients encrypting on shared hardware; a nil sampler disables the noise. The
+// sampler is built from the table of the cipher (ring.NewCDTGaussianSamplerFromTable), computed once.
+// By default the round constants keep the rejection sampling of the specification (SampleZqx): it only
+// depends on the public nonce and counter. With constantTimeRoundConstants, they are drawn from the
+// same XOF by ring.BoundedUniformSampler instead, in constant time; the keystream is then no longer the
+// one of the specification, and the server must derive the same constants (NewMFVRubatoConstantTime).
+func PlainRubatoConstantTime(blocksize int, numRound int, nonce []byte, counter []byte, key []uint64, plainModulus uint64, sampler *ring.CDTGaussianSampler, constantTimeRoundConstants bool) (state []uint64, err error) {
+	return plainRubato(blocksize, numRound, nonce, counter, key, plainModulus, constantTimeRoundConstants, func(state []uint64) error {
+		if sampler != nil {
+			// The last 4 words are dropped from the keystream, as in GaussianSampler.AGN
+			return sampler.AddNoise(state[:len(state)-4], plainModulus)
+		}
+		return nil
+	})
+}
+
+// plainRubato computes the keystream of Rubato, addNoise adds the Gaussian noise to the state. The round
+// constants are drawn by a ring.BoundedUniformSampler with constantTimeRoundConstants, by SampleZqx otherwise.
+func plainRubato(blocksize int, numRound int, nonce []byte, counter []byte, key []uint64, plainModulus uint64, constantTimeRoundConstants bool, addNoise func(state []uint64) error) (state []uint64, err error) {
+	if len(key) < blocksize {
+		return nil, fmt.Errorf("%w: key of %d words, the block size is %d", ErrParamMismatch, len(key), blocksize)
+	}
 	xof := sha3.NewShake256()
 	xof.Write(nonce)
 	xof.Write(counter)
 	state = make([]uint64, blocksize)
 
-	//prng, err := NewPRNG()
-	// Hossein: I used the new Sampling from Lattigo Lib instead of the old one
-	prng, err := sampling.NewPRNG()
-	if err != nil {
-		panic(err)
-	}
-	gaussianSampler := ring.NewGaussianSampler(prng)
-
 	rks := make([][]uint64, numRound+1)
 
+	sampleRoundConstant := newRoundConstantSampler(xof, plainModulus, constantTimeRoundConstants)
 	for r := 0; r <= numRound; r++ {
 		rks[r] = make([]uint64, blocksize)
 		for i := 0; i < blocksize; i++ {
-			rks[r][i] = SampleZqx(xof, plainModulus) * key[i] % plainModulus
+			rc, err := sampleRoundConstant()
+			if err != nil {
+				return nil, err
+			}
+			rks[r][i] = rc * key[i] % plainModulus
 		}
 	}
 
@@ -51,15 +95,15 @@ func PlainRubato(blocksize int, numRound int, nonce []byte, counter []byte, key
 	rubatoLinearLayer(state, plainModulus)
 	rubatoFeistel(state, plainModulus)
 	rubatoLinearLayer(state, plainModulus)
-	if sigma > 0 {
-		rubatoAddGaussianNoise(state, plainModulus, gaussianSampler, sigma)
+	if err = addNoise(state); err != nil {
+		return nil, err
 	}
 	for i := 0; i < blocksize; i++ {
 		state[i] = (state[i] + rks[numRound][i]) % plainModulus
 	}
 	state = state[0 : blocksize-4]
 
-	return
+	return state, nil
 }
 
 func rubatoLinearLayer(state []uint64, plainModulus uint64) {
@@ -159,7 +203,17 @@ func rubatoFeistel(state []uint64, plainModulus uint64) {
 	}
 }
 
-func rubatoAddGaussianNoise(state []uint64, plainModulus uint64, gaussianSampler *ring.GaussianSampler, sigma float64) {
+func rubatoAddGaussianNoise(state []uint64, plainModulus uint64, gaussianSampler *ring.GaussianSampler, sigma float64) error {
 	bound := int(6 * sigma)
-	gaussianSampler.AGN(state, plainModulus, sigma, bound)
+	return gaussianSampler.AGN(state, plainModulus, sigma, bound)
+}
+
+// newRoundConstantSampler returns the sampler of the round constants modulo q read from xof, in constant
+// time (ring.BoundedUniformSampler) or by rejection (SampleZqx)
+func newRoundConstantSampler(xof io.Reader, q uint64, constantTime bool) func() (uint64, error) {
+	if constantTime {
+		sampler := ring.NewBoundedUniformSampler(xof)
+		return func() (uint64, error) { return sampler.Sample(q) }
+	}
+	return func() (uint64, error) { return SampleZqx(xof, q), nil }
 }
@@ -37,8 +37,9 @@ func (prec PrecisionStats) String() string {
 
 }
 
-// GetPrecisionStats generates a PrecisionStats struct from the reference values and the decrypted values
-func GetPrecisionStats(params *Parameters, encoder CKKSEncoder, decryptor CKKSDecryptor, valuesWant []complex128, element interface{}, logSlots int, sigma float64) (prec PrecisionStats) {
+// GetPrecisionStats generates a PrecisionStats struct from the reference values and the decrypted values.
+// It returns an error if the PRNG fails while adding the decoding noise of standard deviation sigma.
+func GetPrecisionStats(params *Parameters, encoder CKKSEncoder, decryptor CKKSDecryptor, valuesWant []complex128, element interface{}, logSlots int, sigma float64) (prec PrecisionStats, err error) {
 
 	var valuesTest []complex128
 
@@ -46,9 +47,13 @@ func GetPrecisionStats(params *Parameters, encoder CKKSEncoder, decryptor CKKSDe
 
 	switch element := element.(type) {
 	case *Ciphertext:
-		valuesTest = encoder.DecodeComplexPublic(decryptor.DecryptNew(element), logSlots, sigma)
+		if valuesTest, err = encoder.DecodeComplexPublic(decryptor.DecryptNew(element), logSlots, sigma); err != nil {
+			return prec, err
+		}
 	case *Plaintext:
-		valuesTest = encoder.DecodeComplexPublic(element, logSlots, sigma)
+		if valuesTest, err = encoder.DecodeComplexPublic(element, logSlots, sigma); err != nil {
+			return prec, err
+		}
 	case []complex128:
 		valuesTest = element
 	}
@@ -118,7 +123,7 @@ func GetPrecisionStats(params *Parameters, encoder CKKSEncoder, decryptor CKKSDe
 	prec.MedianPrecision = deltaToPrecision(prec.MedianDelta)
 	prec.STDFreq = encoder.GetErrSTDFreqDom(valuesWant[:], valuesTest[:], params.Scale())
 	prec.STDTime = encoder.GetErrSTDTimeDom(valuesWant, valuesTest, params.Scale())
-	return prec
+	return prec, nil
 }
 
 func deltaToPrecision(c complex128) complex128 {
@@ -0,0 +1,150 @@
+package RtF
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+
+	"flhhe/src/utils"
+
+	"github.com/tuneinsight/lattigo/v6/utils/sampling"
+)
+
+// PRNGSeedSize is the size in bytes of the seeds of the PRNGs
+const PRNGSeedSize = 64
+
+// SeededPRNG is a sampling.KeyedPRNG which records its seed, NewSeededPRNG(prng.Seed()) replays it
+type SeededPRNG struct {
+	*sampling.KeyedPRNG
+	seed []byte
+}
+
+// masterPRNG derives the seeds of the PRNGs of the package once SetPRNGSeed is called
+var masterPRNG struct {
+	sync.Mutex
+	prng *SeededPRNG
+}
+
+// prngLogger logs the seeds of the PRNGs created by NewPRNG once SetPRNGLogger is called
+var prngLogger struct {
+	sync.Mutex
+	logger utils.Logger
+}
+
+// NewSeededPRNG creates a PRNG keyed with seed
+func NewSeededPRNG(seed []byte) (*SeededPRNG, error) {
+	prng, err := sampling.NewKeyedPRNG(seed)
+	if err != nil {
+		return nil, err
+	}
+	return &SeededPRNG{prng, append([]byte{}, seed...)}, nil
+}
+
+// Seed returns the seed of the PRNG
+func (prng *SeededPRNG) Seed() []byte {
+	return append([]byte{}, prng.seed...)
+}
+
+// NewPRNG creates the PRNG used by the constructors of the package without one of their own (the key
+// generator, the encryptors, the CKKS encoder and PlainRubato). Its seed is fresh randomness, or the
+// next PRNGSeedSize bytes of the master PRNG after SetPRNGSeed, in which case a run creating its PRNGs
+// in the same order draws the same randomness.
+func NewPRNG() (*SeededPRNG, error) {
+	prng, err := newUnloggedPRNG()
+	if err != nil {
+		return nil, err
+	}
+	prngLogger.Lock()
+	defer prngLogger.Unlock()
+	if prngLogger.logger != nil {
+		prngLogger.logger.PrintFormatted("[RtF] PRNG seed: %x", prng.seed)
+	}
+	return prng, nil
+}
+
+// newUnloggedPRNG is NewPRNG without the log of the seed
+func newUnloggedPRNG() (*SeededPRNG, error) {
+	seed := make([]byte, PRNGSeedSize)
+
+	masterPRNG.Lock()
+	defer masterPRNG.Unlock()
+	var err error
+	if masterPRNG.prng != nil {
+		_, err = masterPRNG.prng.Read(seed)
+	} else {
+		_, err = rand.Read(seed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSeededPRNG(seed)
+}
+
+// SetPRNGLogger logs the seed of every PRNG created by NewPRNG with logger, a debug record, so that a
+// failing run can be replayed with the same randomness; a nil logger stops the logs. The PRNG of the key
+// generator (NewKeyGenerator) is never logged: its seed would regenerate the keys.
+func SetPRNGLogger(logger utils.Logger) {
+	prngLogger.Lock()
+	defer prngLogger.Unlock()
+	prngLogger.logger = logger
+}
+
+// ErrSeededKeys is returned by CheckKeygenSeed: anyone holding the seed would regenerate the keys
+var ErrSeededKeys = errors.New("keys generated from a PRNG seed")
+
+// CheckKeygenSeed returns ErrSeededKeys if the PRNGs are seeded (SetPRNGSeed) and allowSeededKeys is
+// false: the key generation of a real run must draw fresh randomness. Only the tests replaying the keys
+// of a run allow them.
+func CheckKeygenSeed(allowSeededKeys bool) error {
+	if PRNGSeed() != nil && !allowSeededKeys {
+		return ErrSeededKeys
+	}
+	return nil
+}
+
+// SetPRNGSeed makes the PRNGs created by NewPRNG deterministic, derived from seed; a nil seed goes back
+// to fresh randomness. The PRNGs are derived in the order of their creation, the runs to replay must
+// not create them from concurrent goroutines. The nonces of the clients never come from these PRNGs,
+// and the keys of a real run can't be generated from them (CheckKeygenSeed).
+func SetPRNGSeed(seed []byte) error {
+	masterPRNG.Lock()
+	defer masterPRNG.Unlock()
+	if seed == nil {
+		masterPRNG.prng = nil
+		return nil
+	}
+	prng, err := NewSeededPRNG(seed)
+	if err != nil {
+		return err
+	}
+	masterPRNG.prng = prng
+	return nil
+}
+
+// PRNGSeed returns the seed set by SetPRNGSeed, nil if the PRNGs use fresh randomness
+func PRNGSeed() []byte {
+	masterPRNG.Lock()
+	defer masterPRNG.Unlock()
+	if masterPRNG.prng == nil {
+		return nil
+	}
+	return masterPRNG.prng.Seed()
+}
+
+// newPRNG is NewPRNG for the constructors, which panic on errors
+func newPRNG() *SeededPRNG {
+	prng, err := NewPRNG()
+	if err != nil {
+		panic(err)
+	}
+	return prng
+}
+
+// newKeyPRNG is newPRNG for the key generator, its seed is never logged
+func newKeyPRNG() *SeededPRNG {
+	prng, err := newUnloggedPRNG()
+	if err != nil {
+		panic(err)
+	}
+	return prng
+}
@@ -0,0 +1,140 @@
+package RtF
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"flhhe/src/utils"
+)
+
+// TestPRNGSeed replays a toy key generation, encryption and Rubato keystream from the same seed
+func TestPRNGSeed(t *testing.T) {
+	cipher := NewToyRubatoCipher(RUBATO80S, RtFToyN10)
+	params, err := cipher.HalfBootParams().Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.SetPlainModulus(cipher.PlainModulus())
+	params.SetLogFVSlots(params.LogN())
+
+	nonce, counter := make([]byte, 8), make([]byte, 8)
+	key := make([]uint64, cipher.BlockSize())
+	for i := range key {
+		key[i] = uint64(i + 1)
+	}
+	message := make([]uint64, params.FVSlots())
+	for i := range message {
+		message[i] = uint64(i) % cipher.PlainModulus()
+	}
+
+	run := func() (sk [][]uint64, ct [][][]uint64, keystream []uint64) {
+		kgen := NewKeyGenerator(params)
+		secret, pk, err := kgen.GenKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoder := NewMFVEncoder(params)
+		pt := NewPlaintextFV(params)
+		encoder.EncodeUintSmall(message, pt)
+		ciphertext, err := NewMFVEncryptorFromPk(params, pk).EncryptNew(pt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pol := range ciphertext.Value() {
+			ct = append(ct, pol.Coeffs)
+		}
+		if keystream, err = cipher.Keystream(nonce, counter, key); err != nil {
+			t.Fatal(err)
+		}
+		return secret.Value.Coeffs, ct, keystream
+	}
+
+	seed := []byte("replay")
+	if err = SetPRNGSeed(seed); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPRNGSeed(nil)
+	if !reflect.DeepEqual(PRNGSeed(), seed) {
+		t.Errorf("PRNGSeed: got %x, want %x", PRNGSeed(), seed)
+	}
+	sk0, ct0, z0 := run()
+
+	SetPRNGSeed(seed)
+	sk1, ct1, z1 := run()
+	if !reflect.DeepEqual(sk0, sk1) || !reflect.DeepEqual(ct0, ct1) || !reflect.DeepEqual(z0, z1) {
+		t.Error("the runs from the same seed differ")
+	}
+
+	SetPRNGSeed(nil)
+	if PRNGSeed() != nil {
+		t.Error("PRNGSeed not reset")
+	}
+	if sk2, _, _ := run(); reflect.DeepEqual(sk0, sk2) {
+		t.Error("the run from fresh randomness replays the seeded one")
+	}
+
+	// A seeded PRNG replays its stream from its recorded seed
+	prng, err := NewPRNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, err := NewSeededPRNG(prng.Seed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b0, b1 := make([]byte, 32), make([]byte, 32)
+	prng.Read(b0)
+	replay.Read(b1)
+	if !reflect.DeepEqual(b0, b1) {
+		t.Error("the PRNG from the recorded seed differs")
+	}
+}
+
+// TestPRNGLogger checks that the seeds of the PRNGs are logged in debug mode, except the one of the key
+// generator, and that the seeded keys need allowSeededKeys
+func TestPRNGLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	utils.SetLogOutput(buf, utils.LogText)
+	defer utils.SetLogOutput(os.Stdout, utils.LogText)
+	SetPRNGLogger(utils.NewLogger(true))
+	defer SetPRNGLogger(nil)
+
+	prng, err := NewPRNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), hex.EncodeToString(prng.Seed())) {
+		t.Errorf("the seed %x isn't logged: %q", prng.Seed(), buf)
+	}
+	buf.Reset()
+	if keyPRNG := newKeyPRNG(); buf.Len() != 0 || keyPRNG == nil {
+		t.Errorf("the seed of the key generator is logged: %q", buf)
+	}
+
+	SetPRNGLogger(nil)
+	if _, err = NewPRNG(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("the seed is logged without logger: %q", buf)
+	}
+
+	if err = CheckKeygenSeed(false); err != nil {
+		t.Errorf("CheckKeygenSeed without seed: %v", err)
+	}
+	if err = SetPRNGSeed([]byte("replay")); err != nil {
+		t.Fatal(err)
+	}
+	defer SetPRNGSeed(nil)
+	if err = CheckKeygenSeed(false); !errors.Is(err, ErrSeededKeys) {
+		t.Errorf("CheckKeygenSeed(false) with a seed: got %v, want %v", err, ErrSeededKeys)
+	}
+	if err = CheckKeygenSeed(true); err != nil {
+		t.Errorf("CheckKeygenSeed(true) with a seed: %v", err)
+	}
+}
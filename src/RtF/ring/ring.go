@@ -45,6 +45,8 @@ type Ring struct {
 	NttNInv   []uint64   //[N^-1] mod Qi in Montgomery form
 
 	polypool *Poly
+
+	pool *LimbPool // runs the per-limb work, see SetLimbPool
 }
 
 // NewRing creates a new RNS Ring with degree N and coefficient moduli Moduli. N must be a power of two larger than 8. Moduli should be
@@ -168,14 +168,14 @@ func (basisextender *FastBasisExtender) ShallowCopy() *FastBasisExtender {
 // Given a polynomial with coefficients in basis {Q0,Q1....Qlevel},
 // it extends its basis from {Q0,Q1....Qlevel} to {Q0,Q1....Qlevel,P0,P1...Pj}
 func (basisextender *FastBasisExtender) ModUpSplitQP(level int, p1, p2 *Poly) {
-	modUpExact(p1.Coeffs[:level+1], p2.Coeffs[:len(basisextender.paramsQP.P)], basisextender.paramsQP)
+	modUpExactParallel(basisextender.ringQ.LimbPool(), p1.Coeffs[:level+1], p2.Coeffs[:len(basisextender.paramsQP.P)], basisextender.paramsQP)
 }
 
 // ModUpSplitPQ extends the RNS basis of a polynomial from P to PQ.
 // Given a polynomial with coefficients in basis {P0,P1....Plevel},
 // it extends its basis from {P0,P1....Plevel} to {Q0,Q1...Qj}
 func (basisextender *FastBasisExtender) ModUpSplitPQ(level int, p1, p2 *Poly) {
-	modUpExact(p1.Coeffs[:level+1], p2.Coeffs[:len(basisextender.paramsPQ.P)], basisextender.paramsPQ)
+	modUpExactParallel(basisextender.ringP.LimbPool(), p1.Coeffs[:level+1], p2.Coeffs[:len(basisextender.paramsPQ.P)], basisextender.paramsPQ)
 }
 
 // ModDownNTTPQ reduces the basis RNS of a polynomial in the NTT domain
@@ -194,45 +194,26 @@ func (basisextender *FastBasisExtender) ModDownNTTPQ(level int, p1, p2 *Poly) {
 	nPj := len(ringP.Modulus)
 
 	// First we get the P basis part of p1 out of the NTT domain
-	for j := 0; j < nPj; j++ {
-		InvNTTLazy(p1.Coeffs[nQi+j], p1.Coeffs[nQi+j], ringP.N, ringP.NttPsiInv[j], ringP.NttNInv[j], ringP.Modulus[j], ringP.MredParams[j])
+	if pool := ringP.LimbPool(); pool != nil {
+		pool.Run(nPj, func(j int) {
+			InvNTTLazy(p1.Coeffs[nQi+j], p1.Coeffs[nQi+j], ringP.N, ringP.NttPsiInv[j], ringP.NttNInv[j], ringP.Modulus[j], ringP.MredParams[j])
+		})
+	} else {
+		for j := 0; j < nPj; j++ {
+			InvNTTLazy(p1.Coeffs[nQi+j], p1.Coeffs[nQi+j], ringP.N, ringP.NttPsiInv[j], ringP.NttNInv[j], ringP.Modulus[j], ringP.MredParams[j])
+		}
 	}
 
 	// Then we target this P basis of p1 and convert it to a Q basis (at the "level" of p1) and copy it on polypool
 	// polypool is now the representation of the P basis of p1 but in basis Q (at the "level" of p1)
-	modUpExact(p1.Coeffs[nQi:nQi+nPj], polypool.Coeffs[:level+1], basisextender.paramsPQ)
+	modUpExactParallel(ringQ.LimbPool(), p1.Coeffs[nQi:nQi+nPj], polypool.Coeffs[:level+1], basisextender.paramsPQ)
 
 	// Finally, for each level of p1 (and polypool since they now share the same basis) we compute p2 = (P^-1) * (p1 - polypool) mod Q
-	for i := 0; i < level+1; i++ {
-
-		qi := ringQ.Modulus[i]
-		twoqi := qi << 1
-		p1tmp := p1.Coeffs[i]
-		p2tmp := p2.Coeffs[i]
-		p3tmp := polypool.Coeffs[i]
-		params := qi - modDownParams[i]
-		mredParams := ringQ.MredParams[i]
-		bredParams := ringQ.BredParams[i]
-		nttPsi := ringQ.NttPsi[i]
-
-		// First we switch back the relevant polypool CRT array back to the NTT domain
-		NTTLazy(p3tmp, p3tmp, ringQ.N, nttPsi, qi, mredParams, bredParams)
-
-		// Then for each coefficient we compute (P^-1) * (p1[i][j] - polypool[i][j]) mod qi
-		for j := 0; j < ringQ.N; j = j + 8 {
-
-			x := (*[8]uint64)(unsafe.Pointer(&p1tmp[j]))
-			y := (*[8]uint64)(unsafe.Pointer(&p3tmp[j]))
-			z := (*[8]uint64)(unsafe.Pointer(&p2tmp[j]))
-
-			z[0] = MRed(y[0]+twoqi-x[0], params, qi, mredParams)
-			z[1] = MRed(y[1]+twoqi-x[1], params, qi, mredParams)
-			z[2] = MRed(y[2]+twoqi-x[2], params, qi, mredParams)
-			z[3] = MRed(y[3]+twoqi-x[3], params, qi, mredParams)
-			z[4] = MRed(y[4]+twoqi-x[4], params, qi, mredParams)
-			z[5] = MRed(y[5]+twoqi-x[5], params, qi, mredParams)
-			z[6] = MRed(y[6]+twoqi-x[6], params, qi, mredParams)
-			z[7] = MRed(y[7]+twoqi-x[7], params, qi, mredParams)
+	if pool := ringQ.LimbPool(); pool != nil {
+		pool.Run(level+1, func(i int) { modDownNTTLimb(ringQ, i, p1, p2, polypool, modDownParams) })
+	} else {
+		for i := 0; i < level+1; i++ {
+			modDownNTTLimb(ringQ, i, p1, p2, polypool, modDownParams)
 		}
 	}
 
@@ -256,45 +237,54 @@ func (basisextender *FastBasisExtender) ModDownSplitNTTPQ(level int, p1Q, p1P, p
 
 	// Then we target this P basis of p1 and convert it to a Q basis (at the "level" of p1) and copy it on polypool
 	// polypool is now the representation of the P basis of p1 but in basis Q (at the "level" of p1)
-	modUpExact(p1P.Coeffs, polypool.Coeffs[:level+1], basisextender.paramsPQ)
+	modUpExactParallel(ringQ.LimbPool(), p1P.Coeffs, polypool.Coeffs[:level+1], basisextender.paramsPQ)
 
 	// Finally, for each level of p1 (and polypool since they now share the same basis) we compute p2 = (P^-1) * (p1 - polypool) mod Q
-	for i := 0; i < level+1; i++ {
-
-		qi := ringQ.Modulus[i]
-		twoqi := qi << 1
-		p1tmp := p1Q.Coeffs[i]
-		p2tmp := p2.Coeffs[i]
-		p3tmp := polypool.Coeffs[i]
-		params := qi - modDownParams[i]
-		mredParams := ringQ.MredParams[i]
-		bredParams := ringQ.BredParams[i]
-		nttPsi := ringQ.NttPsi[i]
-
-		// First we switch back the relevant polypool CRT array back to the NTT domain
-		NTTLazy(p3tmp, p3tmp, ringQ.N, nttPsi, qi, mredParams, bredParams)
-
-		// Then for each coefficient we compute (P^-1) * (p1[i][j] - polypool[i][j]) mod qi
-		for j := 0; j < ringQ.N; j = j + 8 {
-
-			x := (*[8]uint64)(unsafe.Pointer(&p1tmp[j]))
-			y := (*[8]uint64)(unsafe.Pointer(&p3tmp[j]))
-			z := (*[8]uint64)(unsafe.Pointer(&p2tmp[j]))
-
-			z[0] = MRed(y[0]+twoqi-x[0], params, qi, mredParams)
-			z[1] = MRed(y[1]+twoqi-x[1], params, qi, mredParams)
-			z[2] = MRed(y[2]+twoqi-x[2], params, qi, mredParams)
-			z[3] = MRed(y[3]+twoqi-x[3], params, qi, mredParams)
-			z[4] = MRed(y[4]+twoqi-x[4], params, qi, mredParams)
-			z[5] = MRed(y[5]+twoqi-x[5], params, qi, mredParams)
-			z[6] = MRed(y[6]+twoqi-x[6], params, qi, mredParams)
-			z[7] = MRed(y[7]+twoqi-x[7], params, qi, mredParams)
+	if pool := ringQ.LimbPool(); pool != nil {
+		pool.Run(level+1, func(i int) { modDownNTTLimb(ringQ, i, p1Q, p2, polypool, modDownParams) })
+	} else {
+		for i := 0; i < level+1; i++ {
+			modDownNTTLimb(ringQ, i, p1Q, p2, polypool, modDownParams)
 		}
 	}
 
 	// In total we do len(P) + len(Q) NTT, which is optimal (linear in the number of moduli of P and Q)
 }
 
+// modDownNTTLimb computes p2 = (P^-1) * (p1 - polypool) mod qi on the i-th limb of ringQ, where
+// polypool is the P basis part of the input extended to Q outside of the NTT domain
+func modDownNTTLimb(ringQ *Ring, i int, p1, p2, polypool *Poly, modDownParams []uint64) {
+	qi := ringQ.Modulus[i]
+	twoqi := qi << 1
+	p1tmp := p1.Coeffs[i]
+	p2tmp := p2.Coeffs[i]
+	p3tmp := polypool.Coeffs[i]
+	params := qi - modDownParams[i]
+	mredParams := ringQ.MredParams[i]
+	bredParams := ringQ.BredParams[i]
+	nttPsi := ringQ.NttPsi[i]
+
+	// First we switch back the relevant polypool CRT array back to the NTT domain
+	NTTLazy(p3tmp, p3tmp, ringQ.N, nttPsi, qi, mredParams, bredParams)
+
+	// Then for each coefficient we compute (P^-1) * (p1[i][j] - polypool[i][j]) mod qi
+	for j := 0; j < ringQ.N; j = j + 8 {
+
+		x := (*[8]uint64)(unsafe.Pointer(&p1tmp[j]))
+		y := (*[8]uint64)(unsafe.Pointer(&p3tmp[j]))
+		z := (*[8]uint64)(unsafe.Pointer(&p2tmp[j]))
+
+		z[0] = MRed(y[0]+twoqi-x[0], params, qi, mredParams)
+		z[1] = MRed(y[1]+twoqi-x[1], params, qi, mredParams)
+		z[2] = MRed(y[2]+twoqi-x[2], params, qi, mredParams)
+		z[3] = MRed(y[3]+twoqi-x[3], params, qi, mredParams)
+		z[4] = MRed(y[4]+twoqi-x[4], params, qi, mredParams)
+		z[5] = MRed(y[5]+twoqi-x[5], params, qi, mredParams)
+		z[6] = MRed(y[6]+twoqi-x[6], params, qi, mredParams)
+		z[7] = MRed(y[7]+twoqi-x[7], params, qi, mredParams)
+	}
+}
+
 // ModDownPQ reduces the basis of a polynomial.
 // Given a polynomial with coefficients in basis {Q0,Q1....Qlevel,P0,P1...Pj},
 // it reduces its basis from {Q0,Q1....Qlevel,P0,P1...Pj} to {Q0,Q1....Qlevel}
@@ -436,12 +426,32 @@ func (basisextender *FastBasisExtender) ModDownSplitQP(levelQ, levelP int, p1Q,
 
 // Caution, returns the values in [0, 2q-1]
 func modUpExact(p1, p2 [][]uint64, params *modupParams) {
+	modUpExactRange(p1, p2, params, 0, len(p1[0]))
+}
+
+// modUpExactParallel is modUpExact with the coefficients split in one block per worker of the pool,
+// since each coefficient needs all the limbs of p1
+func modUpExactParallel(pool *LimbPool, p1, p2 [][]uint64, params *modupParams) {
+	if pool == nil {
+		modUpExact(p1, p2, params)
+		return
+	}
+	N := len(p1[0])
+	blocks := min(pool.Workers(), N>>3)
+	size := ((N>>3 + blocks - 1) / blocks) << 3
+	pool.Run(blocks, func(b int) {
+		modUpExactRange(p1, p2, params, b*size, min((b+1)*size, N))
+	})
+}
+
+// modUpExactRange applies modUpExact to the coefficients [start, end), multiples of 8
+func modUpExactRange(p1, p2 [][]uint64, params *modupParams, start, end int) {
 
 	var v [8]uint64
 	var y0, y1, y2, y3, y4, y5, y6, y7 [32]uint64
 
 	// We loop over each coefficient and apply the basis extension
-	for x := 0; x < len(p1[0]); x = x + 8 {
+	for x := start; x < end; x = x + 8 {
 
 		reconstructRNS(len(p1), x, p1, &v, &y0, &y1, &y2, &y3, &y4, &y5, &y6, &y7, params.Q, params.mredParamsQ, params.qibMont)
 
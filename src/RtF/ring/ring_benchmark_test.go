@@ -5,6 +5,8 @@ import (
 	"math/big"
 	"math/bits"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func BenchmarkRing(b *testing.B) {
@@ -37,6 +39,7 @@ func BenchmarkRing(b *testing.B) {
 		benchNegCoeffs(testContext, b)
 		benchMulScalar(testContext, b)
 		benchExtendBasis(testContext, b)
+		benchLimbPool(testContext, b)
 		benchDivByLastModulus(testContext, b)
 		benchDivByRNSBasis(testContext, b)
 		benchMRed(testContext, b)
@@ -56,7 +59,7 @@ func benchGenRing(testContext *testParams, b *testing.B) {
 
 func benchMarshalling(testContext *testParams, b *testing.B) {
 
-	p := testContext.uniformSamplerQ.ReadNew()
+	p := newUniformPoly(b, testContext.uniformSamplerQ)
 
 	b.Run(testString("Marshalling/MarshalPoly/", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -82,7 +85,7 @@ func benchSampling(testContext *testParams, b *testing.B) {
 		gaussianSampler := NewGaussianSampler(testContext.prng)
 
 		for i := 0; i < b.N; i++ {
-			gaussianSampler.ReadLvl(len(testContext.ringQ.Modulus)-1, pol, testContext.ringQ, DefaultSigma, DefaultBound)
+			require.NoError(b, gaussianSampler.ReadLvl(len(testContext.ringQ.Modulus)-1, pol, testContext.ringQ, DefaultSigma, DefaultBound))
 		}
 	})
 
@@ -91,7 +94,7 @@ func benchSampling(testContext *testParams, b *testing.B) {
 		ternarySampler := NewTernarySampler(testContext.prng, testContext.ringQ, 1.0/3, true)
 
 		for i := 0; i < b.N; i++ {
-			ternarySampler.Read(pol)
+			require.NoError(b, ternarySampler.Read(pol))
 		}
 	})
 
@@ -100,7 +103,7 @@ func benchSampling(testContext *testParams, b *testing.B) {
 		ternarySampler := NewTernarySampler(testContext.prng, testContext.ringQ, 0.5, true)
 
 		for i := 0; i < b.N; i++ {
-			ternarySampler.Read(pol)
+			require.NoError(b, ternarySampler.Read(pol))
 		}
 	})
 
@@ -109,20 +112,20 @@ func benchSampling(testContext *testParams, b *testing.B) {
 		ternarySampler := NewTernarySamplerSparse(testContext.prng, testContext.ringQ, 128, true)
 
 		for i := 0; i < b.N; i++ {
-			ternarySampler.Read(pol)
+			require.NoError(b, ternarySampler.Read(pol))
 		}
 	})
 
 	b.Run(testString("Sampling/Uniform/", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			testContext.uniformSamplerQ.Read(pol)
+			require.NoError(b, testContext.uniformSamplerQ.Read(pol))
 		}
 	})
 }
 
 func benchMontgomery(testContext *testParams, b *testing.B) {
 
-	p := testContext.uniformSamplerQ.ReadNew()
+	p := newUniformPoly(b, testContext.uniformSamplerQ)
 
 	b.Run(testString("Montgomery/MForm/", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -139,7 +142,7 @@ func benchMontgomery(testContext *testParams, b *testing.B) {
 
 func benchNTT(testContext *testParams, b *testing.B) {
 
-	p := testContext.uniformSamplerQ.ReadNew()
+	p := newUniformPoly(b, testContext.uniformSamplerQ)
 
 	b.Run(testString("NTT/NTT/Montgomery/", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -168,8 +171,8 @@ func benchNTT(testContext *testParams, b *testing.B) {
 
 func benchMulCoeffs(testContext *testParams, b *testing.B) {
 
-	p0 := testContext.uniformSamplerQ.ReadNew()
-	p1 := testContext.uniformSamplerQ.ReadNew()
+	p0 := newUniformPoly(b, testContext.uniformSamplerQ)
+	p1 := newUniformPoly(b, testContext.uniformSamplerQ)
 
 	b.Run(testString("MulCoeffs/Montgomery/", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -198,8 +201,8 @@ func benchMulCoeffs(testContext *testParams, b *testing.B) {
 
 func benchAddCoeffs(testContext *testParams, b *testing.B) {
 
-	p0 := testContext.uniformSamplerQ.ReadNew()
-	p1 := testContext.uniformSamplerQ.ReadNew()
+	p0 := newUniformPoly(b, testContext.uniformSamplerQ)
+	p1 := newUniformPoly(b, testContext.uniformSamplerQ)
 
 	b.Run(testString("AddCoeffs/Add/", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -216,8 +219,8 @@ func benchAddCoeffs(testContext *testParams, b *testing.B) {
 
 func benchSubCoeffs(testContext *testParams, b *testing.B) {
 
-	p0 := testContext.uniformSamplerQ.ReadNew()
-	p1 := testContext.uniformSamplerQ.ReadNew()
+	p0 := newUniformPoly(b, testContext.uniformSamplerQ)
+	p1 := newUniformPoly(b, testContext.uniformSamplerQ)
 
 	b.Run(testString("SubCoeffs/Sub/", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -234,7 +237,7 @@ func benchSubCoeffs(testContext *testParams, b *testing.B) {
 
 func benchNegCoeffs(testContext *testParams, b *testing.B) {
 
-	p0 := testContext.uniformSamplerQ.ReadNew()
+	p0 := newUniformPoly(b, testContext.uniformSamplerQ)
 
 	b.Run(testString("NegCoeffs", testContext.ringQ), func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
@@ -245,10 +248,10 @@ func benchNegCoeffs(testContext *testParams, b *testing.B) {
 
 func benchMulScalar(testContext *testParams, b *testing.B) {
 
-	p := testContext.uniformSamplerQ.ReadNew()
+	p := newUniformPoly(b, testContext.uniformSamplerQ)
 
-	rand1 := RandUniform(testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
-	rand2 := RandUniform(testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
+	rand1 := randUniform(b, testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
+	rand2 := randUniform(b, testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
 
 	scalarBigint := NewUint(rand1)
 	scalarBigint.Mul(scalarBigint, NewUint(rand2))
@@ -271,13 +274,13 @@ func benchExtendBasis(testContext *testParams, b *testing.B) {
 	rescaleParams := make([]uint64, len(testContext.ringP.Modulus))
 
 	for i, pi := range testContext.ringP.Modulus {
-		rescaleParams[i] = RandUniform(testContext.prng, pi, (1<<uint64(bits.Len64(pi)-1) - 1))
+		rescaleParams[i] = randUniform(b, testContext.prng, pi, (1<<uint64(bits.Len64(pi)-1) - 1))
 	}
 
 	basisExtender := NewFastBasisExtender(testContext.ringQ, testContext.ringP)
 
-	p0 := testContext.uniformSamplerQ.ReadNew()
-	p1 := testContext.uniformSamplerP.ReadNew()
+	p0 := newUniformPoly(b, testContext.uniformSamplerQ)
+	p1 := newUniformPoly(b, testContext.uniformSamplerP)
 
 	level := len(testContext.ringQ.Modulus) - 1
 
@@ -300,9 +303,69 @@ func benchExtendBasis(testContext *testParams, b *testing.B) {
 	})
 }
 
+// benchLimbPool compares the serial and parallel (one worker per CPU) per-limb operations
+func benchLimbPool(testContext *testParams, b *testing.B) {
+
+	ringQ, ringP := testContext.ringQ, testContext.ringP
+	level := len(ringQ.Modulus) - 1
+	basisExtender := NewFastBasisExtender(ringQ, ringP)
+
+	p0 := newUniformPoly(b, testContext.uniformSamplerQ)
+	p1 := newUniformPoly(b, testContext.uniformSamplerQ)
+	pP := newUniformPoly(b, testContext.uniformSamplerP)
+	pQP := &Poly{Coeffs: append(newUniformPoly(b, testContext.uniformSamplerQ).Coeffs, newUniformPoly(b, testContext.uniformSamplerP).Coeffs...)}
+
+	pool := NewLimbPool(0)
+	defer pool.Close()
+
+	for _, parallel := range []*LimbPool{nil, pool} {
+
+		ringQ.SetLimbPool(parallel)
+		ringP.SetLimbPool(parallel)
+
+		mode := "Serial"
+		if parallel != nil {
+			mode = fmt.Sprintf("Parallel=%d", parallel.Workers())
+		}
+
+		b.Run(testString(fmt.Sprintf("LimbPool/%s/NTT/", mode), ringQ), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ringQ.NTT(p0, p0)
+			}
+		})
+
+		b.Run(testString(fmt.Sprintf("LimbPool/%s/InvNTT/", mode), ringQ), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ringQ.InvNTT(p0, p0)
+			}
+		})
+
+		b.Run(testString(fmt.Sprintf("LimbPool/%s/MulCoeffsMontgomery/", mode), ringQ), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ringQ.MulCoeffsMontgomery(p0, p1, p0)
+			}
+		})
+
+		b.Run(testString(fmt.Sprintf("LimbPool/%s/ModUpSplitQP/", mode), ringQ), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				basisExtender.ModUpSplitQP(level, p0, pP)
+			}
+		})
+
+		b.Run(testString(fmt.Sprintf("LimbPool/%s/ModDownNTTPQ/", mode), ringQ), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				basisExtender.ModDownNTTPQ(level, pQP, p1)
+			}
+		})
+	}
+
+	ringQ.SetLimbPool(nil)
+	ringP.SetLimbPool(nil)
+}
+
 func benchDivByLastModulus(testContext *testParams, b *testing.B) {
 
-	p0 := testContext.uniformSamplerQ.ReadNew()
+	p0 := newUniformPoly(b, testContext.uniformSamplerQ)
 	p1 := testContext.ringQ.NewPolyLvl(p0.Level() - 1)
 
 	b.Run(testString("DivByLastModulus/Floor/", testContext.ringQ), func(b *testing.B) {
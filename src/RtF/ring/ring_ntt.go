@@ -7,6 +7,12 @@ import (
 
 // NTT computes the NTT of p1 and returns the result on p2.
 func (r *Ring) NTT(p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(len(r.Modulus), func(x int) {
+			NTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
+		})
+		return
+	}
 	for x := range r.Modulus {
 		NTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
 	}
@@ -15,6 +21,12 @@ func (r *Ring) NTT(p1, p2 *Poly) {
 // NTTLvl computes the NTT of p1 and returns the result on p2.
 // The value level defines the number of moduli of the input polynomials.
 func (r *Ring) NTTLvl(level int, p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(level+1, func(x int) {
+			NTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
+		})
+		return
+	}
 	for x := 0; x < level+1; x++ {
 		NTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
 	}
@@ -22,6 +34,12 @@ func (r *Ring) NTTLvl(level int, p1, p2 *Poly) {
 
 // InvNTT computes the inverse-NTT of p1 and returns the result on p2.
 func (r *Ring) InvNTT(p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(len(r.Modulus), func(x int) {
+			InvNTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
+		})
+		return
+	}
 	for x := range r.Modulus {
 		InvNTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
 	}
@@ -30,6 +48,12 @@ func (r *Ring) InvNTT(p1, p2 *Poly) {
 // InvNTTLvl computes the inverse-NTT of p1 and returns the result on p2.
 // The value level defines the number of moduli of the input polynomials.
 func (r *Ring) InvNTTLvl(level int, p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(level+1, func(x int) {
+			InvNTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
+		})
+		return
+	}
 	for x := 0; x < level+1; x++ {
 		InvNTT(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
 	}
@@ -38,6 +62,12 @@ func (r *Ring) InvNTTLvl(level int, p1, p2 *Poly) {
 // NTTLazy computes the NTT of p1 and returns the result on p2.
 // Output values are in the range [0, 2q-1]
 func (r *Ring) NTTLazy(p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(len(r.Modulus), func(x int) {
+			NTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
+		})
+		return
+	}
 	for x := range r.Modulus {
 		NTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
 	}
@@ -47,6 +77,12 @@ func (r *Ring) NTTLazy(p1, p2 *Poly) {
 // The value level defines the number of moduli of the input polynomials.
 // Output values are in the range [0, 2q-1]
 func (r *Ring) NTTLazyLvl(level int, p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(level+1, func(x int) {
+			NTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
+		})
+		return
+	}
 	for x := 0; x < level+1; x++ {
 		NTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsi[x], r.Modulus[x], r.MredParams[x], r.BredParams[x])
 	}
@@ -55,6 +91,12 @@ func (r *Ring) NTTLazyLvl(level int, p1, p2 *Poly) {
 // InvNTTLazy computes the inverse-NTT of p1 and returns the result on p2.
 // Output values are in the range [0, 2q-1]
 func (r *Ring) InvNTTLazy(p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(len(r.Modulus), func(x int) {
+			InvNTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
+		})
+		return
+	}
 	for x := range r.Modulus {
 		InvNTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
 	}
@@ -64,6 +106,12 @@ func (r *Ring) InvNTTLazy(p1, p2 *Poly) {
 // The value level defines the number of moduli of the input polynomials.
 // Output values are in the range [0, 2q-1]
 func (r *Ring) InvNTTLazyLvl(level int, p1, p2 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(level+1, func(x int) {
+			InvNTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
+		})
+		return
+	}
 	for x := 0; x < level+1; x++ {
 		InvNTTLazy(p1.Coeffs[x], p2.Coeffs[x], r.N, r.NttPsiInv[x], r.NttNInv[x], r.Modulus[x], r.MredParams[x])
 	}
@@ -456,50 +456,40 @@ func (r *Ring) MulCoeffsAndAddNoMod(p1, p2, p3 *Poly) {
 // MulCoeffsMontgomery multiplies p1 by p2 coefficient-wise with a
 // Montgomery modular reduction and returns the result on p3.
 func (r *Ring) MulCoeffsMontgomery(p1, p2, p3 *Poly) {
-	for i, qi := range r.Modulus {
-		p1tmp, p2tmp, p3tmp := p1.Coeffs[i], p2.Coeffs[i], p3.Coeffs[i]
-		mredParams := r.MredParams[i]
-
-		for j := 0; j < r.N; j = j + 8 {
-
-			x := (*[8]uint64)(unsafe.Pointer(&p1tmp[j]))
-			y := (*[8]uint64)(unsafe.Pointer(&p2tmp[j]))
-			z := (*[8]uint64)(unsafe.Pointer(&p3tmp[j]))
-
-			z[0] = MRed(x[0], y[0], qi, mredParams)
-			z[1] = MRed(x[1], y[1], qi, mredParams)
-			z[2] = MRed(x[2], y[2], qi, mredParams)
-			z[3] = MRed(x[3], y[3], qi, mredParams)
-			z[4] = MRed(x[4], y[4], qi, mredParams)
-			z[5] = MRed(x[5], y[5], qi, mredParams)
-			z[6] = MRed(x[6], y[6], qi, mredParams)
-			z[7] = MRed(x[7], y[7], qi, mredParams)
-		}
-	}
+	r.MulCoeffsMontgomeryLvl(len(r.Modulus)-1, p1, p2, p3)
 }
 
 // MulCoeffsMontgomeryLvl multiplies p1 by p2 coefficient-wise with a Montgomery
 // modular reduction for the moduli from q_0 up to q_level and returns the result on p3.
 func (r *Ring) MulCoeffsMontgomeryLvl(level int, p1, p2, p3 *Poly) {
+	if pool := r.LimbPool(); pool != nil {
+		pool.Run(level+1, func(i int) { r.mulCoeffsMontgomeryLimb(i, p1, p2, p3) })
+		return
+	}
 	for i := 0; i < level+1; i++ {
-		qi := r.Modulus[i]
-		p1tmp, p2tmp, p3tmp := p1.Coeffs[i], p2.Coeffs[i], p3.Coeffs[i]
-		mredParams := r.MredParams[i]
-		for j := 0; j < r.N; j = j + 8 {
-
-			x := (*[8]uint64)(unsafe.Pointer(&p1tmp[j]))
-			y := (*[8]uint64)(unsafe.Pointer(&p2tmp[j]))
-			z := (*[8]uint64)(unsafe.Pointer(&p3tmp[j]))
+		r.mulCoeffsMontgomeryLimb(i, p1, p2, p3)
+	}
+}
 
-			z[0] = MRed(x[0], y[0], qi, mredParams)
-			z[1] = MRed(x[1], y[1], qi, mredParams)
-			z[2] = MRed(x[2], y[2], qi, mredParams)
-			z[3] = MRed(x[3], y[3], qi, mredParams)
-			z[4] = MRed(x[4], y[4], qi, mredParams)
-			z[5] = MRed(x[5], y[5], qi, mredParams)
-			z[6] = MRed(x[6], y[6], qi, mredParams)
-			z[7] = MRed(x[7], y[7], qi, mredParams)
-		}
+// mulCoeffsMontgomeryLimb is MulCoeffsMontgomery on the i-th limb
+func (r *Ring) mulCoeffsMontgomeryLimb(i int, p1, p2, p3 *Poly) {
+	qi := r.Modulus[i]
+	p1tmp, p2tmp, p3tmp := p1.Coeffs[i], p2.Coeffs[i], p3.Coeffs[i]
+	mredParams := r.MredParams[i]
+	for j := 0; j < r.N; j = j + 8 {
+
+		x := (*[8]uint64)(unsafe.Pointer(&p1tmp[j]))
+		y := (*[8]uint64)(unsafe.Pointer(&p2tmp[j]))
+		z := (*[8]uint64)(unsafe.Pointer(&p3tmp[j]))
+
+		z[0] = MRed(x[0], y[0], qi, mredParams)
+		z[1] = MRed(x[1], y[1], qi, mredParams)
+		z[2] = MRed(x[2], y[2], qi, mredParams)
+		z[3] = MRed(x[3], y[3], qi, mredParams)
+		z[4] = MRed(x[4], y[4], qi, mredParams)
+		z[5] = MRed(x[5], y[5], qi, mredParams)
+		z[6] = MRed(x[6], y[6], qi, mredParams)
+		z[7] = MRed(x[7], y[7], qi, mredParams)
 	}
 }
 
@@ -0,0 +1,105 @@
+package ring
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// LimbPool is a pool of goroutines running the per-limb work of the NTTs, of the Montgomery
+// multiplications and of the basis extensions in parallel. A pool can be shared by any number of
+// rings and evaluators: each call runs on the calling goroutine and on the workers that are idle, so
+// that nested or concurrent calls never wait for a busy pool. Each limb is computed exactly as in the
+// serial code, the results are bit-identical.
+type LimbPool struct {
+	workers int
+	tasks   chan func()
+	close   sync.Once
+}
+
+// defaultLimbPool is the pool of the rings without one of their own, nil (serial) by default
+var defaultLimbPool atomic.Pointer[LimbPool]
+
+// NewLimbPool starts a pool of workers goroutines, runtime.GOMAXPROCS(0) if workers <= 0.
+func NewLimbPool(workers int) *LimbPool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	pool := &LimbPool{workers: workers, tasks: make(chan func())}
+	// The calling goroutine is the first worker of each call
+	for i := 0; i < workers-1; i++ {
+		go func() {
+			for task := range pool.tasks {
+				task()
+			}
+		}()
+	}
+	return pool
+}
+
+// Workers returns the number of goroutines of the pool, including the calling one.
+func (pool *LimbPool) Workers() int {
+	return pool.workers
+}
+
+// Close stops the workers of the pool. The pool must not be used afterwards.
+func (pool *LimbPool) Close() {
+	pool.close.Do(func() { close(pool.tasks) })
+}
+
+// Run calls f(i) for each i in [0, n) and returns once they are all done. The indexes are shared by
+// the calling goroutine and the idle workers of the pool.
+func (pool *LimbPool) Run(n int, f func(i int)) {
+	if pool == nil || pool.workers < 2 || n < 2 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	var next atomic.Int64
+	work := func() {
+		for i := int(next.Add(1) - 1); i < n; i = int(next.Add(1) - 1) {
+			f(i)
+		}
+	}
+
+	var wg sync.WaitGroup
+spawn:
+	for helpers := 1; helpers < min(pool.workers, n); helpers++ {
+		wg.Add(1)
+		// The tasks channel is unbuffered: a task is only handed to a worker which is ready to run it
+		select {
+		case pool.tasks <- func() { defer wg.Done(); work() }:
+		default:
+			wg.Done()
+			break spawn
+		}
+	}
+	work()
+	wg.Wait()
+}
+
+// SetDefaultLimbPool sets the pool used by the rings without one of their own (see Ring.SetLimbPool),
+// nil to go back to the serial execution. It applies to the rings created before the call too.
+func SetDefaultLimbPool(pool *LimbPool) {
+	defaultLimbPool.Store(pool)
+}
+
+// DefaultLimbPool returns the pool set by SetDefaultLimbPool, nil if there is none.
+func DefaultLimbPool() *LimbPool {
+	return defaultLimbPool.Load()
+}
+
+// SetLimbPool sets the pool of the ring, nil to use the default one (see SetDefaultLimbPool).
+func (r *Ring) SetLimbPool(pool *LimbPool) {
+	r.pool = pool
+}
+
+// LimbPool returns the pool running the per-limb work of the ring, nil if it runs serially.
+func (r *Ring) LimbPool() *LimbPool {
+	if r.pool != nil {
+		return r.pool
+	}
+	return defaultLimbPool.Load()
+}
@@ -254,6 +254,10 @@ func (pol *Poly) MarshalBinary() (data []byte, err error) {
 // UnmarshalBinary decodes a slice of byte on the target polynomial.
 func (pol *Poly) UnmarshalBinary(data []byte) (err error) {
 
+	if len(data) < 2 || data[0] > 32 {
+		return errors.New("invalid polynomial encoding")
+	}
+
 	N := uint64(1 << data[0])
 	numberModulies := uint64(data[1])
 	pointer := uint64(2)
@@ -0,0 +1,191 @@
+package ring
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/tuneinsight/lattigo/v6/utils/sampling"
+)
+
+// cdtPrecision is the number of bits of the cumulative distribution table
+const cdtPrecision = 63
+
+// CDTTable is the cumulative distribution table (CDT) of a discrete Gaussian truncated to
+// [-bound, bound], with a 63 bits precision. It is read-only, the CDTGaussianSamplers of the same
+// distribution share it.
+type CDTTable struct {
+	sigma float64
+	bound int
+	cdt   []uint64 // cdt[k] = 2^63 * P(|x| <= k)
+}
+
+// NewCDTTable computes the table of the discrete Gaussian of standard deviation sigma truncated to
+// [-bound, bound].
+func NewCDTTable(sigma float64, bound int) *CDTTable {
+	if sigma <= 0 || bound < 0 {
+		panic("cannot NewCDTTable: sigma must be positive and bound non-negative")
+	}
+
+	// The magnitude |x| is sampled, 0 with weight rho(0) and k > 0 with weight 2*rho(k), and its sign
+	// is a uniform bit: the tail first, for the precision of the sum
+	weights := make([]float64, bound+1)
+	var sum float64
+	for k := bound; k >= 0; k-- {
+		weights[k] = math.Exp(-float64(k*k) / (2 * sigma * sigma))
+		if k > 0 {
+			weights[k] *= 2
+		}
+		sum += weights[k]
+	}
+
+	cdt := make([]uint64, bound+1)
+	var cumulative float64
+	for k := 0; k < bound; k++ {
+		cumulative += weights[k]
+		cdt[k] = uint64(math.Round(math.Ldexp(cumulative/sum, cdtPrecision)))
+	}
+	cdt[bound] = 1 << cdtPrecision
+	return &CDTTable{sigma: sigma, bound: bound, cdt: cdt}
+}
+
+// CDTGaussianSampler samples a discrete Gaussian truncated to [-bound, bound] in constant time: each
+// sample reads the same number of random bytes and scans the whole cumulative distribution table with
+// branch-free comparisons, whatever its value.
+type CDTGaussianSampler struct {
+	baseSampler
+	table        *CDTTable
+	randomBuffer []byte
+	ptr          int
+}
+
+// NewCDTGaussianSampler creates the sampler of the discrete Gaussian of standard deviation sigma
+// truncated to [-bound, bound] from a PRNG.
+func NewCDTGaussianSampler(prng sampling.PRNG, sigma float64, bound int) *CDTGaussianSampler {
+	return NewCDTGaussianSamplerFromTable(prng, NewCDTTable(sigma, bound))
+}
+
+// NewCDTGaussianSamplerFromTable creates the sampler of the distribution of table from a PRNG, without
+// computing the table again.
+func NewCDTGaussianSamplerFromTable(prng sampling.PRNG, table *CDTTable) *CDTGaussianSampler {
+	sampler := new(CDTGaussianSampler)
+	sampler.prng = prng
+	sampler.table = table
+	sampler.randomBuffer = make([]byte, 1024)
+	sampler.ptr = len(sampler.randomBuffer)
+	return sampler
+}
+
+// Sigma returns the standard deviation of the sampler.
+func (sampler *CDTGaussianSampler) Sigma() float64 {
+	return sampler.table.sigma
+}
+
+// Bound returns the bound of the samples in absolute value.
+func (sampler *CDTGaussianSampler) Bound() int {
+	return sampler.table.bound
+}
+
+// randomUint64 reads 8 bytes of the random buffer, refilled from the PRNG when it runs empty
+func (sampler *CDTGaussianSampler) randomUint64() (uint64, error) {
+	if sampler.ptr == len(sampler.randomBuffer) {
+		if _, err := sampler.prng.Read(sampler.randomBuffer); err != nil {
+			return 0, err
+		}
+		sampler.ptr = 0
+	}
+	r := binary.BigEndian.Uint64(sampler.randomBuffer[sampler.ptr : sampler.ptr+8])
+	sampler.ptr += 8
+	return r, nil
+}
+
+// sample returns the magnitude of a sample and its sign bit
+func (sampler *CDTGaussianSampler) sample() (magnitude, sign uint64, err error) {
+	r, err := sampler.randomUint64()
+	if err != nil {
+		return 0, 0, err
+	}
+	sign = r >> cdtPrecision
+	r &= 1<<cdtPrecision - 1
+
+	// magnitude = #{k : cdt[k] <= r}, r - cdt[k] borrows (top bit set) iff r < cdt[k] <= 2^63
+	for _, c := range sampler.table.cdt {
+		magnitude += 1 ^ ((r - c) >> 63)
+	}
+	return
+}
+
+// Sample returns a sample of the discrete Gaussian.
+func (sampler *CDTGaussianSampler) Sample() (int64, error) {
+	magnitude, sign, err := sampler.sample()
+	if err != nil {
+		return 0, err
+	}
+	mask := -sign
+	return int64((magnitude ^ mask) - mask), nil
+}
+
+// SampleMod returns a sample of the discrete Gaussian modulo q > bound, in [0, q-1].
+func (sampler *CDTGaussianSampler) SampleMod(q uint64) (uint64, error) {
+	magnitude, sign, err := sampler.sample()
+	if err != nil {
+		return 0, err
+	}
+	return cRedConstant(magnitude^((magnitude^(q-magnitude))&-sign), q), nil
+}
+
+// AddNoise adds a sample of the discrete Gaussian modulo q > bound to each of the values, which must be
+// in [0, q-1].
+func (sampler *CDTGaussianSampler) AddNoise(values []uint64, q uint64) error {
+	for i := range values {
+		e, err := sampler.SampleMod(q)
+		if err != nil {
+			return err
+		}
+		values[i] = cRedConstant(values[i]+e, q)
+	}
+	return nil
+}
+
+// BoundedUniformSampler samples integers uniformly in [0, bound-1] in constant time: instead of
+// rejecting the samples out of range, each one is the high part of 128 random bits times the bound,
+// whose distance to the uniform distribution is at most bound/2^128.
+type BoundedUniformSampler struct {
+	baseSampler
+	randomBuffer []byte
+	ptr          int
+}
+
+// NewBoundedUniformSampler creates a new BoundedUniformSampler from a PRNG.
+func NewBoundedUniformSampler(prng sampling.PRNG) *BoundedUniformSampler {
+	sampler := new(BoundedUniformSampler)
+	sampler.prng = prng
+	sampler.randomBuffer = make([]byte, 1024)
+	sampler.ptr = len(sampler.randomBuffer)
+	return sampler
+}
+
+// Sample returns an integer in [0, bound-1], or the error of the PRNG.
+func (sampler *BoundedUniformSampler) Sample(bound uint64) (uint64, error) {
+	if sampler.ptr == len(sampler.randomBuffer) {
+		if _, err := sampler.prng.Read(sampler.randomBuffer); err != nil {
+			return 0, err
+		}
+		sampler.ptr = 0
+	}
+	hi := binary.BigEndian.Uint64(sampler.randomBuffer[sampler.ptr : sampler.ptr+8])
+	lo := binary.BigEndian.Uint64(sampler.randomBuffer[sampler.ptr+8 : sampler.ptr+16])
+	sampler.ptr += 16
+
+	// floor((hi*2^64 + lo) * bound / 2^128)
+	loHi, _ := bits.Mul64(lo, bound)
+	hiHi, hiLo := bits.Mul64(hi, bound)
+	_, carry := bits.Add64(hiLo, loHi, 0)
+	return hiHi + carry, nil
+}
+
+// cRedConstant reduces a in [0, 2q-1] modulo q < 2^63 without branching
+func cRedConstant(a, q uint64) uint64 {
+	d := a - q
+	return d + (q & -(d >> 63))
+}
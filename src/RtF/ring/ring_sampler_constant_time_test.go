@@ -0,0 +1,165 @@
+package ring
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/lattigo/v6/utils/sampling"
+)
+
+// constantTimeSamples is the number of samples of the statistical tests, their seeded PRNGs make them
+// deterministic
+const constantTimeSamples = 1 << 18
+
+// chiSquare returns the chi-square statistic of the observed counts against the expected ones, and
+// its degrees of freedom, after merging the bins expecting less than 5 samples into their neighbors
+func chiSquare(observed []int, expected []float64) (stat float64, df int) {
+	var o, e float64
+	bins := 0
+	for i := range observed {
+		o += float64(observed[i])
+		e += expected[i]
+		if e >= 5 || i == len(observed)-1 {
+			stat += (o - e) * (o - e) / e
+			o, e = 0, 0
+			bins++
+		}
+	}
+	return stat, bins - 1
+}
+
+// chiSquareLimit a generous upper bound of the chi-square statistic with df degrees of freedom
+func chiSquareLimit(df int) float64 {
+	return float64(df) + 6*math.Sqrt(2*float64(df))
+}
+
+func TestConstantTimeSamplers(t *testing.T) {
+	for _, sigma := range []float64{0.6383076486422923, 1.6356633496458739, DefaultSigma, 4.428259312455903} {
+		bound := int(6 * sigma)
+		t.Run(fmt.Sprintf("CDTGaussianSampler/sigma=%.2f", sigma), func(t *testing.T) {
+			prng, err := sampling.NewKeyedPRNG([]byte("cdt"))
+			require.NoError(t, err)
+			sampler := NewCDTGaussianSampler(prng, sigma, bound)
+
+			// Target distribution, truncated to [-bound, bound]
+			target := make([]float64, 2*bound+1)
+			var sum, variance float64
+			for k := -bound; k <= bound; k++ {
+				target[k+bound] = math.Exp(-float64(k*k) / (2 * sigma * sigma))
+				sum += target[k+bound]
+			}
+			for k := -bound; k <= bound; k++ {
+				target[k+bound] /= sum
+				variance += target[k+bound] * float64(k*k)
+			}
+
+			counts := make([]int, 2*bound+1)
+			var mean, sq float64
+			for i := 0; i < constantTimeSamples; i++ {
+				x, err := sampler.Sample()
+				require.NoError(t, err)
+				require.LessOrEqual(t, int(math.Abs(float64(x))), bound)
+				counts[int(x)+bound]++
+				mean += float64(x)
+				sq += float64(x * x)
+			}
+
+			expected := make([]float64, len(target))
+			for i := range target {
+				expected[i] = target[i] * constantTimeSamples
+			}
+			stat, df := chiSquare(counts, expected)
+			require.Less(t, stat, chiSquareLimit(df), "chi-square with %d degrees of freedom", df)
+
+			// Moments, within 6 standard errors
+			n := float64(constantTimeSamples)
+			mean /= n
+			require.Less(t, math.Abs(mean), 6*math.Sqrt(variance/n))
+			require.InDelta(t, variance, sq/n, 6*variance*math.Sqrt(2/n))
+		})
+	}
+
+	t.Run("CDTGaussianSampler/SampleMod", func(t *testing.T) {
+		q := T
+		prng0, _ := sampling.NewKeyedPRNG([]byte("mod"))
+		prng1, _ := sampling.NewKeyedPRNG([]byte("mod"))
+		prng2, _ := sampling.NewKeyedPRNG([]byte("mod"))
+		signed := NewCDTGaussianSampler(prng0, DefaultSigma, DefaultBound)
+		mod := NewCDTGaussianSampler(prng1, DefaultSigma, DefaultBound)
+		noise := NewCDTGaussianSampler(prng2, DefaultSigma, DefaultBound)
+
+		values := make([]uint64, 4096)
+		for i := range values {
+			values[i] = q - 1 - uint64(i)
+		}
+		noisy := append([]uint64{}, values...)
+		require.NoError(t, noise.AddNoise(noisy, q))
+		for i := range values {
+			x, err := signed.Sample()
+			require.NoError(t, err)
+			want := uint64((x + int64(q)) % int64(q))
+			got, err := mod.SampleMod(q)
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+			require.Equal(t, (values[i]+want)%q, noisy[i])
+		}
+	})
+	t.Run("CDTGaussianSampler/SharedTable", func(t *testing.T) {
+		table := NewCDTTable(DefaultSigma, DefaultBound)
+		prng0, _ := sampling.NewKeyedPRNG([]byte("table"))
+		prng1, _ := sampling.NewKeyedPRNG([]byte("table"))
+		own := NewCDTGaussianSampler(prng0, DefaultSigma, DefaultBound)
+		shared := NewCDTGaussianSamplerFromTable(prng1, table)
+		require.Equal(t, own.Sigma(), shared.Sigma())
+		require.Equal(t, own.Bound(), shared.Bound())
+		for i := 0; i < 1024; i++ {
+			want, err := own.Sample()
+			require.NoError(t, err)
+			got, err := shared.Sample()
+			require.NoError(t, err)
+			require.Equal(t, want, got)
+		}
+	})
+
+	for _, bound := range []uint64{1, 17, T, 0xffffffffffffffc5} {
+		t.Run(fmt.Sprintf("BoundedUniformSampler/bound=%d", bound), func(t *testing.T) {
+			prng, err := sampling.NewKeyedPRNG([]byte("uniform"))
+			require.NoError(t, err)
+			sampler := NewBoundedUniformSampler(prng)
+
+			// The large bounds are split into 64 buckets of equal size, up to the rounding
+			buckets := min(bound, 64)
+			counts := make([]int, buckets)
+			var mean, sq float64
+			for i := 0; i < constantTimeSamples; i++ {
+				x, err := sampler.Sample(bound)
+				require.NoError(t, err)
+				require.Less(t, x, bound)
+				hi, lo := bits.Mul64(x, buckets)
+				b, _ := bits.Div64(hi, lo, bound)
+				counts[b]++
+				mean += float64(x) / float64(bound)
+				sq += (float64(x) / float64(bound)) * (float64(x) / float64(bound))
+			}
+
+			expected := make([]float64, buckets)
+			for i := range expected {
+				expected[i] = constantTimeSamples / float64(buckets)
+			}
+			stat, df := chiSquare(counts, expected)
+			if df > 0 {
+				require.Less(t, stat, chiSquareLimit(df), "chi-square with %d degrees of freedom", df)
+			}
+
+			// The moments of x/bound, uniform on {0, 1/bound, ..., (bound-1)/bound}
+			n, b := float64(constantTimeSamples), float64(bound)
+			wantMean := (b - 1) / (2 * b)
+			wantVariance := (b*b - 1) / (12 * b * b)
+			require.InDelta(t, wantMean, mean/n, 6*math.Sqrt(wantVariance/n)+1e-12)
+			require.InDelta(t, wantVariance+wantMean*wantMean, sq/n, 6*math.Sqrt(1/(5*n))+1e-12)
+		})
+	}
+}
@@ -2,7 +2,6 @@ package ring
 
 import (
 	"encoding/binary"
-	"flhhe/src/utils"
 	"math"
 
 	"github.com/tuneinsight/lattigo/v6/utils/sampling"
@@ -27,40 +26,47 @@ func NewGaussianSampler(prng sampling.PRNG) *GaussianSampler {
 }
 
 // Read samples a polynomial at the maximum level into pol
-func (gaussianSampler *GaussianSampler) Read(pol *Poly, baseRing *Ring, sigma float64, bound int) {
-	gaussianSampler.ReadLvl(len(baseRing.Modulus)-1, pol, baseRing, sigma, bound)
+func (gaussianSampler *GaussianSampler) Read(pol *Poly, baseRing *Ring, sigma float64, bound int) error {
+	return gaussianSampler.ReadLvl(len(baseRing.Modulus)-1, pol, baseRing, sigma, bound)
 }
 
 // ReadNew samples a new truncated Gaussian polynomial with
 // standard deviation sigma within the given bound using the Ziggurat algorithm.
-func (gaussianSampler *GaussianSampler) ReadNew(baseRing *Ring, sigma float64, bound int) (pol *Poly) {
+func (gaussianSampler *GaussianSampler) ReadNew(baseRing *Ring, sigma float64, bound int) (pol *Poly, err error) {
 	pol = baseRing.NewPoly()
-	gaussianSampler.Read(pol, baseRing, sigma, bound)
-	return pol
+	if err = gaussianSampler.Read(pol, baseRing, sigma, bound); err != nil {
+		return nil, err
+	}
+	return pol, nil
 }
 
 // ReadLvlNew samples a new truncated Gaussian polynomial with
 // standard deviation sigma within the given bound using the Ziggurat algorithm.
-func (gaussianSampler *GaussianSampler) ReadLvlNew(level int, baseRing *Ring, sigma float64, bound int) (pol *Poly) {
+func (gaussianSampler *GaussianSampler) ReadLvlNew(level int, baseRing *Ring, sigma float64, bound int) (pol *Poly, err error) {
 	pol = baseRing.NewPolyLvl(level)
-	gaussianSampler.ReadLvl(level, pol, baseRing, sigma, bound)
-	return pol
+	if err = gaussianSampler.ReadLvl(level, pol, baseRing, sigma, bound); err != nil {
+		return nil, err
+	}
+	return pol, nil
 }
 
 // ReadLvl samples a polynomial at the given level into pol.
-func (gaussianSampler *GaussianSampler) ReadLvl(level int, pol *Poly, baseRing *Ring, sigma float64, bound int) {
+func (gaussianSampler *GaussianSampler) ReadLvl(level int, pol *Poly, baseRing *Ring, sigma float64, bound int) (err error) {
 
 	var coeffFlo float64
 	var coeffInt uint64
 	var sign uint64
 
-	_, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN)
-	utils.HandleError(err)
+	if _, err = gaussianSampler.prng.Read(gaussianSampler.randomBufferN); err != nil {
+		return err
+	}
 
 	for i := 0; i < baseRing.N; i++ {
 
 		for {
-			coeffFlo, sign = gaussianSampler.normFloat64()
+			if coeffFlo, sign, err = gaussianSampler.normFloat64(); err != nil {
+				return err
+			}
 
 			if coeffInt = uint64(coeffFlo*sigma + 0.5); coeffInt <= uint64(bound) {
 				break
@@ -71,27 +77,31 @@ func (gaussianSampler *GaussianSampler) ReadLvl(level int, pol *Poly, baseRing *
 			pol.Coeffs[j][i] = (coeffInt * sign) | (qi-coeffInt)*(sign^1)
 		}
 	}
+	return nil
 }
 
 // ReadAndAdd adds on the input polynomial a truncated Gaussian polynomial of at the maximum level
 // with standard deviation sigma within the given bound using the Ziggurat algorithm.
-func (gaussianSampler *GaussianSampler) ReadAndAdd(pol *Poly, baseRing *Ring, sigma float64, bound int) {
-	gaussianSampler.ReadAndAddLvl(len(baseRing.Modulus)-1, pol, baseRing, sigma, bound)
+func (gaussianSampler *GaussianSampler) ReadAndAdd(pol *Poly, baseRing *Ring, sigma float64, bound int) error {
+	return gaussianSampler.ReadAndAddLvl(len(baseRing.Modulus)-1, pol, baseRing, sigma, bound)
 }
 
 // ReadAndAddLvl samples and adds a polynomial at the given level directly into pol. pol must be at the given level.
-func (gaussianSampler *GaussianSampler) ReadAndAddLvl(level int, pol *Poly, baseRing *Ring, sigma float64, bound int) {
+func (gaussianSampler *GaussianSampler) ReadAndAddLvl(level int, pol *Poly, baseRing *Ring, sigma float64, bound int) (err error) {
 
 	var coeffFlo float64
 	var coeffInt, sign uint64
 
-	_, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN)
-	utils.HandleError(err)
+	if _, err = gaussianSampler.prng.Read(gaussianSampler.randomBufferN); err != nil {
+		return err
+	}
 
 	for i := 0; i < baseRing.N; i++ {
 
 		for {
-			coeffFlo, sign = gaussianSampler.normFloat64()
+			if coeffFlo, sign, err = gaussianSampler.normFloat64(); err != nil {
+				return err
+			}
 
 			if coeffInt = uint64(coeffFlo*sigma + 0.5); coeffInt <= uint64(bound) {
 				break
@@ -102,21 +112,25 @@ func (gaussianSampler *GaussianSampler) ReadAndAddLvl(level int, pol *Poly, base
 			pol.Coeffs[j][i] = CRed(pol.Coeffs[j][i]+((coeffInt*sign)|(qi-coeffInt)*(sign^1)), qi)
 		}
 	}
+	return nil
 }
 
 // AddGaussianNoise adds gaussian noises to state of the Rubato cipher.
-func (GaussianSampler *GaussianSampler) AGN(state []uint64, plainModulus uint64, sigma float64, bound int) {
+func (GaussianSampler *GaussianSampler) AGN(state []uint64, plainModulus uint64, sigma float64, bound int) (err error) {
 
 	var coeffFlo float64
 	var coeffInt, sign uint64
 
-	_, err := GaussianSampler.prng.Read(GaussianSampler.randomBufferN)
-	utils.HandleError(err)
+	if _, err = GaussianSampler.prng.Read(GaussianSampler.randomBufferN); err != nil {
+		return err
+	}
 
 	outputsize := len(state) - 4
 	for i := 0; i < outputsize; i++ {
 		for {
-			coeffFlo, sign = GaussianSampler.normFloat64()
+			if coeffFlo, sign, err = GaussianSampler.normFloat64(); err != nil {
+				return err
+			}
 
 			if coeffInt = uint64(coeffFlo*sigma + 0.5); coeffInt <= uint64(bound) {
 				break
@@ -125,6 +139,7 @@ func (GaussianSampler *GaussianSampler) AGN(state []uint64, plainModulus uint64,
 
 		state[i] = CRed(state[i]+((coeffInt*sign)|(plainModulus-coeffInt)*(sign^1)), plainModulus)
 	}
+	return nil
 }
 
 // randFloat64 returns a uniform float64 value between 0 and 1.
@@ -142,13 +157,14 @@ func randFloat64(randomBytes []byte) float64 {
 //
 // Algorithm adapted from https://golang.org/src/math/rand/normal.go
 // to use a secure PRNG instead of math/rand.
-func (gaussianSampler *GaussianSampler) normFloat64() (float64, uint64) {
+func (gaussianSampler *GaussianSampler) normFloat64() (float64, uint64, error) {
 
 	for {
 
 		if gaussianSampler.ptr == uint64(len(gaussianSampler.randomBufferN)) {
-			_, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN)
-			utils.HandleError(err)
+			if _, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN); err != nil {
+				return 0, 0, err
+			}
 
 			gaussianSampler.ptr = 0
 		}
@@ -167,7 +183,7 @@ func (gaussianSampler *GaussianSampler) normFloat64() (float64, uint64) {
 		if uint32(j) < kn[i] {
 
 			// This case should be hit more than 99% of the time.
-			return x, sign
+			return x, sign, nil
 		}
 
 		// 2
@@ -177,8 +193,9 @@ func (gaussianSampler *GaussianSampler) normFloat64() (float64, uint64) {
 			for {
 
 				if gaussianSampler.ptr == uint64(len(gaussianSampler.randomBufferN)) {
-					_, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN)
-					utils.HandleError(err)
+					if _, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN); err != nil {
+						return 0, 0, err
+					}
 					gaussianSampler.ptr = 0
 				}
 
@@ -186,8 +203,9 @@ func (gaussianSampler *GaussianSampler) normFloat64() (float64, uint64) {
 				gaussianSampler.ptr += 8
 
 				if gaussianSampler.ptr == uint64(len(gaussianSampler.randomBufferN)) {
-					_, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN)
-					utils.HandleError(err)
+					if _, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN); err != nil {
+						return 0, 0, err
+					}
 					gaussianSampler.ptr = 0
 				}
 
@@ -199,19 +217,20 @@ func (gaussianSampler *GaussianSampler) normFloat64() (float64, uint64) {
 				}
 			}
 
-			return x + 3.442619855899, sign
+			return x + 3.442619855899, sign, nil
 		}
 
 		if gaussianSampler.ptr == uint64(len(gaussianSampler.randomBufferN)) {
-			_, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN)
-			utils.HandleError(err)
+			if _, err := gaussianSampler.prng.Read(gaussianSampler.randomBufferN); err != nil {
+				return 0, 0, err
+			}
 			gaussianSampler.ptr = 0
 		}
 
 		// 3
 		if fn[i]+float32(randFloat64(gaussianSampler.randomBufferN[gaussianSampler.ptr:gaussianSampler.ptr+8]))*(fn[i-1]-fn[i]) < float32(math.Exp(-0.5*x*x)) {
 			gaussianSampler.ptr += 8
-			return x, sign
+			return x, sign, nil
 		}
 		gaussianSampler.ptr += 8
 	}
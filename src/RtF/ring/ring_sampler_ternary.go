@@ -1,7 +1,6 @@
 package ring
 
 import (
-	"flhhe/src/utils"
 	"math"
 	"math/bits"
 
@@ -15,7 +14,7 @@ type TernarySampler struct {
 	matrixValues [][3]uint64
 	p            float64
 	hw           int
-	sample       func(lvl int, poly *Poly)
+	sample       func(lvl int, poly *Poly) error
 }
 
 // NewTernarySampler creates a new instance of TernarySampler from a PRNG, the ring definition and the distribution
@@ -53,27 +52,31 @@ func NewTernarySamplerSparse(prng sampling.PRNG, baseRing *Ring, hw int, montgom
 }
 
 // Read samples a polynomial into pol.
-func (ts *TernarySampler) Read(pol *Poly) {
-	ts.sample(len(ts.baseRing.Modulus)-1, pol)
+func (ts *TernarySampler) Read(pol *Poly) error {
+	return ts.sample(len(ts.baseRing.Modulus)-1, pol)
 }
 
 // ReadLvl samples a polynomial into pol at the speciefied level.
-func (ts *TernarySampler) ReadLvl(lvl int, pol *Poly) {
-	ts.sample(lvl, pol)
+func (ts *TernarySampler) ReadLvl(lvl int, pol *Poly) error {
+	return ts.sample(lvl, pol)
 }
 
 // ReadNew allocates and samples a polynomial at the max level.
-func (ts *TernarySampler) ReadNew() (pol *Poly) {
+func (ts *TernarySampler) ReadNew() (pol *Poly, err error) {
 	pol = ts.baseRing.NewPoly()
-	ts.sample(len(ts.baseRing.Modulus)-1, pol)
-	return pol
+	if err = ts.sample(len(ts.baseRing.Modulus)-1, pol); err != nil {
+		return nil, err
+	}
+	return pol, nil
 }
 
 // ReadLvlNew allocates and samples a polynomial at the speficied level.
-func (ts *TernarySampler) ReadLvlNew(lvl int) (pol *Poly) {
+func (ts *TernarySampler) ReadLvlNew(lvl int) (pol *Poly, err error) {
 	pol = ts.baseRing.NewPolyLvl(lvl)
-	ts.sample(lvl, pol)
-	return pol
+	if err = ts.sample(lvl, pol); err != nil {
+		return nil, err
+	}
+	return pol, nil
 }
 
 func (ts *TernarySampler) initializeMatrix(montgomery bool) {
@@ -119,7 +122,7 @@ func (ts *TernarySampler) computeMatrixTernary(p float64) {
 
 }
 
-func (ts *TernarySampler) sampleProba(lvl int, pol *Poly) {
+func (ts *TernarySampler) sampleProba(lvl int, pol *Poly) (err error) {
 
 	if ts.p == 0 {
 		panic("cannot sample -> p = 0")
@@ -134,11 +137,12 @@ func (ts *TernarySampler) sampleProba(lvl int, pol *Poly) {
 		randomBytesCoeffs := make([]byte, ts.baseRing.N>>3)
 		randomBytesSign := make([]byte, ts.baseRing.N>>3)
 
-		_, err := ts.prng.Read(randomBytesCoeffs)
-		utils.HandleError(err)
-
-		_, err = ts.prng.Read(randomBytesSign)
-		utils.HandleError(err)
+		if _, err = ts.prng.Read(randomBytesCoeffs); err != nil {
+			return err
+		}
+		if _, err = ts.prng.Read(randomBytesSign); err != nil {
+			return err
+		}
 
 		for i := 0; i < ts.baseRing.N; i++ {
 			coeff = uint64(uint8(randomBytesCoeffs[i>>3])>>(i&7)) & 1
@@ -158,12 +162,15 @@ func (ts *TernarySampler) sampleProba(lvl int, pol *Poly) {
 		pointer := uint8(0)
 		var bytePointer int
 
-		_, err := ts.prng.Read(randomBytes)
-		utils.HandleError(err)
+		if _, err = ts.prng.Read(randomBytes); err != nil {
+			return err
+		}
 
 		for i := 0; i < ts.baseRing.N; i++ {
 
-			coeff, sign, randomBytes, pointer, bytePointer = ts.kysampling(ts.prng, randomBytes, pointer, bytePointer, ts.baseRing.N)
+			if coeff, sign, randomBytes, pointer, bytePointer, err = ts.kysampling(ts.prng, randomBytes, pointer, bytePointer, ts.baseRing.N); err != nil {
+				return err
+			}
 
 			index = (coeff & (sign ^ 1)) | ((sign & coeff) << 1)
 
@@ -172,9 +179,10 @@ func (ts *TernarySampler) sampleProba(lvl int, pol *Poly) {
 			}
 		}
 	}
+	return nil
 }
 
-func (ts *TernarySampler) sampleSparse(lvl int, pol *Poly) {
+func (ts *TernarySampler) sampleSparse(lvl int, pol *Poly) (err error) {
 
 	if ts.hw > ts.baseRing.N {
 		ts.hw = ts.baseRing.N
@@ -191,15 +199,20 @@ func (ts *TernarySampler) sampleSparse(lvl int, pol *Poly) {
 	randomBytes := make([]byte, uint64(math.Ceil(float64(ts.hw)/8.0))) // We sample ceil(hw/8) bytes
 	pointer := uint8(0)
 
-	_, err := ts.prng.Read(randomBytes)
-	utils.HandleError(err)
+	if _, err = ts.prng.Read(randomBytes); err != nil {
+		return err
+	}
 
 	for i := 0; i < ts.hw; i++ {
 		mask = (1 << uint64(bits.Len64(uint64(ts.baseRing.N-i)))) - 1 // rejection sampling of a random variable between [0, len(index)]
 
-		j = randInt32(ts.prng, mask)
+		if j, err = randInt32(ts.prng, mask); err != nil {
+			return err
+		}
 		for j >= uint64(ts.baseRing.N-i) {
-			j = randInt32(ts.prng, mask)
+			if j, err = randInt32(ts.prng, mask); err != nil {
+				return err
+			}
 		}
 
 		coeff = (uint8(randomBytes[0]) >> (i & 7)) & 1 // random binary digit [0, 1] from the random bytes (0 = 1, 1 = -1)
@@ -218,10 +231,11 @@ func (ts *TernarySampler) sampleSparse(lvl int, pol *Poly) {
 			pointer = 0
 		}
 	}
+	return nil
 }
 
 // kysampling uses the binary expansion and random bytes matrix to sample a discrete Gaussian value and its sign.
-func (ts *TernarySampler) kysampling(prng sampling.PRNG, randomBytes []byte, pointer uint8, bytePointer, byteLength int) (uint64, uint64, []byte, uint8, int) {
+func (ts *TernarySampler) kysampling(prng sampling.PRNG, randomBytes []byte, pointer uint8, bytePointer, byteLength int) (uint64, uint64, []byte, uint8, int, error) {
 
 	var sign uint8
 
@@ -256,8 +270,9 @@ func (ts *TernarySampler) kysampling(prng sampling.PRNG, randomBytes []byte, poi
 
 						if bytePointer >= byteLength {
 							bytePointer = 0
-							_, err := prng.Read(randomBytes)
-							utils.HandleError(err)
+							if _, err := prng.Read(randomBytes); err != nil {
+								return 0, 0, nil, 0, 0, err
+							}
 						}
 
 						sign = uint8(randomBytes[bytePointer]) & 1
@@ -268,7 +283,7 @@ func (ts *TernarySampler) kysampling(prng sampling.PRNG, randomBytes []byte, poi
 						sign = uint8(randomBytes[bytePointer]>>(i+1)) & 1
 					}
 
-					return uint64(row), uint64(sign), randomBytes, pointer + 1, bytePointer
+					return uint64(row), uint64(sign), randomBytes, pointer + 1, bytePointer, nil
 				}
 			}
 
@@ -282,8 +297,9 @@ func (ts *TernarySampler) kysampling(prng sampling.PRNG, randomBytes []byte, poi
 
 		if bytePointer >= byteLength {
 			bytePointer = 0
-			_, err := prng.Read(randomBytes)
-			utils.HandleError(err)
+			if _, err := prng.Read(randomBytes); err != nil {
+				return 0, 0, nil, 0, 0, err
+			}
 		}
 
 	}
@@ -2,7 +2,6 @@ package ring
 
 import (
 	"encoding/binary"
-	"flhhe/src/utils"
 
 	"github.com/tuneinsight/lattigo/v6/utils/sampling"
 )
@@ -23,13 +22,14 @@ func NewUniformSampler(prng sampling.PRNG, baseRing *Ring) *UniformSampler {
 }
 
 // Read generates a new polynomial with coefficients following a uniform distribution over [0, Qi-1].
-func (uniformSampler *UniformSampler) Read(Pol *Poly) {
+func (uniformSampler *UniformSampler) Read(Pol *Poly) (err error) {
 
 	var randomUint, mask, qi uint64
 	var ptr int
 
-	_, err := uniformSampler.prng.Read(uniformSampler.randomBufferN)
-	utils.HandleError(err)
+	if _, err = uniformSampler.prng.Read(uniformSampler.randomBufferN); err != nil {
+		return err
+	}
 
 	for j := range uniformSampler.baseRing.Modulus {
 
@@ -48,8 +48,9 @@ func (uniformSampler *UniformSampler) Read(Pol *Poly) {
 
 				// Refill the pool if it runs empty
 				if ptr == uniformSampler.baseRing.N {
-					_, err = uniformSampler.prng.Read(uniformSampler.randomBufferN)
-					utils.HandleError(err)
+					if _, err = uniformSampler.prng.Read(uniformSampler.randomBufferN); err != nil {
+						return err
+					}
 
 					ptr = 0
 				}
@@ -67,16 +68,18 @@ func (uniformSampler *UniformSampler) Read(Pol *Poly) {
 			ptmp[i] = randomUint
 		}
 	}
+	return nil
 }
 
 // Readlvl generates a new polynomial with coefficients following a uniform distribution over [0, Qi-1].
-func (uniformSampler *UniformSampler) Readlvl(level int, Pol *Poly) {
+func (uniformSampler *UniformSampler) Readlvl(level int, Pol *Poly) (err error) {
 
 	var randomUint, mask, qi uint64
 	var ptr int
 
-	_, err := uniformSampler.prng.Read(uniformSampler.randomBufferN)
-	utils.HandleError(err)
+	if _, err = uniformSampler.prng.Read(uniformSampler.randomBufferN); err != nil {
+		return err
+	}
 
 	for j := 0; j < level+1; j++ {
 
@@ -95,8 +98,9 @@ func (uniformSampler *UniformSampler) Readlvl(level int, Pol *Poly) {
 
 				// Refill the pool if it runs empty
 				if ptr == uniformSampler.baseRing.N {
-					_, err = uniformSampler.prng.Read(uniformSampler.randomBufferN)
-					utils.HandleError(err)
+					if _, err = uniformSampler.prng.Read(uniformSampler.randomBufferN); err != nil {
+						return err
+					}
 					ptr = 0
 				}
 
@@ -113,61 +117,70 @@ func (uniformSampler *UniformSampler) Readlvl(level int, Pol *Poly) {
 			ptmp[i] = randomUint
 		}
 	}
+	return nil
 }
 
 // ReadNew generates a new polynomial with coefficients following a uniform distribution over [0, Qi-1].
 // Polynomial is created at the max level.
-func (uniformSampler *UniformSampler) ReadNew() (Pol *Poly) {
+func (uniformSampler *UniformSampler) ReadNew() (Pol *Poly, err error) {
 	Pol = uniformSampler.baseRing.NewPoly()
-	uniformSampler.Read(Pol)
-	return
+	if err = uniformSampler.Read(Pol); err != nil {
+		return nil, err
+	}
+	return Pol, nil
 }
 
 // ReadLvlNew generates a new polynomial with coefficients following a uniform distribution over [0, Qi-1].
 // Polynomial is created at the specified level.
-func (uniformSampler *UniformSampler) ReadLvlNew(level int) (Pol *Poly) {
+func (uniformSampler *UniformSampler) ReadLvlNew(level int) (Pol *Poly, err error) {
 	Pol = uniformSampler.baseRing.NewPolyLvl(level)
-	uniformSampler.Read(Pol)
-	return
+	if err = uniformSampler.Readlvl(level, Pol); err != nil {
+		return nil, err
+	}
+	return Pol, nil
 }
 
 // RandUniform samples a uniform randomInt variable in the range [0, mask] until randomInt is in the range [0, v-1].
 // mask needs to be of the form 2^n -1.
-func RandUniform(prng sampling.PRNG, v uint64, mask uint64) (randomInt uint64) {
+func RandUniform(prng sampling.PRNG, v uint64, mask uint64) (randomInt uint64, err error) {
 	for {
-		randomInt = randInt64(prng, mask)
+		if randomInt, err = randInt64(prng, mask); err != nil {
+			return 0, err
+		}
 		if randomInt < v {
-			return randomInt
+			return randomInt, nil
 		}
 	}
 }
 
 // randInt32 samples a uniform variable in the range [0, mask], where mask is of the form 2^n-1, with n in [0, 32].
-func randInt32(prng sampling.PRNG, mask uint64) uint64 {
+func randInt32(prng sampling.PRNG, mask uint64) (uint64, error) {
 
 	// generate random 4 bytes
 	randomBytes := make([]byte, 4)
-	_, err := prng.Read(randomBytes)
-	utils.HandleError(err)
+	if _, err := prng.Read(randomBytes); err != nil {
+		return 0, err
+	}
 
 	// convert 4 bytes to a uint32
 	randomUint32 := uint64(binary.BigEndian.Uint32(randomBytes))
 
 	// return required bits
-	return mask & randomUint32
+	return mask & randomUint32, nil
 }
 
 // randInt64 samples a uniform variable in the range [0, mask], where mask is of the form 2^n-1, with n in [0, 64].
-func randInt64(prng sampling.PRNG, mask uint64) uint64 {
+func randInt64(prng sampling.PRNG, mask uint64) (uint64, error) {
 
 	// generate random 8 bytes
 	randomBytes := make([]byte, 8)
-	_, err := prng.Read(randomBytes)
-	utils.HandleError(err)
+	if _, err := prng.Read(randomBytes); err != nil {
+		return 0, err
+	}
 
 	// convert 8 bytes to a uint64
 	randomUint64 := binary.BigEndian.Uint64(randomBytes)
 
 	// return required bits
-	return mask & randomUint64
+	return mask & randomUint64, nil
 }
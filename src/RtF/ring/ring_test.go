@@ -1,6 +1,7 @@
 package ring
 
 import (
+	"errors"
 	"flag"
 	"flhhe/src/utils"
 	"fmt"
@@ -82,6 +83,7 @@ func TestRing(t *testing.T) {
 		testMulScalarBigint(testContext, t)
 		testMulPoly(testContext, t)
 		testExtendBasis(testContext, t)
+		testLimbPool(testContext, t)
 		testScaling(testContext, t)
 		testMultByMonomial(testContext, t)
 	}
@@ -155,8 +157,8 @@ func testPRNG(testContext *testParams, t *testing.T) {
 		crsGenerator1 := NewUniformSampler(prng1, testContext.ringQ)
 		crsGenerator2 := NewUniformSampler(prng2, testContext.ringQ)
 
-		p0 := crsGenerator1.ReadNew()
-		p1 := crsGenerator2.ReadNew()
+		p0 := newUniformPoly(t, crsGenerator1)
+		p1 := newUniformPoly(t, crsGenerator2)
 
 		require.True(t, testContext.ringQ.Equal(p0, p1))
 	})
@@ -180,7 +182,7 @@ func testImportExportPolyString(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("ImportExportPolyString/", testContext.ringQ), func(t *testing.T) {
 
-		p0 := testContext.uniformSamplerQ.ReadNew()
+		p0 := newUniformPoly(t, testContext.uniformSamplerQ)
 		p1 := testContext.ringQ.NewPoly()
 
 		testContext.ringQ.SetCoefficientsString(testContext.ringQ.PolyToString(p0), p1)
@@ -276,7 +278,7 @@ func testMarshalBinary(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("MarshalBinary/Poly/", testContext.ringQ), func(t *testing.T) {
 
-		p := testContext.uniformSamplerQ.ReadNew()
+		p := newUniformPoly(t, testContext.uniformSamplerQ)
 		pTest := testContext.ringQ.NewPoly()
 
 		data, _ := p.MarshalBinary()
@@ -293,7 +295,7 @@ func testUniformSampler(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("UniformSampler/Read/", testContext.ringQ), func(t *testing.T) {
 		pol := testContext.ringQ.NewPoly()
-		testContext.uniformSamplerQ.Read(pol)
+		require.NoError(t, testContext.uniformSamplerQ.Read(pol))
 		for i := 0; i < testContext.ringQ.N; i++ {
 			for j, qi := range testContext.ringQ.Modulus {
 				require.False(t, pol.Coeffs[j][i] > qi)
@@ -302,7 +304,7 @@ func testUniformSampler(testContext *testParams, t *testing.T) {
 	})
 
 	t.Run(testString("UniformSampler/ReadNew/", testContext.ringQ), func(t *testing.T) {
-		pol := testContext.uniformSamplerQ.ReadNew()
+		pol := newUniformPoly(t, testContext.uniformSamplerQ)
 		for i := 0; i < testContext.ringQ.N; i++ {
 			for j, qi := range testContext.ringQ.Modulus {
 				require.False(t, pol.Coeffs[j][i] > qi)
@@ -315,7 +317,8 @@ func testGaussianSampler(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("GaussianSampler/", testContext.ringQ), func(t *testing.T) {
 		gaussianSampler := NewGaussianSampler(testContext.prng)
-		pol := gaussianSampler.ReadNew(testContext.ringQ, DefaultSigma, DefaultBound)
+		pol, err := gaussianSampler.ReadNew(testContext.ringQ, DefaultSigma, DefaultBound)
+		require.NoError(t, err)
 
 		for i := 0; i < testContext.ringQ.N; i++ {
 			for j, qi := range testContext.ringQ.Modulus {
@@ -336,7 +339,8 @@ func testTernarySampler(testContext *testParams, t *testing.T) {
 			}
 			ternarySampler := NewTernarySampler(prng, testContext.ringQ, p, false)
 
-			pol := ternarySampler.ReadNew()
+			pol, err := ternarySampler.ReadNew()
+			require.NoError(t, err)
 			for i, mod := range testContext.ringQ.Modulus {
 				minOne := mod - 1
 				for _, c := range pol.Coeffs[i] {
@@ -356,7 +360,8 @@ func testTernarySampler(testContext *testParams, t *testing.T) {
 
 			ternarySampler := NewTernarySamplerSparse(prng, testContext.ringQ, p, false)
 
-			pol := ternarySampler.ReadNew()
+			pol, err := ternarySampler.ReadNew()
+			require.NoError(t, err)
 
 			for i := range testContext.ringQ.Modulus {
 				hw := 0
@@ -513,7 +518,7 @@ func testGaloisShift(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("GaloisShift/", testContext.ringQ), func(t *testing.T) {
 
-		pWant := testContext.uniformSamplerQ.ReadNew()
+		pWant := newUniformPoly(t, testContext.uniformSamplerQ)
 		pTest := pWant.CopyNew()
 
 		testContext.ringQ.BitReverse(pTest, pTest)
@@ -535,7 +540,7 @@ func testMForm(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("MForm/", testContext.ringQ), func(t *testing.T) {
 
-		polWant := testContext.uniformSamplerQ.ReadNew()
+		polWant := newUniformPoly(t, testContext.uniformSamplerQ)
 		polTest := testContext.ringQ.NewPoly()
 
 		testContext.ringQ.MForm(polWant, polTest)
@@ -549,11 +554,11 @@ func testMulScalarBigint(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("MulScalarBigint/", testContext.ringQ), func(t *testing.T) {
 
-		polWant := testContext.uniformSamplerQ.ReadNew()
+		polWant := newUniformPoly(t, testContext.uniformSamplerQ)
 		polTest := polWant.CopyNew()
 
-		rand1 := RandUniform(testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
-		rand2 := RandUniform(testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
+		rand1 := randUniform(t, testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
+		rand2 := randUniform(t, testContext.prng, 0xFFFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF)
 
 		scalarBigint := NewUint(rand1)
 		scalarBigint.Mul(scalarBigint, NewUint(rand2))
@@ -568,8 +573,8 @@ func testMulScalarBigint(testContext *testParams, t *testing.T) {
 
 func testMulPoly(testContext *testParams, t *testing.T) {
 
-	p1 := testContext.uniformSamplerQ.ReadNew()
-	p2 := testContext.uniformSamplerQ.ReadNew()
+	p1 := newUniformPoly(t, testContext.uniformSamplerQ)
+	p2 := newUniformPoly(t, testContext.uniformSamplerQ)
 	p3Test := testContext.ringQ.NewPoly()
 	p3Want := testContext.ringQ.NewPoly()
 
@@ -626,6 +631,93 @@ func testExtendBasis(testContext *testParams, t *testing.T) {
 	})
 }
 
+func testLimbPool(testContext *testParams, t *testing.T) {
+
+	t.Run(testString("LimbPool/Run/", testContext.ringQ), func(t *testing.T) {
+
+		pool := NewLimbPool(4)
+		defer pool.Close()
+
+		// Nested calls run on the calling goroutines when the workers are busy
+		counts := make([][]int, 16)
+		pool.Run(len(counts), func(i int) {
+			counts[i] = make([]int, 16)
+			pool.Run(len(counts[i]), func(j int) { counts[i][j]++ })
+		})
+		for i := range counts {
+			for j := range counts[i] {
+				require.Equal(t, 1, counts[i][j], "f(%d, %d) calls", i, j)
+			}
+		}
+	})
+
+	t.Run(testString("LimbPool/BitIdentical/", testContext.ringQ), func(t *testing.T) {
+
+		ringQ, ringP := testContext.ringQ, testContext.ringP
+		levelQ := len(ringQ.Modulus) - 1
+		basisextender := NewFastBasisExtender(ringQ, ringP)
+
+		p1 := newUniformPoly(t, testContext.uniformSamplerQ)
+		p2 := newUniformPoly(t, testContext.uniformSamplerQ)
+		pP := newUniformPoly(t, testContext.uniformSamplerP)
+
+		// ops returns the outputs of the parallelized operations on copies of the inputs
+		ops := func() (outputs []*Poly) {
+			ntt, invNTT, mul := ringQ.NewPoly(), ringQ.NewPoly(), ringQ.NewPoly()
+			ringQ.NTT(p1, ntt)
+			ringQ.InvNTTLvl(levelQ, p1, invNTT)
+			ringQ.MulCoeffsMontgomery(p1, p2, mul)
+
+			modUp := ringP.NewPoly()
+			basisextender.ModUpSplitQP(levelQ, p1, modUp)
+
+			modDown, p1P := ringQ.NewPoly(), pP.CopyNew()
+			basisextender.ModDownSplitNTTPQ(levelQ, p1, p1P, modDown)
+
+			pQP := &Poly{Coeffs: append(p2.CopyNew().Coeffs, pP.CopyNew().Coeffs...)}
+			modDownQP := ringQ.NewPoly()
+			basisextender.ModDownNTTPQ(levelQ, pQP, modDownQP)
+
+			return []*Poly{ntt, invNTT, mul, modUp, modDown, modDownQP}
+		}
+
+		want := ops()
+
+		pool := NewLimbPool(4)
+		defer pool.Close()
+		ringQ.SetLimbPool(pool)
+		ringP.SetLimbPool(pool)
+		defer ringQ.SetLimbPool(nil)
+		defer ringP.SetLimbPool(nil)
+
+		for i, have := range ops() {
+			require.Equal(t, want[i].Coeffs, have.Coeffs, "output %d", i)
+		}
+	})
+
+	t.Run(testString("LimbPool/SerialNoAlloc/", testContext.ringQ), func(t *testing.T) {
+
+		ringQ, ringP := testContext.ringQ, testContext.ringP
+		levelQ := len(ringQ.Modulus) - 1
+		basisextender := NewFastBasisExtender(ringQ, ringP)
+
+		p1 := newUniformPoly(t, testContext.uniformSamplerQ)
+		p2 := newUniformPoly(t, testContext.uniformSamplerQ)
+		pP := newUniformPoly(t, testContext.uniformSamplerP)
+		pQP := &Poly{Coeffs: append(p2.CopyNew().Coeffs, pP.CopyNew().Coeffs...)}
+		out := ringQ.NewPoly()
+
+		// Without a pool, the per-limb operations must not build a closure per call
+		allocs := testing.AllocsPerRun(10, func() {
+			ringQ.NTT(p1, out)
+			ringQ.InvNTTLvl(levelQ, p1, out)
+			ringQ.MulCoeffsMontgomery(p1, p2, out)
+			basisextender.ModDownNTTPQ(levelQ, pQP, out)
+		})
+		require.Zero(t, allocs)
+	})
+}
+
 func testScaling(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("Scaling/Simple/", testContext.ringQ), func(t *testing.T) {
@@ -689,7 +781,7 @@ func testMultByMonomial(testContext *testParams, t *testing.T) {
 
 	t.Run(testString("MultByMonomial/", testContext.ringQ), func(t *testing.T) {
 
-		p1 := testContext.uniformSamplerQ.ReadNew()
+		p1 := newUniformPoly(t, testContext.uniformSamplerQ)
 
 		p3Test := testContext.ringQ.NewPoly()
 		p3Want := testContext.ringQ.NewPoly()
@@ -702,3 +794,44 @@ func testMultByMonomial(testContext *testParams, t *testing.T) {
 		require.Equal(t, p3Want.Coeffs[0][:testContext.ringQ.N], p3Test.Coeffs[0][:testContext.ringQ.N])
 	})
 }
+
+// newUniformPoly samples a uniform polynomial with sampler, failing tb on a PRNG error
+func newUniformPoly(tb testing.TB, sampler *UniformSampler) *Poly {
+	tb.Helper()
+	pol, err := sampler.ReadNew()
+	require.NoError(tb, err)
+	return pol
+}
+
+// randUniform samples a uniform integer in [0, v-1] with RandUniform, failing tb on a PRNG error
+func randUniform(tb testing.TB, prng sampling.PRNG, v uint64, mask uint64) uint64 {
+	tb.Helper()
+	r, err := RandUniform(prng, v, mask)
+	require.NoError(tb, err)
+	return r
+}
+
+// failingPRNG is a PRNG whose reads fail
+type failingPRNG struct{}
+
+var errPRNG = errors.New("prng read failure")
+
+func (failingPRNG) Read([]byte) (int, error) {
+	return 0, errPRNG
+}
+
+func TestSamplersPRNGError(t *testing.T) {
+	ringQ, err := NewRing(1<<8, []uint64{T})
+	require.NoError(t, err)
+	prng := failingPRNG{}
+	pol := ringQ.NewPoly()
+
+	require.ErrorIs(t, NewUniformSampler(prng, ringQ).Read(pol), errPRNG)
+	require.ErrorIs(t, NewGaussianSampler(prng).Read(pol, ringQ, DefaultSigma, DefaultBound), errPRNG)
+	require.ErrorIs(t, NewGaussianSampler(prng).AGN(make([]uint64, 16), T, DefaultSigma, DefaultBound), errPRNG)
+	require.ErrorIs(t, NewTernarySampler(prng, ringQ, 1.0/3, false).Read(pol), errPRNG)
+	require.ErrorIs(t, NewTernarySamplerSparse(prng, ringQ, 64, false).Read(pol), errPRNG)
+	require.ErrorIs(t, NewCDTGaussianSampler(prng, DefaultSigma, DefaultBound).AddNoise(make([]uint64, 16), T), errPRNG)
+	_, err = RandUniform(prng, T, 1<<26-1)
+	require.ErrorIs(t, err, errPRNG)
+}
@@ -4,6 +4,7 @@ package RtF
 // ModDownParams denotes optimized modulus switching indices for given RtF parameters
 // (See github.com/smilecjf/lattigo/v2/examples/ckks_fv/main for an example)
 // StcModDownParams assumes that the decoding matrix is factorized with radix 2.
+// SearchModDown (or `flhhe moddown`) searches the entry of a new parameter set.
 
 type ModDownParams struct {
 	CipherModDown []int
@@ -92,6 +93,20 @@ var RubatoModDownParams = []ModDownParams{
 	},
 }
 
+// Pasta mod down indices, not tuned yet: the keystream is evaluated without mod down
+var PastaModDownParams = []ModDownParams{
+	{
+		// Pasta3 with RtF param 128af and radix 2
+		CipherModDown: []int{0, 0, 0, 0},
+		StCModDown:    []int{0, 0, 0, 0, 0, 0, 0, 0},
+	},
+	{
+		// Pasta4 with RtF param 128af and radix 2
+		CipherModDown: []int{0, 0, 0, 0, 0},
+		StCModDown:    []int{0, 0, 0, 0, 0, 0, 0, 0},
+	},
+}
+
 // Rubato80S mod down indices
 var RubatoModDownParams80S = []ModDownParams{
 	{
@@ -610,3 +625,56 @@ var RtFRubatoParams = []*HalfBootParameters{
 		MaxN1N2Ratio: 16.0,
 	},
 }
+
+const (
+	RtFToyN10 = iota
+	RtFToyN12
+)
+
+// RtFToyParams INSECURE parameters for tests only: the 128af moduli chain of RtFRubatoParams on a
+// ring of degree 2^10 or 2^12, so that the whole HHE pipeline runs in seconds. The plaintext modulus
+// of every cipher stays NTT friendly since it is 1 mod 2^17.
+var RtFToyParams = []*HalfBootParameters{
+	toyHalfBootParams(10),
+	toyHalfBootParams(12),
+}
+
+func toyHalfBootParams(logN int) *HalfBootParameters {
+	hb := RtFRubatoParams[0].Copy()
+	hb.LogN = logN
+	hb.LogSlots = logN - 1
+	return hb
+}
+
+// RubatoToyModDownParams mod down indices of each Rubato parameter set with RtFToyParams[RtFToyN10] and
+// RtFToyParams[RtFToyN12] and radix 2, the StC indices were found with SlotsToCoeffsAutoModSwitch
+var RubatoToyModDownParams = [][]ModDownParams{
+	{
+		// Rubato80S with RtF param toy N10 and radix 2
+		{CipherModDown: []int{12, 0, 1}, StCModDown: []int{1, 0, 1, 1, 1}},
+		// Rubato80M with RtF param toy N10 and radix 2
+		{CipherModDown: []int{13, 0, 1}, StCModDown: []int{1, 1, 1, 0, 1}},
+		// Rubato80L with RtF param toy N10 and radix 2
+		{CipherModDown: []int{13, 0, 1}, StCModDown: []int{1, 1, 1, 0, 1}},
+		// Rubato128S with RtF param toy N10 and radix 2
+		{CipherModDown: []int{10, 0, 1, 1, 1, 1}, StCModDown: []int{1, 0, 1, 1, 1}},
+		// Rubato128M with RtF param toy N10 and radix 2
+		{CipherModDown: []int{12, 0, 1, 1}, StCModDown: []int{0, 2, 0, 1, 1}},
+		// Rubato128L with RtF param toy N10 and radix 2
+		{CipherModDown: []int{13, 0, 1}, StCModDown: []int{1, 1, 0, 1, 1}},
+	},
+	{
+		// Rubato80S with RtF param toy N12 and radix 2
+		{CipherModDown: []int{12, 0, 1}, StCModDown: []int{1, 0, 1, 1, 1, 1}},
+		// Rubato80M with RtF param toy N12 and radix 2
+		{CipherModDown: []int{13, 0, 1}, StCModDown: []int{1, 1, 1, 0, 1, 1}},
+		// Rubato80L with RtF param toy N12 and radix 2
+		{CipherModDown: []int{13, 0, 1}, StCModDown: []int{2, 0, 1, 1, 0, 1}},
+		// Rubato128S with RtF param toy N12 and radix 2
+		{CipherModDown: []int{10, 0, 1, 1, 1, 1}, StCModDown: []int{1, 0, 2, 0, 1, 1}},
+		// Rubato128M with RtF param toy N12 and radix 2
+		{CipherModDown: []int{12, 0, 1, 1}, StCModDown: []int{1, 1, 0, 1, 1, 1}},
+		// Rubato128L with RtF param toy N12 and radix 2
+		{CipherModDown: []int{13, 0, 1}, StCModDown: []int{1, 1, 1, 0, 1, 1}},
+	},
+}
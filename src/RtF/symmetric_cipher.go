@@ -0,0 +1,363 @@
+package RtF
+
+import (
+	"fmt"
+	"strings"
+
+	"flhhe/src/RtF/ring"
+)
+
+// SymmetricCipher is an HE-friendly stream cipher of the RtF framework. It binds the plain keystream
+// of the clients, the homomorphic keystream evaluation of the server and the RtF parameters the
+// cipher is tuned for (plaintext modulus, half-bootstrapping parameters and mod down indices).
+type SymmetricCipher interface {
+	Name() string
+	BlockSize() int  // number of words of the key
+	OutputSize() int // number of keystream words per nonce
+	PlainModulus() uint64
+	Sigma() float64 // standard deviation of the keystream noise, 0 if the cipher adds none
+	HalfBootParams() *HalfBootParameters
+	ModDownParams() ModDownParams
+	// Keystream returns an ErrParamMismatch error if the key is shorter than the block size, and the
+	// error of the PRNG of the noise
+	Keystream(nonce []byte, counter []byte, key []uint64) ([]uint64, error)
+	// NewMFVCipher returns an ErrParamMismatch error if params doesn't have the plaintext modulus of the cipher
+	NewMFVCipher(params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) (MFVCipher, error)
+}
+
+// MFVCipher evaluates the keystream of a SymmetricCipher homomorphically, with one nonce per slot.
+// The errors are those of MFVRubato.
+type MFVCipher interface {
+	Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, modDown []int) ([]*Ciphertext, error)
+	CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) ([]*Ciphertext, error)
+	Reset(nbInitModDown int) error
+	EncKey(key []uint64) (res []*Ciphertext, err error)
+}
+
+// rtfFullCoeffsParam the index in RtFHeraParams and in the mod down tables of the 128af parameters,
+// the full-coefficients parameters with arcsine evaluation used by the FL pipeline
+const rtfFullCoeffsParam = 2
+
+type HeraParam struct {
+	Name     string
+	NumRound int
+}
+
+const (
+	HERA80 = iota
+	HERA128
+)
+
+var HeraParams = []HeraParam{
+	{
+		// HERA80
+		Name:     "HERA80",
+		NumRound: 4,
+	},
+	{
+		// HERA128
+		Name:     "HERA128",
+		NumRound: 5,
+	},
+}
+
+// NewRubatoCipher returns the SymmetricCipher of the Rubato parameter set RubatoParams[rubatoParam]
+func NewRubatoCipher(rubatoParam int) SymmetricCipher {
+	return &rubatoCipher{rubatoParam: rubatoParam}
+}
+
+// NewHeraCipher returns the SymmetricCipher of the HERA parameter set HeraParams[heraParam]
+func NewHeraCipher(heraParam int) SymmetricCipher {
+	return &heraCipher{heraParam: heraParam}
+}
+
+// NewPastaCipher returns the SymmetricCipher of the Pasta parameter set PastaParams[pastaParam]
+func NewPastaCipher(pastaParam int) SymmetricCipher {
+	return &pastaCipher{pastaParam: pastaParam}
+}
+
+// NewToyRubatoCipher returns the Rubato parameter set RubatoParams[rubatoParam] on the INSECURE
+// parameters RtFToyParams[toyParam], for tests only
+func NewToyRubatoCipher(rubatoParam int, toyParam int) SymmetricCipher {
+	return &toyCipher{
+		SymmetricCipher: NewRubatoCipher(rubatoParam),
+		toyParam:        toyParam,
+		modDown:         RubatoToyModDownParams[toyParam][rubatoParam],
+	}
+}
+
+// WithConstantTimeNoise returns the cipher with its keystream noise sampled in constant time (see
+// PlainRubatoConstantTime), the cipher itself if it adds no noise. The distribution table of the noise
+// is computed here, once for all the keystreams.
+func WithConstantTimeNoise(cipher SymmetricCipher) SymmetricCipher {
+	switch c := cipher.(type) {
+	case *rubatoCipher:
+		p := RubatoParams[c.rubatoParam]
+		return &rubatoCipher{rubatoParam: c.rubatoParam, cdt: ring.NewCDTTable(p.Sigma, int(6*p.Sigma)), constantTimeRoundConstants: c.constantTimeRoundConstants}
+	case *toyCipher:
+		return &toyCipher{SymmetricCipher: WithConstantTimeNoise(c.SymmetricCipher), toyParam: c.toyParam, modDown: c.modDown}
+	}
+	return cipher
+}
+
+// WithConstantTimeRoundConstants returns the cipher with its keystream noise and its round constants
+// sampled in constant time (see PlainRubatoConstantTime), the cipher itself if it isn't Rubato. Its
+// keystream is no longer the one of the Rubato specification: the clients and the server must both use
+// the option, the server evaluates it with NewMFVRubatoConstantTime.
+func WithConstantTimeRoundConstants(cipher SymmetricCipher) SymmetricCipher {
+	switch c := WithConstantTimeNoise(cipher).(type) {
+	case *rubatoCipher:
+		return &rubatoCipher{rubatoParam: c.rubatoParam, cdt: c.cdt, constantTimeRoundConstants: true}
+	case *toyCipher:
+		return &toyCipher{SymmetricCipher: WithConstantTimeRoundConstants(c.SymmetricCipher), toyParam: c.toyParam, modDown: c.modDown}
+	}
+	return cipher
+}
+
+// SymmetricCipherByName returns the SymmetricCipher of a Rubato, HERA or Pasta parameter set name
+func SymmetricCipherByName(name string) (SymmetricCipher, error) {
+	for i, p := range RubatoParams {
+		if strings.EqualFold(p.Name, name) {
+			return NewRubatoCipher(i), nil
+		}
+	}
+	for i, p := range HeraParams {
+		if strings.EqualFold(p.Name, name) {
+			return NewHeraCipher(i), nil
+		}
+	}
+	for i, p := range PastaParams {
+		if strings.EqualFold(p.Name, name) {
+			return NewPastaCipher(i), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown symmetric cipher parameter set %q", name)
+}
+
+type rubatoCipher struct {
+	rubatoParam int
+	cdt         *ring.CDTTable // the table of the keystream noise sampled in constant time, nil for GaussianSampler
+
+	constantTimeRoundConstants bool // the round constants are drawn by ring.BoundedUniformSampler
+}
+
+func (c *rubatoCipher) Name() string {
+	return RubatoParams[c.rubatoParam].Name
+}
+
+func (c *rubatoCipher) BlockSize() int {
+	return RubatoParams[c.rubatoParam].Blocksize
+}
+
+// OutputSize the last 4 words of the state are dropped because of the noise addition
+func (c *rubatoCipher) OutputSize() int {
+	return RubatoParams[c.rubatoParam].Blocksize - 4
+}
+
+func (c *rubatoCipher) PlainModulus() uint64 {
+	return RubatoParams[c.rubatoParam].PlainModulus
+}
+
+func (c *rubatoCipher) Sigma() float64 {
+	return RubatoParams[c.rubatoParam].Sigma
+}
+
+func (c *rubatoCipher) HalfBootParams() *HalfBootParameters {
+	return RtFRubatoParams[0]
+}
+
+func (c *rubatoCipher) ModDownParams() ModDownParams {
+	return RubatoModDownParams[c.rubatoParam]
+}
+
+func (c *rubatoCipher) Keystream(nonce []byte, counter []byte, key []uint64) ([]uint64, error) {
+	p := RubatoParams[c.rubatoParam]
+	if c.cdt != nil {
+		prng, err := NewPRNG()
+		if err != nil {
+			return nil, err
+		}
+		sampler := ring.NewCDTGaussianSamplerFromTable(prng, c.cdt)
+		return PlainRubatoConstantTime(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, sampler, c.constantTimeRoundConstants)
+	}
+	return PlainRubato(p.Blocksize, p.NumRound, nonce, counter, key, p.PlainModulus, p.Sigma)
+}
+
+func (c *rubatoCipher) NewMFVCipher(params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) (MFVCipher, error) {
+	if c.constantTimeRoundConstants {
+		return NewMFVRubatoConstantTime(c.rubatoParam, params, encoder, encryptor, evaluator, nbInitModDown)
+	}
+	return NewMFVRubato(c.rubatoParam, params, encoder, encryptor, evaluator, nbInitModDown)
+}
+
+type heraCipher struct {
+	heraParam int
+}
+
+func (c *heraCipher) Name() string {
+	return HeraParams[c.heraParam].Name
+}
+
+func (c *heraCipher) BlockSize() int {
+	return 16
+}
+
+func (c *heraCipher) OutputSize() int {
+	return 16
+}
+
+func (c *heraCipher) PlainModulus() uint64 {
+	return RtFHeraParams[rtfFullCoeffsParam].PlainModulus
+}
+
+func (c *heraCipher) Sigma() float64 {
+	return 0
+}
+
+func (c *heraCipher) HalfBootParams() *HalfBootParameters {
+	return RtFHeraParams[rtfFullCoeffsParam]
+}
+
+func (c *heraCipher) ModDownParams() ModDownParams {
+	if c.heraParam == HERA80 {
+		return HeraModDownParams80[rtfFullCoeffsParam]
+	}
+	return HeraModDownParams128[rtfFullCoeffsParam]
+}
+
+// Keystream HERA has no counter, it's appended to the nonce the same way Rubato absorbs both in its XOF
+func (c *heraCipher) Keystream(nonce []byte, counter []byte, key []uint64) ([]uint64, error) {
+	if err := checkKey(c, key); err != nil {
+		return nil, err
+	}
+	return PlainHera(HeraParams[c.heraParam].NumRound, heraNonce(nonce, counter), key, c.PlainModulus()), nil
+}
+
+func (c *heraCipher) NewMFVCipher(params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) (MFVCipher, error) {
+	if err := checkPlainModulus(c.Name(), params, c.PlainModulus()); err != nil {
+		return nil, err
+	}
+	hera, err := NewMFVHera(HeraParams[c.heraParam].NumRound, params, encoder, encryptor, evaluator, nbInitModDown)
+	if err != nil {
+		return nil, err
+	}
+	return &mfvHeraCipher{hera: hera}, nil
+}
+
+// pastaCipher runs on the RtF parameters of HERA, the plaintext modulus is the same and the
+// multiplicative depth of Pasta is lower
+type pastaCipher struct {
+	pastaParam int
+}
+
+func (c *pastaCipher) Name() string {
+	return PastaParams[c.pastaParam].Name
+}
+
+// BlockSize the key is the whole state of Pasta, both halves of t words
+func (c *pastaCipher) BlockSize() int {
+	return 2 * PastaParams[c.pastaParam].T
+}
+
+func (c *pastaCipher) OutputSize() int {
+	return PastaParams[c.pastaParam].T
+}
+
+func (c *pastaCipher) PlainModulus() uint64 {
+	return RtFHeraParams[rtfFullCoeffsParam].PlainModulus
+}
+
+func (c *pastaCipher) Sigma() float64 {
+	return 0
+}
+
+func (c *pastaCipher) HalfBootParams() *HalfBootParameters {
+	return RtFHeraParams[rtfFullCoeffsParam]
+}
+
+func (c *pastaCipher) ModDownParams() ModDownParams {
+	return PastaModDownParams[c.pastaParam]
+}
+
+func (c *pastaCipher) Keystream(nonce []byte, counter []byte, key []uint64) ([]uint64, error) {
+	if err := checkKey(c, key); err != nil {
+		return nil, err
+	}
+	p := PastaParams[c.pastaParam]
+	return PlainPasta(p.T, p.NumRound, nonce, counter, key, c.PlainModulus()), nil
+}
+
+func (c *pastaCipher) NewMFVCipher(params *Parameters, encoder MFVEncoder, encryptor MFVEncryptor, evaluator MFVEvaluator, nbInitModDown int) (MFVCipher, error) {
+	if err := checkPlainModulus(c.Name(), params, c.PlainModulus()); err != nil {
+		return nil, err
+	}
+	if nbInitModDown < 0 || nbInitModDown > params.MaxLevel() {
+		return nil, fmt.Errorf("%w: %d initial mod downs, the parameters have %d levels", ErrParamMismatch, nbInitModDown, params.MaxLevel())
+	}
+	return NewMFVPasta(c.pastaParam, params, encoder, encryptor, evaluator, nbInitModDown), nil
+}
+
+// toyCipher a cipher on the INSECURE RtFToyParams, the name tells the ring degree apart so that its
+// symmetric key isn't mixed up with the one of the real parameters
+type toyCipher struct {
+	SymmetricCipher
+	toyParam int
+	modDown  ModDownParams
+}
+
+func (c *toyCipher) Name() string {
+	return fmt.Sprintf("%s-TOY%d", c.SymmetricCipher.Name(), RtFToyParams[c.toyParam].LogN)
+}
+
+func (c *toyCipher) HalfBootParams() *HalfBootParameters {
+	return RtFToyParams[c.toyParam]
+}
+
+func (c *toyCipher) ModDownParams() ModDownParams {
+	return c.modDown
+}
+
+// checkKey returns an ErrParamMismatch error if the key is shorter than the block size of the cipher
+func checkKey(c SymmetricCipher, key []uint64) error {
+	if len(key) < c.BlockSize() {
+		return fmt.Errorf("%w: key of %d words, the block size of %s is %d", ErrParamMismatch, len(key), c.Name(), c.BlockSize())
+	}
+	return nil
+}
+
+func heraNonce(nonce []byte, counter []byte) []byte {
+	return append(append(make([]byte, 0, len(nonce)+len(counter)), nonce...), counter...)
+}
+
+// mfvHeraCipher adapts MFVHera to the nonce and counter of MFVCipher
+type mfvHeraCipher struct {
+	hera MFVHera
+}
+
+func (c *mfvHeraCipher) nonces(nonce [][]byte, counter []byte) [][]byte {
+	res := make([][]byte, len(nonce))
+	for i := range nonce {
+		res[i] = heraNonce(nonce[i], counter)
+	}
+	return res
+}
+
+func (c *mfvHeraCipher) Crypt(nonce [][]byte, counter []byte, kCt []*Ciphertext, modDown []int) ([]*Ciphertext, error) {
+	return c.hera.Crypt(c.nonces(nonce, counter), kCt, modDown)
+}
+
+func (c *mfvHeraCipher) CryptNoModSwitch(nonce [][]byte, counter []byte, kCt []*Ciphertext) ([]*Ciphertext, error) {
+	return c.hera.CryptNoModSwitch(c.nonces(nonce, counter), kCt)
+}
+
+func (c *mfvHeraCipher) Reset(nbInitModDown int) error {
+	return c.hera.Reset(nbInitModDown)
+}
+
+func (c *mfvHeraCipher) EncKey(key []uint64) (res []*Ciphertext, err error) {
+	return c.hera.EncKey(key)
+}
+
+func (c *mfvHeraCipher) setTrace(trace roundTrace) {
+	c.hera.(*mfvHera).setTrace(trace)
+}
@@ -0,0 +1,96 @@
+package RtF
+
+import (
+	"crypto/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSymmetricCipherByName(t *testing.T) {
+	for _, name := range []string{"RUBATO128L", "rubato80s", "HERA80", "hera128", "PASTA3", "pasta4"} {
+		cipher, err := SymmetricCipherByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cipher.OutputSize() > cipher.BlockSize() {
+			t.Errorf("%s: output size %d > block size %d", cipher.Name(), cipher.OutputSize(), cipher.BlockSize())
+		}
+		if cipher.HalfBootParams().LogSlots != cipher.HalfBootParams().LogN-1 {
+			t.Errorf("%s: the RtF parameters don't use the full coefficients", cipher.Name())
+		}
+	}
+	if _, err := SymmetricCipherByName("AES128"); err == nil {
+		t.Error("unknown cipher not detected")
+	}
+}
+
+func TestSymmetricCipherKeystream(t *testing.T) {
+	nonce, counter := make([]byte, 64), make([]byte, 64)
+	rand.Read(nonce)
+	rand.Read(counter)
+
+	hera := NewHeraCipher(HERA128)
+	key := make([]uint64, hera.BlockSize())
+	for i := range key {
+		key[i] = uint64(i + 1)
+	}
+	want := PlainHera(HeraParams[HERA128].NumRound, append(append([]byte{}, nonce...), counter...), key, hera.PlainModulus())
+	if have, err := hera.Keystream(nonce, counter, key); err != nil || !reflect.DeepEqual(have, want) {
+		t.Errorf("HERA keystream: got %v (error %v), want %v", have, err, want)
+	}
+
+	// Rubato adds noise to its keystream, only the length and range can be checked
+	rubato := NewRubatoCipher(RUBATO128L)
+	key = make([]uint64, rubato.BlockSize())
+	keystream, err := rubato.Keystream(nonce, counter, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keystream) < rubato.OutputSize() {
+		t.Fatalf("Rubato keystream: got %d words, want at least %d", len(keystream), rubato.OutputSize())
+	}
+	for i, z := range keystream {
+		if z >= rubato.PlainModulus() {
+			t.Errorf("Rubato keystream[%d] = %d is not reduced modulo %d", i, z, rubato.PlainModulus())
+		}
+	}
+
+	// The constant-time noise only changes the keystream of Rubato
+	if WithConstantTimeNoise(hera) != hera {
+		t.Error("HERA has no noise to sample in constant time")
+	}
+	if WithConstantTimeRoundConstants(hera) != hera {
+		t.Error("HERA has no round constants to sample in constant time")
+	}
+	for _, cipher := range []SymmetricCipher{rubato, NewToyRubatoCipher(RUBATO128L, RtFToyN10)} {
+		for _, ct := range []SymmetricCipher{WithConstantTimeNoise(cipher), WithConstantTimeRoundConstants(cipher)} {
+			if ct.Name() != cipher.Name() || ct.HalfBootParams() != cipher.HalfBootParams() {
+				t.Errorf("%s: the constant-time cipher has other parameters", cipher.Name())
+			}
+			keystream, err := ct.Keystream(nonce, counter, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want, err := cipher.Keystream(nonce, counter, key); err != nil || len(keystream) != len(want) {
+				t.Errorf("%s: got %d words with the constant-time sampling, want %d (error %v)", cipher.Name(), len(keystream), len(want), err)
+			}
+		}
+	}
+}
+
+// Benchmark the client side keystream generation of each cipher, per nonce
+func BenchmarkSymmetricCipherKeystream(b *testing.B) {
+	nonce, counter := make([]byte, 64), make([]byte, 64)
+	rand.Read(nonce)
+	rand.Read(counter)
+	for _, cipher := range []SymmetricCipher{NewRubatoCipher(RUBATO128L), NewHeraCipher(HERA128), NewPastaCipher(PASTA4)} {
+		key := make([]uint64, cipher.BlockSize())
+		b.Run(cipher.Name(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := cipher.Keystream(nonce, counter, key); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
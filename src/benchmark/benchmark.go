@@ -0,0 +1,262 @@
+// Package benchmark is the benchmark harness of the FedAvg protocols. It sweeps the number of clients,
+// the model sizes and the parameter sets, and runs the HE scheme (the clients encrypt with CKKS,
+// src/he_fedavg) and the HHE one (the clients encrypt with a symmetric cipher, the server
+// transciphers, src/hhe_fedavg) on the same synthetic weights. Each run reports the time of the
+// clients per MB of weights, of the server per client and of the aggregation per ciphertext, the bytes
+// uploaded and the memory, as CSV or JSON.
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/metrics"
+	"flhhe/src/utils"
+)
+
+// Model the shape of the weights of a client: the FC1 and FC2 matrices of the MNIST model
+type Model struct {
+	FC1Rows, FC1Cols int
+	FC2Rows, FC2Cols int
+}
+
+// MNISTModel the model of src/flhhe/mnist/model.py, 784 inputs, 32 hidden units and 10 outputs
+var MNISTModel = Model{FC1Rows: 32, FC1Cols: 784, FC2Rows: 10, FC2Cols: 32}
+
+// ParseModel parses the FC1 and FC2 shapes of a model, e.g. 32x784+10x32
+func ParseModel(s string) (Model, error) {
+	var m Model
+	fc1, fc2, ok := strings.Cut(s, "+")
+	if !ok {
+		return m, fmt.Errorf("model %q: want <fc1 rows>x<fc1 cols>+<fc2 rows>x<fc2 cols>", s)
+	}
+	var err error
+	if m.FC1Rows, m.FC1Cols, err = parseShape(fc1); err != nil {
+		return m, fmt.Errorf("model %q: fc1: %v", s, err)
+	}
+	if m.FC2Rows, m.FC2Cols, err = parseShape(fc2); err != nil {
+		return m, fmt.Errorf("model %q: fc2: %v", s, err)
+	}
+	return m, nil
+}
+
+// parseShape parses <rows>x<cols>
+func parseShape(s string) (rows, cols int, err error) {
+	r, c, ok := strings.Cut(s, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q: want <rows>x<cols>", s)
+	}
+	if rows, err = strconv.Atoi(r); err != nil || rows <= 0 {
+		return 0, 0, fmt.Errorf("%q: invalid number of rows", s)
+	}
+	if cols, err = strconv.Atoi(c); err != nil || cols <= 0 {
+		return 0, 0, fmt.Errorf("%q: invalid number of columns", s)
+	}
+	return rows, cols, nil
+}
+
+func (m Model) String() string {
+	return fmt.Sprintf("%dx%d+%dx%d", m.FC1Rows, m.FC1Cols, m.FC2Rows, m.FC2Cols)
+}
+
+// Weights returns the number of weights of a client
+func (m Model) Weights() int {
+	return m.FC1Rows*m.FC1Cols + m.FC2Rows*m.FC2Cols
+}
+
+// MB returns the size in MB of the weights of a client, as float64
+func (m Model) MB() float64 {
+	return float64(8*m.Weights()) / 1e6
+}
+
+// Generate returns synthetic weights, uniform in [0, 1) (utils.RandomFloatDataGen)
+func (m Model) Generate() utils.ModelWeights {
+	weights := utils.NewModelWeights()
+	weights.FC1 = utils.RandomFloatDataGen(m.FC1Cols, m.FC1Rows)
+	weights.FC2 = utils.RandomFloatDataGen(m.FC2Cols, m.FC2Rows)
+	weights.FC1Flatten = utils.Flatten2D(weights.FC1)
+	weights.FC2Flatten = utils.Flatten2D(weights.FC2)
+	return weights
+}
+
+// Sweep the runs of a benchmark: every parameter set of both schemes for every number of clients and
+// model
+type Sweep struct {
+	Root       string                // receives the keys and the artifacts, one directory per parameter set
+	Clients    []int                 // numbers of clients
+	Models     []Model               // models of the clients
+	Ciphers    []RtF.SymmetricCipher // parameter sets of the HHE scheme
+	CKKSParams []string              // parameter sets of the HE scheme, names in experiment.CKKSParams
+	Packing    keys_dealer.Packing   // packing of the HHE scheme
+}
+
+// Result the costs of one run. The times of the clients and of the server are means per client, the
+// keys are generated once per parameter set and reused by its runs.
+type Result struct {
+	Scheme                        string  `json:"scheme"` // experiment.SchemeHE or experiment.SchemeHHE
+	Params                        string  `json:"params"` // the cipher (HHE) or CKKS (HE) parameter set
+	Packing                       string  `json:"packing,omitempty"`
+	Clients                       int     `json:"clients"`
+	Model                         string  `json:"model"`
+	Weights                       int     `json:"weights"` // per client
+	WeightMB                      float64 `json:"weight_mb"`
+	KeygenSeconds                 float64 `json:"keygen_seconds"`
+	ClientSeconds                 float64 `json:"client_seconds"` // encryption and saving of the upload
+	ClientSecondsPerMB            float64 `json:"client_seconds_per_mb"`
+	UploadBytes                   int64   `json:"upload_bytes"`
+	ServerSecondsPerClient        float64 `json:"server_seconds_per_client"` // transciphering, none for HE
+	AggregateSeconds              float64 `json:"aggregate_seconds"`
+	Ciphertexts                   int     `json:"ciphertexts"` // aggregated ciphertexts of a client
+	AggregateSecondsPerCiphertext float64 `json:"aggregate_seconds_per_ciphertext"`
+	TotalSeconds                  float64 `json:"total_seconds"`    // clients, server and aggregation, without the keys
+	AllocBytes                    uint64  `json:"alloc_bytes"`      // allocated during the run
+	PeakAllocBytes                uint64  `json:"peak_alloc_bytes"` // peak of the heap during the run, with the keys of the sweep
+}
+
+// Report the results of a sweep, with the environment of the machine that ran it
+type Report struct {
+	Date       time.Time `json:"date"`
+	GoVersion  string    `json:"go_version"`
+	GOOS       string    `json:"goos"`
+	GOARCH     string    `json:"goarch"`
+	NumCPU     int       `json:"num_cpu"`
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	Results    []Result  `json:"results"`
+}
+
+// scheme a parameter set of one of the schemes, its keys are generated by the constructor
+type scheme interface {
+	name() string
+	keygen() time.Duration
+	// run runs the clients, the server and the aggregation on the weights and fills the result
+	run(logger utils.Logger, weights []utils.ModelWeights, result *Result) error
+}
+
+// Run runs the sweep. The keys of each parameter set are generated first, then each model and number
+// of clients runs on the same synthetic weights for all the parameter sets.
+func Run(logger utils.Logger, sweep Sweep) (*Report, error) {
+	if len(sweep.Clients) == 0 || len(sweep.Models) == 0 || len(sweep.Ciphers)+len(sweep.CKKSParams) == 0 {
+		return nil, fmt.Errorf("benchmark: empty sweep")
+	}
+	for _, n := range sweep.Clients {
+		if n <= 0 {
+			return nil, fmt.Errorf("benchmark: %d clients", n)
+		}
+	}
+
+	var schemes []scheme
+	for _, name := range sweep.CKKSParams {
+		s, err := newHEScheme(logger, filepath.Join(sweep.Root, name), name)
+		if err != nil {
+			return nil, err
+		}
+		schemes = append(schemes, s)
+	}
+	for _, cipher := range sweep.Ciphers {
+		root := filepath.Join(sweep.Root, cipher.Name()+"_"+sweep.Packing.String())
+		s, err := newHHEScheme(logger, root, cipher, sweep.Packing)
+		if err != nil {
+			return nil, err
+		}
+		schemes = append(schemes, s)
+	}
+
+	report := &Report{
+		Date:       time.Now().UTC(),
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		NumCPU:     runtime.NumCPU(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+	}
+	for _, model := range sweep.Models {
+		for _, n := range sweep.Clients {
+			weights := make([]utils.ModelWeights, n)
+			for i := range weights {
+				weights[i] = model.Generate()
+			}
+			for _, s := range schemes {
+				logger.PrintHeader(fmt.Sprintf("Benchmark %s, %d clients, model %s", s.name(), n, model))
+				result := Result{
+					Params:        s.name(),
+					Clients:       n,
+					Model:         model.String(),
+					Weights:       model.Weights(),
+					WeightMB:      model.MB(),
+					KeygenSeconds: s.keygen().Seconds(),
+				}
+				if err := measure(logger, s, weights, &result); err != nil {
+					return nil, fmt.Errorf("benchmark %s, %d clients, model %s: %w", s.name(), n, model, err)
+				}
+				report.Results = append(report.Results, result)
+			}
+		}
+	}
+	return report, nil
+}
+
+// measure runs a scheme and derives the rates and the memory of the result
+func measure(logger utils.Logger, s scheme, weights []utils.ModelWeights, result *Result) error {
+	runtime.GC()
+	var start runtime.MemStats
+	runtime.ReadMemStats(&start)
+	metrics.Default().Reset()
+
+	if err := s.run(logger, weights, result); err != nil {
+		return err
+	}
+
+	n := float64(len(weights))
+	result.TotalSeconds = result.ClientSeconds + result.ServerSecondsPerClient*n + result.AggregateSeconds
+	result.ClientSeconds /= n
+	result.ClientSecondsPerMB = result.ClientSeconds / result.WeightMB
+	result.UploadBytes /= int64(len(weights))
+	if result.Ciphertexts > 0 {
+		result.AggregateSecondsPerCiphertext = result.AggregateSeconds / float64(result.Ciphertexts)
+	}
+
+	report := metrics.Default().Report()
+	var end runtime.MemStats
+	runtime.ReadMemStats(&end)
+	result.AllocBytes = end.TotalAlloc - start.TotalAlloc
+	result.PeakAllocBytes = report.PeakAllocBytes
+	return nil
+}
+
+// sampleMemory records the memory usage in the peak of the run
+func sampleMemory() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	metrics.Default().ObserveMemory("", "", "", &m)
+}
+
+// writeWeights writes the weights of the clients in the JSON format of utils.ModelWeights into the
+// plaintext weights directory of the root, and returns their IDs and file names
+func writeWeights(root string, weights []utils.ModelWeights) (clientIDs []string, files []string, err error) {
+	dir := filepath.Join(root, configs.PlaintextWeights)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create directory: %v", err)
+	}
+	for i, w := range weights {
+		clientID := fmt.Sprintf("do%d", i+1)
+		file := fmt.Sprintf("bench_%s.json", clientID)
+		data, err := json.Marshal(map[string][][]float64{"fc1": w.FC1, "fc2": w.FC2})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = os.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+			return nil, nil, err
+		}
+		clientIDs, files = append(clientIDs, clientID), append(files, file)
+	}
+	return clientIDs, files, nil
+}
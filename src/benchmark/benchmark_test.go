@@ -0,0 +1,105 @@
+package benchmark
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"flhhe/src/RtF"
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+// toySweep the HE and HHE schemes on small rings, with a model that fits the N = 1024 coefficients of
+// the toy RtF parameters
+func toySweep(root string, clients ...int) Sweep {
+	return Sweep{
+		Root:       root,
+		Clients:    clients,
+		Models:     []Model{{FC1Rows: 12, FC1Cols: 64, FC2Rows: 10, FC2Cols: 16}},
+		Ciphers:    []RtF.SymmetricCipher{RtF.NewToyRubatoCipher(RtF.RUBATO128L, RtF.RtFToyN10)},
+		CKKSParams: []string{"N12QP109"},
+		Packing:    keys_dealer.PackingCoefficients,
+	}
+}
+
+func TestParseModel(t *testing.T) {
+	m, err := ParseModel("32x784+10x32")
+	if err != nil || m != MNISTModel || m.Weights() != 25408 {
+		t.Errorf("ParseModel(32x784+10x32): got %+v (%v), want %+v", m, err, MNISTModel)
+	}
+	for _, s := range []string{"", "32x784", "32x784+10", "0x784+10x32", "32xa+10x32"} {
+		if _, err = ParseModel(s); err == nil {
+			t.Errorf("ParseModel(%q): no error", s)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	report, err := Run(utils.NewLogger(false), toySweep(t.TempDir(), 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 4 {
+		t.Fatalf("got %d results, want 4", len(report.Results))
+	}
+	for i, res := range report.Results {
+		wantScheme, wantClients := experiment.SchemeHE, 1+i/2
+		if i%2 == 1 {
+			wantScheme = experiment.SchemeHHE
+		}
+		if res.Scheme != wantScheme || res.Clients != wantClients || res.Weights != 12*64+10*16 {
+			t.Errorf("result %d: got %s with %d clients and %d weights, want %s with %d clients and %d weights",
+				i, res.Scheme, res.Clients, res.Weights, wantScheme, wantClients, 12*64+10*16)
+		}
+		if res.ClientSeconds <= 0 || res.UploadBytes <= 0 || res.AggregateSeconds <= 0 || res.Ciphertexts <= 0 || res.PeakAllocBytes == 0 {
+			t.Errorf("result %d: missing costs %+v", i, res)
+		}
+		if (res.ServerSecondsPerClient > 0) != (res.Scheme == experiment.SchemeHHE) {
+			t.Errorf("result %d: %s server time per client %f", i, res.Scheme, res.ServerSecondsPerClient)
+		}
+	}
+
+	// One CSV row per result, the JSON report decodes to the same results
+	buf := new(bytes.Buffer)
+	if err = report.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || len(rows[0]) != len(csvHeader) || rows[4][0] != experiment.SchemeHHE {
+		t.Errorf("unexpected CSV %v", rows)
+	}
+	buf.Reset()
+	if err = report.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err = json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Results) != 4 || decoded.Results[3] != report.Results[3] || decoded.GOMAXPROCS == 0 {
+		t.Errorf("unexpected JSON report %s", buf)
+	}
+}
+
+// BenchmarkFedAvg reports the costs of the toy sweep with 3 clients
+func BenchmarkFedAvg(b *testing.B) {
+	sweep := toySweep(b.TempDir(), 3)
+	for range b.N {
+		report, err := Run(utils.NewLogger(false), sweep)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, res := range report.Results {
+			b.ReportMetric(res.ClientSecondsPerMB, res.Scheme+"-client-s/MB")
+			b.ReportMetric(res.ServerSecondsPerClient, res.Scheme+"-server-s/client")
+			b.ReportMetric(res.AggregateSecondsPerCiphertext, res.Scheme+"-aggregate-s/ct")
+			b.ReportMetric(float64(res.UploadBytes), res.Scheme+"-upload-B/client")
+		}
+	}
+}
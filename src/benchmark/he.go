@@ -0,0 +1,150 @@
+package benchmark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flhhe/configs"
+	"flhhe/src/experiment"
+	"flhhe/src/utils"
+
+	"github.com/tuneinsight/lattigo/v6/core/rlwe"
+	"github.com/tuneinsight/lattigo/v6/schemes/ckks"
+)
+
+// heScheme the HE scheme of src/he_fedavg with a CKKS parameter set: the clients encrypt their weights
+// with the public key, the server averages the ciphertexts
+type heScheme struct {
+	root       string
+	paramsName string
+	params     ckks.Parameters
+	pk         *rlwe.PublicKey
+	evk        rlwe.EvaluationKeySet
+	encoder    *ckks.Encoder
+	keygenTime time.Duration
+}
+
+// newHEScheme generates the keys of the CKKS parameter set, like the keys dealer of src/he_fedavg
+func newHEScheme(logger utils.Logger, root string, name string) (*heScheme, error) {
+	literal, ok := experiment.CKKSParams[name]
+	if !ok {
+		return nil, fmt.Errorf("benchmark: unknown CKKS parameter set %q", name)
+	}
+	logger.PrintFormatted("[Keys Dealer] %s keys generation", name)
+	t := time.Now()
+	params, err := ckks.NewParametersFromLiteral(literal)
+	if err != nil {
+		return nil, fmt.Errorf("benchmark %s: %v", name, err)
+	}
+	kgen := rlwe.NewKeyGenerator(params)
+	sk := kgen.GenSecretKeyNew()
+	s := &heScheme{
+		root:       root,
+		paramsName: name,
+		params:     params,
+		pk:         kgen.GenPublicKeyNew(sk),
+		evk:        rlwe.NewMemEvaluationKeySet(kgen.GenRelinearizationKeyNew(sk)),
+		encoder:    ckks.NewEncoder(params),
+	}
+	s.keygenTime = time.Since(t)
+	sampleMemory()
+	return s, nil
+}
+
+func (s *heScheme) name() string {
+	return s.paramsName
+}
+
+func (s *heScheme) keygen() time.Duration {
+	return s.keygenTime
+}
+
+func (s *heScheme) run(logger utils.Logger, weights []utils.ModelWeights, result *Result) error {
+	result.Scheme = experiment.SchemeHE
+
+	// The clients encrypt and save their weights, the ciphertexts are their upload
+	encrypted := make([][]*rlwe.Ciphertext, len(weights))
+	for i, w := range weights {
+		clientID := fmt.Sprintf("do%d", i+1)
+		logger.PrintFormatted("[Client %s] Encrypting the weights homomorphically", clientID)
+		t := time.Now()
+		fc1, err := s.encrypt(w.FC1Flatten)
+		if err != nil {
+			return err
+		}
+		fc2, err := s.encrypt(w.FC2Flatten)
+		if err != nil {
+			return err
+		}
+		encrypted[i] = append(fc1, fc2...)
+		size, err := s.save(clientID, encrypted[i])
+		if err != nil {
+			return err
+		}
+		result.ClientSeconds += time.Since(t).Seconds()
+		result.UploadBytes += size
+		sampleMemory()
+	}
+
+	// The server sums the ciphertexts of the clients and multiplies the sum by 1/len(weights)
+	logger.PrintMessage("[Server] Encrypted averaging")
+	t := time.Now()
+	eval := ckks.NewEvaluator(s.params, s.evk)
+	for i := range encrypted[0] {
+		avg := encrypted[0][i].CopyNew()
+		for j := 1; j < len(encrypted); j++ {
+			if err := eval.Add(avg, encrypted[j][i], avg); err != nil {
+				return err
+			}
+		}
+		if err := eval.Mul(avg, 1/float64(len(encrypted)), avg); err != nil {
+			return err
+		}
+	}
+	result.AggregateSeconds = time.Since(t).Seconds()
+	result.Ciphertexts = len(encrypted[0])
+	sampleMemory()
+	return nil
+}
+
+// encrypt encrypts the values with the public key, in ciphertexts of MaxSlots values
+func (s *heScheme) encrypt(values []float64) ([]*rlwe.Ciphertext, error) {
+	slots := s.params.MaxSlots()
+	encryptor := rlwe.NewEncryptor(s.params, s.pk)
+	var ciphertexts []*rlwe.Ciphertext
+	for start := 0; start < len(values); start += slots {
+		pt := ckks.NewPlaintext(s.params, s.params.MaxLevel())
+		if err := s.encoder.Encode(values[start:min(start+slots, len(values))], pt); err != nil {
+			return nil, err
+		}
+		ct, err := encryptor.EncryptNew(pt)
+		if err != nil {
+			return nil, err
+		}
+		ciphertexts = append(ciphertexts, ct)
+	}
+	return ciphertexts, nil
+}
+
+// save saves the ciphertexts of a client and returns their size in bytes
+func (s *heScheme) save(clientID string, ciphertexts []*rlwe.Ciphertext) (int64, error) {
+	dir := filepath.Join(s.root, configs.HEEncryptedWeights, clientID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %v", err)
+	}
+	var size int64
+	for i, ct := range ciphertexts {
+		path := filepath.Join(dir, fmt.Sprintf("ct_%d.bin", i))
+		if err := utils.Serialize(ct, path); err != nil {
+			return 0, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
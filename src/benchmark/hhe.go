@@ -0,0 +1,101 @@
+package benchmark
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+)
+
+// hheScheme the HHE scheme of src/hhe_fedavg with a symmetric cipher: the clients encrypt with its
+// keystream, the server transciphers their uploads into CKKS ciphertexts and averages them
+type hheScheme struct {
+	root          string
+	cipher        RtF.SymmetricCipher
+	rubatoParams  *keys_dealer.RubatoParams
+	hheComponents *keys_dealer.HHEComponents
+	rubato        RtF.MFVCipher
+	keygenTime    time.Duration
+}
+
+// newHHEScheme generates the keys of the cipher under root, or loads them if they exist
+func newHHEScheme(logger utils.Logger, root string, cipher RtF.SymmetricCipher, packing keys_dealer.Packing) (*hheScheme, error) {
+	t := time.Now()
+	rubatoParams, hheComponents, rubato, err := keys_dealer.RunKeysDealer(logger, root, cipher, packing, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("benchmark %s: %w", cipher.Name(), err)
+	}
+	sampleMemory()
+	return &hheScheme{
+		root:          root,
+		cipher:        cipher,
+		rubatoParams:  rubatoParams,
+		hheComponents: hheComponents,
+		rubato:        rubato,
+		keygenTime:    time.Since(t),
+	}, nil
+}
+
+func (s *hheScheme) name() string {
+	return s.cipher.Name()
+}
+
+func (s *hheScheme) keygen() time.Duration {
+	return s.keygenTime
+}
+
+func (s *hheScheme) run(logger utils.Logger, weights []utils.ModelWeights, result *Result) error {
+	result.Scheme, result.Packing = experiment.SchemeHHE, s.rubatoParams.Packing.String()
+	clientIDs, files, err := writeWeights(s.root, weights)
+	if err != nil {
+		return err
+	}
+
+	uploadDir := filepath.Join(s.root, configs.SymmetricEncryptedWeights)
+	flClients := make([]*client.FLClient, len(clientIDs))
+	for i := range clientIDs {
+		if _, err = client.Register(logger, s.root, clientIDs[i]); err != nil {
+			return err
+		}
+		t := time.Now()
+		if flClients[i], err = client.RunFLClient(logger, s.root, s.rubatoParams, s.hheComponents, files[i], clientIDs[i], 0); err != nil {
+			return err
+		}
+		result.ClientSeconds += time.Since(t).Seconds()
+		sampleMemory()
+		size, err := client.UploadSize(uploadDir, clientIDs[i])
+		if err != nil {
+			return err
+		}
+		result.UploadBytes += size
+	}
+
+	// Each run is a round 0 of its own, with a verifier of its own
+	verifier, err := server.LoadVerifier(filepath.Join(s.root, configs.Keys), 0, "")
+	if err != nil {
+		return err
+	}
+	logger = logger.With(utils.LogKeyRole, utils.RoleServer)
+	t := time.Now()
+	if _, err = server.Transcipher(logger, s.root, flClients, s.rubatoParams, s.hheComponents, s.rubato, verifier); err != nil {
+		return err
+	}
+	result.ServerSecondsPerClient = time.Since(t).Seconds() / float64(len(clientIDs))
+	sampleMemory()
+
+	t = time.Now()
+	if err = server.HEFedAvg(logger, s.root, clientIDs, s.rubatoParams, s.hheComponents); err != nil {
+		return err
+	}
+	result.AggregateSeconds = time.Since(t).Seconds()
+	result.Ciphertexts = len(server.CipherIndexes(s.rubatoParams))
+	sampleMemory()
+	return nil
+}
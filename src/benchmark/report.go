@@ -0,0 +1,70 @@
+package benchmark
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// csvHeader the columns of WriteCSV, the JSON names of the fields of Result
+var csvHeader = []string{
+	"scheme", "params", "packing", "clients", "model", "weights", "weight_mb",
+	"keygen_seconds", "client_seconds", "client_seconds_per_mb", "upload_bytes",
+	"server_seconds_per_client", "aggregate_seconds", "ciphertexts", "aggregate_seconds_per_ciphertext",
+	"total_seconds", "alloc_bytes", "peak_alloc_bytes",
+}
+
+// WriteCSV writes the results, one row per run
+func (r *Report) WriteCSV(w io.Writer) error {
+	f := func(x float64) string { return strconv.FormatFloat(x, 'g', 6, 64) }
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, res := range r.Results {
+		row := []string{
+			res.Scheme, res.Params, res.Packing, strconv.Itoa(res.Clients), res.Model, strconv.Itoa(res.Weights), f(res.WeightMB),
+			f(res.KeygenSeconds), f(res.ClientSeconds), f(res.ClientSecondsPerMB), strconv.FormatInt(res.UploadBytes, 10),
+			f(res.ServerSecondsPerClient), f(res.AggregateSeconds), strconv.Itoa(res.Ciphertexts), f(res.AggregateSecondsPerCiphertext),
+			f(res.TotalSeconds), strconv.FormatUint(res.AllocBytes, 10), strconv.FormatUint(res.PeakAllocBytes, 10),
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the report with its environment
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Save writes the report into dir as <name>.csv and <name>.json, and returns their paths
+func (r *Report) Save(dir string, name string) (csvPath string, jsonPath string, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	csvPath, jsonPath = filepath.Join(dir, name+".csv"), filepath.Join(dir, name+".json")
+	for path, write := range map[string]func(io.Writer) error{csvPath: r.WriteCSV, jsonPath: r.WriteJSON} {
+		f, err := os.Create(path)
+		if err != nil {
+			return "", "", err
+		}
+		err = write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return csvPath, jsonPath, nil
+}
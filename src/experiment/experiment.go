@@ -0,0 +1,393 @@
+// Package experiment is the declarative description of an experiment: the clients and their weight
+// files, the scheme, the parameter sets and the directories. Every entrypoint loads and validates it,
+// and saves a copy next to its results so a run can be reproduced from its outputs alone.
+package experiment
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	FLRubato "flhhe"
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/RtF/ring"
+	"flhhe/src/metrics"
+	"flhhe/src/utils"
+
+	"github.com/tuneinsight/lattigo/v6/schemes/ckks"
+	"gopkg.in/yaml.v3"
+)
+
+// Schemes, the one of the experiment is run by `flhhe run` and src/hhe_fedavg. src/he_fedavg always runs
+// the HE scheme, the baseline of the HHE one.
+const (
+	SchemeHE  = "he"  // clients encrypt with CKKS directly (src/he_fedavg)
+	SchemeHHE = "hhe" // clients encrypt with Rubato, the server transciphers (src/hhe_fedavg)
+)
+
+// Symmetric ciphers of the HHE scheme
+const (
+	CipherRubato = "rubato" // the parameter set is RubatoParams
+	CipherHera   = "hera"   // the parameter set is HeraParams
+	CipherPasta  = "pasta"  // the parameter set is PastaParams
+)
+
+// Packings of the weights
+const (
+	PackingCoefficients = "coefficients" // the Rubato keystream is added to the plaintext coefficients
+	PackingSlots        = "slots"        // half the values per plaintext, repacked by HalfBoot into one CKKS ciphertext
+)
+
+// Aggregation rules
+const (
+	AggregationFedAvg = "fedavg"
+)
+
+// DefaultConfig the experiment configuration loaded when none is given, relative to the root
+const DefaultConfig = "configs/experiment.yaml"
+
+// SavedConfig the file name of the copy saved with the results of a run (he, hhe, inference, ...)
+const SavedConfig = "experiment_%s.yaml"
+
+// SavedMetrics the file name of the metrics report saved with the results of a run
+const SavedMetrics = "metrics_%s.json"
+
+// SavedBandwidth the file name of the bandwidth report saved with the results of a run
+const SavedBandwidth = "bandwidth_%s.json"
+
+// CKKSParams the CKKS parameter sets of the HE scheme
+var CKKSParams = map[string]ckks.ParametersLiteral{
+	// the parameters of the original HE FedAvg experiment
+	"N16QP421": {
+		LogN:            16,
+		LogQ:            []int{55, 45, 45, 45, 45, 45, 45, 45},
+		LogP:            []int{61},
+		LogDefaultScale: 45,
+	},
+	"N12QP109":   configs.CKKSComplexParamsN12QP109,
+	"N13QP218":   configs.CKKSComplexParamsN13QP218,
+	"N14QP438":   configs.CKKSComplexParamsN14QP438,
+	"N15QP881":   configs.CKKSComplexParamsN15QP881,
+	"PN16QP1761": configs.CKKSComplexParamsPN16QP1761,
+}
+
+// Client a data owner and its plaintext weights file (in configs.PlaintextWeights)
+type Client struct {
+	ID      string `yaml:"id" json:"id"`
+	Weights string `yaml:"weights" json:"weights"`
+}
+
+// Experiment the configuration of a whole run. A run is one round of the protocol, the flhhe commands
+// take the round of their uploads with -round. The keys, ciphertexts, averages and diagnostics are saved
+// in the fixed layout of configs/paths.go under Root, ResultsDir only receives the reports: the copy of
+// the configuration, the metrics, bandwidth and benchmark reports.
+type Experiment struct {
+	Name                       string   `yaml:"name" json:"name"`
+	Scheme                     string   `yaml:"scheme" json:"scheme"`               // scheme run by `flhhe run` and src/hhe_fedavg
+	Cipher                     string   `yaml:"cipher" json:"cipher"`               // symmetric cipher of the HHE scheme
+	RubatoParams               string   `yaml:"rubato_params" json:"rubato_params"` // name in RtF.RubatoParams (HHE)
+	HeraParams                 string   `yaml:"hera_params" json:"hera_params"`     // name in RtF.HeraParams (HHE)
+	PastaParams                string   `yaml:"pasta_params" json:"pasta_params"`   // name in RtF.PastaParams (HHE)
+	CKKSParams                 string   `yaml:"ckks_params" json:"ckks_params"`     // name in CKKSParams (HE)
+	Packing                    string   `yaml:"packing" json:"packing"`
+	Aggregation                string   `yaml:"aggregation" json:"aggregation"`
+	Bootstrap                  bool     `yaml:"bootstrap" json:"bootstrap"`                                         // refresh the aggregate with a full bootstrapping (HHE)
+	ConstantTimeNoise          bool     `yaml:"constant_time_noise" json:"constant_time_noise"`                     // sample the Rubato keystream noise in constant time
+	ConstantTimeRoundConstants bool     `yaml:"constant_time_round_constants" json:"constant_time_round_constants"` // and the round constants, clients and server
+	Clients                    []Client `yaml:"clients" json:"clients"`
+	Root                       string   `yaml:"root" json:"root"`                     // root of the configs/paths.go layout, the repository if empty
+	ResultsDir                 string   `yaml:"results_dir" json:"results_dir"`       // relative to the root, receives the reports of the runs only
+	Parallelism                int      `yaml:"parallelism" json:"parallelism"`       // maximum number of CPUs, all of them if 0
+	ParallelLimbs              bool     `yaml:"parallel_limbs" json:"parallel_limbs"` // process the RNS limbs of the HE operations in parallel
+	Seed                       string   `yaml:"seed" json:"seed"`                     // hex seed of the RtF PRNGs replaying a run, fresh randomness if empty
+	LogFormat                  string   `yaml:"log_format" json:"log_format"`         // text or json records on the standard output, see utils.SetLogOutput
+	MetricsAddr                string   `yaml:"metrics_addr" json:"metrics_addr"`     // address of the /metrics endpoint of the server, none if empty
+}
+
+// Default returns the configuration of the original experiment: three MNIST clients and Rubato 128L
+func Default() *Experiment {
+	return &Experiment{
+		Name:         "mnist-fedavg",
+		Scheme:       SchemeHHE,
+		Cipher:       CipherRubato,
+		RubatoParams: RtF.RubatoParams[RtF.RUBATO128L].Name,
+		HeraParams:   RtF.HeraParams[RtF.HERA128].Name,
+		PastaParams:  RtF.PastaParams[RtF.PASTA4].Name,
+		CKKSParams:   "N16QP421",
+		Packing:      PackingCoefficients,
+		Aggregation:  AggregationFedAvg,
+		Clients: []Client{
+			{ID: "do1", Weights: "weights_no_137.json"},
+			{ID: "do2", Weights: "weights_no_258.json"},
+			{ID: "do3", Weights: "weights_no_469.json"},
+		},
+		ResultsDir: "weights/MNIST",
+		LogFormat:  string(utils.LogText),
+	}
+}
+
+// Load reads a YAML or JSON (by extension) configuration. Missing fields keep their default value.
+// The configuration is validated, and its root resolved to the repository if empty.
+func Load(path string) (*Experiment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the experiment configuration: %v", err)
+	}
+
+	e := Default()
+	e.Clients = nil
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, e)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, e)
+	default:
+		err = fmt.Errorf("unknown format %q, want .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if e.Clients == nil {
+		e.Clients = Default().Clients
+	}
+	if e.Root == "" {
+		e.Root = FLRubato.FindRootPath()
+	}
+
+	if err = e.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// LoadOrDefault loads the configuration at path, or the default one of the root if path is empty
+// and configs/experiment.yaml doesn't exist
+func LoadOrDefault(path string) (*Experiment, error) {
+	if path != "" {
+		return Load(path)
+	}
+	root := FLRubato.FindRootPath()
+	if _, err := os.Stat(filepath.Join(root, DefaultConfig)); err == nil {
+		return Load(filepath.Join(root, DefaultConfig))
+	}
+	e := Default()
+	e.Root = root
+	return e, e.Validate()
+}
+
+// Validate checks the configuration. The weight files are checked separately by CheckWeights, only
+// the roles reading them need them.
+func (e *Experiment) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(e.Scheme == SchemeHE || e.Scheme == SchemeHHE, "scheme: %q, want %q or %q", e.Scheme, SchemeHE, SchemeHHE)
+	check(e.Cipher == CipherRubato || e.Cipher == CipherHera || e.Cipher == CipherPasta,
+		"cipher: %q, want %q, %q or %q", e.Cipher, CipherRubato, CipherHera, CipherPasta)
+	_, err := e.RubatoParamIndex()
+	check(err == nil, "rubato_params: %v", err)
+	_, err = e.HeraParamIndex()
+	check(err == nil, "hera_params: %v", err)
+	_, err = e.PastaParamIndex()
+	check(err == nil, "pasta_params: %v", err)
+	_, ok := CKKSParams[e.CKKSParams]
+	check(ok, "ckks_params: unknown parameter set %q", e.CKKSParams)
+	check(e.Packing == PackingCoefficients || e.Packing == PackingSlots,
+		"packing: %q, want %q or %q", e.Packing, PackingCoefficients, PackingSlots)
+	check(e.Aggregation == AggregationFedAvg, "aggregation: %q, want %q", e.Aggregation, AggregationFedAvg)
+	check(e.Parallelism >= 0, "parallelism: %d, want >= 0", e.Parallelism)
+	_, err = e.seed()
+	check(err == nil, "seed: %v", err)
+	_, err = utils.ParseLogFormat(e.LogFormat)
+	check(err == nil, "log_format: %v", err)
+	if e.MetricsAddr != "" {
+		_, _, err = net.SplitHostPort(e.MetricsAddr)
+		check(err == nil, "metrics_addr: %v", err)
+	}
+	check(e.ResultsDir != "", "results_dir: empty")
+
+	check(len(e.Clients) > 0, "clients: empty")
+	ids := make(map[string]bool, len(e.Clients))
+	for i, client := range e.Clients {
+		check(client.ID != "" && !strings.ContainsAny(client.ID, `/\,`), "clients[%d].id: %q is not a valid ID", i, client.ID)
+		check(!ids[client.ID], "clients[%d].id: %q is duplicated", i, client.ID)
+		ids[client.ID] = true
+		check(client.Weights != "", "clients[%d].weights: empty", i)
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckWeights checks that the weight files of the clients exist under the root
+func (e *Experiment) CheckWeights() error {
+	var errs []error
+	for i, client := range e.Clients {
+		path := filepath.Join(e.Root, configs.PlaintextWeights, client.Weights)
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("clients[%d].weights: %s not found", i, path))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RubatoParamIndex returns the index in RtF.RubatoParams of the Rubato parameter set
+func (e *Experiment) RubatoParamIndex() (int, error) {
+	for i, p := range RtF.RubatoParams {
+		if strings.EqualFold(p.Name, e.RubatoParams) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown parameter set %q", e.RubatoParams)
+}
+
+// HeraParamIndex returns the index in RtF.HeraParams of the HERA parameter set
+func (e *Experiment) HeraParamIndex() (int, error) {
+	for i, p := range RtF.HeraParams {
+		if strings.EqualFold(p.Name, e.HeraParams) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown parameter set %q", e.HeraParams)
+}
+
+// PastaParamIndex returns the index in RtF.PastaParams of the Pasta parameter set
+func (e *Experiment) PastaParamIndex() (int, error) {
+	for i, p := range RtF.PastaParams {
+		if strings.EqualFold(p.Name, e.PastaParams) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown parameter set %q", e.PastaParams)
+}
+
+// SymmetricCipher returns the symmetric cipher of the HHE scheme, with its parameter set
+func (e *Experiment) SymmetricCipher() (RtF.SymmetricCipher, error) {
+	switch e.Cipher {
+	case CipherRubato:
+		i, err := e.RubatoParamIndex()
+		if err != nil {
+			return nil, fmt.Errorf("rubato_params: %v", err)
+		}
+		return e.WithConstantTime(RtF.NewRubatoCipher(i)), nil
+	case CipherHera:
+		i, err := e.HeraParamIndex()
+		if err != nil {
+			return nil, fmt.Errorf("hera_params: %v", err)
+		}
+		return RtF.NewHeraCipher(i), nil
+	case CipherPasta:
+		i, err := e.PastaParamIndex()
+		if err != nil {
+			return nil, fmt.Errorf("pasta_params: %v", err)
+		}
+		return RtF.NewPastaCipher(i), nil
+	}
+	return nil, fmt.Errorf("cipher: unknown cipher %q", e.Cipher)
+}
+
+// WithConstantTime returns the Rubato cipher with the constant-time sampling of the configuration
+func (e *Experiment) WithConstantTime(cipher RtF.SymmetricCipher) RtF.SymmetricCipher {
+	switch {
+	case e.ConstantTimeRoundConstants:
+		return RtF.WithConstantTimeRoundConstants(cipher)
+	case e.ConstantTimeNoise:
+		return RtF.WithConstantTimeNoise(cipher)
+	}
+	return cipher
+}
+
+// ClientIDs returns the IDs of the clients, in order
+func (e *Experiment) ClientIDs() []string {
+	ids := make([]string, len(e.Clients))
+	for i, client := range e.Clients {
+		ids[i] = client.ID
+	}
+	return ids
+}
+
+// seed decodes Seed, nil if it's empty
+func (e *Experiment) seed() ([]byte, error) {
+	if e.Seed == "" {
+		return nil, nil
+	}
+	seed, err := hex.DecodeString(e.Seed)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) > RtF.PRNGSeedSize {
+		return nil, fmt.Errorf("%d bytes, want at most %d", len(seed), RtF.PRNGSeedSize)
+	}
+	return seed, nil
+}
+
+// ApplyRuntime limits the number of CPUs used by the run to Parallelism, and with ParallelLimbs shares
+// a pool of one goroutine per CPU between all the rings of the run (see ring.SetDefaultLimbPool).
+// With a Seed, the randomness of the RtF encryptions and keystreams is derived from it (see
+// RtF.SetPRNGSeed), a run without one draws fresh randomness. The loggers write their records on the
+// standard output in LogFormat.
+func (e *Experiment) ApplyRuntime() error {
+	format, err := utils.ParseLogFormat(e.LogFormat)
+	if err != nil {
+		return fmt.Errorf("log_format: %v", err)
+	}
+	utils.SetLogOutput(os.Stdout, format)
+
+	if e.Parallelism > 0 {
+		runtime.GOMAXPROCS(e.Parallelism)
+	}
+	if e.ParallelLimbs && ring.DefaultLimbPool() == nil {
+		ring.SetDefaultLimbPool(ring.NewLimbPool(0))
+	}
+
+	seed, err := e.seed()
+	if err != nil {
+		return fmt.Errorf("seed: %v", err)
+	}
+	return RtF.SetPRNGSeed(seed)
+}
+
+// Save writes the configuration in YAML into the results directory of the root, for the given run. The
+// Seed is left out: it would give away the randomness of the run next to its results.
+func (e *Experiment) Save(run string) (string, error) {
+	dir := filepath.Join(e.Root, e.ResultsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	saved := *e
+	saved.Seed = ""
+	data, err := yaml.Marshal(&saved)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf(SavedConfig, run))
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save the experiment configuration: %v", err)
+	}
+	return path, nil
+}
+
+// SaveMetrics writes the report of the default metrics registry into the results directory of the root,
+// for the given run
+func (e *Experiment) SaveMetrics(run string) (string, error) {
+	path := filepath.Join(e.Root, e.ResultsDir, fmt.Sprintf(SavedMetrics, run))
+	if err := metrics.Default().SaveReport(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// BandwidthPath the path of the bandwidth report of the given run, in the results directory of the root
+func (e *Experiment) BandwidthPath(run string) string {
+	return filepath.Join(e.Root, e.ResultsDir, fmt.Sprintf(SavedBandwidth, run))
+}
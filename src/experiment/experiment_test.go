@@ -0,0 +1,114 @@
+package experiment
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	FLRubato "flhhe"
+)
+
+func TestLoadDefaultConfig(t *testing.T) {
+	e, err := Load(filepath.Join(FLRubato.FindRootPath(), DefaultConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.Root = FLRubato.FindRootPath()
+	if !reflect.DeepEqual(e, want) {
+		t.Errorf("%s doesn't match Default():\ngot  %+v\nwant %+v", DefaultConfig, e, want)
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	dir := t.TempDir()
+
+	// Missing fields keep their default value
+	path := filepath.Join(dir, "experiment.json")
+	json := `{"name": "two-clients", "rubato_params": "rubato128s", "clients": [{"id": "a", "weights": "a.json"}, {"id": "b", "weights": "b.json"}], "root": "` + dir + `"}`
+	if err := os.WriteFile(path, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Scheme != SchemeHHE || len(e.Clients) != 2 || e.Clients[1].ID != "b" {
+		t.Errorf("unexpected configuration %+v", e)
+	}
+	if index, _ := e.RubatoParamIndex(); index != 3 {
+		t.Errorf("rubato128s: got index %d, want 3", index)
+	}
+	if err = e.CheckWeights(); err == nil {
+		t.Errorf("missing weight files not detected")
+	}
+
+	// The saved copy loads back to the same configuration
+	saved, err := e.Save("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := Load(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e, reloaded) {
+		t.Errorf("saved configuration differs:\ngot  %+v\nwant %+v", reloaded, e)
+	}
+
+	// but for the seed, which isn't saved next to the results
+	e.Seed = "00010203"
+	if saved, err = e.Save("test"); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err = Load(saved); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Seed != "" || e.Seed != "00010203" {
+		t.Errorf("saved seed %q, configuration seed %q", reloaded.Seed, e.Seed)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	e := Default()
+	e.Scheme = "fhe"
+	e.Cipher = "aes"
+	e.RubatoParams = "RUBATO256"
+	e.PastaParams = "PASTA5"
+	e.Seed = "not hex"
+	e.LogFormat = "xml"
+	e.MetricsAddr = "9090"
+	e.Clients = append(e.Clients, e.Clients[0])
+
+	err := e.Validate()
+	if err == nil {
+		t.Fatal("invalid configuration not detected")
+	}
+	for _, field := range []string{"scheme", "cipher", "rubato_params", "pasta_params", "seed", "log_format", "metrics_addr", "duplicated"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q doesn't report %s", err, field)
+		}
+	}
+}
+
+func TestSymmetricCipher(t *testing.T) {
+	e := Default()
+	cipher, err := e.SymmetricCipher()
+	if err != nil || cipher.Name() != "RUBATO128L" {
+		t.Errorf("default cipher: got %v (%v), want RUBATO128L", cipher, err)
+	}
+
+	e.Cipher, e.HeraParams = CipherHera, "hera80"
+	cipher, err = e.SymmetricCipher()
+	if err != nil || cipher.Name() != "HERA80" {
+		t.Errorf("hera cipher: got %v (%v), want HERA80", cipher, err)
+	}
+
+	e.Cipher, e.PastaParams = CipherPasta, "pasta3"
+	cipher, err = e.SymmetricCipher()
+	if err != nil || cipher.Name() != "PASTA3" {
+		t.Errorf("pasta cipher: got %v (%v), want PASTA3", cipher, err)
+	}
+}
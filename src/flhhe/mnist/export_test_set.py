@@ -0,0 +1,35 @@
+import argparse
+import json
+
+from loguru import logger
+
+from flhhe.consts import PROJECT_ROOT
+from flhhe.mnist.dataset import load_dataset
+
+
+def main():
+    parser = argparse.ArgumentParser(
+        description="Export an MNIST test set to JSON for the Go encrypted inference"
+    )
+    parser.add_argument("--test-set", default="test_all.pt")
+    parser.add_argument("--num-samples", type=int, default=100)
+    args = parser.parse_args()
+
+    data_path = PROJECT_ROOT / "data/MNIST/processed"
+    test_set = load_dataset(data_path / args.test_set)
+
+    images, labels = [], []
+    for idx in range(min(args.num_samples, len(test_set))):
+        image, label = test_set[idx]
+        images.append(image.flatten().tolist())
+        labels.append(int(label))
+
+    output_path = data_path / args.test_set.replace(".pt", ".json")
+    with open(output_path, "w") as f:
+        json.dump({"images": images, "labels": labels}, f)
+
+    logger.info(f"Exported {len(images)} samples to {output_path}")
+
+
+if __name__ == "__main__":
+    main()
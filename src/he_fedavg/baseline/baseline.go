@@ -0,0 +1,446 @@
+// Package baseline is the HE baseline of the FedAvg protocol: the clients encrypt their weights with
+// CKKS under the public key of the keys dealer and the server averages the ciphertexts. It is run by
+// `just run-he` and `flhhe he`, and the decrypted average is what the HHE one is compared with.
+package baseline
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flhhe/configs"
+	"flhhe/src/experiment"
+	"flhhe/src/utils"
+
+	"github.com/tuneinsight/lattigo/v6/core/rlwe"
+	"github.com/tuneinsight/lattigo/v6/schemes/ckks"
+)
+
+// RunHEFedAvg runs the keys dealer, the clients and the server of the HE baseline in one process, and
+// saves the decrypted average weights as he_decrypted_avg_fc<i>.json in configs.DecryptedWeights.
+func RunHEFedAvg(logger utils.Logger, cfg *experiment.Experiment) error {
+	root := cfg.Root
+	configCopy, err := cfg.Save("he")
+	if err != nil {
+		return err
+	}
+	logger.PrintFormatted("Experiment configuration saved to %s", configCopy)
+	plaintextWeightDir := filepath.Join(root, configs.PlaintextWeights)
+
+	decryptedWeightDir := filepath.Join(root, configs.DecryptedWeights)
+	if err := os.MkdirAll(decryptedWeightDir, 0755); err != nil {
+		return err
+	}
+
+	plainHEEncryptedWeightsDir := filepath.Join(root, configs.HEEncryptedWeights, "plain_he")
+
+	// ---- Keys Dealer ----
+	logger.PrintHeader("Keys Dealer")
+	ckksParams, _, _, Slots, err := keysDealerCKKSParams(logger, experiment.CKKSParams[cfg.CKKSParams], true)
+	if err != nil {
+		return err
+	}
+	sk, pk, evk, ckksEncoder := keysDealerKeysGen(logger, ckksParams, true)
+
+	// ---- Clients ----
+	logger.PrintHeader("Clients")
+	weights, err := clientWeights(logger, plaintextWeightDir, cfg.Clients, true)
+	if err != nil {
+		return err
+	}
+	t := time.Now()
+	encryptedWeights, err := clientEncryptWeights(logger, weights, Slots, ckksParams, ckksEncoder, pk, true, true, plainHEEncryptedWeightsDir)
+	if err != nil {
+		return err
+	}
+	logger.PrintRunningTime("Time to encrypt the weights homomorphically", t)
+
+	// -- Aggregator Server --
+	logger.PrintHeader("Aggregator Server")
+	t = time.Now()
+	encryptedAvg, err := aggregatorEncryptedFedAvg(logger, encryptedWeights, ckksParams, evk)
+	if err != nil {
+		return err
+	}
+	logger.PrintRunningTime("Time for aggregator server to aggregate the encrypted weights", t)
+
+	// -- Debugging --
+	logger.PrintHeader("Testing values")
+	plaintextAvgFC1, plaintextAvgFC2 := plaintextAveraging(logger, weights)
+	t = time.Now()
+	decryptedAvgFC1, err := decryptAndDecode(logger, ckksEncoder, encryptedAvg.FC1Encrypted, ckksParams, sk)
+	if err != nil {
+		return err
+	}
+	decryptedAvgFC2, err := decryptAndDecode(logger, ckksEncoder, encryptedAvg.FC2Encrypted, ckksParams, sk)
+	if err != nil {
+		return err
+	}
+	logger.PrintRunningTime("Time to decrypt and decode the encrypted average weights", t)
+
+	decryptedAvgFC1 = decryptedAvgFC1[:len(plaintextAvgFC1)]
+	decryptedAvgFC2 = decryptedAvgFC2[:len(plaintextAvgFC2)]
+
+	diff := calculateError(decryptedAvgFC1, plaintextAvgFC1)
+	logger.PrintFormatted("Comparing encrypted and plaintext calculations, error = : %f", diff)
+
+	diff = calculateError(decryptedAvgFC2, plaintextAvgFC2)
+	logger.PrintFormatted("Comparing encrypted and plaintext calculations, error = : %f", diff)
+
+	// Save the plaintext and decrypted averages to JSON files
+	if err = utils.SaveToJSON(logger, decryptedWeightDir, "he_decrypted_avg_fc1.json", decryptedAvgFC1); err != nil {
+		return err
+	}
+	return utils.SaveToJSON(logger, decryptedWeightDir, "he_decrypted_avg_fc2.json", decryptedAvgFC2)
+}
+
+func keysDealerCKKSParams(
+	logger utils.Logger,
+	literal ckks.ParametersLiteral,
+	verbose bool,
+) (ckks.Parameters, uint, int, int, error) {
+	logger.PrintMessage("[Key Dealer]: CKKS Parameters")
+	ckksParams, err := ckks.NewParametersFromLiteral(literal)
+	if err != nil {
+		return ckks.Parameters{}, 0, 0, 0, err
+	}
+	encodingPrecision := ckksParams.EncodingPrecision() // we will need this value later
+	LogSlots := ckksParams.LogMaxSlots()
+	Slots := 1 << LogSlots
+
+	if verbose {
+		logger.PrintFormatted("CKKS Parameters: %+v", ckksParams)
+		logger.PrintFormatted("Encoding Precision: %d", encodingPrecision)
+		logger.PrintFormatted("Log Slots: %d", LogSlots)
+		logger.PrintFormatted("Slots: %d", Slots)
+	}
+
+	return ckksParams, encodingPrecision, LogSlots, Slots, nil
+}
+
+func keysDealerKeysGen(
+	logger utils.Logger,
+	ckksParams ckks.Parameters,
+	verbose bool,
+) (*rlwe.SecretKey, *rlwe.PublicKey, rlwe.EvaluationKeySet, *ckks.Encoder) {
+	logger.PrintMessage("[Key Dealer]: Keys Generation")
+	kgen := rlwe.NewKeyGenerator(ckksParams)
+	sk := kgen.GenSecretKeyNew()
+	pk := kgen.GenPublicKeyNew(sk)
+	rlk := kgen.GenRelinearizationKeyNew(sk)
+	evk := rlwe.NewMemEvaluationKeySet(rlk)
+	ecd := ckks.NewEncoder(ckksParams)
+	if verbose {
+		logger.PrintFormatted("Secret Key Binary Size: %d", sk.BinarySize())
+		logger.PrintFormatted("Public Key Binary Size: %d", pk.BinarySize())
+		logger.PrintFormatted("Relinearization key type: %T", rlk)
+		logger.PrintFormatted("Evaluation Key Set type: %T", evk)
+		logger.PrintFormatted("Encoder type: %T", ecd)
+	}
+	return sk, pk, evk, ecd
+}
+
+func clientLoadWeights(
+	logger utils.Logger,
+	weightDir string,
+	weightPath string,
+	verbose bool,
+) (utils.ModelWeights, error) {
+	logger.PrintMessage("[Client - Initialization]: Load plaintext weights from JSON")
+	weights := utils.NewModelWeights()
+	if err := weights.LoadWeights(weightDir + weightPath); err != nil {
+		return weights, err
+	}
+	if verbose {
+		weights.Print2DLayerDimension(logger)
+		logger.PrintFormatted("weights.FC1_flatten len: %d", len(weights.FC1Flatten))
+		logger.PrintFormatted("weights.FC2_flatten len: %d", len(weights.FC2Flatten))
+	}
+	return weights, nil
+}
+
+func clientWeights(
+	logger utils.Logger,
+	weightDir string,
+	clients []experiment.Client,
+	verbose bool,
+) ([]utils.ModelWeights, error) {
+	weights := make([]utils.ModelWeights, len(clients))
+	for i, client := range clients {
+		var err error
+		if weights[i], err = clientLoadWeights(logger, weightDir, "/"+client.Weights, verbose); err != nil {
+			return nil, err
+		}
+	}
+	return weights, nil
+}
+
+func clientEncryptWeights(
+	logger utils.Logger,
+	weights []utils.ModelWeights,
+	slots int,
+	ckksParams ckks.Parameters,
+	ecd *ckks.Encoder,
+	pk *rlwe.PublicKey,
+	verbose bool,
+	save bool,
+	savedWeightsDir string,
+) ([]utils.ModelWeights, error) {
+	logger.PrintMessage("[Client]: Encrypting the weights homomorphically")
+	for i := range weights {
+		var err error
+		if weights[i].FC1Encrypted, err = encryptFlattened(weights[i].FC1Flatten, slots, ckksParams, ecd, pk); err != nil {
+			return nil, err
+		}
+		if weights[i].FC2Encrypted, err = encryptFlattened(weights[i].FC2Flatten, slots, ckksParams, ecd, pk); err != nil {
+			return nil, err
+		}
+		if verbose {
+			logger.PrintFormatted("weights[%d].FC1_encrypted type: %T", i, weights[i].FC1Encrypted)
+			logger.PrintFormatted("weights[%d].FC2_encrypted type: %T", i, weights[i].FC2Encrypted)
+			// logger.PrintMessages("metadata: ", weights[i].FC1Encrypted[i].MetaData)
+		}
+		if save {
+			clientWeightDir := filepath.Join(savedWeightsDir, fmt.Sprintf("do_%d", i+1)) // do stands for data owner
+			if err = SaveEncryptedWeights(logger, weights[i].FC1Encrypted, clientWeightDir, "he_encrypted_fc1"); err != nil {
+				return nil, err
+			}
+			if err = SaveEncryptedWeights(logger, weights[i].FC2Encrypted, clientWeightDir, "he_encrypted_fc2"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return weights, nil
+}
+
+func plaintextAveraging(
+	logger utils.Logger,
+	weights []utils.ModelWeights,
+) ([]float64, []float64) {
+	logger.PrintMessage("[Debug]: Plaintext Averaging")
+	wantAvgFC1 := make([]float64, len(weights[0].FC1Flatten))
+	wantAvgFC2 := make([]float64, len(weights[0].FC2Flatten))
+	for i := range wantAvgFC1 {
+		for j := range weights {
+			wantAvgFC1[i] += weights[j].FC1Flatten[i]
+		}
+		wantAvgFC1[i] *= 1.0 / float64(len(weights))
+	}
+	for i := range wantAvgFC2 {
+		for j := range weights {
+			wantAvgFC2[i] += weights[j].FC2Flatten[i]
+		}
+		wantAvgFC2[i] *= 1.0 / float64(len(weights))
+	}
+	return wantAvgFC1, wantAvgFC2
+}
+
+func aggregatorEncryptedFedAvg(
+	logger utils.Logger,
+	weights []utils.ModelWeights,
+	ckksParams ckks.Parameters,
+	evk rlwe.EvaluationKeySet,
+) (utils.ModelWeights, error) {
+	logger.PrintMessage("FLAggregator: Encrypted Averaging")
+	eval := ckks.NewEvaluator(ckksParams, evk)
+	numClients := len(weights)
+	scalar := 1.0 / float64(numClients)
+
+	// Get the number of ciphertexts per layer (FC1 and FC2)
+	numFC1Ciphertexts := len(weights[0].FC1Encrypted)
+	numFC2Ciphertexts := len(weights[0].FC2Encrypted)
+
+	// Initialize result arrays
+	avgFC1 := make([]*rlwe.Ciphertext, numFC1Ciphertexts)
+	avgFC2 := make([]*rlwe.Ciphertext, numFC2Ciphertexts)
+
+	// Process FC1 layer
+	for i := range numFC1Ciphertexts {
+		// Start with first client's ciphertext
+		var err error
+		avgFC1[i] = weights[0].FC1Encrypted[i]
+		// Add other clients' ciphertexts
+		for j := 1; j < numClients; j++ {
+			if avgFC1[i], err = eval.AddNew(avgFC1[i], weights[j].FC1Encrypted[i]); err != nil {
+				return utils.ModelWeights{}, err
+			}
+		}
+		// Multiply by scalar (1/numClients)
+		if avgFC1[i], err = eval.MulRelinNew(avgFC1[i], scalar); err != nil {
+			return utils.ModelWeights{}, err
+		}
+	}
+
+	// Process FC2 layer
+	for i := range numFC2Ciphertexts {
+		// Start with first client's ciphertext
+		var err error
+		avgFC2[i] = weights[0].FC2Encrypted[i]
+		// Add other clients' ciphertexts
+		for j := 1; j < numClients; j++ {
+			if avgFC2[i], err = eval.AddNew(avgFC2[i], weights[j].FC2Encrypted[i]); err != nil {
+				return utils.ModelWeights{}, err
+			}
+		}
+		// Multiply by scalar (1/numClients)
+		if avgFC2[i], err = eval.MulRelinNew(avgFC2[i], scalar); err != nil {
+			return utils.ModelWeights{}, err
+		}
+	}
+
+	// Create result model weights
+	result := utils.NewModelWeights()
+	result.FC1Encrypted = avgFC1
+	result.FC2Encrypted = avgFC2
+
+	return result, nil
+}
+
+func decryptAndDecode(
+	logger utils.Logger,
+	ecd *ckks.Encoder,
+	ciphertext []*rlwe.Ciphertext,
+	params ckks.Parameters,
+	sk *rlwe.SecretKey,
+) ([]float64, error) {
+	logger.PrintMessage("[Debug]: Decrypt and Decode")
+	dec := rlwe.NewDecryptor(params, sk)
+	var decryptedAvg []float64
+	for i := range ciphertext {
+		decrypted, err := decryptDecode(ciphertext[i], dec, ecd, params)
+		if err != nil {
+			return nil, err
+		}
+		decryptedAvg = append(decryptedAvg, decrypted...)
+	}
+	return decryptedAvg, nil
+}
+
+func encryptFlattened(
+	plaintext []float64,
+	numSlots int,
+	params ckks.Parameters,
+	encoder *ckks.Encoder,
+	pk *rlwe.PublicKey,
+) ([]*rlwe.Ciphertext, error) {
+	numCiphertexts := len(plaintext) / numSlots
+	if len(plaintext)%numSlots != 0 {
+		numCiphertexts += 1
+	}
+	// logger.PrintFormatted("numCiphertexts: %d", numCiphertexts)
+	result := make([]*rlwe.Ciphertext, numCiphertexts)
+	for i := range numCiphertexts {
+		plaintextStart := i * numSlots
+		plaintextEnd := (i + 1) * numSlots
+		if plaintextEnd > len(plaintext) {
+			plaintextEnd = len(plaintext)
+		}
+		plaintextVec := plaintext[plaintextStart:plaintextEnd]
+		// logger.PrintFormatted("plaintextVec number %d len: %d", i, len(plaintextVec))
+		ciphertext, err := encryptVec(plaintextVec, params, encoder, pk)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = ciphertext
+	}
+	return result, nil
+}
+
+// HE encrypts a plaintext vector of float64 using the provided parameters and public key
+func encryptVec(
+	plaintext []float64,
+	params ckks.Parameters,
+	encoder *ckks.Encoder,
+	pk *rlwe.PublicKey,
+) (*rlwe.Ciphertext, error) {
+	pt1 := ckks.NewPlaintext(params, params.MaxLevel())
+	if err := encoder.Encode(plaintext, pt1); err != nil {
+		return nil, err
+	}
+	// encrypt
+	enc := rlwe.NewEncryptor(params, pk)
+	return enc.EncryptNew(pt1)
+}
+
+func decryptDecode(
+	ct *rlwe.Ciphertext,
+	dec *rlwe.Decryptor,
+	ecd *ckks.Encoder,
+	params ckks.Parameters,
+) ([]float64, error) {
+	dec_pt := dec.DecryptNew(ct)
+	// Decodes the plaintext
+	have := make([]float64, params.MaxSlots())
+	err := ecd.Decode(dec_pt, have)
+	if err != nil {
+		return nil, err
+	}
+	return have, nil
+}
+
+func calculateError(have []float64, want []float64) float64 {
+	var sum float64
+	for i := range have {
+		sum += math.Abs(have[i] - want[i])
+	}
+	return sum
+}
+
+// SaveEncryptedWeights saves encrypted weights to binary files
+func SaveEncryptedWeights(
+	logger utils.Logger,
+	weights []*rlwe.Ciphertext,
+	outputDir string,
+	prefix string,
+) error {
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	// Save each ciphertext
+	for _, ct := range weights {
+		fileName := fmt.Sprintf("%s.bin", prefix)
+		filePath := filepath.Join(outputDir, fileName)
+		if err := utils.Serialize(ct, filePath); err != nil {
+			return err
+		}
+		logger.PrintFormatted("Saved ciphertext to %s", filePath)
+	}
+	return nil
+}
+
+// LoadEncryptedWeights loads encrypted weights from binary files
+func LoadEncryptedWeights(
+	logger utils.Logger,
+	params ckks.Parameters,
+	inputDir string,
+	prefix string,
+) ([]*rlwe.Ciphertext, error) {
+	var weights []*rlwe.Ciphertext
+	i := 0
+
+	// Keep loading ciphertexts until we can't find the next file
+	for {
+		fileName := fmt.Sprintf("%s_%d.bin", prefix, i)
+		filePath := filepath.Join(inputDir, fileName)
+
+		// Check if file exists
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			break
+		}
+
+		// Create new ciphertext and load it
+		ct := rlwe.NewCiphertext(params, 1, params.MaxLevel())
+		if err := utils.Deserialize(ct, filePath); err != nil {
+			return nil, err
+		}
+		weights = append(weights, ct)
+		logger.PrintFormatted("Loaded ciphertext from %s", filePath)
+		i++
+	}
+
+	return weights, nil
+}
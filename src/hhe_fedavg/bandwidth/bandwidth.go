@@ -0,0 +1,326 @@
+// Package bandwidth does the bandwidth accounting of the messages of a FedAvg round. Every artifact a
+// role sends to another is sized from the files the protocol writes under the root: the keys the keys
+// dealer hands out and the client registry, the symmetric ciphertexts, nonces, counters and signatures
+// of the clients, and the aggregate and diagnostics the server releases to the key holder. The public
+// identity keys the clients register are raw Ed25519 keys. The HE baseline of src/he_fedavg is sized
+// from its CKKS parameters, as the serialized size of its keys and ciphertexts for the same weights.
+package bandwidth
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"flhhe/configs"
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+
+	"github.com/tuneinsight/lattigo/v6/core/rlwe"
+	"github.com/tuneinsight/lattigo/v6/schemes/ckks"
+)
+
+// Artifacts exchanged by the roles
+const (
+	EvaluationKeys       = "evaluation_keys"       // rotation and relinearization keys, to the server
+	FVSymmetricKey       = "fv_symmetric_key"      // FV ciphertexts of the symmetric key, to the server
+	SymmetricKey         = "symmetric_key"         // to each client
+	PublicKey            = "public_key"            // HE baseline, to each client
+	IdentityKey          = "identity_key"          // public identity key of each client, to the keys dealer
+	ClientRegistry       = "client_registry"       // the registered identity keys, to the server
+	SymmetricCiphertexts = "symmetric_ciphertexts" // the uploads of the client but their nonce seed, counter and signature
+	Nonces               = "nonces"                // the nonce seed and the counter of each upload
+	Signature            = "signature"             // the signature of the uploads of the client
+	CKKSCiphertexts      = "ckks_ciphertexts"      // HE baseline, the encrypted weights
+	Aggregate            = "aggregate"             // the encrypted average, to the key holder
+	Diagnostics          = "diagnostics"           // the encrypted client diagnostics, to the key holder
+)
+
+// WeightBytes the size of a plaintext weight, a float64
+const WeightBytes = 8
+
+// Message an artifact sent from a role to another
+type Message struct {
+	Artifact string `json:"artifact"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	ClientID string `json:"client_id,omitempty"` // the client sending or receiving it
+	Bytes    int64  `json:"bytes"`
+}
+
+// Total the bytes a role (or a single client) sends and receives
+type Total struct {
+	Role          string `json:"role"`
+	ClientID      string `json:"client_id,omitempty"`
+	UploadBytes   int64  `json:"upload_bytes"`
+	DownloadBytes int64  `json:"download_bytes"`
+}
+
+// Scheme the messages of one scheme and their totals
+type Scheme struct {
+	Scheme            string    `json:"scheme"`
+	Params            string    `json:"params"`
+	Messages          []Message `json:"messages"`
+	Roles             []Total   `json:"roles"`   // keys dealer, clients (all of them) and server
+	Clients           []Total   `json:"clients"` // each client
+	ClientUploadBytes int64     `json:"client_upload_bytes"`
+	UploadRatio       float64   `json:"upload_ratio"` // client uploads over the plaintext weights
+}
+
+// Report the bandwidth of the HHE round and of the HE baseline with the same clients and weights
+type Report struct {
+	Clients        int     `json:"clients"`
+	Weights        int     `json:"weights"`         // all the clients
+	PlaintextBytes int64   `json:"plaintext_bytes"` // WeightBytes per weight
+	HHE            Scheme  `json:"hhe"`
+	HE             Scheme  `json:"he"`
+	HHEOverHE      float64 `json:"hhe_over_he"` // HHE client uploads over the HE ones
+}
+
+// Account sizes the messages of the HHE round under rootPath with the given clients, and of the HE
+// baseline with the CKKS parameter set heParams. The plaintext weights of the clients are loaded to
+// size the baseline; the diagnostics are only counted if the server released them.
+func Account(
+	logger utils.Logger,
+	rootPath string,
+	rubatoParams *keys_dealer.RubatoParams,
+	clients []experiment.Client,
+	heParams string) (*Report, error) {
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("bandwidth: no clients")
+	}
+	literal, ok := experiment.CKKSParams[heParams]
+	if !ok {
+		return nil, fmt.Errorf("bandwidth: unknown CKKS parameter set %q", heParams)
+	}
+	params, err := ckks.NewParametersFromLiteral(literal)
+	if err != nil {
+		return nil, fmt.Errorf("bandwidth %s: %v", heParams, err)
+	}
+
+	report := &Report{Clients: len(clients)}
+	clientIDs := make([]string, len(clients))
+	heCiphertexts := make([]int, len(clients))
+	for i, c := range clients {
+		weights, err := utils.OpenModelWeights(logger, rootPath, c.Weights)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth: client %s: %w", c.ID, err)
+		}
+		clientIDs[i] = c.ID
+		report.Weights += len(weights.FC1Flatten) + len(weights.FC2Flatten)
+		heCiphertexts[i] = HECiphertexts(params, len(weights.FC1Flatten)) + HECiphertexts(params, len(weights.FC2Flatten))
+	}
+	report.PlaintextBytes = WeightBytes * int64(report.Weights)
+
+	hhe, err := hheMessages(rootPath, rubatoParams, clientIDs)
+	if err != nil {
+		return nil, err
+	}
+	report.HHE = newScheme(experiment.SchemeHHE, rubatoParams.Cipher.Name(), hhe, clientIDs, report.PlaintextBytes)
+	report.HE = newScheme(experiment.SchemeHE, heParams, heMessages(params, clientIDs, heCiphertexts), clientIDs, report.PlaintextBytes)
+	report.HHEOverHE = float64(report.HHE.ClientUploadBytes) / float64(report.HE.ClientUploadBytes)
+	return report, nil
+}
+
+// hheMessages sizes the artifacts of the HHE round from their files
+func hheMessages(rootPath string, rubatoParams *keys_dealer.RubatoParams, clientIDs []string) ([]Message, error) {
+	keysDir := filepath.Join(rootPath, configs.Keys)
+	symKeyDir := keys_dealer.SymmetricKeyDir(keysDir, rubatoParams)
+	uploadDir := filepath.Join(rootPath, configs.SymmetricEncryptedWeights)
+	avgDir := filepath.Join(rootPath, configs.HEEncryptedWeights, "avg")
+	diagnosticsDir := filepath.Join(rootPath, configs.Diagnostics)
+
+	evk, err := filesSize(filepath.Join(keysDir, configs.RotationKeys), filepath.Join(keysDir, configs.RelinearizationKeys))
+	if err != nil {
+		return nil, err
+	}
+	fvKey, err := dirSize(filepath.Join(symKeyDir, configs.SymmetricKeyCipherDir))
+	if err != nil {
+		return nil, err
+	}
+	symKey, err := filesSize(filepath.Join(symKeyDir, configs.SymmetricKey))
+	if err != nil {
+		return nil, err
+	}
+	registry, err := filesSize(filepath.Join(keysDir, configs.ClientRegistry))
+	if err != nil {
+		return nil, err
+	}
+	messages := []Message{
+		{Artifact: EvaluationKeys, From: utils.RoleKeysDealer, To: utils.RoleServer, Bytes: evk},
+		{Artifact: FVSymmetricKey, From: utils.RoleKeysDealer, To: utils.RoleServer, Bytes: fvKey},
+		{Artifact: ClientRegistry, From: utils.RoleKeysDealer, To: utils.RoleServer, Bytes: registry},
+	}
+	for _, id := range clientIDs {
+		messages = append(messages,
+			Message{Artifact: IdentityKey, From: utils.RoleClient, To: utils.RoleKeysDealer, ClientID: id, Bytes: ed25519.PublicKeySize},
+			Message{Artifact: SymmetricKey, From: utils.RoleKeysDealer, To: utils.RoleClient, ClientID: id, Bytes: symKey},
+		)
+	}
+
+	for _, id := range clientIDs {
+		upload, err := client.UploadSize(uploadDir, id)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth: client %s: %w", id, err)
+		}
+		// Each upload carries the nonce seed and the counter
+		uploads, err := client.LoadUploads(uploadDir, id)
+		if err != nil {
+			return nil, fmt.Errorf("bandwidth: client %s: %w", id, err)
+		}
+		var nonces int64
+		for _, u := range uploads {
+			nonces += int64(len(u.NonceSeed) + len(u.Counter))
+		}
+		signature, err := filesSize(filepath.Join(uploadDir, client.SignatureFile(id)))
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages,
+			Message{Artifact: SymmetricCiphertexts, From: utils.RoleClient, To: utils.RoleServer, ClientID: id, Bytes: upload - nonces - signature},
+			Message{Artifact: Nonces, From: utils.RoleClient, To: utils.RoleServer, ClientID: id, Bytes: nonces},
+			Message{Artifact: Signature, From: utils.RoleClient, To: utils.RoleServer, ClientID: id, Bytes: signature},
+		)
+	}
+
+	var outputs []string
+	for _, index := range server.CipherIndexes(rubatoParams) {
+		outputs = append(outputs, filepath.Join(avgDir, configs.CtNameFix+strconv.Itoa(index)+configs.CtFormat))
+	}
+	aggregate, err := filesSize(outputs...)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, Message{Artifact: Aggregate, From: utils.RoleServer, To: utils.RoleKeysDealer, Bytes: aggregate})
+
+	if _, err := os.Stat(diagnosticsDir); err == nil {
+		files := []string{filepath.Join(diagnosticsDir, "avg_norm"+configs.CtFormat)}
+		for _, id := range clientIDs {
+			files = append(files,
+				filepath.Join(diagnosticsDir, id+"_norm"+configs.CtFormat),
+				filepath.Join(diagnosticsDir, id+"_dot_avg"+configs.CtFormat),
+			)
+		}
+		size, err := filesSize(files...)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, Message{Artifact: Diagnostics, From: utils.RoleServer, To: utils.RoleKeysDealer, Bytes: size})
+	}
+	return messages, nil
+}
+
+// heMessages sizes the artifacts of the HE baseline: the public key of the clients, the
+// relinearization key of the server, the ciphertexts of the clients and their average
+func heMessages(params ckks.Parameters, clientIDs []string, ciphertexts []int) []Message {
+	pk := int64(rlwe.NewPublicKey(params).BinarySize())
+	rlk := int64(rlwe.NewRelinearizationKey(params).BinarySize())
+	ct := int64(ckks.NewCiphertext(params, 1, params.MaxLevel()).BinarySize())
+
+	messages := []Message{{Artifact: EvaluationKeys, From: utils.RoleKeysDealer, To: utils.RoleServer, Bytes: rlk}}
+	for _, id := range clientIDs {
+		messages = append(messages, Message{Artifact: PublicKey, From: utils.RoleKeysDealer, To: utils.RoleClient, ClientID: id, Bytes: pk})
+	}
+	for i, id := range clientIDs {
+		messages = append(messages, Message{Artifact: CKKSCiphertexts, From: utils.RoleClient, To: utils.RoleServer, ClientID: id, Bytes: int64(ciphertexts[i]) * ct})
+	}
+	// The average is a multiplication by a constant, without rescaling, it keeps the level of the inputs
+	return append(messages, Message{Artifact: Aggregate, From: utils.RoleServer, To: utils.RoleKeysDealer, Bytes: int64(ciphertexts[0]) * ct})
+}
+
+// HECiphertexts the number of ciphertexts of the HE baseline encrypting n values, MaxSlots per ciphertext
+func HECiphertexts(params ckks.Parameters, n int) int {
+	return (n + params.MaxSlots() - 1) / params.MaxSlots()
+}
+
+// newScheme sums the messages per role and per client
+func newScheme(name string, params string, messages []Message, clientIDs []string, plaintextBytes int64) Scheme {
+	s := Scheme{Scheme: name, Params: params, Messages: messages}
+	roles := map[string]*Total{}
+	for _, role := range []string{utils.RoleKeysDealer, utils.RoleClient, utils.RoleServer} {
+		s.Roles = append(s.Roles, Total{Role: role})
+	}
+	for i := range s.Roles {
+		roles[s.Roles[i].Role] = &s.Roles[i]
+	}
+	clients := map[string]*Total{}
+	s.Clients = make([]Total, len(clientIDs))
+	for i, id := range clientIDs {
+		s.Clients[i] = Total{Role: utils.RoleClient, ClientID: id}
+		clients[id] = &s.Clients[i]
+	}
+
+	for _, m := range messages {
+		roles[m.From].UploadBytes += m.Bytes
+		roles[m.To].DownloadBytes += m.Bytes
+		switch {
+		case m.From == utils.RoleClient:
+			clients[m.ClientID].UploadBytes += m.Bytes
+			s.ClientUploadBytes += m.Bytes
+		case m.To == utils.RoleClient:
+			clients[m.ClientID].DownloadBytes += m.Bytes
+		}
+	}
+	s.UploadRatio = float64(s.ClientUploadBytes) / float64(plaintextBytes)
+	return s
+}
+
+// Print logs the totals of the schemes and the upload ratios
+func (r *Report) Print(logger utils.Logger) {
+	logger.PrintFormatted("Bandwidth of %d clients, %d plaintext weights (%d bytes)", r.Clients, r.Weights, r.PlaintextBytes)
+	for _, s := range []Scheme{r.HHE, r.HE} {
+		for _, m := range s.Messages {
+			logger.PrintFormatted("%s %s: %s %s -> %s %s, %d bytes", s.Scheme, s.Params, m.Artifact, m.From, m.To, m.ClientID, m.Bytes)
+		}
+		for _, t := range append(s.Roles, s.Clients...) {
+			logger.PrintFormatted("%s %s: %s %s uploads %d bytes, downloads %d bytes", s.Scheme, s.Params, t.Role, t.ClientID, t.UploadBytes, t.DownloadBytes)
+		}
+		logger.PrintFormatted("%s %s: client uploads %d bytes, %.2fx the plaintext weights", s.Scheme, s.Params, s.ClientUploadBytes, s.UploadRatio)
+	}
+	logger.PrintFormatted("HHE client uploads are %.4fx the HE ones", r.HHEOverHE)
+}
+
+// Save writes the report as JSON to path
+func (r *Report) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// filesSize returns the total size of the files
+func filesSize(paths ...string) (int64, error) {
+	var size int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, fmt.Errorf("bandwidth: %w", err)
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// dirSize returns the total size of the files of a directory
+func dirSize(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("bandwidth: %w", err)
+	}
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return filesSize(paths...)
+}
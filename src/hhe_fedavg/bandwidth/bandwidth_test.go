@@ -0,0 +1,192 @@
+package bandwidth
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+
+	"github.com/tuneinsight/lattigo/v6/core/rlwe"
+	"github.com/tuneinsight/lattigo/v6/schemes/ckks"
+)
+
+// TestHEMessages checks the sizes of the HE baseline against the files of the keys and ciphertexts
+// the HE scheme serializes, and that the totals balance
+func TestHEMessages(t *testing.T) {
+	params, err := ckks.NewParametersFromLiteral(experiment.CKKSParams["N12QP109"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kgen := rlwe.NewKeyGenerator(params)
+	sk := kgen.GenSecretKeyNew()
+	pk := kgen.GenPublicKeyNew(sk)
+	pt := ckks.NewPlaintext(params, params.MaxLevel())
+	if err = ckks.NewEncoder(params).Encode([]float64{0.5, -0.25}, pt); err != nil {
+		t.Fatal(err)
+	}
+	ct, err := rlwe.NewEncryptor(params, pk).EncryptNew(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	fileSize := func(object any, name string) int64 {
+		path := filepath.Join(dir, name)
+		if err := utils.Serialize(object, path); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	ctSize := fileSize(ct, "ct.bin")
+
+	// 3000 and 40 values take 2 and 1 ciphertexts of 2048 slots
+	n := HECiphertexts(params, 3000) + HECiphertexts(params, 40)
+	if n != 3 {
+		t.Fatalf("HECiphertexts: got %d ciphertexts, want 3", n)
+	}
+	clientIDs := []string{"do1", "do2"}
+	s := newScheme(experiment.SchemeHE, "N12QP109", heMessages(params, clientIDs, []int{n, n}), clientIDs, 2*WeightBytes*3040)
+	want := map[string]int64{
+		PublicKey:       fileSize(pk, "pk.bin"),
+		EvaluationKeys:  fileSize(kgen.GenRelinearizationKeyNew(sk), "re.bin"),
+		CKKSCiphertexts: 3 * ctSize,
+		Aggregate:       3 * ctSize,
+	}
+	for _, m := range s.Messages {
+		if m.Bytes != want[m.Artifact] {
+			t.Errorf("%s %s -> %s: got %d bytes, want %d", m.Artifact, m.From, m.To, m.Bytes, want[m.Artifact])
+		}
+	}
+
+	var upload, download int64
+	for _, total := range s.Roles {
+		upload += total.UploadBytes
+		download += total.DownloadBytes
+	}
+	if upload != download {
+		t.Errorf("roles upload %d bytes and download %d bytes", upload, download)
+	}
+	for _, c := range s.Clients {
+		if c.UploadBytes != 3*ctSize || c.DownloadBytes != want[PublicKey] {
+			t.Errorf("client %s: uploads %d bytes and downloads %d bytes, want %d and %d",
+				c.ClientID, c.UploadBytes, c.DownloadBytes, 3*ctSize, want[PublicKey])
+		}
+	}
+	if s.ClientUploadBytes != 6*ctSize || s.UploadRatio != float64(6*ctSize)/float64(2*WeightBytes*3040) {
+		t.Errorf("client uploads %d bytes (ratio %f), want %d", s.ClientUploadBytes, s.UploadRatio, 6*ctSize)
+	}
+}
+
+// TestAccount checks the sizes of the HHE round against the files of a round under a temporary root:
+// the client messages add up to their uploads and identity keys, the aggregate to the output files
+func TestAccount(t *testing.T) {
+	logger := utils.NewLogger(false)
+	rootPath := t.TempDir()
+	rubatoParams, err := keys_dealer.InitRubatoParams(logger, RtF.NewToyRubatoCipher(RtF.RUBATO128L, RtF.RtFToyN10), keys_dealer.PackingCoefficients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := rubatoParams.Params
+
+	// The artifacts of the keys dealer and the server are only sized, their content doesn't matter
+	keysDir := filepath.Join(rootPath, configs.Keys)
+	symKeyDir := keys_dealer.SymmetricKeyDir(keysDir, rubatoParams)
+	avgDir := filepath.Join(rootPath, configs.HEEncryptedWeights, "avg")
+	writeFile := func(path string, size int) int64 {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return int64(size)
+	}
+	evk := writeFile(filepath.Join(keysDir, configs.RotationKeys), 1000) + writeFile(filepath.Join(keysDir, configs.RelinearizationKeys), 200)
+	fvKey := writeFile(filepath.Join(symKeyDir, configs.SymmetricKeyCipherDir, "ct_0.bin"), 300) +
+		writeFile(filepath.Join(symKeyDir, configs.SymmetricKeyCipherDir, "length.txt"), 1)
+	writeFile(filepath.Join(symKeyDir, configs.SymmetricKey), 8*rubatoParams.Blocksize)
+	writeFile(filepath.Join(keysDir, configs.ClientRegistry), 50)
+	var aggregate int64
+	for _, index := range server.CipherIndexes(rubatoParams) {
+		aggregate += writeFile(filepath.Join(avgDir, configs.CtNameFix+strconv.Itoa(index)+configs.CtFormat), 400)
+	}
+
+	// Two clients with FC1 and FC2 fitting one output each
+	clientIDs := []string{"do1", "do2"}
+	uploadDir := filepath.Join(rootPath, configs.SymmetricEncryptedWeights)
+	weightsDir := filepath.Join(rootPath, configs.PlaintextWeights)
+	clients := make([]experiment.Client, len(clientIDs))
+	if err = os.MkdirAll(weightsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range clientIDs {
+		clients[i] = experiment.Client{ID: id, Weights: id + ".json"}
+		data, err := json.Marshal(map[string][][]float64{"fc1": utils.CreateMatrixFloat(12, 64), "fc2": utils.CreateMatrixFloat(10, 16)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(weightsDir, clients[i].Weights), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		c := &client.FLClient{
+			ClientID:   id,
+			NonceSeed:  make([]byte, RtF.PRNGSeedSize),
+			Counter:    make([]byte, client.NonceSize),
+			Signature:  make([]byte, ed25519.SignatureSize),
+			SymmCipher: []*RtF.PlaintextRingT{RtF.NewPlaintextRingT(params), RtF.NewPlaintextRingT(params)},
+		}
+		if err = client.SaveUploads(c, rubatoParams.Packing, params, uploadDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := Account(logger, rootPath, rubatoParams, clients, "N12QP109")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{EvaluationKeys: evk, FVSymmetricKey: fvKey, Aggregate: aggregate, IdentityKey: ed25519.PublicKeySize,
+		Nonces: 2 * (RtF.PRNGSeedSize + client.NonceSize), Signature: ed25519.SignatureSize}
+	for _, m := range report.HHE.Messages {
+		if bytes, ok := want[m.Artifact]; ok && m.Bytes != bytes {
+			t.Errorf("%s %s -> %s: got %d bytes, want %d", m.Artifact, m.From, m.To, m.Bytes, bytes)
+		}
+		if m.Artifact == Diagnostics {
+			t.Error("diagnostics accounted, the server released none")
+		}
+	}
+	for _, c := range report.HHE.Clients {
+		size, err := client.UploadSize(uploadDir, c.ClientID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size += ed25519.PublicKeySize; c.UploadBytes != size || c.DownloadBytes != int64(8*rubatoParams.Blocksize) {
+			t.Errorf("client %s uploads %d bytes and downloads %d, want %d and %d", c.ClientID, c.UploadBytes, c.DownloadBytes,
+				size, 8*rubatoParams.Blocksize)
+		}
+	}
+	var upload, download int64
+	for _, total := range report.HHE.Roles {
+		upload += total.UploadBytes
+		download += total.DownloadBytes
+	}
+	if upload != download {
+		t.Errorf("roles upload %d bytes and download %d bytes", upload, download)
+	}
+	if report.PlaintextBytes != int64(WeightBytes*len(clientIDs)*(12*64+10*16)) || report.HHEOverHE <= 0 {
+		t.Errorf("%d plaintext bytes, HHE over HE %f", report.PlaintextBytes, report.HHEOverHE)
+	}
+}
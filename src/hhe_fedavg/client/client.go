@@ -1,30 +1,39 @@
 package client
 
 import (
+	"bytes"
 	"crypto/rand"
 	"fmt"
-	"log"
 	"math"
 	"os"
 	"path/filepath"
-	"strconv"
 	"time"
 
 	"flhhe/configs"
 	"flhhe/src/RtF"
 	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/metrics"
 	"flhhe/src/utils"
 )
 
+// NonceSize the size in bytes of each nonce and of the counter
+const NonceSize = 64
+
 type FLClient struct {
 	ClientID      string
-	Nonces        [][]byte
+	Round         int
+	NonceSeed     []byte   // the nonces are derived from it by ExpandNonces
+	Nonces        [][]byte // one per FV slot
 	Counter       []byte
+	Signature     []byte // of the uploads by the identity key of the client, see Sign
 	KeyStream     [][]uint64
 	SymmCipher    []*RtF.PlaintextRingT
 	PlaintextData [][]float64 // for debug
 }
 
+// RunFLClient encrypts the weights of the client for the given round with the keystream of the symmetric
+// cipher and saves what the server receives: the uploads of the symmetric ciphertexts, with the nonce
+// seed and the counter, signed by the identity key of the client (see Register)
 func RunFLClient(
 	logger utils.Logger,
 	rootPath string,
@@ -32,49 +41,66 @@ func RunFLClient(
 	hheComponents *keys_dealer.HHEComponents,
 	weightPath string,
 	clientID string,
-) *FLClient {
+	round int,
+) (*FLClient, error) {
+	logger = logger.With(utils.LogKeyRole, utils.RoleClient, utils.LogKeyClientID, clientID)
 	logger.PrintHeader(fmt.Sprintf("--- Client %s ---", clientID))
 	logger.PrintMessage("[Client - Initialization]: Load plaintext weights from JSON")
 
 	keysDir := filepath.Join(rootPath, configs.Keys)
+	identityKey, err := LoadIdentityKey(rootPath, clientID)
+	if err != nil {
+		return nil, err
+	}
 
-	modelWeights := utils.OpenModelWeights(logger, rootPath, weightPath)
+	modelWeights, err := utils.OpenModelWeights(logger, rootPath, weightPath)
+	if err != nil {
+		return nil, err
+	}
 	modelWeights.Print2DLayerDimension(logger)
 
 	logger.PrintMessage("[Client] Preparing the data")
 	outputSize := 2 // 1 for FC1 and 1 for FC2
-	var data [][]float64 = PreparingData(logger, outputSize, params.Params, modelWeights)
+	data, err := PreparingData(logger, outputSize, params.Params, modelWeights)
+	if err != nil {
+		return nil, err
+	}
 	logger.PrintFormatted("Data.shape = [%d][%d]", len(data), len(data[0]))
 
-	logger.PrintMessage("[Client - Offline] Generating the nonces")
-	nonces := make([][]byte, params.Params.N())
-	for i := range params.Params.N() {
-		nonces[i] = make([]byte, 64)
-		rand.Read(nonces[i])
+	// The nonce seed and the counter are fresh randomness even in a seeded run (RtF.SetPRNGSeed): two
+	// clients, or two rounds, sharing them would reuse the keystream of the shared symmetric key
+	logger.PrintMessage("[Client - Offline] Generating the nonces, one per FV slot, from a nonce seed")
+	nonceSeed := make([]byte, RtF.PRNGSeedSize)
+	if _, err = rand.Read(nonceSeed); err != nil {
+		return nil, err
+	}
+	nonces, err := ExpandNonces(nonceSeed, params.Params.FVSlots())
+	if err != nil {
+		return nil, err
 	}
 	logger.PrintFormatted("Nonces diminsion: [%d][%d]", len(nonces), len(nonces[0]))
 
 	logger.PrintMessage("[Client - Offline] Generating counter")
-	counter := make([]byte, 64)
-	rand.Read(counter)
+	counter := make([]byte, NonceSize)
+	if _, err = rand.Read(counter); err != nil {
+		return nil, err
+	}
 	logger.PrintFormatted("Counter diminsion: [%d]", len(counter))
 
 	logger.PrintMessage("[Client - Offline] Loading the symmetric key")
-	symKeyPath := filepath.Join(keysDir, configs.SymmetricKey)
-	symKey := keys_dealer.LoadSymmKey(symKeyPath, params.Blocksize)
+	symKeyPath := filepath.Join(keys_dealer.SymmetricKeyDir(keysDir, params), configs.SymmetricKey)
+	symKey, err := keys_dealer.LoadSymmKey(symKeyPath, params.Blocksize)
+	if err != nil {
+		return nil, err
+	}
 
-	logger.PrintMessage("[Client - Offline] Generating the keystream z")
+	logger.PrintFormatted("[Client - Offline] Generating the %s keystream z", params.Cipher.Name())
 	t := time.Now()
-	keystream := make([][]uint64, params.Params.N())
-	for i := range params.Params.N() {
-		keystream[i] = RtF.PlainRubato(
-			params.Blocksize,
-			params.NumRound,
-			nonces[i],
-			counter,
-			symKey,
-			params.Params.PlainModulus(),
-			params.Sigma)
+	keystream := make([][]uint64, params.Params.FVSlots())
+	for i := range params.Params.FVSlots() {
+		if keystream[i], err = params.Cipher.Keystream(nonces[i], counter, symKey); err != nil {
+			return nil, err
+		}
 	}
 	logger.PrintRunningTime("Time to generate the keystream", t)
 
@@ -83,44 +109,72 @@ func RunFLClient(
 	plainCKKSRingTs := EncryptData(logger, params, hheComponents.CkksEncoder, data, keystream)
 	logger.PrintRunningTime("Time to encrypting the plaintext data using the symmetric key stream", t)
 
-	// Save the symmetric encrypted data
-	t = time.Now()
-	logger.PrintMessage("[Client - Online] Saving the symmetric encrypted data")
-	ciphertextDir := filepath.Join(rootPath, configs.SymmetricEncryptedWeights)
-	os.MkdirAll(ciphertextDir, 0755)
-	SavePlaintextRingTArray(logger, plainCKKSRingTs, ciphertextDir, clientID)
-	logger.PrintRunningTime("Time to save the symmetric encrypted data", t)
-
-	return &FLClient{
+	flClient := &FLClient{
 		ClientID:      clientID,
+		Round:         round,
+		NonceSeed:     nonceSeed,
 		Nonces:        nonces,
 		Counter:       counter,
 		KeyStream:     keystream,
 		SymmCipher:    plainCKKSRingTs,
 		PlaintextData: data,
 	}
+
+	if err = flClient.Sign(identityKey, params.Packing, params.Params); err != nil {
+		return nil, err
+	}
+
+	// Save the symmetric encrypted data
+	t = time.Now()
+	logger.PrintMessage("[Client - Online] Saving the symmetric encrypted data")
+	ciphertextDir := filepath.Join(rootPath, configs.SymmetricEncryptedWeights)
+	if err = SaveUploads(flClient, params.Packing, params.Params, ciphertextDir); err != nil {
+		return nil, err
+	}
+	logger.PrintFormatted("Symmetric encrypted data saved to %s", ciphertextDir)
+	logger.PrintRunningTime("Time to save the symmetric encrypted data", t)
+	uploadSize, err := UploadSize(ciphertextDir, clientID)
+	if err != nil {
+		return nil, err
+	}
+	logger.PrintFormatted("Upload size: %d bytes", uploadSize)
+	metrics.Default().Add(metrics.BytesUploaded, utils.RoleClient, clientID, float64(uploadSize))
+	metrics.Default().Add(metrics.Ciphertexts, utils.RoleClient, clientID, float64(len(plainCKKSRingTs)))
+
+	return flClient, nil
 }
 
-func PreparingData(logger utils.Logger, outputSize int, params *RtF.Parameters, mw utils.ModelWeights) [][]float64 {
+// PreparingData splits the flattened weights into outputSize rows of params.FVSlots() values, the
+// number of values per plaintext of the packing
+func PreparingData(logger utils.Logger, outputSize int, params *RtF.Parameters, mw utils.ModelWeights) ([][]float64, error) {
 	data := make([][]float64, outputSize)
+	n := params.FVSlots()
 
-	logger.PrintFormatted("The data structure is [%d][%d] ([outputSize][params.N()])", outputSize, params.N())
+	logger.PrintFormatted("The data structure is [%d][%d] ([outputSize][params.FVSlots()])", outputSize, n)
 	logger.PrintFormatted("We have the flatten weights as [%d] (for FC1) and [%d] (for FC2)",
 		len(mw.FC1Flatten), len(mw.FC2Flatten))
 
+	if len(mw.FC1Flatten) == 0 || len(mw.FC2Flatten) == 0 {
+		return nil, fmt.Errorf("empty weights: [%d] (for FC1) and [%d] (for FC2)", len(mw.FC1Flatten), len(mw.FC2Flatten))
+	}
+	if len(mw.FC1Flatten) > n || len(mw.FC2Flatten) > n {
+		return nil, fmt.Errorf("%w: the weights [%d] (for FC1) and [%d] (for FC2) don't fit in %d FV slots",
+			RtF.ErrParamMismatch, len(mw.FC1Flatten), len(mw.FC2Flatten), n)
+	}
+
 	cnt := 0
 
 	// start with FC1
-	cipherPerFC1 := int(math.Ceil(float64(len(mw.FC1Flatten)) / float64(params.N())))
-	paddingLenFC1 := params.N() - (len(mw.FC1Flatten) / cipherPerFC1)
-	fc1Space := params.N() - paddingLenFC1
-	logger.PrintFormatted("Number of ciphers required to store FC1 = len(FC1Flatten) / params.N(): %d", cipherPerFC1)
+	cipherPerFC1 := int(math.Ceil(float64(len(mw.FC1Flatten)) / float64(n)))
+	paddingLenFC1 := n - (len(mw.FC1Flatten) / cipherPerFC1)
+	fc1Space := n - paddingLenFC1
+	logger.PrintFormatted("Number of ciphers required to store FC1 = len(FC1Flatten) / params.FVSlots(): %d", cipherPerFC1)
 	logger.PrintFormatted("FC1 Space: %d", fc1Space)
 	logger.PrintFormatted("Padding length for each cipher required to store FC1: %d", paddingLenFC1)
 	if cipherPerFC1 > 0 {
 		for i := range cipherPerFC1 {
-			data[cnt] = make([]float64, params.N())
-			for j := range params.N() {
+			data[cnt] = make([]float64, n)
+			for j := range n {
 				if j < fc1Space {
 					data[cnt][j] = mw.FC1Flatten[(i*fc1Space)+j]
 				} else {
@@ -130,22 +184,22 @@ func PreparingData(logger utils.Logger, outputSize int, params *RtF.Parameters,
 			cnt++ // moving to the next plaintext slot (row)
 		}
 	} else {
-		log.Fatalln("Something is wrong with the input data length!")
+		return nil, fmt.Errorf("something is wrong with the input data length")
 	}
 
 	logger.PrintMessages("FC1 data space and the padding: ", data[cnt-1][fc1Space-4:fc1Space+4])
 
 	// then FC2
-	cipherPerFC2 := int(math.Ceil(float64(len(mw.FC2Flatten)) / float64(params.N())))
-	paddingLenFC2 := params.N() - (len(mw.FC2Flatten) / cipherPerFC2)
-	fc2Space := params.N() - paddingLenFC2
+	cipherPerFC2 := int(math.Ceil(float64(len(mw.FC2Flatten)) / float64(n)))
+	paddingLenFC2 := n - (len(mw.FC2Flatten) / cipherPerFC2)
+	fc2Space := n - paddingLenFC2
 	logger.PrintFormatted("Number of ciphers required to store FC2: %d", cipherPerFC2)
 	logger.PrintFormatted("FC2 Space: %d", fc2Space)
 	logger.PrintFormatted("Padding length required to store FC2: %d", paddingLenFC2)
 	if cipherPerFC2 > 0 {
 		for i := range cipherPerFC2 {
-			data[cnt] = make([]float64, params.N())
-			for j := range params.N() {
+			data[cnt] = make([]float64, n)
+			for j := range n {
 				if j < fc2Space {
 					data[cnt][j] = mw.FC2Flatten[(i*fc2Space)+j]
 				} else {
@@ -155,13 +209,16 @@ func PreparingData(logger utils.Logger, outputSize int, params *RtF.Parameters,
 			cnt++ // moving to the next plaintext slot (row)
 		}
 	} else {
-		log.Fatalln("Something is wrong with the input data length!")
+		return nil, fmt.Errorf("something is wrong with the input data length")
 	}
 	logger.PrintMessages("FC2 data space and the padding: ", data[cnt-1][fc2Space-4:fc2Space+4])
 
-	return data
+	return data, nil
 }
 
+// EncryptData encodes the params.Params.FVSlots() values of each row of data in the coefficients of a
+// plaintext, so that the SlotsToCoeffs of HalfBoot puts them back in order in the CKKS slots, and adds
+// the keystream of one nonce to each FV slot
 func EncryptData(
 	logger utils.Logger,
 	params *keys_dealer.RubatoParams,
@@ -174,14 +231,14 @@ func EncryptData(
 		coefficients[s] = make([]float64, params.Params.N())
 	}
 
-	// Copy data to coefficients with bit-reversal
+	// Copy data to coefficients with bit-reversal, the second half of the values going to the upper
+	// half of the coefficients. With the slots packing, only the FV slots are used.
+	n := params.Params.FVSlots()
 	for s := range params.OutputSize {
-		for i := range params.Params.N() {
+		for i := range n / 2 {
 			j := utils.BitReverse64(uint64(i), uint64(params.Params.LogN()-1))
-			if i < params.Params.N()/2 {
-				coefficients[s][j] = data[s][i]
-				coefficients[s][j+uint64(params.Params.N()/2)] = data[s][i+params.Params.N()/2]
-			}
+			coefficients[s][j] = data[s][i]
+			coefficients[s][j+uint64(params.Params.N()/2)] = data[s][i+n/2]
 		}
 	}
 
@@ -192,7 +249,7 @@ func EncryptData(
 		plainCKKSRingTs[s] = ckksEncoder.EncodeCoeffsRingTNew(coefficients[s], params.MessageScaling) // scales up the plaintext message
 		poly := plainCKKSRingTs[s].Value()[0]
 		logger.PrintMessage("Modulo q addition between the keystream z and the scaled message -> c_{ctr}")
-		for i := range params.Params.N() {
+		for i := range n {
 			j := utils.BitReverse64(uint64(i), uint64(params.Params.LogN()))
 			poly.Coeffs[0][j] = (poly.Coeffs[0][j] + keystream[i][s]) % params.Params.PlainModulus() // modulo q addition between the keystream to the scaled message
 		}
@@ -202,68 +259,101 @@ func EncryptData(
 	return plainCKKSRingTs
 }
 
-// SavePlaintextRingTArray saves an array of plaintexts to individual files in a directory
-func SavePlaintextRingTArray(logger utils.Logger, plaintexts []*RtF.PlaintextRingT, dirPath string, clientID string) error {
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+// checkPlaintextRingT returns an RtF.ErrParamMismatch error if pt isn't a polynomial of degree N modulo
+// the plaintext modulus
+func checkPlaintextRingT(params *RtF.Parameters, pt *RtF.PlaintextRingT) error {
+	if pt == nil || pt.El() == nil || len(pt.Value()) != 1 || pt.Value()[0] == nil || len(pt.Value()[0].Coeffs) != 1 {
+		return fmt.Errorf("%w: the plaintext isn't a single polynomial modulo the plaintext modulus", RtF.ErrParamMismatch)
 	}
-
-	// Save length file
-	lengthPath := filepath.Join(dirPath, fmt.Sprintf("%s_length.txt", clientID))
-	if err := os.WriteFile(lengthPath, []byte(strconv.Itoa(len(plaintexts))), 0644); err != nil {
-		return fmt.Errorf("failed to write length file: %v", err)
+	if n := len(pt.Value()[0].Coeffs[0]); n != params.N() {
+		return fmt.Errorf("%w: plaintext ring degree %d != %d", RtF.ErrParamMismatch, n, params.N())
 	}
-
-	// Save each plaintext
-	for i, pt := range plaintexts {
-		if pt == nil {
-			return fmt.Errorf("plaintext at index %d is nil", i)
-		}
-
-		fileName := fmt.Sprintf("%s_pt_%d.bin", clientID, i)
-		filePath := filepath.Join(dirPath, fileName)
-
-		if err := utils.Serialize(pt, filePath); err != nil {
-			return fmt.Errorf("failed to save plaintext %d: %v", i, err)
-		}
-	}
-
-	logger.PrintFormatted("Symmetric encrypted data saved to %s", dirPath)
-
 	return nil
 }
 
-// LoadPlaintextRingTArray loads an array of plaintexts from a directory
-func LoadPlaintextRingTArray(dirPath string, clientID string, params *RtF.Parameters) []*RtF.PlaintextRingT {
-	// Read length file
-	lengthPath := filepath.Join(dirPath, fmt.Sprintf("%s_length.txt", clientID))
-	lengthBytes, err := os.ReadFile(lengthPath)
+// UploadSize returns the size in bytes of the files of a client the server receives, the uploads saved
+// by SaveUploads, their number and their signature
+func UploadSize(dirPath string, clientID string) (int64, error) {
+	length, err := keys_dealer.LoadLength(filepath.Join(dirPath, fmt.Sprintf("%s_length.txt", clientID)))
 	if err != nil {
-		panic(fmt.Errorf("failed to read length file: %v", err))
+		return 0, err
 	}
-
-	length, err := strconv.Atoi(string(lengthBytes))
-	if err != nil {
-		panic(fmt.Errorf("failed to parse length: %v", err))
+	files := []string{fmt.Sprintf("%s_length.txt", clientID), SignatureFile(clientID)}
+	for i := range length {
+		files = append(files, uploadFile(clientID, i))
 	}
+	var size int64
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(dirPath, file))
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
 
-	// Create array to hold plaintexts
-	plaintexts := make([]*RtF.PlaintextRingT, length)
-
-	// Load each plaintext
-	for i := range length {
-		fileName := fmt.Sprintf("%s_pt_%d.bin", clientID, i)
-		filePath := filepath.Join(dirPath, fileName)
+// LoadFLClient rebuilds what the server receives from a client (the symmetric ciphertexts, the nonces,
+// the counter and the signature) from the uploads saved by RunFLClient. The plaintext data stays on the client side.
+// The errors of a bad upload wrap RtF.ErrCorruptArtifact or RtF.ErrParamMismatch.
+func LoadFLClient(logger utils.Logger, rootPath string, clientID string, params *RtF.Parameters) (*FLClient, error) {
+	logger = logger.With(utils.LogKeyClientID, clientID)
+	logger.PrintFormatted("[Server] Loading the symmetric encrypted data of client %s", clientID)
+	ciphertextDir := filepath.Join(rootPath, configs.SymmetricEncryptedWeights)
 
-		// Create new plaintext object
-		plaintexts[i] = RtF.NewPlaintextRingT(params)
+	uploads, err := LoadUploads(ciphertextDir, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("client %s: %w", clientID, err)
+	}
+	if len(uploads) == 0 {
+		return nil, fmt.Errorf("client %s: %w: no upload", clientID, RtF.ErrCorruptArtifact)
+	}
 
-		// Deserialize into it
-		if err := utils.Deserialize(plaintexts[i], filePath); err != nil {
-			panic(fmt.Errorf("failed to load plaintext %d: %v", i, err))
+	// The uploads of a client share its round, nonce seed and counter
+	first := uploads[0]
+	flClient := &FLClient{
+		ClientID:   clientID,
+		Round:      first.Round,
+		NonceSeed:  first.NonceSeed,
+		Counter:    first.Counter,
+		SymmCipher: make([]*RtF.PlaintextRingT, len(uploads)),
+	}
+	for i, u := range uploads {
+		if u.ClientID != clientID || u.Tensor != i || u.Round != first.Round ||
+			!bytes.Equal(u.NonceSeed, first.NonceSeed) || !bytes.Equal(u.Counter, first.Counter) {
+			return nil, fmt.Errorf("client %s: %w: upload %d is tensor %d of round %d of client %s", clientID,
+				RtF.ErrCorruptArtifact, i, u.Tensor, u.Round, u.ClientID)
 		}
+		if flClient.SymmCipher[i], err = u.PlaintextRingT(params); err != nil {
+			return nil, fmt.Errorf("client %s: upload %d: %w", clientID, i, err)
+		}
+	}
+	if flClient.Nonces, err = ExpandNonces(first.NonceSeed, params.FVSlots()); err != nil {
+		return nil, fmt.Errorf("client %s: %w: %v", clientID, RtF.ErrCorruptArtifact, err)
 	}
+	// An upload without signature loads, the server rejects it
+	if flClient.Signature, err = os.ReadFile(filepath.Join(ciphertextDir, SignatureFile(clientID))); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("client %s: failed to read the signature: %v", clientID, err)
+	}
+	return flClient, nil
+}
 
-	return plaintexts
+// Check returns an RtF.ErrParamMismatch error if the upload of the client doesn't fit params: one nonce
+// per FV slot, a counter and at least outputSize plaintexts in the plaintext ring
+func (c *FLClient) Check(params *RtF.Parameters, outputSize int) error {
+	if len(c.Nonces) != params.FVSlots() {
+		return fmt.Errorf("%w: got %d nonces, want %d", RtF.ErrParamMismatch, len(c.Nonces), params.FVSlots())
+	}
+	if len(c.Counter) == 0 {
+		return fmt.Errorf("%w: empty counter", RtF.ErrParamMismatch)
+	}
+	if len(c.SymmCipher) < outputSize {
+		return fmt.Errorf("%w: got %d symmetric ciphertexts, want %d", RtF.ErrParamMismatch, len(c.SymmCipher), outputSize)
+	}
+	for i, pt := range c.SymmCipher[:outputSize] {
+		if err := checkPlaintextRingT(params, pt); err != nil {
+			return fmt.Errorf("symmetric ciphertext %d: %w", i, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/metrics"
+	"flhhe/src/utils"
+)
+
+// toyClient runs RunFLClient for one client on the INSECURE toy parameters under rootPath, with synthetic
+// weights and a random symmetric key, without the HE keys the client doesn't use
+func toyClient(t *testing.T, rootPath string, clientID string) (*keys_dealer.RubatoParams, *FLClient) {
+	t.Helper()
+	logger := utils.NewLogger(false)
+	rubatoParams, err := keys_dealer.InitRubatoParams(logger, RtF.NewToyRubatoCipher(RtF.RUBATO128L, RtF.RtFToyN10), keys_dealer.PackingCoefficients)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := make([]uint64, rubatoParams.Blocksize)
+	for i := range key {
+		key[i] = utils.RandUint64() % rubatoParams.PlainModulus
+	}
+	keysDir := filepath.Join(rootPath, configs.Keys)
+	if err = keys_dealer.SaveSymmKey(key, filepath.Join(keys_dealer.SymmetricKeyDir(keysDir, rubatoParams), configs.SymmetricKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	// FC1 and FC2 each fit in the 1024 coefficients of one output
+	weights := map[string][][]float64{"fc1": utils.CreateMatrixFloat(12, 64), "fc2": utils.CreateMatrixFloat(10, 16)}
+	for _, layer := range weights {
+		for i := range layer {
+			for j := range layer[i] {
+				layer[i][j] = utils.RandFloat64(-1, 1)
+			}
+		}
+	}
+	data, err := json.Marshal(weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weightsDir := filepath.Join(rootPath, configs.PlaintextWeights)
+	if err = os.MkdirAll(weightsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(weightsDir, clientID+".json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = Register(logger, rootPath, clientID); err != nil {
+		t.Fatal(err)
+	}
+	hheComponents := &keys_dealer.HHEComponents{CkksEncoder: RtF.NewCKKSEncoder(rubatoParams.Params)}
+	c, err := RunFLClient(logger, rootPath, rubatoParams, hheComponents, clientID+".json", clientID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rubatoParams, c
+}
+
+// TestRunFLClient checks that the uploads of a client load back to what it sent and that its metrics
+// count them, and that a truncated upload or one saved under another client ID doesn't load
+func TestRunFLClient(t *testing.T) {
+	logger := utils.NewLogger(false)
+	rootPath := t.TempDir()
+	metrics.Default().Reset()
+	rubatoParams, c := toyClient(t, rootPath, "do1")
+	params := rubatoParams.Params
+
+	loaded, err := LoadFLClient(logger, rootPath, c.ClientID, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(loaded.Nonces) != fmt.Sprint(c.Nonces) || string(loaded.Counter) != string(c.Counter) ||
+		string(loaded.Signature) != string(c.Signature) || loaded.Round != c.Round {
+		t.Errorf("LoadFLClient: round, nonces, counter or signature differ")
+	}
+	if len(loaded.SymmCipher) != len(c.SymmCipher) {
+		t.Fatalf("LoadFLClient: got %d symmetric ciphertexts, want %d", len(loaded.SymmCipher), len(c.SymmCipher))
+	}
+	for i, pt := range loaded.SymmCipher {
+		if fmt.Sprint(pt.Value()[0].Coeffs) != fmt.Sprint(c.SymmCipher[i].Value()[0].Coeffs) {
+			t.Errorf("LoadFLClient: symmetric ciphertext %d differs", i)
+		}
+	}
+	if err = loaded.Check(params, rubatoParams.OutputSize); err != nil {
+		t.Errorf("Check of the loaded upload: %v", err)
+	}
+
+	uploadDir := filepath.Join(rootPath, configs.SymmetricEncryptedWeights)
+	size, err := UploadSize(uploadDir, c.ClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counters := make(map[metrics.Counter]bool)
+	for _, counter := range metrics.Default().Report().Counters {
+		counters[counter] = true
+	}
+	for _, want := range []metrics.Counter{
+		{Labels: metrics.Labels{Role: utils.RoleClient, ClientID: c.ClientID}, Name: metrics.BytesUploaded, Value: float64(size)},
+		{Labels: metrics.Labels{Role: utils.RoleClient, ClientID: c.ClientID}, Name: metrics.Ciphertexts, Value: float64(len(c.SymmCipher))},
+	} {
+		if !counters[want] {
+			t.Errorf("metrics: missing counter %+v", want)
+		}
+	}
+
+	for _, name := range []string{"length.txt", "ct_0.bin", "ct_1.bin"} {
+		data, err := os.ReadFile(filepath.Join(uploadDir, c.ClientID+"_"+name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(uploadDir, "renamed_"+name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if name == "ct_1.bin" {
+			data = data[:len(data)/2]
+		}
+		if err = os.WriteFile(filepath.Join(uploadDir, "corrupt_"+name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"corrupt", "renamed"} {
+		if _, err = LoadFLClient(logger, rootPath, id, params); !errors.Is(err, RtF.ErrCorruptArtifact) {
+			t.Errorf("LoadFLClient of a %s upload: got error %v, want %v", id, err, RtF.ErrCorruptArtifact)
+		}
+	}
+}
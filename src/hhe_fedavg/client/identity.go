@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+// signatureDomain separates the digests signed by the clients from any other use of their keys
+const signatureDomain = "flhhe upload signature v1"
+
+// Register loads the identity key of the client under rootPath, generating it if it has none, and
+// registers its public key with the keys dealer. The private key stays with the client.
+func Register(logger utils.Logger, rootPath string, clientID string) (ed25519.PublicKey, error) {
+	logger = logger.With(utils.LogKeyRole, utils.RoleClient, utils.LogKeyClientID, clientID)
+	key, err := LoadIdentityKey(rootPath, clientID)
+	if errors.Is(err, os.ErrNotExist) {
+		// Fresh randomness, never the PRNGs of RtF.SetPRNGSeed: a seeded run would give all the
+		// clients the same key
+		logger.PrintFormatted("[Client] Generating the identity key of client %s", clientID)
+		if _, key, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			return nil, err
+		}
+		dir := filepath.Join(rootPath, configs.ClientIdentityKeys)
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %v", err)
+		}
+		if err = os.WriteFile(identityKeyPath(rootPath, clientID), key.Seed(), 0600); err != nil {
+			return nil, fmt.Errorf("failed to save the identity key: %v", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	public := key.Public().(ed25519.PublicKey)
+	if err = keys_dealer.RegisterClient(filepath.Join(rootPath, configs.Keys), clientID, public); err != nil {
+		return nil, err
+	}
+	logger.PrintFormatted("[Client] Identity key of client %s registered with the keys dealer", clientID)
+	return public, nil
+}
+
+// LoadIdentityKey loads the identity key of the client saved by Register, an error wrapping
+// os.ErrNotExist if the client isn't registered
+func LoadIdentityKey(rootPath string, clientID string) (ed25519.PrivateKey, error) {
+	seed, err := os.ReadFile(identityKeyPath(rootPath, clientID))
+	if err != nil {
+		return nil, fmt.Errorf("client %s: failed to read the identity key: %w", clientID, err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("client %s: %w: identity key of %d bytes", clientID, RtF.ErrCorruptArtifact, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func identityKeyPath(rootPath string, clientID string) string {
+	return filepath.Join(rootPath, configs.ClientIdentityKeys, clientID+"_identity.key")
+}
+
+// UploadDigest returns the digest a client signs: its ID, the round, the nonce seed and the checksums of its
+// uploads, which are the hashes of its symmetric ciphertexts
+func UploadDigest(clientID string, round int, nonceSeed []byte, checksums [][]byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(signatureDomain))
+	h.Write(binary.LittleEndian.AppendUint16(nil, uint16(len(clientID))))
+	h.Write([]byte(clientID))
+	h.Write(binary.LittleEndian.AppendUint32(nil, uint32(round)))
+	h.Write(binary.LittleEndian.AppendUint16(nil, uint16(len(nonceSeed))))
+	h.Write(nonceSeed)
+	h.Write(binary.LittleEndian.AppendUint16(nil, uint16(len(checksums))))
+	for _, checksum := range checksums {
+		h.Write(checksum)
+	}
+	return h.Sum(nil)
+}
+
+// Digest encodes the uploads of the client and returns their UploadDigest
+func (c *FLClient) Digest(packing keys_dealer.Packing, params *RtF.Parameters) ([]byte, error) {
+	checksums := make([][]byte, len(c.SymmCipher))
+	for i, pt := range c.SymmCipher {
+		if err := checkPlaintextRingT(params, pt); err != nil {
+			return nil, fmt.Errorf("symmetric ciphertext %d: %w", i, err)
+		}
+		data, err := NewUpload(c, i, packing, params, pt).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		checksums[i] = data[len(data)-ChecksumSize:]
+	}
+	return UploadDigest(c.ClientID, c.Round, c.NonceSeed, checksums), nil
+}
+
+// Sign signs the digest of the uploads of the client with its identity key
+func (c *FLClient) Sign(key ed25519.PrivateKey, packing keys_dealer.Packing, params *RtF.Parameters) error {
+	digest, err := c.Digest(packing, params)
+	if err != nil {
+		return err
+	}
+	c.Signature = ed25519.Sign(key, digest)
+	return nil
+}
+
+// Verify checks the signature of the uploads of the client with its identity key, and that its nonces
+// are those of its nonce seed, the ones the signature covers
+func (c *FLClient) Verify(key ed25519.PublicKey, packing keys_dealer.Packing, params *RtF.Parameters) error {
+	if len(c.Signature) != ed25519.SignatureSize {
+		return errors.New("the upload isn't signed")
+	}
+	nonces, err := ExpandNonces(c.NonceSeed, len(c.Nonces))
+	if err != nil {
+		return err
+	}
+	for i := range nonces {
+		if !bytes.Equal(nonces[i], c.Nonces[i]) {
+			return fmt.Errorf("nonce %d isn't derived from the nonce seed", i)
+		}
+	}
+	digest, err := c.Digest(packing, params)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, digest, c.Signature) {
+		return errors.New("the signature doesn't match the upload")
+	}
+	return nil
+}
+
+// SignatureFile the file name of the signature of the uploads of a client, saved next to them
+func SignatureFile(clientID string) string {
+	return fmt.Sprintf("%s_signature.sig", clientID)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+func TestRegister(t *testing.T) {
+	logger := utils.NewLogger(false)
+	rootPath := t.TempDir()
+	public, err := Register(logger, rootPath, "do1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Registering again keeps the key, a new key for the client conflicts with the registered one
+	again, err := Register(logger, rootPath, "do1")
+	if err != nil || !bytes.Equal(public, again) {
+		t.Errorf("second Register: got key %x and error %v, want key %x", again, err, public)
+	}
+	if err = os.Remove(identityKeyPath(rootPath, "do1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Register(logger, rootPath, "do1"); !errors.Is(err, keys_dealer.ErrIdentityConflict) {
+		t.Errorf("Register with a new key: got error %v, want %v", err, keys_dealer.ErrIdentityConflict)
+	}
+	if _, err = LoadIdentityKey(rootPath, "do2"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadIdentityKey of an unregistered client: got error %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	packing := keys_dealer.PackingCoefficients
+	params := testParams(packing)
+	logger := utils.NewLogger(false)
+	rootPath := t.TempDir()
+	public, err := Register(logger, rootPath, "do1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := LoadIdentityKey(rootPath, "do1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Register(logger, rootPath, "do2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed := bytes.Repeat([]byte{3}, RtF.PRNGSeedSize)
+	nonces, err := ExpandNonces(seed, params.FVSlots())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts := make([]*RtF.PlaintextRingT, 2)
+	for i := range pts {
+		pts[i] = RtF.NewPlaintextRingT(params)
+		for j := range pts[i].Value()[0].Coeffs[0] {
+			pts[i].Value()[0].Coeffs[0][j] = utils.RandUint64() % params.PlainModulus()
+		}
+	}
+	c := &FLClient{ClientID: "do1", Round: 2, NonceSeed: seed, Nonces: nonces, Counter: make([]byte, NonceSize), SymmCipher: pts}
+	if err = c.Verify(public, packing, params); err == nil {
+		t.Error("Verify of an unsigned upload: no error")
+	}
+	if err = c.Sign(key, packing, params); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Verify(public, packing, params); err != nil {
+		t.Fatalf("Verify of the signed upload: %v", err)
+	}
+	if err = c.Verify(other, packing, params); err == nil {
+		t.Error("Verify with the key of another client: no error")
+	}
+
+	// Any change to the signed fields breaks the signature
+	for name, tamper := range map[string]func(c *FLClient){
+		"client ID":   func(c *FLClient) { c.ClientID = "do2" },
+		"round":       func(c *FLClient) { c.Round++ },
+		"nonce seed":  func(c *FLClient) { c.NonceSeed = bytes.Repeat([]byte{4}, RtF.PRNGSeedSize) },
+		"nonce":       func(c *FLClient) { c.Nonces = append([][]byte{make([]byte, NonceSize)}, c.Nonces[1:]...) },
+		"coefficient": func(c *FLClient) { c.SymmCipher = []*RtF.PlaintextRingT{RtF.NewPlaintextRingT(params), pts[1]} },
+	} {
+		tampered := *c
+		tamper(&tampered)
+		if err = tampered.Verify(public, packing, params); err == nil {
+			t.Errorf("Verify of an upload with another %s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+)
+
+// UploadMagic the first bytes of an upload, followed by UploadVersion
+const UploadMagic = "FLSC"
+
+// UploadVersion the version of the upload format
+const UploadVersion = 1
+
+// ChecksumSize the size of the checksum closing an upload, an unkeyed SHA-256 digest: an integrity
+// check, the authentication is delegated to the signature of the client (see Upload)
+const ChecksumSize = sha256.Size
+
+// Upload is the wire format of one symmetric ciphertext, what the client sends for a tensor: its
+// coefficients modulo the plaintext modulus p packed at ceil(log2 p) bits instead of the 64-bit words
+// of a serialized PlaintextRingT, with what the server needs to evaluate the keystream and a checksum.
+// The checksum is the unkeyed SHA-256 digest of the rest of the message: it detects corrupt or
+// truncated uploads, not forged ones, anyone can recompute it. Authentication is delegated to the
+// Ed25519 signature of the client over the checksums of its uploads (FLClient.Sign): an upload is only
+// authentic once server.Verifier checked that signature, UnmarshalBinary alone doesn't authenticate it.
+//
+// Layout (little endian): UploadMagic, UploadVersion (1 byte), the client ID (2 bytes length), the
+// round (4 bytes), the tensor (2 bytes), the packing (1 byte), logN (1 byte), p (8 bytes), the bits per
+// coefficient (1 byte), the nonce seed and the counter (2 bytes length each), the N packed
+// coefficients, the checksum.
+type Upload struct {
+	ClientID     string
+	Round        int
+	Tensor       int // index of the symmetric ciphertext, 0 for FC1 and 1 for FC2
+	Packing      keys_dealer.Packing
+	LogN         int
+	PlainModulus uint64
+	NonceSeed    []byte // ExpandNonces derives the nonce of each FV slot from it
+	Counter      []byte
+	Coeffs       []uint64
+}
+
+// NewUpload returns the upload of the symmetric ciphertext pt, the tensor-th of the client
+func NewUpload(c *FLClient, tensor int, packing keys_dealer.Packing, params *RtF.Parameters, pt *RtF.PlaintextRingT) *Upload {
+	return &Upload{
+		ClientID:     c.ClientID,
+		Round:        c.Round,
+		Tensor:       tensor,
+		Packing:      packing,
+		LogN:         params.LogN(),
+		PlainModulus: params.PlainModulus(),
+		NonceSeed:    c.NonceSeed,
+		Counter:      c.Counter,
+		Coeffs:       pt.Value()[0].Coeffs[0],
+	}
+}
+
+// CoeffBits returns the number of bits of a coefficient modulo p, ceil(log2 p)
+func CoeffBits(p uint64) int {
+	return bits.Len64(p - 1)
+}
+
+// MarshalBinary encodes the upload and appends its checksum
+func (u *Upload) MarshalBinary() ([]byte, error) {
+	if len(u.ClientID) > 0xffff || len(u.NonceSeed) > 0xffff || len(u.Counter) > 0xffff {
+		return nil, fmt.Errorf("upload of client %.16s: field too long", u.ClientID)
+	}
+	if len(u.Coeffs) != 1<<u.LogN {
+		return nil, fmt.Errorf("upload of client %s: %d coefficients, want 2^%d", u.ClientID, len(u.Coeffs), u.LogN)
+	}
+	nbBits := CoeffBits(u.PlainModulus)
+	buf := new(bytes.Buffer)
+	buf.WriteString(UploadMagic)
+	buf.WriteByte(UploadVersion)
+	writeBytes(buf, []byte(u.ClientID))
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(u.Round)))
+	buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(u.Tensor)))
+	buf.WriteByte(byte(u.Packing))
+	buf.WriteByte(byte(u.LogN))
+	buf.Write(binary.LittleEndian.AppendUint64(nil, u.PlainModulus))
+	buf.WriteByte(byte(nbBits))
+	writeBytes(buf, u.NonceSeed)
+	writeBytes(buf, u.Counter)
+	packed, err := packCoeffs(u.Coeffs, nbBits, u.PlainModulus)
+	if err != nil {
+		return nil, fmt.Errorf("upload of client %s: %w", u.ClientID, err)
+	}
+	buf.Write(packed)
+	checksum := sha256.Sum256(buf.Bytes())
+	return append(buf.Bytes(), checksum[:]...), nil
+}
+
+// UnmarshalBinary decodes an upload and checks its integrity, not its authenticity (see Upload). The
+// errors wrap RtF.ErrCorruptArtifact.
+func (u *Upload) UnmarshalBinary(data []byte) error {
+	if len(data) < ChecksumSize {
+		return fmt.Errorf("%w: upload of %d bytes", RtF.ErrCorruptArtifact, len(data))
+	}
+	body, checksum := data[:len(data)-ChecksumSize], data[len(data)-ChecksumSize:]
+	if digest := sha256.Sum256(body); !bytes.Equal(checksum, digest[:]) {
+		return fmt.Errorf("%w: the upload doesn't match its checksum", RtF.ErrCorruptArtifact)
+	}
+
+	r := &reader{data: body}
+	if magic := r.next(len(UploadMagic)); string(magic) != UploadMagic {
+		return fmt.Errorf("%w: not an upload", RtF.ErrCorruptArtifact)
+	}
+	if version := r.byte(); version != UploadVersion {
+		return fmt.Errorf("%w: upload version %d, want %d", RtF.ErrCorruptArtifact, version, UploadVersion)
+	}
+	u.ClientID = string(r.bytes())
+	u.Round = int(binary.LittleEndian.Uint32(r.next(4)))
+	u.Tensor = int(binary.LittleEndian.Uint16(r.next(2)))
+	u.Packing = keys_dealer.Packing(r.byte())
+	u.LogN = int(r.byte())
+	u.PlainModulus = binary.LittleEndian.Uint64(r.next(8))
+	nbBits := int(r.byte())
+	u.NonceSeed = r.bytes()
+	u.Counter = r.bytes()
+	if r.err != nil {
+		return fmt.Errorf("%w: truncated upload header", RtF.ErrCorruptArtifact)
+	}
+	if u.LogN > 17 || u.PlainModulus < 2 || nbBits != CoeffBits(u.PlainModulus) {
+		return fmt.Errorf("%w: upload of 2^%d coefficients of %d bits modulo %d", RtF.ErrCorruptArtifact, u.LogN, nbBits, u.PlainModulus)
+	}
+	packed := r.next(r.remaining())
+	var err error
+	if u.Coeffs, err = unpackCoeffs(packed, 1<<u.LogN, nbBits, u.PlainModulus); err != nil {
+		return fmt.Errorf("%w: %v", RtF.ErrCorruptArtifact, err)
+	}
+	return nil
+}
+
+// PlaintextRingT rebuilds the symmetric ciphertext the server scales up with FVScaleUp, an
+// RtF.ErrParamMismatch error if the upload was made for another ring, plaintext modulus or packing
+func (u *Upload) PlaintextRingT(params *RtF.Parameters) (*RtF.PlaintextRingT, error) {
+	if u.LogN != params.LogN() || u.PlainModulus != params.PlainModulus() {
+		return nil, fmt.Errorf("%w: upload of degree 2^%d modulo %d, want 2^%d modulo %d",
+			RtF.ErrParamMismatch, u.LogN, u.PlainModulus, params.LogN(), params.PlainModulus())
+	}
+	if 1<<u.Packing.LogFVSlots(params) != params.FVSlots() {
+		return nil, fmt.Errorf("%w: upload packed in the %s, the parameters have %d FV slots",
+			RtF.ErrParamMismatch, u.Packing, params.FVSlots())
+	}
+	pt := RtF.NewPlaintextRingT(params)
+	copy(pt.Value()[0].Coeffs[0], u.Coeffs)
+	return pt, nil
+}
+
+// ExpandNonces derives n nonces of NonceSize bytes from the nonce seed of an upload
+func ExpandNonces(seed []byte, n int) ([][]byte, error) {
+	prng, err := RtF.NewSeededPRNG(seed)
+	if err != nil {
+		return nil, err
+	}
+	nonces := make([][]byte, n)
+	for i := range nonces {
+		nonces[i] = make([]byte, NonceSize)
+		if _, err = prng.Read(nonces[i]); err != nil {
+			return nil, err
+		}
+	}
+	return nonces, nil
+}
+
+// SaveUploads saves the upload of each symmetric ciphertext of the client in dirPath, as
+// <clientID>_ct_<i>.bin, their number in <clientID>_length.txt and their signature in
+// <clientID>_signature.bin
+func SaveUploads(c *FLClient, packing keys_dealer.Packing, params *RtF.Parameters, dirPath string) error {
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dirPath, SignatureFile(c.ClientID)), c.Signature, 0644); err != nil {
+		return fmt.Errorf("failed to write the signature: %v", err)
+	}
+	lengthPath := filepath.Join(dirPath, fmt.Sprintf("%s_length.txt", c.ClientID))
+	if err := os.WriteFile(lengthPath, []byte(strconv.Itoa(len(c.SymmCipher))), 0644); err != nil {
+		return fmt.Errorf("failed to write length file: %v", err)
+	}
+	for i, pt := range c.SymmCipher {
+		if pt == nil {
+			return fmt.Errorf("plaintext at index %d is nil", i)
+		}
+		data, err := NewUpload(c, i, packing, params, pt).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(dirPath, uploadFile(c.ClientID, i)), data, 0644); err != nil {
+			return fmt.Errorf("failed to save upload %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// LoadUploads loads the uploads saved by SaveUploads, the errors of a bad upload wrap RtF.ErrCorruptArtifact
+func LoadUploads(dirPath string, clientID string) ([]*Upload, error) {
+	length, err := keys_dealer.LoadLength(filepath.Join(dirPath, fmt.Sprintf("%s_length.txt", clientID)))
+	if err != nil {
+		return nil, err
+	}
+	uploads := make([]*Upload, length)
+	for i := range uploads {
+		data, err := os.ReadFile(filepath.Join(dirPath, uploadFile(clientID, i)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upload %d: %v", i, err)
+		}
+		uploads[i] = new(Upload)
+		if err = uploads[i].UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("upload %d: %w", i, err)
+		}
+	}
+	return uploads, nil
+}
+
+// uploadFile the file name of the i-th upload of a client
+func uploadFile(clientID string, i int) string {
+	return fmt.Sprintf("%s_ct_%d.bin", clientID, i)
+}
+
+// packCoeffs packs the coefficients, each lower than p, at nbBits bits, least significant bits first
+func packCoeffs(coeffs []uint64, nbBits int, p uint64) ([]byte, error) {
+	packed := make([]byte, (len(coeffs)*nbBits+7)/8)
+	for i, c := range coeffs {
+		if c >= p {
+			return nil, fmt.Errorf("coefficient %d is %d, not lower than %d", i, c, p)
+		}
+		for bit := i * nbBits; c != 0; bit, c = bit+8-bit%8, c>>(8-bit%8) {
+			packed[bit/8] |= byte(c << (bit % 8))
+		}
+	}
+	return packed, nil
+}
+
+// unpackCoeffs unpacks n coefficients of nbBits bits packed by packCoeffs
+func unpackCoeffs(packed []byte, n int, nbBits int, p uint64) ([]uint64, error) {
+	if len(packed) != (n*nbBits+7)/8 {
+		return nil, fmt.Errorf("%d bytes of packed coefficients, want %d", len(packed), (n*nbBits+7)/8)
+	}
+	coeffs := make([]uint64, n)
+	mask := uint64(1)<<nbBits - 1
+	for i := range coeffs {
+		var c uint64
+		for bit, shift := i*nbBits, 0; shift < nbBits; bit, shift = bit+8-bit%8, shift+8-bit%8 {
+			c |= uint64(packed[bit/8]>>(bit%8)) << shift
+		}
+		if coeffs[i] = c & mask; coeffs[i] >= p {
+			return nil, fmt.Errorf("coefficient %d is %d, not lower than %d", i, coeffs[i], p)
+		}
+	}
+	return coeffs, nil
+}
+
+// writeBytes writes b prefixed by its length on 2 bytes
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	buf.Write(binary.LittleEndian.AppendUint16(nil, uint16(len(b))))
+	buf.Write(b)
+}
+
+// reader reads the fields of an upload, err is set once a field overruns the data
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = fmt.Errorf("truncated")
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *reader) bytes() []byte {
+	return append([]byte{}, r.next(int(binary.LittleEndian.Uint16(r.next(2))))...)
+}
+
+func (r *reader) remaining() int {
+	return len(r.data)
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+// testParams the RtF parameters of the upload tests, with the plaintext modulus of Rubato
+func testParams(packing keys_dealer.Packing) *RtF.Parameters {
+	params := RtF.DefaultParams[RtF.PN13QP218].WithPlainModulus(RtF.RubatoParams[RtF.RUBATO128L].PlainModulus)
+	params.SetLogFVSlots(packing.LogFVSlots(params))
+	return params
+}
+
+func TestUpload(t *testing.T) {
+	params := testParams(keys_dealer.PackingCoefficients)
+	p := params.PlainModulus()
+	prng, err := RtF.NewPRNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, counter := make([]byte, RtF.PRNGSeedSize), make([]byte, NonceSize)
+	prng.Read(seed)
+	prng.Read(counter)
+
+	pt := RtF.NewPlaintextRingT(params)
+	coeffs := pt.Value()[0].Coeffs[0]
+	for i := range coeffs {
+		coeffs[i] = utils.RandUint64() % p
+	}
+	coeffs[0], coeffs[1] = 0, p-1
+	c := &FLClient{ClientID: "do1", Round: 3, NonceSeed: seed, Counter: counter}
+	data, err := NewUpload(c, 1, keys_dealer.PackingCoefficients, params, pt).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The coefficients take ceil(log2 p) bits instead of 64
+	nbBits := CoeffBits(p)
+	if nbBits != 25 {
+		t.Errorf("CoeffBits(%d) = %d, want 25", p, nbBits)
+	}
+	header := len(UploadMagic) + 1 + 2 + len(c.ClientID) + 4 + 2 + 1 + 1 + 8 + 1 + 2 + len(seed) + 2 + len(counter)
+	if want := header + (params.N()*nbBits+7)/8 + ChecksumSize; len(data) != want {
+		t.Errorf("upload of %d bytes, want %d", len(data), want)
+	}
+	ptData, err := pt.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > len(ptData)*nbBits/64+header+ChecksumSize {
+		t.Errorf("upload of %d bytes, the serialized PlaintextRingT takes %d", len(data), len(ptData))
+	}
+
+	u := new(Upload)
+	if err = u.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if u.ClientID != "do1" || u.Round != 3 || u.Tensor != 1 || u.Packing != keys_dealer.PackingCoefficients ||
+		!bytes.Equal(u.NonceSeed, seed) || !bytes.Equal(u.Counter, counter) {
+		t.Errorf("decoded header %+v", u)
+	}
+	rebuilt, err := u.PlaintextRingT(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, coeff := range rebuilt.Value()[0].Coeffs[0] {
+		if coeff != coeffs[i] {
+			t.Fatalf("coefficient %d: got %d, want %d", i, coeff, coeffs[i])
+		}
+	}
+
+	// A flipped bit or a truncation doesn't match the checksum
+	for name, bad := range map[string][]byte{
+		"flipped":   append(append([]byte{}, data[:header+10]...), append([]byte{data[header+10] ^ 1}, data[header+11:]...)...),
+		"truncated": data[:len(data)-1],
+		"empty":     nil,
+	} {
+		if err = new(Upload).UnmarshalBinary(bad); !errors.Is(err, RtF.ErrCorruptArtifact) {
+			t.Errorf("%s upload: got error %v, want %v", name, err, RtF.ErrCorruptArtifact)
+		}
+	}
+
+	// The upload doesn't fit the slots packing nor another plaintext modulus
+	for _, other := range []*RtF.Parameters{testParams(keys_dealer.PackingSlots), params.WithPlainModulus(p - 2)} {
+		if _, err = u.PlaintextRingT(other); !errors.Is(err, RtF.ErrParamMismatch) {
+			t.Errorf("PlaintextRingT with other parameters: got error %v, want %v", err, RtF.ErrParamMismatch)
+		}
+	}
+}
+
+func TestExpandNonces(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, RtF.PRNGSeedSize)
+	a, err := ExpandNonces(seed, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ExpandNonces(seed, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range a {
+		if len(a[i]) != NonceSize || !bytes.Equal(a[i], b[i]) {
+			t.Fatalf("nonce %d differs between two expansions of the seed", i)
+		}
+		if i > 0 && bytes.Equal(a[i], a[i-1]) {
+			t.Errorf("nonces %d and %d are equal", i-1, i)
+		}
+	}
+}
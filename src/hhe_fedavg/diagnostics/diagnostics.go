@@ -0,0 +1,215 @@
+// Package diagnostics computes the encrypted per-client diagnostics of a FedAvg round. The server
+// computes, without the secret key, the squared L2 norm of each client update and its inner product
+// with the average. Only these encrypted scalars are released to the key holder, who derives the
+// cosine similarities and can flag stragglers or anomalous clients without ever seeing the raw
+// weights.
+package diagnostics
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/utils"
+)
+
+// ClientDiagnostics holds the encrypted metrics of one client. Since the protocol runs a single
+// round from scratch, the client weights are the client update.
+type ClientDiagnostics struct {
+	ClientID    string
+	SquaredNorm *RtF.Ciphertext // ||w_i||^2 in every slot
+	DotAverage  *RtF.Ciphertext // <w_i, avg> in every slot
+}
+
+// Diagnostics holds the encrypted metrics of all the clients of a round
+type Diagnostics struct {
+	AvgSquaredNorm *RtF.Ciphertext // ||avg||^2 in every slot
+	Clients        []*ClientDiagnostics
+}
+
+// Report is the decrypted view of the diagnostics of one client
+type Report struct {
+	ClientID         string
+	SquaredNorm      float64
+	CosineSimilarity float64
+}
+
+// GenRotationIndexes returns the rotations needed to sum all the slots of a ciphertext
+func GenRotationIndexes(kgen RtF.KeyGenerator, params *RtF.Parameters) []int {
+	return kgen.GenRotationIndexesForInnerSum(1, params.Slots())
+}
+
+// Ops returns the CKKS operations of an inner product of Compute on nbTensors pairs of ciphertexts in the
+// same state: their products, rescaled and summed, and the rotations of InnerSum
+func Ops(params *RtF.Parameters, nbTensors int) []RtF.CKKSOp {
+	ops := []RtF.CKKSOp{{Kind: RtF.CKKSMulRelin}, {Kind: RtF.CKKSRescale}, {Kind: RtF.CKKSAdd, Count: nbTensors}}
+	for i := 0; i < params.LogSlots(); i++ {
+		ops = append(ops, RtF.CKKSOp{Kind: RtF.CKKSRotate})
+	}
+	return ops
+}
+
+// CheckKeys verifies that the rotation keys needed by Compute are in the set, and that the ciphertexts
+// of nbTensors tensors in the states inputs (e.g. HalfBootOutput for the clients and the plan of the
+// aggregation for the average) have the levels of its products, with the parameters hb: it returns an
+// RtF.ErrInsufficientLevels error otherwise
+func CheckKeys(
+	params *RtF.Parameters,
+	rotKeys *RtF.RotationKeySet,
+	hb *RtF.HalfBootParameters,
+	nbTensors int,
+	inputs ...RtF.CKKSState,
+) error {
+	kgen := RtF.NewKeyGenerator(params)
+	for _, k := range GenRotationIndexes(kgen, params) {
+		if _, ok := rotKeys.GetRotationKey(params.GaloisElementForColumnRotationBy(k)); !ok {
+			return fmt.Errorf("rotation key for k=%d is missing, the keys must be regenerated", k)
+		}
+	}
+	for _, input := range inputs {
+		if _, err := RtF.PlanCKKS(hb, input, Ops(params, nbTensors), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compute evaluates the encrypted diagnostics from the per-client ciphertexts
+// ciphertexts[client][tensor] and the average ciphertexts avg[tensor]
+func Compute(
+	logger utils.Logger,
+	params *RtF.Parameters,
+	evaluator RtF.CKKSEvaluator,
+	clientIDs []string,
+	ciphertexts [][]*RtF.Ciphertext,
+	avg []*RtF.Ciphertext,
+) (*Diagnostics, error) {
+	logger.PrintMessage("[Server - Online] Computing the encrypted client diagnostics")
+	t := time.Now()
+
+	var err error
+	diagnostics := &Diagnostics{Clients: make([]*ClientDiagnostics, len(clientIDs))}
+	if diagnostics.AvgSquaredNorm, err = innerProduct(params, evaluator, avg, avg); err != nil {
+		return nil, fmt.Errorf("avg squared norm: %w", err)
+	}
+
+	for i, clientID := range clientIDs {
+		client := &ClientDiagnostics{ClientID: clientID}
+		if client.SquaredNorm, err = innerProduct(params, evaluator, ciphertexts[i], ciphertexts[i]); err != nil {
+			return nil, fmt.Errorf("client %s squared norm: %w", clientID, err)
+		}
+		if client.DotAverage, err = innerProduct(params, evaluator, ciphertexts[i], avg); err != nil {
+			return nil, fmt.Errorf("client %s inner product with the average: %w", clientID, err)
+		}
+		diagnostics.Clients[i] = client
+	}
+	logger.PrintRunningTime("Time to compute the encrypted client diagnostics", t)
+
+	return diagnostics, nil
+}
+
+// innerProduct returns an encryption of sum_j <a[j], b[j]> replicated in all the slots
+func innerProduct(params *RtF.Parameters, evaluator RtF.CKKSEvaluator, a, b []*RtF.Ciphertext) (*RtF.Ciphertext, error) {
+	if len(a) != len(b) {
+		return nil, fmt.Errorf("%d ciphertexts vs %d ciphertexts", len(a), len(b))
+	}
+
+	var acc *RtF.Ciphertext
+	for j := range a {
+		ct := evaluator.MulRelinNew(a[j], b[j])
+		if err := evaluator.Rescale(ct, params.Scale(), ct); err != nil {
+			return nil, err
+		}
+		if acc == nil {
+			acc = ct
+		} else {
+			evaluator.Add(acc, ct, acc)
+		}
+	}
+	evaluator.InnerSum(acc, 1, params.Slots(), acc)
+
+	return acc, nil
+}
+
+// Save stores the encrypted diagnostics in dirPath, to be sent to the key holder
+func Save(logger utils.Logger, dirPath string, diagnostics *Diagnostics) error {
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err := utils.Serialize(diagnostics.AvgSquaredNorm, filepath.Join(dirPath, "avg_norm"+configs.CtFormat)); err != nil {
+		return err
+	}
+	for _, client := range diagnostics.Clients {
+		if err := utils.Serialize(client.SquaredNorm, filepath.Join(dirPath, client.ClientID+"_norm"+configs.CtFormat)); err != nil {
+			return err
+		}
+		if err := utils.Serialize(client.DotAverage, filepath.Join(dirPath, client.ClientID+"_dot_avg"+configs.CtFormat)); err != nil {
+			return err
+		}
+	}
+	logger.PrintFormatted("Encrypted client diagnostics saved to %s", dirPath)
+	return nil
+}
+
+// Load reads the encrypted diagnostics of the given clients from dirPath
+func Load(dirPath string, clientIDs []string, params *RtF.Parameters) (*Diagnostics, error) {
+	load := func(name string) (*RtF.Ciphertext, error) {
+		ct := RtF.NewCiphertextFVLvl(params, 1, 0)
+		if err := utils.Deserialize(ct, filepath.Join(dirPath, name+configs.CtFormat)); err != nil {
+			return nil, err
+		}
+		return ct, nil
+	}
+
+	var err error
+	diagnostics := &Diagnostics{Clients: make([]*ClientDiagnostics, len(clientIDs))}
+	if diagnostics.AvgSquaredNorm, err = load("avg_norm"); err != nil {
+		return nil, err
+	}
+	for i, clientID := range clientIDs {
+		client := &ClientDiagnostics{ClientID: clientID}
+		if client.SquaredNorm, err = load(clientID + "_norm"); err != nil {
+			return nil, err
+		}
+		if client.DotAverage, err = load(clientID + "_dot_avg"); err != nil {
+			return nil, err
+		}
+		diagnostics.Clients[i] = client
+	}
+	return diagnostics, nil
+}
+
+// Decrypt is run by the key holder to obtain the squared norms and the cosine similarities
+// cos(w_i, avg) = <w_i, avg> / (||w_i|| * ||avg||)
+func Decrypt(
+	logger utils.Logger,
+	params *RtF.Parameters,
+	encoder RtF.CKKSEncoder,
+	decryptor RtF.CKKSDecryptor,
+	diagnostics *Diagnostics,
+) []Report {
+	logger.PrintMessage("[Keys Dealer] Decrypting the client diagnostics")
+	decrypt := func(ct *RtF.Ciphertext) float64 {
+		return real(encoder.DecodeComplex(decryptor.DecryptNew(ct), params.LogSlots())[0])
+	}
+
+	avgSquaredNorm := decrypt(diagnostics.AvgSquaredNorm)
+	logger.PrintFormatted("||avg||^2 = %f", avgSquaredNorm)
+
+	reports := make([]Report, len(diagnostics.Clients))
+	for i, client := range diagnostics.Clients {
+		squaredNorm := decrypt(client.SquaredNorm)
+		reports[i] = Report{
+			ClientID:         client.ClientID,
+			SquaredNorm:      squaredNorm,
+			CosineSimilarity: decrypt(client.DotAverage) / math.Sqrt(squaredNorm*avgSquaredNorm),
+		}
+		logger.PrintFormatted("Client %s: ||w||^2 = %f, cos(w, avg) = %f",
+			reports[i].ClientID, reports[i].SquaredNorm, reports[i].CosineSimilarity)
+	}
+	return reports
+}
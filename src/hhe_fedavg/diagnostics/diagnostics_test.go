@@ -0,0 +1,141 @@
+package diagnostics
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"flhhe/src/RtF"
+	"flhhe/src/utils"
+)
+
+func TestDiagnostics(t *testing.T) {
+	// The INSECURE toy parameters of HalfBoot, whose levels CheckKeys plans
+	hb := RtF.RtFToyParams[RtF.RtFToyN10]
+	params, err := hb.Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainModulus := RtF.RubatoParams[RtF.RUBATO128S].PlainModulus
+	params = params.WithPlainModulus(plainModulus)
+	numClients, numTensors := 3, 2
+	halfBootOutput := RtF.HalfBootOutput(hb, plainModulus)
+
+	kgen := RtF.NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotKeys, err := kgen.GenRotationKeysForRotations(GenRotationIndexes(kgen, params), false, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKeys(params, rotKeys, hb, numTensors, halfBootOutput); err != nil {
+		t.Fatal(err)
+	}
+	someRotKeys, err := kgen.GenRotationKeysForRotations([]int{1}, false, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKeys(params, someRotKeys, hb, numTensors, halfBootOutput); err == nil {
+		t.Error("CheckKeys: missing rotation keys not detected")
+	}
+	lastLevel := halfBootOutput
+	lastLevel.Level = 0
+	if err := CheckKeys(params, rotKeys, hb, numTensors, halfBootOutput, lastLevel); !errors.Is(err, RtF.ErrInsufficientLevels) {
+		t.Errorf("CheckKeys of ciphertexts at level 0: got error %v, want %v", err, RtF.ErrInsufficientLevels)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoder := RtF.NewCKKSEncoder(params)
+	encryptor := RtF.NewCKKSEncryptorFromPk(params, pk)
+	decryptor := RtF.NewCKKSDecryptor(params, sk)
+	evaluator := RtF.NewCKKSEvaluator(params, RtF.EvaluationKey{Rlk: rlk, Rtks: rotKeys})
+
+	// Plaintext weights, the last client is an outlier pointing in the opposite direction
+	weights := make([][][]float64, numClients)
+	for i := range weights {
+		weights[i] = make([][]float64, numTensors)
+		for j := range weights[i] {
+			weights[i][j] = make([]float64, params.Slots())
+			for k := range weights[i][j] {
+				weights[i][j][k] = utils.RandFloat64(0, 0.1)
+				if i == numClients-1 {
+					weights[i][j][k] = -weights[i][j][k]
+				}
+			}
+		}
+	}
+
+	encrypt := func(values []float64) *RtF.Ciphertext {
+		complexValues := make([]complex128, len(values))
+		for k, v := range values {
+			complexValues[k] = complex(v, 0)
+		}
+		ct, err := encryptor.EncryptNew(encoder.EncodeComplexNew(complexValues, params.LogSlots()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ct
+	}
+
+	clientIDs := []string{"do1", "do2", "do3"}
+	ciphertexts := make([][]*RtF.Ciphertext, numClients)
+	for i := range ciphertexts {
+		ciphertexts[i] = make([]*RtF.Ciphertext, numTensors)
+		for j := range ciphertexts[i] {
+			ciphertexts[i][j] = encrypt(weights[i][j])
+		}
+	}
+
+	// Same aggregation as the server, without rescaling after the multiplication by 1/n
+	avg := make([]*RtF.Ciphertext, numTensors)
+	plainAvg := make([][]float64, numTensors)
+	for j := range avg {
+		avg[j] = ciphertexts[0][j].CopyNew().Ciphertext()
+		for i := 1; i < numClients; i++ {
+			avg[j] = evaluator.AddNew(avg[j], ciphertexts[i][j])
+		}
+		avg[j] = evaluator.MultByConstNew(avg[j], 1/float64(numClients))
+
+		plainAvg[j] = make([]float64, params.Slots())
+		for k := range plainAvg[j] {
+			for i := range weights {
+				plainAvg[j][k] += weights[i][j][k] / float64(numClients)
+			}
+		}
+	}
+
+	logger := utils.NewLogger(false)
+	d, err := Compute(logger, params, evaluator, clientIDs, ciphertexts, avg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports := Decrypt(logger, params, encoder, decryptor, d)
+
+	dot := func(a, b [][]float64) (res float64) {
+		for j := range a {
+			for k := range a[j] {
+				res += a[j][k] * b[j][k]
+			}
+		}
+		return
+	}
+	avgSquaredNorm := dot(plainAvg, plainAvg)
+	for i, report := range reports {
+		squaredNorm := dot(weights[i], weights[i])
+		cosine := dot(weights[i], plainAvg) / math.Sqrt(squaredNorm*avgSquaredNorm)
+		if math.Abs(report.SquaredNorm-squaredNorm) > 1e-3*squaredNorm {
+			t.Errorf("client %s squared norm: got %f, want %f", report.ClientID, report.SquaredNorm, squaredNorm)
+		}
+		if math.Abs(report.CosineSimilarity-cosine) > 1e-3 {
+			t.Errorf("client %s cosine similarity: got %f, want %f", report.ClientID, report.CosineSimilarity, cosine)
+		}
+	}
+	if reports[numClients-1].CosineSimilarity >= 0 {
+		t.Errorf("outlier client not detected: cosine similarity %f", reports[numClients-1].CosineSimilarity)
+	}
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
-	FLRubato "flhhe"
+	"flhhe/configs"
 	"flhhe/src/RtF"
+	"flhhe/src/experiment"
+	"flhhe/src/he_fedavg/baseline"
+	"flhhe/src/metrics"
 	"flhhe/src/utils"
 
+	"flhhe/src/hhe_fedavg/bandwidth"
 	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/diagnostics"
 	"flhhe/src/hhe_fedavg/keys_dealer"
 	"flhhe/src/hhe_fedavg/server"
 )
@@ -51,22 +61,98 @@ import (
 */
 //================= How Rubato Works End =================//
 func main() {
+	configPath := flag.String("config", "", "experiment configuration (default "+experiment.DefaultConfig+")")
+	flag.Parse()
+
 	logger := utils.NewLogger(utils.DEBUG)
-	rootPath := FLRubato.FindRootPath()
-	paramIndex := RtF.RUBATO128L
+	cfg, err := experiment.LoadOrDefault(*configPath)
+	utils.HandleError(err)
+	utils.HandleError(cfg.CheckWeights())
+	utils.HandleError(cfg.ApplyRuntime())
+	RtF.SetPRNGLogger(logger)
+	if cfg.Scheme == experiment.SchemeHE {
+		runHE(logger, cfg)
+		return
+	}
+	rootPath := cfg.Root
+	cipher, err := cfg.SymmetricCipher()
+	utils.HandleError(err)
+	configCopy, err := cfg.Save("hhe")
+	utils.HandleError(err)
+	logger.PrintFormatted("Experiment configuration saved to %s", configCopy)
+
+	packing, err := keys_dealer.ParsePacking(cfg.Packing)
+	utils.HandleError(err)
+
+	if cfg.MetricsAddr != "" {
+		metricsServer, err := metrics.Default().Serve(cfg.MetricsAddr)
+		utils.HandleError(err)
+		defer metricsServer.Close()
+		logger.PrintFormatted("Serving the metrics on http://%s/metrics", cfg.MetricsAddr)
+	}
 
 	t := time.Now()
 
-	rubatoParams, hheComponents, rubato := keys_dealer.RunKeysDealer(logger, rootPath, paramIndex)
+	rubatoParams, hheComponents, rubato, err := keys_dealer.RunKeysDealer(logger, rootPath, cipher, packing, cfg.Bootstrap, false)
+	utils.HandleError(err)
 	logger.PrintFormatted("Rubato Parameters: %+v", rubatoParams)
 	logger.PrintFormatted("HHE Components: %+v", hheComponents)
-	logger.PrintFormatted("Rubato Instance Addr: %+v", &rubato)
+	logger.PrintFormatted("%s Instance Addr: %+v", cipher.Name(), &rubato)
+
+	// The experiments run a single round, the round 0. The clients register their identity key with the
+	// keys dealer, the server verifies the signatures of their uploads with it.
+	flClients := make([]*client.FLClient, len(cfg.Clients))
+	for i, c := range cfg.Clients {
+		_, err = client.Register(logger, rootPath, c.ID)
+		utils.HandleError(err)
+		flClients[i], err = client.RunFLClient(logger, rootPath, rubatoParams, hheComponents, c.Weights, c.ID, 0)
+		utils.HandleError(err)
+	}
+	verifier, err := server.LoadVerifier(filepath.Join(rootPath, configs.Keys), 0, "")
+	utils.HandleError(err)
+	// The clients the server skips are left out of the average and of the diagnostics
+	clientIDs, err := server.RunFLServer(logger, rootPath, flClients, rubatoParams, hheComponents, rubato, verifier)
+	if errors.Is(err, server.ErrClientSkipped) && clientIDs != nil {
+		logger.PrintFormatted("Aggregated %d of %d clients: %v", len(clientIDs), len(flClients), err)
+	} else {
+		utils.HandleError(err)
+	}
+
+	// The key holder decrypts the client diagnostics released by the server
+	diagnosticsDir := filepath.Join(rootPath, configs.Diagnostics)
+	if _, err := os.Stat(diagnosticsDir); err == nil {
+		d, err := diagnostics.Load(diagnosticsDir, clientIDs, rubatoParams.Params)
+		utils.HandleError(err)
+		diagnostics.Decrypt(logger, rubatoParams.Params, hheComponents.CkksEncoder, hheComponents.CkksDecryptor, d)
+	}
 
-	flClients := make([]*client.FLClient, 3)
-	flClients[0] = client.RunFLClient(logger, rootPath, rubatoParams, hheComponents, "weights_no_137.json", "do1")
-	flClients[1] = client.RunFLClient(logger, rootPath, rubatoParams, hheComponents, "weights_no_258.json", "do2")
-	flClients[2] = client.RunFLClient(logger, rootPath, rubatoParams, hheComponents, "weights_no_469.json", "do3")
-	server.RunFLServer(logger, rootPath, flClients, rubatoParams, hheComponents, rubato)
+	logger.PrintRunningTime("Total time to run the program", t)
+
+	// The bytes exchanged by the roles, against the plaintext weights and the HE baseline
+	aggregatedClients := make([]experiment.Client, 0, len(clientIDs))
+	for _, c := range cfg.Clients {
+		if slices.Contains(clientIDs, c.ID) {
+			aggregatedClients = append(aggregatedClients, c)
+		}
+	}
+	traffic, err := bandwidth.Account(logger, rootPath, rubatoParams, aggregatedClients, cfg.CKKSParams)
+	utils.HandleError(err)
+	traffic.Print(logger)
+	utils.HandleError(traffic.Save(cfg.BandwidthPath("hhe")))
+	logger.PrintFormatted("Bandwidth report saved to %s", cfg.BandwidthPath("hhe"))
 
+	report, err := cfg.SaveMetrics("hhe")
+	utils.HandleError(err)
+	logger.PrintFormatted("Metrics report saved to %s", report)
+}
+
+// runHE runs the HE baseline of src/he_fedavg, the scheme of the experiment instead of HHE
+func runHE(logger utils.Logger, cfg *experiment.Experiment) {
+	t := time.Now()
+	// RunHEFedAvg saves the configuration itself
+	utils.HandleError(baseline.RunHEFedAvg(logger, cfg))
 	logger.PrintRunningTime("Total time to run the program", t)
+	report, err := cfg.SaveMetrics("he")
+	utils.HandleError(err)
+	logger.PrintFormatted("Metrics report saved to %s", report)
 }
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"encoding/json"
 	FLRubato "flhhe"
 	"flhhe/configs"
 	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
 	"flhhe/src/hhe_fedavg/keys_dealer"
 	"flhhe/src/hhe_fedavg/server"
 	"flhhe/src/utils"
 	"fmt"
 	"math"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -28,30 +31,35 @@ func loadDecryptCompare(
 	avgCiphertextsDir := filepath.Join(rootPath, configs.HEEncryptedWeights, "avg")
 	logger.PrintFormatted("Avg ciphertexts dir (from the HHE protocol): %s", avgCiphertextsDir)
 
-	plainHEDecryptedAvgWeights := utils.LoadFromJSON(logger, plainHEDecryptedAvgWeightsDir, fmt.Sprintf("he_decrypted_avg_fc%d.json", weightIndex))
+	plainHEDecryptedAvgWeights, err := utils.LoadFromJSON(logger, plainHEDecryptedAvgWeightsDir, fmt.Sprintf("he_decrypted_avg_fc%d.json", weightIndex))
+	utils.HandleError(err)
 	logger.PrintFormatted("Plaintext avg weights type: %T and length: %d", plainHEDecryptedAvgWeights, len(plainHEDecryptedAvgWeights))
 
 	logger.PrintFormatted("Rubato params num slots: %d", rubatoParams.Params.Slots())
 
-	plainHEDecryptedAvgWeightsComplex := make([]complex128, rubatoParams.Params.Slots())
+	// The two HalfBoot outputs together hold the N values of each output
+	plainHEDecryptedAvgWeightsComplex := make([]complex128, rubatoParams.Params.N())
 	for i := range len(plainHEDecryptedAvgWeights) {
 		plainHEDecryptedAvgWeightsComplex[i] = complex(plainHEDecryptedAvgWeights[i], 0)
 	}
 
 	// Load and decrypt the heAvgWeights
 	logger.PrintMessage("--- Decrypting the HE ciphertexts of the avg weights from the HHE protocol ---")
-	heAvgWeights := server.LoadCipher(logger, weightIndex-1, avgCiphertextsDir, rubatoParams.Params)
+	heAvgWeightsHalves, err := server.LoadOutput(logger, weightIndex-1, rubatoParams.Packing, avgCiphertextsDir, rubatoParams.Params)
+	utils.HandleError(err)
+	heAvgWeights := heAvgWeightsHalves[0]
 
 	ckksEncoder := hheComponents.CkksEncoder
 	ckksDecryptor := hheComponents.CkksDecryptor
 	t := time.Now()
-	decryptedAvgWeights := ckksEncoder.DecodeComplex(ckksDecryptor.DecryptNew(heAvgWeights), rubatoParams.Params.LogSlots())
+	decryptedAvgWeights := server.DecryptOutput(rubatoParams.Params, heAvgWeightsHalves, ckksDecryptor, ckksEncoder)
 	logger.PrintRunningTime("Time to decrypt the HE ciphertexts of the avg weights from the HHE protocol", t)
 	logger.PrintFormatted("Decrypted avg weights type: %T and length: %d", decryptedAvgWeights, len(decryptedAvgWeights))
 
-	// Calculate the error
+	// Calculate the error, on each half since the precision statistics are computed in the slots
 	logger.PrintMessage("--- Calculating the error ---")
 	logSlots := rubatoParams.Params.LogSlots()
+	slots := rubatoParams.Params.Slots()
 	sigma := rubatoParams.Params.Sigma()
 
 	logger.PrintFormatted("Level: %d (logQ = %d)", heAvgWeights.Level(), rubatoParams.Params.LogQLvl(heAvgWeights.Level()))
@@ -61,46 +69,162 @@ func loadDecryptCompare(
 	logger.PrintFormatted("plaintextAvgWeights{%d}: [%6.10f %6.10f %6.10f %6.10f...]",
 		len(plainHEDecryptedAvgWeightsComplex), plainHEDecryptedAvgWeightsComplex[0], plainHEDecryptedAvgWeightsComplex[1], plainHEDecryptedAvgWeightsComplex[2], plainHEDecryptedAvgWeightsComplex[3])
 
-	precisionStats := RtF.GetPrecisionStats(rubatoParams.Params, ckksEncoder, nil, plainHEDecryptedAvgWeightsComplex, decryptedAvgWeights, logSlots, sigma)
-	fmt.Println(precisionStats.String())
-
-	// Assert that precision values are in good range
-	minRealThreshold := 18.0 // Log2
-	minImagThreshold := 31.0 // Log2
-	if real(precisionStats.MinPrecision) < minRealThreshold || imag(precisionStats.MinPrecision) < minImagThreshold {
-		panic(fmt.Sprintf("Minimum precision below threshold: got (%.2f, %.2f), want at least (%.2f, %.2f)",
-			real(precisionStats.MinPrecision), imag(precisionStats.MinPrecision), minRealThreshold, minImagThreshold))
-	}
-
-	avgRealThreshold := 21.0 // Log2
-	avgImagThreshold := 36.0 // Log2
-	if real(precisionStats.MeanPrecision) < avgRealThreshold || imag(precisionStats.MeanPrecision) < avgImagThreshold {
-		panic(fmt.Sprintf("Average precision below threshold: got (%.2f, %.2f), want at least (%.2f, %.2f)",
-			real(precisionStats.MeanPrecision), imag(precisionStats.MeanPrecision), avgRealThreshold, avgImagThreshold))
-	}
-
-	// Check error standard deviation (lower is better)
-	errStdFThreshold := 24.0 // Log2
-	errStdTThreshold := 16.0 // Log2
-	if math.Log2(precisionStats.STDFreq) > errStdFThreshold {
-		panic(fmt.Sprintf("Error stdF too high: got %.2f, want at most %.2f",
-			math.Log2(precisionStats.STDFreq), errStdFThreshold))
-	}
-	if math.Log2(precisionStats.STDTime) > errStdTThreshold {
-		panic(fmt.Sprintf("Error stdT too high: got %.2f, want at most %.2f",
-			math.Log2(precisionStats.STDTime), errStdTThreshold))
+	for half := range rubatoParams.Packing.NbHalves() {
+		want := plainHEDecryptedAvgWeightsComplex[half*slots : (half+1)*slots]
+		have := decryptedAvgWeights[half*slots : (half+1)*slots]
+		precisionStats, err := RtF.GetPrecisionStats(rubatoParams.Params, ckksEncoder, nil, want, have, logSlots, sigma)
+		utils.HandleError(err)
+		fmt.Println(precisionStats.String())
+
+		// Assert that precision values are in good range
+		minRealThreshold := 18.0 // Log2
+		minImagThreshold := 31.0 // Log2
+		if real(precisionStats.MinPrecision) < minRealThreshold || imag(precisionStats.MinPrecision) < minImagThreshold {
+			panic(fmt.Sprintf("Minimum precision below threshold: got (%.2f, %.2f), want at least (%.2f, %.2f)",
+				real(precisionStats.MinPrecision), imag(precisionStats.MinPrecision), minRealThreshold, minImagThreshold))
+		}
+
+		avgRealThreshold := 21.0 // Log2
+		avgImagThreshold := 36.0 // Log2
+		if real(precisionStats.MeanPrecision) < avgRealThreshold || imag(precisionStats.MeanPrecision) < avgImagThreshold {
+			panic(fmt.Sprintf("Average precision below threshold: got (%.2f, %.2f), want at least (%.2f, %.2f)",
+				real(precisionStats.MeanPrecision), imag(precisionStats.MeanPrecision), avgRealThreshold, avgImagThreshold))
+		}
+
+		// Check error standard deviation (lower is better)
+		errStdFThreshold := 24.0 // Log2
+		errStdTThreshold := 16.0 // Log2
+		if math.Log2(precisionStats.STDFreq) > errStdFThreshold {
+			panic(fmt.Sprintf("Error stdF too high: got %.2f, want at most %.2f",
+				math.Log2(precisionStats.STDFreq), errStdFThreshold))
+		}
+		if math.Log2(precisionStats.STDTime) > errStdTThreshold {
+			panic(fmt.Sprintf("Error stdT too high: got %.2f, want at most %.2f",
+				math.Log2(precisionStats.STDTime), errStdTThreshold))
+		}
 	}
 
-	utils.SaveComplexToJSON(logger, plainHEDecryptedAvgWeightsDir, fmt.Sprintf("hhe_decrypted_avg_fc%d.json", weightIndex), decryptedAvgWeights)
+	utils.HandleError(utils.SaveComplexToJSON(logger, plainHEDecryptedAvgWeightsDir, fmt.Sprintf("hhe_decrypted_avg_fc%d.json", weightIndex), decryptedAvgWeights))
 }
 
+// TestHHEFedAvg needs the artifacts of `just run-hhe` and the 128-bit parameters (64 GB of memory)
 func TestHHEFedAvg(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the test of the full-size HHE artifacts in short mode")
+	}
 	logger := utils.NewLogger(utils.DEBUG)
 	rootPath := FLRubato.FindRootPath()
 
-	paramIndex := RtF.RUBATO128L
-	rubatoParams, hheComponents, _ := keys_dealer.RunKeysDealer(logger, rootPath, paramIndex)
+	cipher := RtF.NewRubatoCipher(RtF.RUBATO128L)
+	rubatoParams, hheComponents, _, err := keys_dealer.RunKeysDealer(logger, rootPath, cipher, keys_dealer.PackingCoefficients, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	loadDecryptCompare(logger, rootPath, 1, rubatoParams, hheComponents) // test avgFC1
 	loadDecryptCompare(logger, rootPath, 2, rubatoParams, hheComponents) // test avgFC2
 }
+
+// Precision thresholds of the toy end-to-end run (log2), the CKKS error doesn't depend on the ring
+// degree much but the toy rings average it over fewer coefficients
+const (
+	toyMinPrecision  = 14.0
+	toyMeanPrecision = 17.0
+)
+
+// TestHHEFedAvgToy runs keys generation, client encryption, transciphering, aggregation and decryption
+// on the INSECURE toy parameters with synthetic weights, in a temporary root. The checks of each step
+// live in the tests of its package.
+func TestHHEFedAvgToy(t *testing.T) {
+	logger := utils.NewLogger(false)
+	rootPath := t.TempDir()
+	cipher := RtF.NewToyRubatoCipher(RtF.RUBATO128L, RtF.RtFToyN10)
+	packing := keys_dealer.PackingCoefficients
+
+	// Synthetic weights, FC1 spans both HalfBoot outputs of the N = 1024 coefficients, FC2 fits in one
+	clientIDs := []string{"do1", "do2", "do3"}
+	weightsDir := filepath.Join(rootPath, configs.PlaintextWeights)
+	if err := os.MkdirAll(weightsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	randomMatrix := func(rows, cols int) [][]float64 {
+		m := make([][]float64, rows)
+		for i := range m {
+			m[i] = make([]float64, cols)
+			for j := range m[i] {
+				m[i][j] = utils.RandFloat64(-1, 1)
+			}
+		}
+		return m
+	}
+	var fc1, fc2 [][]float64
+	for _, id := range clientIDs {
+		w := map[string][][]float64{"fc1": randomMatrix(12, 64), "fc2": randomMatrix(10, 16)}
+		data, err := json.Marshal(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join(weightsDir, id+".json"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		fc1 = append(fc1, utils.Flatten2D(w["fc1"]))
+		fc2 = append(fc2, utils.Flatten2D(w["fc2"]))
+	}
+
+	start := time.Now()
+	rubatoParams, hheComponents, rubato, err := keys_dealer.RunKeysDealer(logger, rootPath, cipher, packing, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flClients := make([]*client.FLClient, len(clientIDs))
+	for i, id := range clientIDs {
+		if _, err = client.Register(logger, rootPath, id); err != nil {
+			t.Fatal(err)
+		}
+		if flClients[i], err = client.RunFLClient(logger, rootPath, rubatoParams, hheComponents, id+".json", id, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	verifier, err := server.LoadVerifier(filepath.Join(rootPath, configs.Keys), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregated, err := server.RunFLServer(logger, rootPath, flClients, rubatoParams, hheComponents, rubato, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(aggregated) != fmt.Sprint(clientIDs) {
+		t.Errorf("RunFLServer aggregated %v, want %v", aggregated, clientIDs)
+	}
+	t.Logf("%s: HHE FedAvg of %d clients in %s", cipher.Name(), len(clientIDs), time.Since(start))
+
+	params := rubatoParams.Params
+	avgDir := filepath.Join(rootPath, configs.HEEncryptedWeights, "avg")
+	for s, layer := range [][][]float64{fc1, fc2} {
+		want := make([]complex128, params.FVSlots())
+		for _, values := range layer {
+			for i, v := range values {
+				want[i] += complex(v/float64(len(layer)), 0)
+			}
+		}
+		output, err := server.LoadOutput(logger, s, packing, avgDir, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := server.DecryptOutput(params, output, hheComponents.CkksDecryptor, hheComponents.CkksEncoder)
+
+		slots := params.Slots()
+		for half := range packing.NbHalves() {
+			stats, err := RtF.GetPrecisionStats(params, hheComponents.CkksEncoder, nil,
+				want[half*slots:(half+1)*slots], have[half*slots:(half+1)*slots], params.LogSlots(), params.Sigma())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("avgFC%d half %d: min precision %.2f, mean precision %.2f", s+1, half, real(stats.MinPrecision), real(stats.MeanPrecision))
+			if real(stats.MinPrecision) < toyMinPrecision || real(stats.MeanPrecision) < toyMeanPrecision {
+				t.Errorf("avgFC%d half %d: precision (min %.2f, mean %.2f) below (%.2f, %.2f)", s+1, half,
+					real(stats.MinPrecision), real(stats.MeanPrecision), toyMinPrecision, toyMeanPrecision)
+			}
+		}
+	}
+}
@@ -0,0 +1,482 @@
+// Package inference is the inference engine: it evaluates the aggregated MNIST model FC1 -> activation
+// -> FC2 on encrypted inputs with the encrypted average weights produced by the HHE FedAvg protocol.
+//
+// Trust model: the evaluator (Engine) only holds the public key, the evaluation keys and the encrypted
+// weights, it never sees the weights, the inputs or the logits in the clear. The data owner encrypts its
+// inputs with the public key (EncryptInput), and the key holder, the holder of the secret key who also
+// decrypts the average of the protocol, decrypts the logits only (LogitsDecryptor). The key holder could
+// decrypt the weights and, with the ciphertexts, the inputs: it must not collude with the evaluator.
+package inference
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/hhe_fedavg/server"
+	"flhhe/src/utils"
+)
+
+// ModelShape describes the dimensions of the FC1 -> activation -> FC2 network
+type ModelShape struct {
+	InputSize  int
+	HiddenSize int
+	OutputSize int
+}
+
+// MNISTShape is the shape of the SimpleMNISTModel trained by the Python clients
+var MNISTShape = ModelShape{InputSize: 784, HiddenSize: 32, OutputSize: 10}
+
+// DefaultActivationBound is the interval [-B, B] on which the ReLU is approximated
+const DefaultActivationBound = 8.0
+
+// ForwardDepth is the number of levels consumed by Forward: the product of FC1, the activation (2), the
+// gather of the hidden values and the product of FC2. The weights must be at this level at least.
+const ForwardDepth = 5
+
+// Slot layout of the encrypted tensors:
+//
+//	fc1:    slot h*InputSize + i  = W1[h][i]  (row-major, as produced by client.PreparingData)
+//	fc2:    slot o*HiddenSize + h = W2[o][h]
+//	input:  slot i                = x[i]
+//	hidden: slot h                = act(FC1(x))[h]
+//	logits: slot o*HiddenSize     = y[o]
+//
+// Each layer is a product of two ciphertexts: the input vector is replicated on every row of the
+// row-major weights (rotations), multiplied slot-wise with them (MulRelin), and the products of each row
+// are summed into its first slot (InnerSum). The first slots of the rows of FC1 are gathered into the
+// hidden vector with LinearTransform, by the plaintext matrix of diagonals e_h at index h*(InputSize-1).
+
+// Engine evaluates the MNIST model on encrypted inputs with the encrypted aggregated weights, without
+// the secret key
+type Engine struct {
+	params     *RtF.Parameters
+	shape      ModelShape
+	encoder    RtF.CKKSEncoder
+	encryptor  RtF.CKKSEncryptor
+	evaluator  RtF.CKKSEvaluator
+	activation *RtF.Poly
+	fc1        *RtF.Ciphertext
+	fc2        *RtF.Ciphertext
+	gather     *diagMatrix
+}
+
+// diagMatrix is the diagonal form of a plaintext matrix, encoded at the levels it is evaluated at
+type diagMatrix struct {
+	diagonals map[int][]complex128
+	encoded   map[int]*RtF.PtDiagMatrix // indexed by level
+}
+
+// ReLUApproximation returns the least-squares degree 2 approximation of ReLU on [-bound, bound],
+// i.e. ReLU(x) ~ 3B/32 + x/2 + 15x^2/(32B), obtained from |x| ~ 3/16 + 15x^2/16 on [-1, 1].
+func ReLUApproximation(bound float64) *RtF.Poly {
+	return RtF.NewPoly([]complex128{
+		complex(3*bound/32, 0),
+		complex(0.5, 0),
+		complex(15/(32*bound), 0),
+	})
+}
+
+// evaluateActivation evaluates the ReLU approximation on a plaintext value
+func evaluateActivation(x, bound float64) float64 {
+	return 3*bound/32 + x/2 + 15*x*x/(32*bound)
+}
+
+// checkShape verifies that the packed tensors, with their rows replicated to a power of two, fit into
+// the slots of one ciphertext
+func checkShape(params *RtF.Parameters, shape ModelShape) error {
+	slots := params.Slots()
+	if n := nextPowerOfTwo(shape.HiddenSize) * shape.InputSize; n > slots {
+		return fmt.Errorf("FC1 needs %d slots but only %d are available", n, slots)
+	}
+	if n := nextPowerOfTwo(shape.OutputSize) * shape.HiddenSize; n > slots {
+		return fmt.Errorf("FC2 needs %d slots but only %d are available", n, slots)
+	}
+	return nil
+}
+
+func nextPowerOfTwo(n int) int {
+	return 1 << bits.Len(uint(n-1))
+}
+
+// gatherDiagonals returns the diagonals of the matrix moving the slot r*stride to the slot r, for r < rows
+func gatherDiagonals(params *RtF.Parameters, rows, stride int) map[int][]complex128 {
+	slots := params.Slots()
+	diagonals := make(map[int][]complex128)
+	for r := range rows {
+		diagonal := make([]complex128, slots)
+		diagonal[r] = 1
+		diagonals[r*(stride-1)&(slots-1)] = diagonal
+	}
+	return diagonals
+}
+
+// replicateRotations returns the rotations replicating a vector of cols slots on rows rows, rounded up to
+// a power of two: the rows past rows are not read
+func replicateRotations(params *RtF.Parameters, rows, cols int) []int {
+	rotations := []int{}
+	for n := 1; n < rows; n <<= 1 {
+		rotations = append(rotations, -n*cols&(params.Slots()-1))
+	}
+	return rotations
+}
+
+// GenRotationIndexes returns all the rotations the engine needs, to be used with
+// GenRotationKeysForRotations by the holder of the secret key.
+func GenRotationIndexes(kgen RtF.KeyGenerator, params *RtF.Parameters, encoder RtF.CKKSEncoder, shape ModelShape) []int {
+	rotations := replicateRotations(params, shape.HiddenSize, shape.InputSize)
+	rotations = append(rotations, kgen.GenRotationIndexesForInnerSum(1, shape.InputSize)...)
+	// The rotations of the BSGS algorithm only depend on the diagonals present, so level 0 is enough
+	gather := encoder.EncodeDiagMatrixAtLvl(0, gatherDiagonals(params, shape.HiddenSize, shape.InputSize), params.Scale(), 16.0, params.LogSlots())
+	rotations = append(rotations, kgen.GenRotationIndexesForDiagMatrix(gather)...)
+	rotations = append(rotations, replicateRotations(params, shape.OutputSize, shape.HiddenSize)...)
+	rotations = append(rotations, kgen.GenRotationIndexesForInnerSum(1, shape.HiddenSize)...)
+
+	unique := make([]int, 0, len(rotations))
+	for _, k := range rotations {
+		if !utils.IsInSliceInt(k, unique) {
+			unique = append(unique, k)
+		}
+	}
+	return unique
+}
+
+// InferenceKeysGen generates and saves the rotation keys of the inference engine.
+// It is run once by the key holder, next to the keys of the keys dealer. It returns an
+// RtF.ErrCorruptArtifact error if the secret key can't be decoded.
+func InferenceKeysGen(logger utils.Logger, keysDir string, params *RtF.Parameters, shape ModelShape) error {
+	logger.PrintMessage("[Key Holder] Inference keys generation")
+
+	rotKeysPath := filepath.Join(keysDir, configs.InferenceRotationKeys)
+	if _, err := os.Stat(rotKeysPath); err == nil {
+		logger.PrintFormatted("Inference rotation keys already exist in %s, skipping keys generation", rotKeysPath)
+		return nil
+	}
+
+	sk := new(RtF.SecretKey)
+	if err := keys_dealer.Deserialize(sk, filepath.Join(keysDir, configs.SecretKey)); err != nil {
+		return err
+	}
+
+	kgen := RtF.NewKeyGenerator(params)
+	rotations := GenRotationIndexes(kgen, params, RtF.NewCKKSEncoder(params), shape)
+	logger.PrintFormatted("Inference rotations: %v", rotations)
+
+	t := time.Now()
+	rotKeys, err := kgen.GenRotationKeysForRotations(rotations, false, sk)
+	if err != nil {
+		return err
+	}
+	logger.PrintMemUsage("Inference Rotation Keys Generation")
+	logger.PrintRunningTime("Inference Rotation Keys Generation", t)
+	return utils.Serialize(rotKeys, rotKeysPath)
+}
+
+// NewEngine loads the public key and the inference evaluation keys from keysDir and prepares the engine
+// for the encrypted average weights fc1 and fc2 (e.g. the "avg" ciphertexts of the HHE FedAvg server).
+// It doesn't read the secret key. It returns an RtF.ErrCorruptArtifact error if a key can't be decoded,
+// and an RtF.ErrInsufficientLevels error if the weights are under ForwardDepth.
+func NewEngine(
+	logger utils.Logger,
+	keysDir string,
+	params *RtF.Parameters,
+	shape ModelShape,
+	activationBound float64,
+	fc1, fc2 *RtF.Ciphertext,
+) (*Engine, error) {
+	logger.PrintMessage("[Evaluator] Initializing the inference engine")
+
+	pk := new(RtF.PublicKey)
+	if err := keys_dealer.Deserialize(pk, filepath.Join(keysDir, configs.PublicKey)); err != nil {
+		return nil, err
+	}
+	rlk := new(RtF.RelinearizationKey)
+	if err := keys_dealer.Deserialize(rlk, filepath.Join(keysDir, configs.RelinearizationKeys)); err != nil {
+		return nil, err
+	}
+	rotKeys := new(RtF.RotationKeySet)
+	if err := keys_dealer.Deserialize(rotKeys, filepath.Join(keysDir, configs.InferenceRotationKeys)); err != nil {
+		return nil, err
+	}
+	logger.PrintMemUsage("Reading inference keys")
+
+	engine, err := newEngine(params, shape, activationBound, pk, RtF.EvaluationKey{Rlk: rlk, Rtks: rotKeys}, fc1, fc2)
+	if err != nil {
+		return nil, err
+	}
+	logger.PrintFormatted("FC1 ciphertext: level %d, scale 2^%f", fc1.Level(), math.Log2(fc1.Scale()))
+	logger.PrintFormatted("FC2 ciphertext: level %d, scale 2^%f", fc2.Level(), math.Log2(fc2.Scale()))
+
+	return engine, nil
+}
+
+func newEngine(
+	params *RtF.Parameters,
+	shape ModelShape,
+	activationBound float64,
+	pk *RtF.PublicKey,
+	evk RtF.EvaluationKey,
+	fc1, fc2 *RtF.Ciphertext,
+) (*Engine, error) {
+	if err := checkShape(params, shape); err != nil {
+		return nil, err
+	}
+	if fc1.Level() < ForwardDepth {
+		return nil, fmt.Errorf("%w: FC1 is at level %d, the inference needs %d", RtF.ErrInsufficientLevels, fc1.Level(), ForwardDepth)
+	}
+	if fc2.Level() < 1 {
+		return nil, fmt.Errorf("%w: FC2 is at level %d, the inference needs 1", RtF.ErrInsufficientLevels, fc2.Level())
+	}
+	return &Engine{
+		params:     params,
+		shape:      shape,
+		encoder:    RtF.NewCKKSEncoder(params),
+		encryptor:  RtF.NewCKKSEncryptorFromPk(params, pk),
+		evaluator:  RtF.NewCKKSEvaluator(params, evk),
+		activation: ReLUApproximation(activationBound),
+		fc1:        fc1,
+		fc2:        fc2,
+		gather: &diagMatrix{
+			diagonals: gatherDiagonals(params, shape.HiddenSize, shape.InputSize),
+			encoded:   make(map[int]*RtF.PtDiagMatrix),
+		},
+	}, nil
+}
+
+// EncryptInput encrypts the input vector with the public key, at the maximum level: the data owner
+// doesn't depend on the level of the weights, Forward evaluates the product of FC1 at theirs
+func (e *Engine) EncryptInput(x []float64) (*RtF.Ciphertext, error) {
+	if len(x) != e.shape.InputSize {
+		return nil, fmt.Errorf("input has %d values, want %d", len(x), e.shape.InputSize)
+	}
+	values := make([]complex128, e.params.Slots())
+	for i := range e.shape.InputSize {
+		values[i] = complex(x[i], 0)
+	}
+	pt := e.encoder.EncodeComplexAtLvlNew(e.params.MaxLevel(), values, e.params.LogSlots())
+	return e.encryptor.EncryptNew(pt)
+}
+
+// Forward evaluates FC2(act(FC1(x))) on an encrypted input. The returned ciphertext holds the
+// logit of class o in slot o*HiddenSize.
+func (e *Engine) Forward(ctIn *RtF.Ciphertext) (*RtF.Ciphertext, error) {
+	// The input is only read at the level of FC1
+	ct := ctIn.CopyNew().Ciphertext()
+	if ct.Level() > e.fc1.Level() {
+		e.evaluator.DropLevel(ct, ct.Level()-e.fc1.Level())
+	}
+	ct, err := e.multiply(ct, e.fc1, e.shape.HiddenSize, e.shape.InputSize)
+	if err != nil {
+		return nil, fmt.Errorf("FC1: %w", err)
+	}
+
+	// Activation: evaluated slot-wise, only the first slot of each row is gathered
+	if ct, err = e.evaluator.EvaluatePoly(ct, e.activation, e.params.Scale()); err != nil {
+		return nil, fmt.Errorf("activation: %w", err)
+	}
+	if ct.Level() == 0 {
+		return nil, fmt.Errorf("gather: %w: no level left", RtF.ErrInsufficientLevels)
+	}
+	ct = e.evaluator.LinearTransform(ct, e.encodeGather(ct.Level()))[0]
+	if err = e.evaluator.Rescale(ct, e.params.Scale(), ct); err != nil {
+		return nil, fmt.Errorf("gather: %w", err)
+	}
+
+	if ct, err = e.multiply(ct, e.fc2, e.shape.OutputSize, e.shape.HiddenSize); err != nil {
+		return nil, fmt.Errorf("FC2: %w", err)
+	}
+	return ct, nil
+}
+
+// multiply evaluates the product of the encrypted rows x cols weights with the vector of the first cols
+// slots of ct: ct is replicated on every row, multiplied by the weights, and each row is summed into its
+// first slot. The other slots hold partial sums.
+func (e *Engine) multiply(ct, weights *RtF.Ciphertext, rows, cols int) (*RtF.Ciphertext, error) {
+	if ct.Level() == 0 || weights.Level() == 0 {
+		return nil, fmt.Errorf("%w: no level left", RtF.ErrInsufficientLevels)
+	}
+	replicated := ct.CopyNew().Ciphertext()
+	for _, k := range replicateRotations(e.params, rows, cols) {
+		e.evaluator.Add(replicated, e.evaluator.RotateNew(replicated, k), replicated)
+	}
+	res := e.evaluator.MulRelinNew(replicated, weights)
+	if err := e.evaluator.Rescale(res, e.params.Scale(), res); err != nil {
+		return nil, err
+	}
+	e.evaluator.InnerSum(res, 1, cols, res)
+	return res, nil
+}
+
+// encodeGather returns the gather matrix encoded at the given level, with a scale equal to the modulus
+// dropped by the following rescale so that the ciphertext scale is preserved
+func (e *Engine) encodeGather(level int) *RtF.PtDiagMatrix {
+	if matrix, ok := e.gather.encoded[level]; ok {
+		return matrix
+	}
+	scale := float64(e.params.Qi()[level])
+	matrix := e.encoder.EncodeDiagMatrixAtLvl(level, e.gather.diagonals, scale, 16.0, e.params.LogSlots())
+	e.gather.encoded[level] = matrix
+	return matrix
+}
+
+// LogitsDecryptor decrypts the logits output by Forward, with the secret key of the key holder
+type LogitsDecryptor struct {
+	params    *RtF.Parameters
+	shape     ModelShape
+	encoder   RtF.CKKSEncoder
+	decryptor RtF.CKKSDecryptor
+}
+
+// NewLogitsDecryptor creates the LogitsDecryptor of the key holder
+func NewLogitsDecryptor(params *RtF.Parameters, shape ModelShape, sk *RtF.SecretKey) *LogitsDecryptor {
+	return &LogitsDecryptor{
+		params:    params,
+		shape:     shape,
+		encoder:   RtF.NewCKKSEncoder(params),
+		decryptor: RtF.NewCKKSDecryptor(params, sk),
+	}
+}
+
+// DecryptLogits decrypts the output of Forward and extracts the OutputSize logits
+func (d *LogitsDecryptor) DecryptLogits(ct *RtF.Ciphertext) []float64 {
+	values := d.encoder.DecodeComplex(d.decryptor.DecryptNew(ct), d.params.LogSlots())
+	logits := make([]float64, d.shape.OutputSize)
+	for o := range d.shape.OutputSize {
+		logits[o] = real(values[o*d.shape.HiddenSize])
+	}
+	return logits
+}
+
+// Evaluate runs the encrypted inference on a test set and returns the accuracy in percent: each image is
+// encrypted as by its data owner, evaluated by the engine, and its logits are decrypted by the key holder
+func (e *Engine) Evaluate(logger utils.Logger, testSet *TestSet, decryptor *LogitsDecryptor) (float64, error) {
+	logger.PrintFormatted("[Evaluator] Encrypted inference on %d images", len(testSet.Images))
+	correct := 0
+	for n, x := range testSet.Images {
+		t := time.Now()
+		ctIn, err := e.EncryptInput(x)
+		if err != nil {
+			return 0, err
+		}
+		ctOut, err := e.Forward(ctIn)
+		if err != nil {
+			return 0, err
+		}
+		predicted := argmax(decryptor.DecryptLogits(ctOut))
+		if predicted == testSet.Labels[n] {
+			correct++
+		}
+		logger.PrintRunningTime(fmt.Sprintf("Image %d (label %d, predicted %d)", n, testSet.Labels[n], predicted), t)
+	}
+	accuracy := 100 * float64(correct) / float64(len(testSet.Images))
+	logger.PrintFormatted("Accuracy on the encrypted test set: %.2f%%", accuracy)
+	return accuracy, nil
+}
+
+// PlainForward is the plaintext reference of Forward, using the same activation approximation
+func PlainForward(fc1, fc2 [][]float64, x []float64, activationBound float64) []float64 {
+	hidden := make([]float64, len(fc1))
+	for h := range fc1 {
+		for i := range x {
+			hidden[h] += fc1[h][i] * x[i]
+		}
+		hidden[h] = evaluateActivation(hidden[h], activationBound)
+	}
+	logits := make([]float64, len(fc2))
+	for o := range fc2 {
+		for h := range hidden {
+			logits[o] += fc2[o][h] * hidden[h]
+		}
+	}
+	return logits
+}
+
+func argmax(values []float64) int {
+	best := 0
+	for i := range values {
+		if values[i] > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// TestSet is a flattened MNIST test set exported by flhhe.mnist.export_test_set
+type TestSet struct {
+	Images [][]float64 `json:"images"`
+	Labels []int       `json:"labels"`
+}
+
+// LoadTestSet loads an exported test set from a JSON file
+func LoadTestSet(path string) (*TestSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	testSet := new(TestSet)
+	if err = json.Unmarshal(data, testSet); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(%s): %w", path, err)
+	}
+	if len(testSet.Images) != len(testSet.Labels) {
+		return nil, fmt.Errorf("test set has %d images but %d labels", len(testSet.Images), len(testSet.Labels))
+	}
+	return testSet, nil
+}
+
+// Run generates the inference keys if they are missing, loads the average weights of the HHE FedAvg
+// run under the root of the experiment and the MNIST test set, and returns the accuracy of the
+// encrypted inference in percent. It plays the three roles of the trust model: only the LogitsDecryptor
+// of the key holder reads the secret key, not the engine.
+func Run(logger utils.Logger, cfg *experiment.Experiment, activationBound float64) (float64, error) {
+	cipher, err := cfg.SymmetricCipher()
+	if err != nil {
+		return 0, err
+	}
+	packing, err := keys_dealer.ParsePacking(cfg.Packing)
+	if err != nil {
+		return 0, err
+	}
+	rubatoParams, err := keys_dealer.InitRubatoParams(logger, cipher, packing)
+	if err != nil {
+		return 0, err
+	}
+	keysDir := filepath.Join(cfg.Root, configs.Keys)
+	if err = InferenceKeysGen(logger, keysDir, rubatoParams.Params, MNISTShape); err != nil {
+		return 0, err
+	}
+
+	avgCiphertextsDir := filepath.Join(cfg.Root, configs.HEEncryptedWeights, "avg")
+	// FC1 (784 x 32) and FC2 (32 x 10) fit in the first half of their outputs
+	fc1, err := server.LoadCipher(logger, server.CipherIndex(0, 0), avgCiphertextsDir, rubatoParams.Params)
+	if err != nil {
+		return 0, err
+	}
+	fc2, err := server.LoadCipher(logger, server.CipherIndex(1, 0), avgCiphertextsDir, rubatoParams.Params)
+	if err != nil {
+		return 0, err
+	}
+
+	engine, err := NewEngine(logger, keysDir, rubatoParams.Params, MNISTShape, activationBound, fc1, fc2)
+	if err != nil {
+		return 0, err
+	}
+	sk := new(RtF.SecretKey)
+	if err = keys_dealer.Deserialize(sk, filepath.Join(keysDir, configs.SecretKey)); err != nil {
+		return 0, err
+	}
+	decryptor := NewLogitsDecryptor(rubatoParams.Params, MNISTShape, sk)
+
+	testSet, err := LoadTestSet(filepath.Join(cfg.Root, configs.MNIST, configs.MNISTTestSet))
+	if err != nil {
+		return 0, err
+	}
+	return engine.Evaluate(logger, testSet, decryptor)
+}
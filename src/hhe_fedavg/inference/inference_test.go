@@ -0,0 +1,112 @@
+package inference
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"flhhe/src/RtF"
+	"flhhe/src/utils"
+)
+
+func TestReLUApproximation(t *testing.T) {
+	bound := 4.0
+	poly := ReLUApproximation(bound)
+	if poly.Degree() != 2 {
+		t.Fatalf("degree: got %d, want 2", poly.Degree())
+	}
+	// The least-squares fit stays within B/8 of ReLU on [-B, B]
+	for x := -bound; x <= bound; x += 0.25 {
+		if diff := math.Abs(evaluateActivation(x, bound) - math.Max(x, 0)); diff > bound/8 {
+			t.Errorf("ReLU(%f): approximation error %f too large", x, diff)
+		}
+	}
+}
+
+func TestEncryptedForward(t *testing.T) {
+	params := RtF.DefaultParams[RtF.PN13QP218].WithPlainModulus(RtF.RubatoParams[RtF.RUBATO128S].PlainModulus)
+	// Sizes which aren't powers of two, as the ones of MNIST
+	shape := ModelShape{InputSize: 12, HiddenSize: 6, OutputSize: 3}
+	bound := DefaultActivationBound
+
+	kgen := RtF.NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := RtF.NewCKKSEncoder(params)
+	rotKeys, err := kgen.GenRotationKeysForRotations(GenRotationIndexes(kgen, params, encoder, shape), false, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evk := RtF.EvaluationKey{Rlk: rlk, Rtks: rotKeys}
+
+	w1 := utils.CreateMatrixFloat(shape.HiddenSize, shape.InputSize)
+	w2 := utils.CreateMatrixFloat(shape.OutputSize, shape.HiddenSize)
+	for h := range w1 {
+		for i := range w1[h] {
+			w1[h][i] = utils.RandFloat64(-0.5, 0.5)
+		}
+	}
+	for o := range w2 {
+		for h := range w2[o] {
+			w2[o][h] = utils.RandFloat64(-0.5, 0.5)
+		}
+	}
+
+	// The weights are encrypted at the lowest level the inference accepts, as an average left by HalfBoot
+	encryptor := RtF.NewCKKSEncryptorFromPk(params, pk)
+	encryptFlatten := func(w [][]float64, level int) *RtF.Ciphertext {
+		values := make([]complex128, params.Slots())
+		for i, v := range utils.Flatten2D(w) {
+			values[i] = complex(v, 0)
+		}
+		ct, err := encryptor.EncryptNew(encoder.EncodeComplexAtLvlNew(level, values, params.LogSlots()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ct
+	}
+
+	_, err = newEngine(params, shape, bound, pk, evk, encryptFlatten(w1, ForwardDepth-1), encryptFlatten(w2, ForwardDepth))
+	if !errors.Is(err, RtF.ErrInsufficientLevels) {
+		t.Errorf("newEngine with FC1 at level %d: got error %v, want %v", ForwardDepth-1, err, RtF.ErrInsufficientLevels)
+	}
+	engine, err := newEngine(params, shape, bound, pk, evk, encryptFlatten(w1, ForwardDepth), encryptFlatten(w2, ForwardDepth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decryptor := NewLogitsDecryptor(params, shape, sk)
+
+	x := make([]float64, shape.InputSize)
+	for i := range x {
+		x[i] = utils.RandFloat64(-1, 1)
+	}
+
+	ctIn, err := engine.EncryptInput(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctIn.Level() != params.MaxLevel() {
+		t.Errorf("input encrypted at level %d, want %d", ctIn.Level(), params.MaxLevel())
+	}
+	ctOut, err := engine.Forward(ctIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	have := decryptor.DecryptLogits(ctOut)
+	want := PlainForward(w1, w2, x, bound)
+	for o := range want {
+		if math.Abs(have[o]-want[o]) > 1e-3 {
+			t.Errorf("logit %d: got %f, want %f", o, have[o], want[o])
+		}
+	}
+	if argmax(have) != argmax(want) {
+		t.Errorf("prediction: got %d, want %d", argmax(have), argmax(want))
+	}
+}
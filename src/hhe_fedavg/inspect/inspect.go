@@ -0,0 +1,382 @@
+// Package inspect inspects the .bin artifacts written by utils.Serialize (ciphertexts, plaintexts and
+// keys) and the symmetric uploads of the clients. The files are parsed from their headers only, so
+// large rotation key sets are listed without being loaded in memory. Given the secret key, ciphertexts
+// are also decrypted to report their noise budget (FV) or their precision (CKKS).
+package inspect
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/utils"
+)
+
+// Kinds of artifacts
+const (
+	KindCiphertext         = "ciphertext"
+	KindPlaintext          = "plaintext"
+	KindSecretKey          = "secret key"
+	KindPublicKey          = "public key"
+	KindRelinearizationKey = "relinearization key"
+	KindRotationKeySet     = "rotation key set"
+	KindUpload             = "symmetric upload"
+)
+
+// ErrUnknownFormat is returned when a file doesn't parse as any of the known artifacts
+var ErrUnknownFormat = errors.New("unknown artifact format")
+
+// Report describes an artifact
+type Report struct {
+	Path string
+	Kind string
+	Size int64
+
+	// Ciphertexts and plaintexts
+	LogN     int
+	Degree   int
+	Level    int
+	Scale    float64
+	IsNTT    bool
+	NbModuli int
+
+	// Symmetric uploads
+	Upload *client.Upload
+
+	// Keys
+	Decomposition  int
+	GaloisElements []uint64
+	Rotations      map[uint64]string // Galois element -> rotation it performs
+
+	// Only given the secret key
+	NoiseBudget *int     // FV ciphertexts, in bits
+	Precision   *float64 // CKKS ciphertexts, log2 of the inverse of the maximum error
+}
+
+// Inspect parses the artifact at path. The parameters are used to name the moduli and rotations,
+// and sk (optional) to measure the noise.
+func Inspect(path string, params *RtF.Parameters, sk *RtF.SecretKey) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Path: path, Size: info.Size()}
+
+	// The keys are not self-describing, they are recognized by their file names
+	switch filepath.Base(path) {
+	case configs.SecretKey:
+		report.Kind = KindSecretKey
+		err = report.readPolys(f, 1)
+	case configs.PublicKey:
+		report.Kind = KindPublicKey
+		err = report.readPolys(f, 2)
+	case configs.RelinearizationKeys:
+		report.Kind = KindRelinearizationKey
+		err = report.readRelinearizationKey(f)
+	case configs.RotationKeys, configs.InferenceRotationKeys:
+		report.Kind = KindRotationKeySet
+		err = report.readRotationKeySet(f, params)
+	default:
+		var upload bool
+		if upload, err = isUpload(f); err == nil && upload {
+			err = report.readUpload(f)
+		} else if err == nil {
+			err = report.readElement(f)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if sk != nil && report.Kind == KindCiphertext {
+		if err = report.measureNoise(path, params, sk); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return report, nil
+}
+
+// readPolyHeader reads the header of a ring.Poly and skips its coefficients
+func readPolyHeader(r io.ReadSeeker) (logN, nbModuli int, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return 0, 0, err
+	}
+	logN, nbModuli = int(header[0]), int(header[1])
+	if logN == 0 || logN > 17 || nbModuli == 0 {
+		return 0, 0, ErrUnknownFormat
+	}
+	_, err = r.Seek(int64(nbModuli)<<(logN+3), io.SeekCurrent)
+	return logN, nbModuli, err
+}
+
+func (report *Report) readPolys(r io.ReadSeeker, count int) (err error) {
+	for range count {
+		if report.LogN, report.NbModuli, err = readPolyHeader(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSwitchingKey reads the decomposition size of a rlwe.SwitchingKey and skips its polynomials
+func (report *Report) readSwitchingKey(r io.ReadSeeker) (err error) {
+	decomposition := make([]byte, 1)
+	if _, err = io.ReadFull(r, decomposition); err != nil {
+		return err
+	}
+	report.Decomposition = int(decomposition[0])
+	return report.readPolys(r, 2*report.Decomposition)
+}
+
+func (report *Report) readRelinearizationKey(r io.ReadSeeker) error {
+	degree := make([]byte, 1)
+	if _, err := io.ReadFull(r, degree); err != nil {
+		return err
+	}
+	report.Degree = int(degree[0])
+	for range report.Degree {
+		if err := report.readSwitchingKey(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (report *Report) readRotationKeySet(r io.ReadSeeker, params *RtF.Parameters) error {
+	galEl := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, galEl); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		report.GaloisElements = append(report.GaloisElements, uint64(binary.BigEndian.Uint32(galEl)))
+		if err := report.readSwitchingKey(r); err != nil {
+			return err
+		}
+	}
+	sort.Slice(report.GaloisElements, func(i, j int) bool { return report.GaloisElements[i] < report.GaloisElements[j] })
+
+	if params != nil && params.LogN() == report.LogN {
+		report.Rotations = rotationsOf(params, report.GaloisElements)
+	}
+	return nil
+}
+
+// rotationsOf maps the Galois elements to the column rotations (or the conjugation) they perform
+func rotationsOf(params *RtF.Parameters, galEls []uint64) map[uint64]string {
+	wanted := make(map[uint64]bool, len(galEls))
+	for _, galEl := range galEls {
+		wanted[galEl] = true
+	}
+
+	rotations := make(map[uint64]string, len(galEls))
+	if wanted[params.GaloisElementForRowRotation()] {
+		rotations[params.GaloisElementForRowRotation()] = "conjugate"
+	}
+	for k := 1; k < params.N()/2 && len(rotations) < len(galEls); k++ {
+		if galEl := params.GaloisElementForColumnRotationBy(k); wanted[galEl] {
+			rotations[galEl] = fmt.Sprintf("rotate by %d", k)
+		}
+	}
+	return rotations
+}
+
+// readElement reads the layout of RtF.Element.MarshalBinary: the number of polynomials, each
+// polynomial prefixed by its length, the scale and the NTT flag
+func (report *Report) readElement(r io.ReadSeeker) error {
+	var nbPolys uint64
+	if err := binary.Read(r, binary.LittleEndian, &nbPolys); err != nil {
+		return err
+	}
+	if nbPolys == 0 || nbPolys > 8 {
+		return ErrUnknownFormat
+	}
+
+	for range nbPolys {
+		var length uint64
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return err
+		}
+		var err error
+		if report.LogN, report.NbModuli, err = readPolyHeader(r); err != nil {
+			return err
+		}
+		if length != uint64(2+report.NbModuli<<(report.LogN+3)) {
+			return ErrUnknownFormat
+		}
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &report.Scale); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &report.IsNTT); err != nil {
+		return err
+	}
+	if rest, err := io.ReadAll(r); err != nil || len(rest) != 0 {
+		return ErrUnknownFormat
+	}
+
+	report.Degree = int(nbPolys) - 1
+	report.Level = report.NbModuli - 1
+	report.Kind = KindCiphertext
+	if report.Degree == 0 {
+		report.Kind = KindPlaintext
+	}
+	return nil
+}
+
+// isUpload reports whether the file starts with client.UploadMagic, and rewinds it
+func isUpload(r io.ReadSeeker) (bool, error) {
+	magic := make([]byte, len(client.UploadMagic))
+	n, err := io.ReadFull(r, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	return string(magic[:n]) == client.UploadMagic, nil
+}
+
+// readUpload decodes a symmetric upload, which is checked against its checksum
+func (report *Report) readUpload(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	upload := new(client.Upload)
+	if err = upload.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	report.Kind, report.Upload = KindUpload, upload
+	report.LogN, report.NbModuli = upload.LogN, 1
+	return nil
+}
+
+// measureNoise decrypts the ciphertext: FV ciphertexts (not in the NTT domain) report their
+// invariant noise budget, CKKS ciphertexts their precision. The messages of this protocol are
+// real, so the imaginary part of the decoded slots is pure error.
+func (report *Report) measureNoise(path string, params *RtF.Parameters, sk *RtF.SecretKey) error {
+	if params == nil || params.LogN() != report.LogN || report.Level > params.MaxLevel() {
+		return fmt.Errorf("the parameters don't match the ciphertext (logN = %d, level = %d)", report.LogN, report.Level)
+	}
+
+	ct := RtF.NewCiphertextFVLvl(params, report.Degree, report.Level)
+	if err := utils.Deserialize(ct, path); err != nil {
+		return err
+	}
+
+	if !report.IsNTT {
+		if report.Degree != 1 {
+			return nil
+		}
+		budget := RtF.NewMFVNoiseEstimator(params, sk).InvariantNoiseBudget(ct)
+		report.NoiseBudget = &budget
+		return nil
+	}
+
+	encoder := RtF.NewCKKSEncoder(params)
+	values := encoder.DecodeComplex(RtF.NewCKKSDecryptor(params, sk).DecryptNew(ct), params.LogSlots())
+	var maxErr float64
+	for _, v := range values {
+		maxErr = math.Max(maxErr, math.Abs(imag(v)))
+	}
+	precision := -math.Log2(maxErr)
+	report.Precision = &precision
+	return nil
+}
+
+// Format formats the report, naming the moduli of the parameters when they match
+func (report *Report) Format(params *RtF.Parameters) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", report.Path)
+	fmt.Fprintf(&b, "  type:            %s\n", report.Kind)
+	fmt.Fprintf(&b, "  size:            %d bytes\n", report.Size)
+	fmt.Fprintf(&b, "  degree N:        2^%d\n", report.LogN)
+
+	switch report.Kind {
+	case KindCiphertext, KindPlaintext:
+		fmt.Fprintf(&b, "  degree:          %d\n", report.Degree)
+		fmt.Fprintf(&b, "  level:           %d\n", report.Level)
+		if report.Scale > 0 {
+			fmt.Fprintf(&b, "  scale:           2^%.2f\n", math.Log2(report.Scale))
+		} else {
+			fmt.Fprintf(&b, "  scale:           none\n")
+		}
+		fmt.Fprintf(&b, "  NTT:             %t\n", report.IsNTT)
+	case KindUpload:
+		fmt.Fprintf(&b, "  client:          %s\n", report.Upload.ClientID)
+		fmt.Fprintf(&b, "  round:           %d\n", report.Upload.Round)
+		fmt.Fprintf(&b, "  tensor:          %d\n", report.Upload.Tensor)
+		fmt.Fprintf(&b, "  packing:         %s\n", report.Upload.Packing)
+		fmt.Fprintf(&b, "  coefficients:    %d bits\n", client.CoeffBits(report.Upload.PlainModulus))
+		fmt.Fprintf(&b, "  nonce seed:      %d bytes\n", len(report.Upload.NonceSeed))
+		fmt.Fprintf(&b, "  counter:         %d bytes\n", len(report.Upload.Counter))
+	case KindRelinearizationKey:
+		fmt.Fprintf(&b, "  degree:          %d\n", report.Degree)
+		fmt.Fprintf(&b, "  decomposition:   %d\n", report.Decomposition)
+	case KindRotationKeySet:
+		fmt.Fprintf(&b, "  keys:            %d\n", len(report.GaloisElements))
+		fmt.Fprintf(&b, "  decomposition:   %d\n", report.Decomposition)
+		for _, galEl := range report.GaloisElements {
+			if rotation, ok := report.Rotations[galEl]; ok {
+				fmt.Fprintf(&b, "    galEl %-10d %s\n", galEl, rotation)
+			} else {
+				fmt.Fprintf(&b, "    galEl %d\n", galEl)
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "  moduli:          %s\n", report.moduli(params))
+	if report.NoiseBudget != nil {
+		fmt.Fprintf(&b, "  noise budget:    %d bits\n", *report.NoiseBudget)
+	}
+	if report.Precision != nil {
+		fmt.Fprintf(&b, "  precision:       %.2f bits\n", *report.Precision)
+	}
+	return b.String()
+}
+
+// moduli names the modulus chain of the artifact: a prefix of Q for ciphertexts, Q and P for keys,
+// and the plaintext modulus for plaintexts in R_t
+func (report *Report) moduli(params *RtF.Parameters) string {
+	if params == nil || params.LogN() != report.LogN {
+		return fmt.Sprintf("%d moduli", report.NbModuli)
+	}
+
+	var moduli []uint64
+	switch {
+	case report.Kind == KindPlaintext && report.NbModuli == 1 && !report.IsNTT, report.Kind == KindUpload:
+		moduli = []uint64{params.PlainModulus()}
+	case report.NbModuli <= len(params.Qi()):
+		moduli = params.Qi()[:report.NbModuli]
+	case report.NbModuli == len(params.Qi())+len(params.Pi()):
+		moduli = append(append([]uint64{}, params.Qi()...), params.Pi()...)
+	default:
+		return fmt.Sprintf("%d moduli (don't match the parameters)", report.NbModuli)
+	}
+
+	bits := make([]string, len(moduli))
+	for i, q := range moduli {
+		bits[i] = fmt.Sprintf("%d", int(math.Ceil(math.Log2(float64(q)))))
+	}
+	return fmt.Sprintf("%d moduli, log2 = [%s]", len(moduli), strings.Join(bits, " "))
+}
@@ -0,0 +1,154 @@
+package inspect
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+func TestInspect(t *testing.T) {
+	params := RtF.DefaultParams[RtF.PN13QP218].WithPlainModulus(RtF.RubatoParams[RtF.RUBATO128S].PlainModulus)
+	params.SetLogFVSlots(params.LogN())
+	dir := t.TempDir()
+
+	kgen := RtF.NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotations := []int{1, 2, -1}
+	rotKeys, err := kgen.GenRotationKeysForRotations(rotations, true, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serialize := func(object any, name string) string {
+		path := filepath.Join(dir, name)
+		if err := utils.Serialize(object, path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	fvEncoder := RtF.NewMFVEncoder(params)
+	fvPlaintext := RtF.NewPlaintextFV(params)
+	fvEncoder.EncodeUint([]uint64{1, 2, 3}, fvPlaintext)
+	fvCiphertext, err := RtF.NewMFVEncryptorFromPk(params, pk).EncryptNew(fvPlaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ckksEncoder := RtF.NewCKKSEncoder(params)
+	values := make([]complex128, params.Slots())
+	for i := range values {
+		values[i] = complex(utils.RandFloat64(-1, 1), 0)
+	}
+	ckksCiphertext, err := RtF.NewCKKSEncryptorFromPk(params, pk).EncryptNew(ckksEncoder.EncodeComplexNew(values, params.LogSlots()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ptRingT := RtF.NewPlaintextRingT(params)
+	fvEncoder.EncodeUintRingT([]uint64{4, 5, 6}, ptRingT)
+
+	paths := map[string]string{
+		KindSecretKey:          serialize(sk, configs.SecretKey),
+		KindPublicKey:          serialize(pk, configs.PublicKey),
+		KindRelinearizationKey: serialize(rlk, configs.RelinearizationKeys),
+		KindRotationKeySet:     serialize(rotKeys, configs.RotationKeys),
+		"fv":                   serialize(fvCiphertext, "ct_0.bin"),
+		"ckks":                 serialize(ckksCiphertext, "ctx_0.bin"),
+		KindPlaintext:          serialize(ptRingT, "do1_pt_0.bin"),
+	}
+
+	for _, kind := range []string{KindSecretKey, KindPublicKey, KindRelinearizationKey, KindRotationKeySet} {
+		report, err := Inspect(paths[kind], params, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Kind != kind || report.LogN != params.LogN() {
+			t.Errorf("%s: got %s with logN %d", kind, report.Kind, report.LogN)
+		}
+	}
+
+	report, err := Inspect(paths[KindRotationKeySet], params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.GaloisElements) != len(rotations)+1 {
+		t.Errorf("rotation keys: got %d Galois elements, want %d", len(report.GaloisElements), len(rotations)+1)
+	}
+	if report.Rotations[params.GaloisElementForColumnRotationBy(2)] != "rotate by 2" {
+		t.Errorf("rotation keys: Galois element of the rotation by 2 not recognized")
+	}
+	if report.Rotations[params.GaloisElementForRowRotation()] != "conjugate" {
+		t.Errorf("rotation keys: conjugation not recognized")
+	}
+	if report.Decomposition == 0 {
+		t.Errorf("rotation keys: decomposition size not read")
+	}
+
+	report, err = Inspect(paths["fv"], params, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != KindCiphertext || report.Degree != 1 || report.Level != fvCiphertext.Level() || report.IsNTT {
+		t.Errorf("fv ciphertext: got %+v", report)
+	}
+	if report.NoiseBudget == nil || *report.NoiseBudget <= 0 {
+		t.Errorf("fv ciphertext: noise budget not measured")
+	}
+
+	report, err = Inspect(paths["ckks"], params, sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != KindCiphertext || !report.IsNTT || math.Abs(report.Scale-params.Scale()) > 1 {
+		t.Errorf("ckks ciphertext: got %+v", report)
+	}
+	if report.Precision == nil || *report.Precision < 10 {
+		t.Errorf("ckks ciphertext: precision not measured")
+	}
+
+	report, err = Inspect(paths[KindPlaintext], params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != KindPlaintext || report.Level != 0 {
+		t.Errorf("plaintext: got %+v", report)
+	}
+
+	data, err := client.NewUpload(&client.FLClient{ClientID: "do1", Round: 2}, 1, keys_dealer.PackingCoefficients, params, ptRingT).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadPath := filepath.Join(dir, "do1_ct_1.bin")
+	if err = os.WriteFile(uploadPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err = Inspect(uploadPath, params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Kind != KindUpload || report.LogN != params.LogN() || report.Upload.ClientID != "do1" || report.Upload.Round != 2 || report.Upload.Tensor != 1 {
+		t.Errorf("upload: got %+v", report)
+	}
+
+	garbage := filepath.Join(dir, "garbage.bin")
+	if err = os.WriteFile(garbage, []byte("not an artifact"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Inspect(garbage, params, nil); err == nil {
+		t.Errorf("garbage: no error")
+	}
+}
@@ -1,30 +1,43 @@
-/// The keys dealer is responsible for generating the Rubato parameters, HHE keys,
-/// symmetric keys and their corresponding FV ciphertexts
+/// The keys dealer is responsible for generating the symmetric cipher (Rubato or HERA) parameters,
+/// HHE keys, symmetric keys and their corresponding FV ciphertexts
 
 package keys_dealer
 
 import (
 	"encoding/binary"
+	"errors"
 	"flhhe/configs"
 	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/diagnostics"
 	"flhhe/src/utils"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 )
 
+// BootstrapStCDepth the number of moduli consumed by the SlotsToCoeffs of the full bootstrapping of the
+// aggregate, see RtF.HalfBootParameters.BootstrappingParams
+const BootstrapStCDepth = 3
+
+// RubatoParams the parameters of the symmetric cipher of the pipeline, Rubato or HERA, bound to their
+// RtF parameters. RubatoModDown holds the mod down indices of the selected cipher. Packing sets the
+// number of FV slots of Params. BtpParams are the parameters of the full bootstrapping of the
+// aggregate, nil if it is disabled.
 type RubatoParams struct {
+	Cipher         RtF.SymmetricCipher
+	Packing        Packing
 	Blocksize      int
 	OutputSize     int
-	NumRound       int
 	PlainModulus   uint64
 	Sigma          float64
 	MessageScaling float64
 	RubatoModDown  []int
 	StcModDown     []int
 	HalfBsParams   *RtF.HalfBootParameters
+	BtpParams      *RtF.BootstrappingParameters
 	Params         *RtF.Parameters
 }
 
@@ -35,97 +48,134 @@ type HHEComponents struct {
 	CkksDecryptor    RtF.CKKSDecryptor
 	FvEvaluator      RtF.MFVEvaluator
 	HalfBootstrapper *RtF.HalfBootstrapper
+	Bootstrapper     *RtF.Bootstrapper // nil if the full bootstrapping is disabled
 	CkksEvaluator    RtF.CKKSEvaluator
+	RotKeys          *RtF.RotationKeySet
 }
 
+// RunKeysDealer generates or loads the keys of the pipeline under rootPath for the given packing of the
+// client data. With bootstrap, it also generates the rotation keys of the full bootstrapping of the
+// aggregate. The keys are only generated from seeded PRNGs (RtF.SetPRNGSeed) with allowSeededKeys, for
+// the tests replaying them; it returns RtF.ErrSeededKeys otherwise.
 func RunKeysDealer(
 	logger utils.Logger,
 	rootPath string,
-	paramIndex int) (
+	cipher RtF.SymmetricCipher,
+	packing Packing,
+	bootstrap bool,
+	allowSeededKeys bool) (
 	rubatoParams *RubatoParams,
 	hheComponents *HHEComponents,
-	rubato RtF.MFVRubato,
+	rubato RtF.MFVCipher,
+	err error,
 ) {
+	logger = logger.With(utils.LogKeyRole, utils.RoleKeysDealer)
 	logger.PrintHeader("--- Keys Dealer ---")
 	logger.PrintMessage("[Keys Dealer] Preparing Common things for all FL Clients")
 	logger.PrintFormatted("Root Path: %s", rootPath)
-	logger.PrintFormatted("Using Rubato Parameter Index: %d (%s)", paramIndex, RtF.RubatoParams[paramIndex].Name)
+	logger.PrintFormatted("Using symmetric cipher: %s", cipher.Name())
 
-	// Initialize Rubato parameters
-	rubatoParams = InitRubatoParams(logger, paramIndex)
+	// Initialize the symmetric cipher parameters
+	if rubatoParams, err = InitRubatoParams(logger, cipher, packing); err != nil {
+		return nil, nil, nil, err
+	}
+	if bootstrap {
+		if err = EnableBootstrapping(rubatoParams); err != nil {
+			return nil, nil, nil, err
+		}
+	}
 
 	// Initialize HHE components
 	keysDir := filepath.Join(rootPath, configs.Keys)
 
 	// Create keysDir if it doesn't exist
-	if err := os.MkdirAll(keysDir, 0755); err != nil {
-		utils.HandleError(fmt.Errorf("failed to create keys directory: %v", err))
+	if err = os.MkdirAll(keysDir, 0755); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create keys directory: %w", err)
 	}
 	logger.PrintFormatted("Keys directory: %s", keysDir)
 
-	HHEKeysGen(logger, keysDir, rubatoParams.Params, rubatoParams.HalfBsParams)
+	if err = HHEKeysGen(logger, keysDir, rubatoParams, allowSeededKeys); err != nil {
+		return nil, nil, nil, err
+	}
 
 	// reading the already generated keys from a previous step, it will save time and memory :)
-	hheComponents = InitHHEScheme(
-		logger, keysDir, rubatoParams.Params, rubatoParams.HalfBsParams,
+	if hheComponents, err = InitHHEScheme(logger, keysDir, rubatoParams); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if rubato, err = NewMFVCipher(rubatoParams, hheComponents); err != nil {
+		return nil, nil, nil, err
+	}
+
+	symKey, symKeyFVCiphertext, err := SymmetricKeyGen(
+		logger, SymmetricKeyDir(keysDir, rubatoParams), rubatoParams.Blocksize, rubatoParams.Params, rubato, allowSeededKeys,
 	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	logger.PrintFormatted("Symmetric Key: %+T, len = %d", symKey, len(symKey))
+	logger.PrintFormatted("FV encrypted symmetric key: %+T, len = %d", symKeyFVCiphertext, len(symKeyFVCiphertext))
+
+	return rubatoParams, hheComponents, rubato, nil
+}
 
-	rubato = RtF.NewMFVRubato(
-		paramIndex,
+// NewMFVCipher returns the homomorphic evaluator of the keystream of the symmetric cipher
+func NewMFVCipher(rubatoParams *RubatoParams, hheComponents *HHEComponents) (RtF.MFVCipher, error) {
+	return rubatoParams.Cipher.NewMFVCipher(
 		rubatoParams.Params,
 		hheComponents.FvEncoder,
 		hheComponents.FvEncryptor,
 		hheComponents.FvEvaluator,
 		rubatoParams.RubatoModDown[0],
 	)
+}
 
-	var err error
-	symKey, symKeyFVCiphertext, err := SymmetricKeyGen(
-		logger, keysDir, rubatoParams.Blocksize, rubatoParams.Params, rubato,
-	)
-	if err != nil {
-		utils.HandleError(err)
+// SymmetricKeyDir the directory of the symmetric key and its FV ciphertexts, one per cipher since their
+// block sizes differ, and per packing since the FV ciphertexts hold the key in each FV slot
+func SymmetricKeyDir(keysDir string, rubatoParams *RubatoParams) string {
+	name := rubatoParams.Cipher.Name()
+	if rubatoParams.Packing != PackingCoefficients {
+		name += "_" + rubatoParams.Packing.String()
 	}
-	logger.PrintFormatted("Symmetric Key: %+T, len = %d", symKey, len(symKey))
-	logger.PrintFormatted("FV encrypted symmetric key: %+T, len = %d", symKeyFVCiphertext, len(symKeyFVCiphertext))
-
-	return rubatoParams, hheComponents, rubato
+	return filepath.Join(keysDir, name)
 }
 
-func InitRubatoParams(logger utils.Logger, paramIndex int) *RubatoParams {
-	logger.PrintMessage("[Keys Dealer] Rubato parameters")
-	blockSize := RtF.RubatoParams[paramIndex].Blocksize
-	outputSize := 2 // originally: outputSize := blockSize - 4
-	numRound := RtF.RubatoParams[paramIndex].NumRound
-	plainModulus := RtF.RubatoParams[paramIndex].PlainModulus
-	sigma := RtF.RubatoParams[paramIndex].Sigma
+// InitRubatoParams binds the cipher to its RtF parameters, with the number of FV slots of the packing
+func InitRubatoParams(logger utils.Logger, cipher RtF.SymmetricCipher, packing Packing) (*RubatoParams, error) {
+	logger.PrintFormatted("[Keys Dealer] %s parameters", cipher.Name())
+	blockSize := cipher.BlockSize()
+	outputSize := 2 // originally: outputSize := cipher.OutputSize()
+	plainModulus := cipher.PlainModulus()
+	sigma := cipher.Sigma()
 
-	// RtF Rubato parameters, for full-coefficients only (128bit security)
-	halfBsParams := RtF.RtFRubatoParams[0]
+	// RtF parameters of the cipher (128bit security)
+	halfBsParams := cipher.HalfBootParams()
 	params, err := halfBsParams.Params()
 	if err != nil {
-		utils.HandleError(err)
+		return nil, err
 	}
 	params.SetPlainModulus(plainModulus)
 	messageScaling := float64(params.PlainModulus()) / halfBsParams.MessageRatio
 
-	rubatoModDown := RtF.RubatoModDownParams[paramIndex].CipherModDown
-	stcModDown := RtF.RubatoModDownParams[paramIndex].StCModDown
+	rubatoModDown := cipher.ModDownParams().CipherModDown
+	stcModDown := cipher.ModDownParams().StCModDown
 
-	params.SetLogFVSlots(params.LogN())
+	params.SetLogFVSlots(packing.LogFVSlots(params))
 
+	logger.PrintFormatted("packing = %s", packing)
 	logger.PrintFormatted("blockSize = %d", blockSize)
 	logger.PrintFormatted("outputSize = %d", outputSize)
-	logger.PrintFormatted("numRound = %d", numRound)
 	logger.PrintFormatted("plainModulus = %d", plainModulus)
 	logger.PrintFormatted("sigma = %f", sigma)
 	logger.PrintFormatted("params.N() = %d", params.N())
 	logger.PrintFormatted("params.Slots() = %d", params.Slots())
+	logger.PrintFormatted("params.FVSlots() = %d", params.FVSlots())
 
 	return &RubatoParams{
+		Cipher:         cipher,
+		Packing:        packing,
 		Blocksize:      blockSize,
 		OutputSize:     outputSize,
-		NumRound:       numRound,
 		PlainModulus:   plainModulus,
 		Sigma:          sigma,
 		MessageScaling: messageScaling,
@@ -133,17 +183,27 @@ func InitRubatoParams(logger utils.Logger, paramIndex int) *RubatoParams {
 		StcModDown:     stcModDown,
 		HalfBsParams:   halfBsParams,
 		Params:         params,
-	}
+	}, nil
 }
 
-// Generates and saves cryptographic keys for Homomorphic Hybrid Encryption (HHE).
+// EnableBootstrapping sets the parameters of the full bootstrapping of the aggregate, on the moduli chain
+// of HalfBoot so that it shares its keys
+func EnableBootstrapping(rubatoParams *RubatoParams) (err error) {
+	rubatoParams.BtpParams, err = rubatoParams.HalfBsParams.BootstrappingParams(BootstrapStCDepth)
+	return err
+}
+
+// Generates and saves cryptographic keys for Homomorphic Hybrid Encryption (HHE), with the rotation keys
+// of the packing of rubatoParams, and those of the full bootstrapping if it is enabled. Seeded PRNGs
+// need allowSeededKeys (see RtF.CheckKeygenSeed).
 func HHEKeysGen(
 	logger utils.Logger,
 	keysDir string,
-	params *RtF.Parameters,
-	hbtParams *RtF.HalfBootParameters,
-) {
+	rubatoParams *RubatoParams,
+	allowSeededKeys bool,
+) error {
 	logger.PrintMessage("[Keys Dealer] HHE keys generation")
+	params, hbtParams, btpParams := rubatoParams.Params, rubatoParams.HalfBsParams, rubatoParams.BtpParams
 
 	var err error
 
@@ -161,17 +221,25 @@ func HHEKeysGen(
 	if fileExists(secretKeyPath) || fileExists(publicKeyPath) ||
 		fileExists(rotationKeyPath) || fileExists(relinKeysPath) {
 		logger.PrintFormatted("Some key files already exist in %s, skipping keys generation", keysDir)
-		return
+		return nil
+	}
+	if err = RtF.CheckKeygenSeed(allowSeededKeys); err != nil {
+		return err
 	}
 
 	kgen := RtF.NewKeyGenerator(params)
 
 	logger.PrintMemUsage("Secret and Public Keys Generation")
-	sk, pk := kgen.GenKeyPairSparse(hbtParams.H)
-	err = utils.Serialize(sk, filepath.Join(keysDir, configs.SecretKey))
-	utils.HandleError(err)
-	err = utils.Serialize(pk, filepath.Join(keysDir, configs.PublicKey))
-	utils.HandleError(err)
+	sk, pk, err := kgen.GenKeyPairSparse(hbtParams.H)
+	if err != nil {
+		return err
+	}
+	if err = utils.Serialize(sk, filepath.Join(keysDir, configs.SecretKey)); err != nil {
+		return err
+	}
+	if err = utils.Serialize(pk, filepath.Join(keysDir, configs.PublicKey)); err != nil {
+		return err
+	}
 
 	fvEncoder := RtF.NewMFVEncoder(params)
 
@@ -188,72 +256,93 @@ func HHEKeysGen(
 	//utils.HandleError(err)
 
 	t = time.Now()
-	rotationsStC := kgen.GenRotationIndexesForSlotsToCoeffsMat(ptDiagMats)
+	rotationsStC := packingRotations(kgen, params, rubatoParams.Packing, ptDiagMats)
 	logger.PrintMemUsage("Rotation Indices Generation")
 	logger.PrintRunningTime("Rotation Indices Generation", t)
 	rotations := append(rotationsHalfBoot, rotationsStC...)
+	// Inner-sum rotations used by the server to compute the encrypted client diagnostics
+	rotations = append(rotations, diagnostics.GenRotationIndexes(kgen, params)...)
+	if btpParams != nil {
+		rotations = append(rotations, kgen.GenRotationIndexesForBootstrapping(params.LogSlots(), btpParams)...)
+	}
 
 	t = time.Now()
-	rotKeys := kgen.GenRotationKeysForRotations(rotations, true, sk)
+	rotKeys, err := kgen.GenRotationKeysForRotations(rotations, true, sk)
+	if err != nil {
+		return err
+	}
 	logger.PrintMemUsage("Rotation Keys Generation")
 	logger.PrintRunningTime("Rotation Keys Generation", t)
-	err = utils.Serialize(rotKeys, filepath.Join(keysDir, configs.RotationKeys))
-	utils.HandleError(err)
+	if err = utils.Serialize(rotKeys, filepath.Join(keysDir, configs.RotationKeys)); err != nil {
+		return err
+	}
 
 	t = time.Now()
-	rlk := kgen.GenRelinearizationKey(sk)
+	rlk, err := kgen.GenRelinearizationKey(sk)
+	if err != nil {
+		return err
+	}
 	logger.PrintMemUsage("Relinearization Keys Generation")
 	logger.PrintRunningTime("Relinearization Keys Generation", t)
-	err = utils.Serialize(rlk, filepath.Join(keysDir, configs.RelinearizationKeys))
-	utils.HandleError(err)
+	return utils.Serialize(rlk, filepath.Join(keysDir, configs.RelinearizationKeys))
 }
 
 // InitHHEScheme loads the homomorphic hybrid encryption keys from storage and initializes
 // the complete cryptographic scheme including encoders, encryptors, decryptors, evaluators,
-// and the half-bootstrapping components. It returns all necessary components for HHE operations.
+// and the half-bootstrapping components, and the full bootstrapping ones if they are enabled.
+// It returns all necessary components for HHE operations, an RtF.ErrCorruptArtifact error if a key can't
+// be decoded and an RtF.ErrParamMismatch one if the keys were generated for other parameters.
 func InitHHEScheme(
 	logger utils.Logger,
 	keysDir string,
-	params *RtF.Parameters,
-	hbtpParams *RtF.HalfBootParameters) *HHEComponents {
+	rubatoParams *RubatoParams) (*HHEComponents, error) {
 	logger.PrintMessage("[Keys Dealer] Initializing HHE Scheme")
+	params, hbtpParams, btpParams := rubatoParams.Params, rubatoParams.HalfBsParams, rubatoParams.BtpParams
 
 	logger.PrintMessage("Reading the keys and public parameters from storage and setup the scheme")
 	t := time.Now()
 	var err error
 
 	sk := new(RtF.SecretKey)
-	if err = utils.Deserialize(sk, filepath.Join(keysDir, configs.SecretKey)); err != nil {
-		utils.HandleError(err)
+	if err = Deserialize(sk, filepath.Join(keysDir, configs.SecretKey)); err != nil {
+		return nil, err
 	}
 	logger.PrintMemUsage("Reading sk")
 
 	pk := new(RtF.PublicKey)
-	if err = utils.Deserialize(pk, filepath.Join(keysDir, configs.PublicKey)); err != nil {
-		utils.HandleError(err)
+	if err = Deserialize(pk, filepath.Join(keysDir, configs.PublicKey)); err != nil {
+		return nil, err
 	}
 	logger.PrintMemUsage("Reading pk")
 
 	rotKeys := new(RtF.RotationKeySet)
-	if err = utils.Deserialize(rotKeys, filepath.Join(keysDir, configs.RotationKeys)); err != nil {
-		utils.HandleError(err)
+	if err = Deserialize(rotKeys, filepath.Join(keysDir, configs.RotationKeys)); err != nil {
+		return nil, err
 	}
 	logger.PrintMemUsage("Reading rotKeys")
 
 	rlKeys := new(RtF.RelinearizationKey)
-	if err = utils.Deserialize(rlKeys, filepath.Join(keysDir, configs.RelinearizationKeys)); err != nil {
-		utils.HandleError(err)
+	if err = Deserialize(rlKeys, filepath.Join(keysDir, configs.RelinearizationKeys)); err != nil {
+		return nil, err
 	}
 	logger.PrintMemUsage("Reading rlKeys")
 
 	hbtpKey := RtF.BootstrappingKey{Rlk: rlKeys, Rtks: rotKeys}
 	halfBootstrapper, err := RtF.NewHalfBootstrapper(params, hbtpParams, hbtpKey)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	logger.PrintMemUsage("New HalfBootstrapper")
 
+	var bootstrapper *RtF.Bootstrapper
+	if btpParams != nil {
+		if bootstrapper, err = RtF.NewBootstrapper(params, btpParams, hbtpKey); err != nil {
+			return nil, fmt.Errorf("%w (the keys in %s may have been generated without bootstrapping, remove them to regenerate them)", err, keysDir)
+		}
+		logger.PrintMemUsage("New Bootstrapper")
+	}
+
 	fvEncoder := RtF.NewMFVEncoder(params)
 	ckksEncoder := RtF.NewCKKSEncoder(params)
 	fvEncryptor := RtF.NewMFVEncryptorFromPk(params, pk)
@@ -261,6 +350,15 @@ func InitHHEScheme(
 
 	ptDiagMat := fvEncoder.GenSlotToCoeffMatFV(2)
 	logger.PrintMemUsage("PtDiagMatrix Generation")
+	var rotMissing []int
+	for _, rotation := range packingRotations(RtF.NewKeyGenerator(params), params, rubatoParams.Packing, ptDiagMat) {
+		if _, generated := rotKeys.Keys[params.GaloisElementForColumnRotationBy(rotation)]; !generated {
+			rotMissing = append(rotMissing, rotation)
+		}
+	}
+	if len(rotMissing) != 0 {
+		return nil, fmt.Errorf("%w: rotation key(s) of the %s packing missing: %d (the keys in %s may have been generated for another packing, remove them to regenerate them)", RtF.ErrParamMismatch, rubatoParams.Packing, rotMissing, keysDir)
+	}
 	fvEvaluator := RtF.NewMFVEvaluator(params, RtF.EvaluationKey{Rlk: rlKeys, Rtks: rotKeys}, ptDiagMat)
 	logger.PrintRunningTime("Total time to load the keys: ", t)
 
@@ -272,23 +370,37 @@ func InitHHEScheme(
 		FvEncryptor:      fvEncryptor,
 		CkksDecryptor:    ckksDecryptor,
 		HalfBootstrapper: halfBootstrapper,
+		Bootstrapper:     bootstrapper,
 		FvEvaluator:      fvEvaluator,
 		CkksEvaluator:    ckksEvaluator,
+		RotKeys:          rotKeys,
+	}, nil
+}
+
+// packingRotations returns the rotations of the SlotsToCoeffs of the keystream, whose matrices depend
+// on the number of FV slots, and the one of the HalfBoot repacking of the packing
+func packingRotations(kgen RtF.KeyGenerator, params *RtF.Parameters, packing Packing, ptDiagMats [][]*RtF.PtDiagMatrixT) []int {
+	rotations := kgen.GenRotationIndexesForSlotsToCoeffsMat(ptDiagMats)
+	if packing.Repack() {
+		rotations = append(rotations, params.Slots()/2)
 	}
+	return rotations
 }
 
-// SymmetricKeyGen generates a symmetric key and its corresponding FV ciphertext
-// If the key and ciphertext already exist in storage, it loads and returns them.
+// SymmetricKeyGen generates a symmetric key and its corresponding FV ciphertext in symKeyDir (see SymmetricKeyDir)
+// If the key and ciphertext already exist in storage, it loads and returns them. Seeded PRNGs need
+// allowSeededKeys (see RtF.CheckKeygenSeed).
 func SymmetricKeyGen(
 	logger utils.Logger,
-	keysDir string,
+	symKeyDir string,
 	blockSize int,
 	params *RtF.Parameters,
-	rubato RtF.MFVRubato) (key []uint64, kCt []*RtF.Ciphertext, err error) {
+	rubato RtF.MFVCipher,
+	allowSeededKeys bool) (key []uint64, kCt []*RtF.Ciphertext, err error) {
 	logger.PrintMessage("[Keys Dealer] Generating / Loading Symmetric Keys")
 
-	symKeyPath := filepath.Join(keysDir, configs.SymmetricKey)
-	symCipherDir := filepath.Join(keysDir, configs.SymmetricKeyCipherDir)
+	symKeyPath := filepath.Join(symKeyDir, configs.SymmetricKey)
+	symCipherDir := filepath.Join(symKeyDir, configs.SymmetricKeyCipherDir)
 
 	fileExists := func(path string) bool {
 		_, err := os.Stat(path)
@@ -304,17 +416,24 @@ func SymmetricKeyGen(
 		logger.PrintMessage("Loading existing symmetric key and ciphertext")
 
 		// Load symmetric key
-		key = LoadSymmKey(symKeyPath, blockSize)
+		if key, err = LoadSymmKey(symKeyPath, blockSize); err != nil {
+			return nil, nil, err
+		}
 
 		t := time.Now()
 		// Load ciphertext array kCt
-		kCt = LoadCiphertextArray(symCipherDir, params)
+		if kCt, err = LoadCiphertextArray(symCipherDir, params); err != nil {
+			return nil, nil, err
+		}
 		logger.PrintRunningTime("Time to load the symmetric key FV ciphertext", t)
 
 		return key, kCt, nil
 	}
 
 	// Generate new symmetric key
+	if err = RtF.CheckKeygenSeed(allowSeededKeys); err != nil {
+		return nil, nil, err
+	}
 	t := time.Now()
 	key = make([]uint64, blockSize)
 	for i := 0; i < blockSize; i++ {
@@ -324,7 +443,6 @@ func SymmetricKeyGen(
 
 	// Save symmetric key
 	if err := SaveSymmKey(key, symKeyPath); err != nil {
-		fmt.Printf("Failed to save key: %v\n", err)
 		return nil, nil, fmt.Errorf("failed to save symmetric key: %v", err)
 	}
 	logger.PrintFormatted("Symmetric key saved to %s", symKeyPath)
@@ -332,12 +450,13 @@ func SymmetricKeyGen(
 	// Compute FV Ciphertext of the symmetric key
 	logger.PrintMessage("Compute FV Ciphertext of the Symmetric Key")
 	t = time.Now()
-	kCt = rubato.EncKey(key)
+	if kCt, err = rubato.EncKey(key); err != nil {
+		return nil, nil, err
+	}
 	logger.PrintRunningTime("Time to compute FV Ciphertext of the Symmetric Key: ", t)
 
 	// Save ciphertext array kCt
 	if err := SaveCiphertextArray(kCt, symCipherDir); err != nil {
-		fmt.Printf("Failed to save ciphertext array: %v\n", err)
 		return nil, nil, fmt.Errorf("failed to save FV ciphertext symmetric key: %v", err)
 	}
 	logger.PrintFormatted("FV Ciphertext of the Symmetric key saved to %s", symCipherDir)
@@ -346,18 +465,13 @@ func SymmetricKeyGen(
 }
 
 // LoadCiphertextArray loads an array of ciphertexts from a directory
-// params is needed to create new ciphertext objects
-func LoadCiphertextArray(dirPath string, params *RtF.Parameters) []*RtF.Ciphertext {
+// params is needed to create new ciphertext objects. It returns an RtF.ErrCorruptArtifact error if a file
+// can't be decoded and an RtF.ErrParamMismatch one if a ciphertext isn't in the ring of params.
+func LoadCiphertextArray(dirPath string, params *RtF.Parameters) ([]*RtF.Ciphertext, error) {
 	// Read length file
-	lengthPath := filepath.Join(dirPath, "length.txt")
-	lengthBytes, err := os.ReadFile(lengthPath)
+	length, err := LoadLength(filepath.Join(dirPath, "length.txt"))
 	if err != nil {
-		panic(fmt.Errorf("failed to read length file: %v", err))
-	}
-
-	length, err := strconv.Atoi(string(lengthBytes))
-	if err != nil {
-		panic(fmt.Errorf("failed to parse length: %v", err))
+		return nil, err
 	}
 
 	// Create array to hold ciphertexts
@@ -372,22 +486,65 @@ func LoadCiphertextArray(dirPath string, params *RtF.Parameters) []*RtF.Cipherte
 		ct := RtF.NewCiphertextFVLvl(params, 1, 0)
 
 		// Deserialize into it
-		if err := utils.Deserialize(ct, filePath); err != nil {
-			panic(fmt.Errorf("failed to load ciphertext %d: %v", i, err))
+		if err := Deserialize(ct, filePath); err != nil {
+			return nil, fmt.Errorf("failed to load ciphertext %d: %w", i, err)
+		}
+		if err := RtF.CheckCiphertext(params, ct); err != nil {
+			return nil, fmt.Errorf("ciphertext %d: %w", i, err)
 		}
 
 		ciphertexts[i] = ct
 	}
 
-	return ciphertexts
+	return ciphertexts, nil
+}
+
+// MaxArtifactLength bounds the number of elements read from the length files, far above the number of
+// ciphertexts or plaintexts of the pipeline
+const MaxArtifactLength = 1 << 16
+
+// LoadLength reads the number of elements of an array saved with its length file, an
+// RtF.ErrCorruptArtifact error if it isn't a number in [0, MaxArtifactLength]
+func LoadLength(lengthPath string) (int, error) {
+	lengthBytes, err := os.ReadFile(lengthPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read length file: %w", err)
+	}
+
+	length, err := strconv.Atoi(string(lengthBytes))
+	if err != nil {
+		return 0, fmt.Errorf("%w: failed to parse length: %v", RtF.ErrCorruptArtifact, err)
+	}
+	if length < 0 || length > MaxArtifactLength {
+		return 0, fmt.Errorf("%w: length %d out of [0, %d]", RtF.ErrCorruptArtifact, length, MaxArtifactLength)
+	}
+	return length, nil
+}
+
+// Deserialize is utils.Deserialize for the artifacts of the pipeline: the errors opening or reading the
+// file are returned as they are, those decoding it, including the panics of the decoders, wrap
+// RtF.ErrCorruptArtifact.
+func Deserialize(object any, path string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %s: %v", RtF.ErrCorruptArtifact, path, r)
+		}
+	}()
+
+	err = utils.Deserialize(object, path)
+	var pathErr *fs.PathError
+	if err != nil && !errors.As(err, &pathErr) {
+		return fmt.Errorf("%w: %s: %w", RtF.ErrCorruptArtifact, path, err)
+	}
+	return err
 }
 
 // LoadKey loads a sequential key array from a file
-func LoadSymmKey(filePath string, blockSize int) []uint64 {
+func LoadSymmKey(filePath string, blockSize int) ([]uint64, error) {
 	// Open the file
 	file, err := os.Open(filePath)
 	if err != nil {
-		panic(fmt.Errorf("failed to open file: %v", err))
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
@@ -397,11 +554,11 @@ func LoadSymmKey(filePath string, blockSize int) []uint64 {
 	// Read each uint64 value
 	for i := 0; i < blockSize; i++ {
 		if err := binary.Read(file, binary.LittleEndian, &key[i]); err != nil {
-			panic(fmt.Errorf("failed to read key at position %d: %v", i, err))
+			return nil, fmt.Errorf("%w: failed to read key at position %d: %v", RtF.ErrCorruptArtifact, i, err)
 		}
 	}
 
-	return key
+	return key, nil
 }
 
 // SaveCiphertextArray saves an array of ciphertexts to individual files in a directory
@@ -0,0 +1,29 @@
+package keys_dealer
+
+import (
+	"errors"
+	"testing"
+
+	"flhhe/src/RtF"
+	"flhhe/src/utils"
+)
+
+// TestRunKeysDealerSeeded checks that the keys are only generated from seeded PRNGs with allowSeededKeys
+func TestRunKeysDealerSeeded(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the toy key generation in short mode")
+	}
+	logger := utils.NewLogger(false)
+	cipher := RtF.NewToyRubatoCipher(RtF.RUBATO128L, RtF.RtFToyN10)
+	if err := RtF.SetPRNGSeed([]byte("replay")); err != nil {
+		t.Fatal(err)
+	}
+	defer RtF.SetPRNGSeed(nil)
+
+	if _, _, _, err := RunKeysDealer(logger, t.TempDir(), cipher, PackingCoefficients, false, false); !errors.Is(err, RtF.ErrSeededKeys) {
+		t.Errorf("RunKeysDealer from a seed: got error %v, want %v", err, RtF.ErrSeededKeys)
+	}
+	if _, _, _, err := RunKeysDealer(logger, t.TempDir(), cipher, PackingCoefficients, false, true); err != nil {
+		t.Errorf("RunKeysDealer from a seed with allowSeededKeys: %v", err)
+	}
+}
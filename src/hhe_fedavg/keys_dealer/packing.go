@@ -0,0 +1,65 @@
+package keys_dealer
+
+import (
+	"fmt"
+	"strings"
+
+	"flhhe/src/RtF"
+)
+
+// Packing the layout of the client data in the plaintexts of the symmetric cipher, each value being
+// masked by the keystream of one nonce. It sets the number of FV slots, i.e. the number of keystream
+// blocks the server evaluates per plaintext, and what the server does with the output of the
+// CoeffsToSlots of HalfBoot.
+type Packing int
+
+const (
+	// PackingCoefficients N values per plaintext, in all its coefficients. HalfBoot outputs them as two
+	// CKKS ciphertexts (the coefficients [0, N/2) and [N/2, N)): twice the values per keystream
+	// evaluation, for throughput.
+	PackingCoefficients Packing = iota
+	// PackingSlots Slots values per plaintext. The server evaluates the keystream of half the nonces and
+	// HalfBoot repacks the values into the slots of one CKKS ciphertext, for latency.
+	PackingSlots
+)
+
+var packingNames = []string{"coefficients", "slots"}
+
+// ParsePacking returns the packing of the given name (case insensitive), as in the experiment configuration
+func ParsePacking(name string) (Packing, error) {
+	for i, packingName := range packingNames {
+		if strings.EqualFold(name, packingName) {
+			return Packing(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown packing %q, want one of %s", name, strings.Join(packingNames, ", "))
+}
+
+func (packing Packing) String() string {
+	if packing < 0 || int(packing) >= len(packingNames) {
+		return fmt.Sprintf("Packing(%d)", int(packing))
+	}
+	return packingNames[packing]
+}
+
+// LogFVSlots returns the log2 of the number of FV slots of the packing, which is also the number of
+// values and of nonces per plaintext
+func (packing Packing) LogFVSlots(params *RtF.Parameters) int {
+	if packing == PackingSlots {
+		return params.LogSlots()
+	}
+	return params.LogN()
+}
+
+// Repack reports whether HalfBoot repacks the values into a single CKKS ciphertext
+func (packing Packing) Repack() bool {
+	return packing == PackingSlots
+}
+
+// NbHalves returns the number of CKKS ciphertexts HalfBoot outputs per plaintext
+func (packing Packing) NbHalves() int {
+	if packing.Repack() {
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,40 @@
+package keys_dealer
+
+import (
+	"testing"
+
+	"flhhe/src/RtF"
+)
+
+func TestPacking(t *testing.T) {
+	params, err := RtF.NewToyRubatoCipher(RtF.RUBATO128L, RtF.RtFToyN10).HalfBootParams().Params()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name       string
+		packing    Packing
+		logFVSlots int
+		nbHalves   int
+	}{
+		{"coefficients", PackingCoefficients, params.LogN(), 2},
+		{"Slots", PackingSlots, params.LogSlots(), 1},
+	} {
+		packing, err := ParsePacking(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packing != tc.packing {
+			t.Errorf("%s: got packing %s, want %s", tc.name, packing, tc.packing)
+		}
+		if got := packing.LogFVSlots(params); got != tc.logFVSlots {
+			t.Errorf("%s: got %d log FV slots, want %d", tc.name, got, tc.logFVSlots)
+		}
+		if got := packing.NbHalves(); got != tc.nbHalves {
+			t.Errorf("%s: got %d halves, want %d", tc.name, got, tc.nbHalves)
+		}
+	}
+	if _, err := ParsePacking("rows"); err == nil {
+		t.Error("no error for an unknown packing")
+	}
+}
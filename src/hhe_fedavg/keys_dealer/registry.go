@@ -0,0 +1,88 @@
+package keys_dealer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+)
+
+// ErrIdentityConflict is returned when a client registers an identity key other than its registered one
+var ErrIdentityConflict = errors.New("identity key conflict")
+
+// Registry the Ed25519 identity keys of the clients registered with the keys dealer, by client ID. The
+// server verifies the signatures of the uploads with them.
+type Registry map[string]ed25519.PublicKey
+
+// RegisterClient registers the identity key of a client in the registry of keysDir. Registering the
+// same key again is a no-op, another key for a registered client is an ErrIdentityConflict error.
+func RegisterClient(keysDir string, clientID string, key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("client %s: identity key of %d bytes, want %d", clientID, len(key), ed25519.PublicKeySize)
+	}
+	registry, err := LoadRegistry(keysDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if registry == nil {
+		registry = Registry{}
+	}
+	if registered, ok := registry[clientID]; ok {
+		if bytes.Equal(registered, key) {
+			return nil
+		}
+		return fmt.Errorf("client %s: %w, remove it from %s to register a new one", clientID, ErrIdentityConflict, configs.ClientRegistry)
+	}
+	registry[clientID] = key
+
+	ids := make([]string, 0, len(registry))
+	for id := range registry {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	entries := make([]registryEntry, len(ids))
+	for i, id := range ids {
+		entries[i] = registryEntry{ClientID: id, IdentityKey: registry[id]}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(keysDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	return os.WriteFile(filepath.Join(keysDir, configs.ClientRegistry), data, 0644)
+}
+
+// LoadRegistry loads the registry of keysDir, an error wrapping os.ErrNotExist if no client registered
+func LoadRegistry(keysDir string) (Registry, error) {
+	data, err := os.ReadFile(filepath.Join(keysDir, configs.ClientRegistry))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the client registry: %w", err)
+	}
+	var entries []registryEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%w: client registry: %v", RtF.ErrCorruptArtifact, err)
+	}
+	registry := make(Registry, len(entries))
+	for _, entry := range entries {
+		if len(entry.IdentityKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: client registry: identity key of %s of %d bytes", RtF.ErrCorruptArtifact, entry.ClientID, len(entry.IdentityKey))
+		}
+		registry[entry.ClientID] = entry.IdentityKey
+	}
+	return registry, nil
+}
+
+// registryEntry an entry of the registry file, the key is base64 encoded
+type registryEntry struct {
+	ClientID    string `json:"client_id"`
+	IdentityKey []byte `json:"identity_key"`
+}
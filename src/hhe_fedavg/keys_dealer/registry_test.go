@@ -0,0 +1,55 @@
+package keys_dealer
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+)
+
+func TestRegistry(t *testing.T) {
+	keysDir := t.TempDir()
+	if _, err := LoadRegistry(keysDir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadRegistry without registry: got error %v, want %v", err, os.ErrNotExist)
+	}
+
+	key1 := bytes.Repeat([]byte{1}, ed25519.PublicKeySize)
+	key2 := bytes.Repeat([]byte{2}, ed25519.PublicKeySize)
+	for _, tc := range []struct {
+		clientID string
+		key      ed25519.PublicKey
+		want     error
+	}{
+		{"do2", key2, nil},
+		{"do1", key1, nil},
+		{"do1", key1, nil}, // the same key again
+		{"do1", key2, ErrIdentityConflict},
+	} {
+		if err := RegisterClient(keysDir, tc.clientID, tc.key); !errors.Is(err, tc.want) {
+			t.Errorf("RegisterClient(%s): got error %v, want %v", tc.clientID, err, tc.want)
+		}
+	}
+	if err := RegisterClient(keysDir, "do3", key1[:16]); err == nil {
+		t.Error("RegisterClient of a truncated key: no error")
+	}
+
+	registry, err := LoadRegistry(keysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registry) != 2 || !bytes.Equal(registry["do1"], key1) || !bytes.Equal(registry["do2"], key2) {
+		t.Errorf("LoadRegistry: got %v", registry)
+	}
+
+	if err = os.WriteFile(filepath.Join(keysDir, configs.ClientRegistry), []byte(`[{"client_id":"do1","identity_key":"AQI="}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadRegistry(keysDir); !errors.Is(err, RtF.ErrCorruptArtifact) {
+		t.Errorf("LoadRegistry of a short key: got error %v, want %v", err, RtF.ErrCorruptArtifact)
+	}
+}
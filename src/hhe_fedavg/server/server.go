@@ -1,10 +1,13 @@
 package server
 
 import (
+	"errors"
 	"flhhe/configs"
 	"flhhe/src/RtF"
 	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/diagnostics"
 	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/metrics"
 	"flhhe/src/utils"
 	"fmt"
 	"math"
@@ -14,36 +17,117 @@ import (
 	"time"
 )
 
-// RunFLServer is the main entry point for the Federated Learning server
+// NbHalves is the maximum number of CKKS ciphertexts HalfBoot outputs for each FV ciphertext. With the
+// coefficients packing, the client data fills the N coefficients, the first ciphertext holds the values
+// [0, N/2) in its slots and the second one the values [N/2, N). With the slots packing, HalfBoot repacks
+// the Slots values into the first ciphertext only (see keys_dealer.Packing).
+const NbHalves = 2
+
+// CipherIndex returns the index of the CKKS ciphertext holding the given half of the output s
+func CipherIndex(s, half int) int {
+	return s*NbHalves + half
+}
+
+// CipherIndexes returns the indexes of the CKKS ciphertexts of each client, the halves of each output
+// the packing of rubatoParams produces
+func CipherIndexes(rubatoParams *keys_dealer.RubatoParams) []int {
+	indexes := make([]int, 0, rubatoParams.OutputSize*NbHalves)
+	for s := range rubatoParams.OutputSize {
+		for half := range rubatoParams.Packing.NbHalves() {
+			indexes = append(indexes, CipherIndex(s, half))
+		}
+	}
+	return indexes
+}
+
+// ErrClientSkipped is wrapped by the errors of the clients left out of the aggregate, whose upload is
+// corrupt, made for other parameters or rejected by the Verifier
+var ErrClientSkipped = errors.New("client skipped")
+
+// RunFLServer is the main entry point for the Federated Learning server, it returns the IDs of the
+// aggregated clients. The clients whose upload can't be transciphered are skipped: the others are
+// aggregated and the returned error joins those of the skipped clients, each wrapping ErrClientSkipped.
+// Any other error stops the server.
 func RunFLServer(
 	logger utils.Logger,
 	rootPath string,
 	flClients []*client.FLClient,
 	rubatoParams *keys_dealer.RubatoParams,
 	hheComponents *keys_dealer.HHEComponents,
-	rubato RtF.MFVRubato,
-) {
+	rubato RtF.MFVCipher,
+	verifier *Verifier,
+) (clientIDs []string, err error) {
+	logger = logger.With(utils.LogKeyRole, utils.RoleServer)
 	logger.PrintHeader("--- Server (Aggregator / Data Scientist) ---")
 
+	// Load the ciphertexts of the transciphered clients and do HEFedAvg
+	clientIDs, skipped := Transcipher(logger, rootPath, flClients, rubatoParams, hheComponents, rubato, verifier)
+	if len(clientIDs) == 0 {
+		return nil, fmt.Errorf("no client to aggregate: %w", skipped)
+	}
+	if err = HEFedAvg(logger, rootPath, clientIDs, rubatoParams, hheComponents); err != nil {
+		return nil, err
+	}
+	return clientIDs, skipped
+}
+
+// Transcipher turns the symmetric ciphertexts of each client into CKKS ciphertexts saved under
+// configs.HEEncryptedWeights/<clientID>, and returns the IDs of the clients it transciphered. The verifier
+// authenticates each upload first, and records it once transciphered. A client whose upload is corrupt, made for other parameters or
+// rejected by the verifier is skipped, the returned error joins those of the skipped clients, each
+// wrapping ErrClientSkipped.
+func Transcipher(
+	logger utils.Logger,
+	rootPath string,
+	flClients []*client.FLClient,
+	rubatoParams *keys_dealer.RubatoParams,
+	hheComponents *keys_dealer.HHEComponents,
+	rubato RtF.MFVCipher,
+	verifier *Verifier,
+) (clientIDs []string, err error) {
+	if verifier == nil {
+		return nil, errors.New("no verifier of the client uploads")
+	}
+
 	// Load the FV encrypted symmetric key
-	symKeyFVCiphertext := loadSymmetricKey(logger, rootPath, rubatoParams)
+	symKeyFVCiphertext, err := loadSymmetricKey(logger, rootPath, rubatoParams)
+	if err != nil {
+		return nil, err
+	}
 
 	// Process each client
+	var skipped []error
 	for _, flClient := range flClients {
-		processClient(
-			logger,
-			rootPath,
-			flClient,
-			rubatoParams,
-			hheComponents,
-			rubato,
-			symKeyFVCiphertext,
-		)
+		logger := logger.With(utils.LogKeyClientID, flClient.ClientID)
+		t := time.Now()
+		err := flClient.Check(rubatoParams.Params, rubatoParams.OutputSize)
+		if err == nil {
+			err = verifier.Verify(flClient, rubatoParams)
+		}
+		if err == nil {
+			err = processClient(
+				logger,
+				rootPath,
+				flClient,
+				rubatoParams,
+				hheComponents,
+				rubato,
+				symKeyFVCiphertext,
+			)
+		}
+		// Only a transciphered upload counts as received, a failed one can be sent again
+		if err == nil {
+			err = verifier.Record(flClient)
+		}
+		if err != nil {
+			logger.PrintFormatted("[Server] Skipping client %s: %v", flClient.ClientID, err)
+			skipped = append(skipped, fmt.Errorf("%w %s: %w", ErrClientSkipped, flClient.ClientID, err))
+			continue
+		}
+		logger.PrintRunningTime("[Server] Total time to transcipher the client", t)
+		clientIDs = append(clientIDs, flClient.ClientID)
 	}
-
-	// Load the ciphertexts and do HEFedAvg
-	heFedAvg(logger, rootPath, flClients, rubatoParams, hheComponents)
-
+	return clientIDs, errors.Join(skipped...)
 }
 
 // loadSymmetricKey loads the FV encrypted symmetric key
@@ -51,10 +135,10 @@ func loadSymmetricKey(
 	logger utils.Logger,
 	rootPath string,
 	rubatoParams *keys_dealer.RubatoParams,
-) []*RtF.Ciphertext {
+) ([]*RtF.Ciphertext, error) {
 	logger.PrintMessage("[Server - Offline] Loading the FV encrypted symmetric key")
 	keysDir := filepath.Join(rootPath, configs.Keys)
-	symCipherDir := filepath.Join(keysDir, configs.SymmetricKeyCipherDir)
+	symCipherDir := filepath.Join(keys_dealer.SymmetricKeyDir(keysDir, rubatoParams), configs.SymmetricKeyCipherDir)
 	logger.PrintFormatted("Symmetric key ciphertext directory: %s", symCipherDir)
 	return keys_dealer.LoadCiphertextArray(symCipherDir, rubatoParams.Params)
 }
@@ -66,17 +150,22 @@ func processClient(
 	flClient *client.FLClient,
 	rubatoParams *keys_dealer.RubatoParams,
 	hheComponents *keys_dealer.HHEComponents,
-	rubato RtF.MFVRubato,
+	rubato RtF.MFVCipher,
 	symKeyFVCiphertext []*RtF.Ciphertext,
-) {
+) error {
 	logger.PrintMessage(fmt.Sprintf("--- Processing client %s ---", flClient.ClientID))
 
-	// Reset the rubato instance before processing
-	rubato.Reset(rubatoParams.RubatoModDown[0])
+	// Reset the cipher instance before processing
+	if err := rubato.Reset(rubatoParams.RubatoModDown[0]); err != nil {
+		return err
+	}
 
 	// Generate and process keystreams (Z)
 	t := time.Now()
-	fvKeyStreams := generateKeystreams(logger, flClient, rubatoParams, hheComponents, rubato, symKeyFVCiphertext)
+	fvKeyStreams, err := generateKeystreams(logger, flClient, rubatoParams, hheComponents, rubato, symKeyFVCiphertext)
+	if err != nil {
+		return err
+	}
 	logger.PrintRunningTime("[Server - Offline] Total time to produce Z", t)
 
 	// Scales up the symmetric ciphertext into FV-ciphertext space (C)
@@ -86,27 +175,34 @@ func processClient(
 
 	// Transciphers the symmetric ciphertext into CKKS ciphertext (M)
 	t = time.Now()
-	transciphering(logger, rootPath, flClient, rubatoParams, hheComponents, fvKeyStreams, plaintexts)
+	if err := transciphering(logger, rootPath, flClient, rubatoParams, hheComponents, fvKeyStreams, plaintexts); err != nil {
+		return err
+	}
 	logger.PrintRunningTime("[Server - Online] Total time to transcipher, verify the accuracy of the ciphertext and save the ciphertext", t)
+	return nil
 }
 
-// generateKeystreams evaluates the keystreams and performs linear transformation
+// generateKeystreams evaluates the keystreams and performs linear transformation, it returns an
+// RtF.ErrParamMismatch error if the nonces of the client or the encrypted key don't fit the parameters
 func generateKeystreams(
 	logger utils.Logger,
 	flClient *client.FLClient,
 	rubatoParams *keys_dealer.RubatoParams,
 	hheComponents *keys_dealer.HHEComponents,
-	rubato RtF.MFVRubato,
+	rubato RtF.MFVCipher,
 	symKeyFVCiphertext []*RtF.Ciphertext,
-) []*RtF.Ciphertext {
+) ([]*RtF.Ciphertext, error) {
 	// Evaluate keystreams
 	logger.PrintMessage("[Server - Offline] Evaluates the keystreams (Eval^{FV}) to produce V")
 	t := time.Now()
-	fvKeyStreams := rubato.CryptNoModSwitch(
+	fvKeyStreams, err := rubato.CryptNoModSwitch(
 		flClient.Nonces,
 		flClient.Counter,
 		symKeyFVCiphertext,
 	)
+	if err != nil {
+		return nil, err
+	}
 	logger.PrintRunningTime("Time to evaluate the keystreams (Eval^{FV}) to produce V", t)
 	logger.PrintFormatted("Keystreams dimension: [%d]", len(fvKeyStreams))
 
@@ -114,12 +210,14 @@ func generateKeystreams(
 	logger.PrintMessage("[Server - Offline] Performs linear transformation SlotToCoeffs^{FV} to produce Z")
 	t = time.Now()
 	for i := range rubatoParams.OutputSize {
-		fvKeyStreams[i] = hheComponents.FvEvaluator.SlotsToCoeffs(fvKeyStreams[i], rubatoParams.StcModDown)
+		if fvKeyStreams[i], err = hheComponents.FvEvaluator.SlotsToCoeffs(fvKeyStreams[i], rubatoParams.StcModDown); err != nil {
+			return nil, err
+		}
 		hheComponents.FvEvaluator.ModSwitchMany(fvKeyStreams[i], fvKeyStreams[i], fvKeyStreams[i].Level())
 	}
 	logger.PrintRunningTime("Time to perform linear transformation SlotToCoeffs^{FV} to produce Z", t)
 
-	return fvKeyStreams
+	return fvKeyStreams, nil
 }
 
 // fvScaleUpSymCipher scales up the symmetric ciphertext into FV-ciphertext space
@@ -151,7 +249,7 @@ func transciphering(
 	hheComponents *keys_dealer.HHEComponents,
 	fvKeyStreams []*RtF.Ciphertext,
 	plaintexts []*RtF.Plaintext,
-) {
+) error {
 	logger.PrintMessage("[Server - Online] Transciphering the symmetric ciphertext into CKKS ciphertext (produce M)")
 
 	for s := range rubatoParams.OutputSize {
@@ -166,23 +264,39 @@ func transciphering(
 		setScale(ciphertext, rubatoParams)
 
 		// Perform half-bootstrapping
-		ctBoot := performHalfBoot(logger, ciphertext, hheComponents)
+		ctBoot, err := performHalfBoot(logger, ciphertext, rubatoParams.Packing, hheComponents)
+		if err != nil {
+			return err
+		}
 
 		logger.PrintRunningTime("[Server - Online] Total time to transcipher to produce M", t)
 
-		// Generate debug values
-		valuesWant := generateDebugValues(flClient, rubatoParams, s)
-
-		// Print debug information
-		printString := fmt.Sprintf("Precision of HalfBoot(ciphertext[%d]): ", s)
-		logger.PrintMessage(printString)
-		PrintDebug(logger, rubatoParams.Params, ctBoot, valuesWant, hheComponents.CkksDecryptor, hheComponents.CkksEncoder)
-
-		// Save the ciphertext
 		cipherDir := filepath.Join(rootPath, configs.HEEncryptedWeights, flClient.ClientID)
-		os.MkdirAll(cipherDir, 0755)
-		SaveCipher(logger, s, cipherDir, ctBoot)
+		if err := os.MkdirAll(cipherDir, 0755); err != nil {
+			return err
+		}
+		for half := range rubatoParams.Packing.NbHalves() {
+			// The plaintext data is only known when the client runs in the same process
+			if flClient.PlaintextData != nil {
+				// Generate debug values
+				valuesWant := generateDebugValues(flClient, rubatoParams, s, half)
+
+				// Print debug information
+				printString := fmt.Sprintf("Precision of HalfBoot(ciphertext[%d])[%d]: ", s, half)
+				logger.PrintMessage(printString)
+				if err := PrintDebug(logger, rubatoParams.Params, ctBoot[half], valuesWant, hheComponents.CkksDecryptor, hheComponents.CkksEncoder); err != nil {
+					return err
+				}
+			}
+
+			// Save the ciphertext
+			if err := SaveCipher(logger, CipherIndex(s, half), cipherDir, ctBoot[half]); err != nil {
+				return err
+			}
+			metrics.Default().Add(metrics.Ciphertexts, utils.RoleServer, flClient.ClientID, 1)
+		}
 	}
+	return nil
 }
 
 // createInitialCiphertext creates and initializes a new ciphertext
@@ -206,63 +320,138 @@ func setScale(
 	ciphertext.SetScale(scale)
 }
 
-// performHalfBoot performs the half-bootstrapping operation (M)
+// performHalfBoot performs the half-bootstrapping operation (M). Without repacking, HalfBoot
+// outputs the coefficients [0, N/2) and [N/2, N) of X in the slots of two CKKS-ciphertexts. With the
+// slots packing, it repacks them into the slots of the first one and the second one is nil.
 func performHalfBoot(
 	logger utils.Logger,
 	ciphertext *RtF.Ciphertext,
+	packing keys_dealer.Packing,
 	hheComponents *keys_dealer.HHEComponents,
-) *RtF.Ciphertext {
-	logger.PrintMessage("Halfboot X and outputs a CKKS-ciphertext M containing the CKKS encrypted messages in its slots")
+) ([NbHalves]*RtF.Ciphertext, error) {
+	logger.PrintFormatted("Halfboot X and outputs %d CKKS-ciphertext(s) M containing the CKKS encrypted messages in their slots", packing.NbHalves())
 	t := time.Now()
-	ctBoot, _ := hheComponents.HalfBootstrapper.HalfBoot(ciphertext, false)
+	ct0, ct1, err := hheComponents.HalfBootstrapper.HalfBoot(ciphertext, packing.Repack())
+	if err != nil {
+		return [NbHalves]*RtF.Ciphertext{}, err
+	}
 	logger.PrintRunningTime("HalfBoot", t)
-	return ctBoot
+	return [NbHalves]*RtF.Ciphertext{ct0, ct1}, nil
 }
 
-func heFedAvg(
+// HEFedAvg averages the transciphered CKKS ciphertexts of the given clients and saves the result
+// under configs.HEEncryptedWeights/avg
+func HEFedAvg(
 	logger utils.Logger,
 	rootPath string,
-	flClients []*client.FLClient,
+	clientIDs []string,
 	rubatoParams *keys_dealer.RubatoParams,
 	hheComponents *keys_dealer.HHEComponents,
-) {
+) error {
 	logger.PrintMessage("[Server - Online] HEFedAvg")
 
+	// Check that the levels left after HalfBoot are enough for the aggregation
+	plan, err := RtF.PlanCKKS(rubatoParams.HalfBsParams, RtF.HalfBootOutput(rubatoParams.HalfBsParams, rubatoParams.PlainModulus), FedAvgOps(len(clientIDs)), 0)
+	if err != nil {
+		return err
+	}
+	logger.PrintFormatted("CKKS plan of the aggregation:\n%s", plan)
+
 	// Load the ciphertexts
-	ciphertexts := make([][]*RtF.Ciphertext, len(flClients))
-	for i := range flClients {
-		ciphertexts[i] = make([]*RtF.Ciphertext, rubatoParams.OutputSize)
-		cipherDir := filepath.Join(rootPath, configs.HEEncryptedWeights, flClients[i].ClientID)
-		for j := range rubatoParams.OutputSize {
-			ciphertexts[i][j] = LoadCipher(logger, j, cipherDir, rubatoParams.Params)
+	indexes := CipherIndexes(rubatoParams)
+	nbCiphers := len(indexes)
+	ciphertexts := make([][]*RtF.Ciphertext, len(clientIDs))
+	for i := range clientIDs {
+		ciphertexts[i] = make([]*RtF.Ciphertext, nbCiphers)
+		cipherDir := filepath.Join(rootPath, configs.HEEncryptedWeights, clientIDs[i])
+		for j, index := range indexes {
+			if ciphertexts[i][j], err = LoadCipher(logger, index, cipherDir, rubatoParams.Params); err != nil {
+				return fmt.Errorf("client %s: %w", clientIDs[i], err)
+			}
 		}
 	}
 	logger.PrintFormatted("Ciphertexts: %+v", ciphertexts)
 
 	// Do HEFedAvg
 	t := time.Now()
-	avgCiphertexts := make([]*RtF.Ciphertext, rubatoParams.OutputSize)
-	for i := range rubatoParams.OutputSize {
+	avgCiphertexts := make([]*RtF.Ciphertext, nbCiphers)
+	for i := range nbCiphers {
 		avgCiphertexts[i] = ciphertexts[0][i].CopyNew().Ciphertext()
-		for j := 1; j < len(flClients); j++ {
+		for j := 1; j < len(clientIDs); j++ {
 			avgCiphertexts[i] = hheComponents.CkksEvaluator.AddNew(avgCiphertexts[i], ciphertexts[j][i])
 		}
 	}
-	for i := range rubatoParams.OutputSize {
-		avgCiphertexts[i] = hheComponents.CkksEvaluator.MultByConstNew(avgCiphertexts[i], 1/float64(len(flClients)))
+	for i := range nbCiphers {
+		avgCiphertexts[i] = hheComponents.CkksEvaluator.MultByConstNew(avgCiphertexts[i], 1/float64(len(clientIDs)))
 	}
 	logger.PrintRunningTime("Time to aggregate the ciphertexts", t)
 	logger.PrintFormatted("AvgCiphertexts: %+v", avgCiphertexts)
 
+	// Refresh the average for a deeper post-processing, the diagnostics keep the levels of the aggregation
+	outputCiphertexts := avgCiphertexts
+	if hheComponents.Bootstrapper != nil {
+		outputCiphertexts = Bootstrap(logger, hheComponents.Bootstrapper, avgCiphertexts)
+	}
+
 	// Save the average ciphertexts
 	avgCiphertextsDir := filepath.Join(rootPath, configs.HEEncryptedWeights, "avg")
-	os.MkdirAll(avgCiphertextsDir, 0755)
-	for i := range rubatoParams.OutputSize {
-		SaveCipher(logger, i, avgCiphertextsDir, avgCiphertexts[i])
+	if err := os.MkdirAll(avgCiphertextsDir, 0755); err != nil {
+		return err
+	}
+	for i, index := range indexes {
+		if err := SaveCipher(logger, index, avgCiphertextsDir, outputCiphertexts[i]); err != nil {
+			return err
+		}
 	}
 	logger.PrintFormatted("AvgCiphertexts saved to %s", avgCiphertextsDir)
+	metrics.Default().Add(metrics.Ciphertexts, utils.RoleServer, "", float64(len(outputCiphertexts)))
+
+	// Compute the encrypted client diagnostics, released to the key holder only
+	halfBootOutput := RtF.HalfBootOutput(rubatoParams.HalfBsParams, rubatoParams.PlainModulus)
+	if err := diagnostics.CheckKeys(rubatoParams.Params, hheComponents.RotKeys, rubatoParams.HalfBsParams, nbCiphers, halfBootOutput, plan.Final()); err != nil {
+		logger.PrintFormatted("Skipping the client diagnostics: %v", err)
+	} else {
+		d, err := diagnostics.Compute(logger, rubatoParams.Params, hheComponents.CkksEvaluator, clientIDs, ciphertexts, avgCiphertexts)
+		if err != nil {
+			return err
+		}
+		if err = diagnostics.Save(logger, filepath.Join(rootPath, configs.Diagnostics), d); err != nil {
+			return err
+		}
+	}
 
 	logger.PrintMessage("[Server - Online] HEFedAvg done")
+	return nil
+}
+
+// FedAvgOps returns the CKKS operations of HEFedAvg on the ciphertexts of nbClients clients: their sum
+// and its product by 1/nbClients, which multiplies the scale by a modulus without consuming a level
+func FedAvgOps(nbClients int) []RtF.CKKSOp {
+	return []RtF.CKKSOp{
+		{Kind: RtF.CKKSAdd, Count: nbClients},
+		{Kind: RtF.CKKSMultByConst, Constant: 1 / float64(nbClients)},
+	}
+}
+
+// Bootstrap refreshes copies of the ciphertexts with a full bootstrapping, they are output at the level
+// len(BootstrappingParameters.ResidualModuli)-1 with the default scale
+func Bootstrap(
+	logger utils.Logger,
+	bootstrapper *RtF.Bootstrapper,
+	ciphertexts []*RtF.Ciphertext,
+) []*RtF.Ciphertext {
+	logger.PrintMessage("[Server - Online] Bootstrapping the average")
+	t := time.Now()
+	bootstrapped := make([]*RtF.Ciphertext, len(ciphertexts))
+	for i := range ciphertexts {
+		bootstrapped[i] = bootstrapper.Bootstrapp(ciphertexts[i].CopyNew().Ciphertext())
+	}
+	logger.PrintRunningTime("Time to bootstrap the ciphertexts", t)
+	if len(bootstrapped) > 0 {
+		logger.PrintFormatted("Bootstrapped ciphertexts at level %d with scale 2^%.2f",
+			bootstrapped[0].Level(), math.Log2(bootstrapped[0].Scale()))
+	}
+	return bootstrapped
 }
 
 // generateDebugValues creates values for debugging and precision checking
@@ -270,37 +459,97 @@ func generateDebugValues(
 	flClient *client.FLClient,
 	rubatoParams *keys_dealer.RubatoParams,
 	index int,
+	half int,
 ) []complex128 {
-	valuesWant := make([]complex128, rubatoParams.Params.Slots())
-	for i := range rubatoParams.Params.Slots() {
-		valuesWant[i] = complex(flClient.PlaintextData[index][i], 0)
+	return SplitHalves(flClient.PlaintextData[index], rubatoParams.Params.Slots())[half]
+}
+
+// SplitHalves splits the N values of an output into the slots expected in each HalfBoot output
+func SplitHalves(values []float64, slots int) [NbHalves][]complex128 {
+	var halves [NbHalves][]complex128
+	for half := range NbHalves {
+		halves[half] = make([]complex128, slots)
+		for i := range slots {
+			if j := half*slots + i; j < len(values) {
+				halves[half][i] = complex(values[j], 0)
+			}
+		}
+	}
+	return halves
+}
+
+// MergeHalves concatenates the decoded slots of the HalfBoot outputs back into the N values of an output
+func MergeHalves(halves [NbHalves][]complex128) []complex128 {
+	values := make([]complex128, 0, NbHalves*len(halves[0]))
+	for _, half := range halves {
+		values = append(values, half...)
+	}
+	return values
+}
+
+// LoadOutput loads the CKKS ciphertexts holding the halves of the output s, those the packing doesn't
+// produce are nil
+func LoadOutput(
+	logger utils.Logger,
+	s int,
+	packing keys_dealer.Packing,
+	ciphersDir string,
+	params *RtF.Parameters) (ciphertexts [NbHalves]*RtF.Ciphertext, err error) {
+	for half := range packing.NbHalves() {
+		if ciphertexts[half], err = LoadCipher(logger, CipherIndex(s, half), ciphersDir, params); err != nil {
+			return ciphertexts, err
+		}
 	}
-	return valuesWant
+	return ciphertexts, nil
 }
 
+// DecryptOutput decrypts the halves of an output into its values, N with the coefficients packing and
+// Slots with the slots one (the nil halves are skipped)
+func DecryptOutput(
+	params *RtF.Parameters,
+	ciphertexts [NbHalves]*RtF.Ciphertext,
+	decryptor RtF.CKKSDecryptor,
+	encoder RtF.CKKSEncoder) []complex128 {
+	var halves [NbHalves][]complex128
+	for half, ciphertext := range ciphertexts {
+		if ciphertext == nil {
+			continue
+		}
+		halves[half] = encoder.DecodeComplex(decryptor.DecryptNew(ciphertext), params.LogSlots())
+	}
+	return MergeHalves(halves)
+}
+
+// SaveCipher saves the ciphertext of the given index in ciphersDir
 func SaveCipher(
 	logger utils.Logger,
 	index int,
 	ciphersDir string,
-	ciphertext *RtF.Ciphertext) {
-	var err error
+	ciphertext *RtF.Ciphertext) error {
 	fileName := configs.CtNameFix + strconv.Itoa(index) + configs.CtFormat
-	err = utils.Serialize(ciphertext, filepath.Join(ciphersDir, fileName))
-	utils.HandleError(err)
+	if err := utils.Serialize(ciphertext, filepath.Join(ciphersDir, fileName)); err != nil {
+		return err
+	}
 	logger.PrintFormatted("Ciphertext saved to %s", filepath.Join(ciphersDir, fileName))
+	return nil
 }
 
-// LoadCipher loads a ciphertext from the provided path
+// LoadCipher loads a ciphertext from the provided path, an RtF.ErrCorruptArtifact error if it can't be
+// decoded and an RtF.ErrParamMismatch one if it isn't in the ring of params
 func LoadCipher(
 	logger utils.Logger,
 	index int,
 	ciphersDir string,
-	params *RtF.Parameters) *RtF.Ciphertext {
+	params *RtF.Parameters) (*RtF.Ciphertext, error) {
 	fileName := configs.CtNameFix + strconv.Itoa(index) + configs.CtFormat
 	ciphertext := RtF.NewCiphertextFVLvl(params, 1, 0)
-	err := utils.Deserialize(ciphertext, filepath.Join(ciphersDir, fileName))
-	utils.HandleError(err)
-	return ciphertext
+	if err := keys_dealer.Deserialize(ciphertext, filepath.Join(ciphersDir, fileName)); err != nil {
+		return nil, err
+	}
+	if err := RtF.CheckCiphertext(params, ciphertext); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return ciphertext, nil
 }
 
 func PrintDebug(
@@ -309,7 +558,7 @@ func PrintDebug(
 	ciphertext *RtF.Ciphertext,
 	valuesWant []complex128,
 	decryptor RtF.CKKSDecryptor,
-	encoder RtF.CKKSEncoder) {
+	encoder RtF.CKKSEncoder) error {
 	if utils.DEBUG {
 		logger.PrintMessage("--- Print Debug ---")
 		valuesTest := encoder.DecodeComplex(decryptor.DecryptNew(ciphertext), params.LogSlots())
@@ -323,7 +572,11 @@ func PrintDebug(
 		logger.PrintFormatted("ValuesTest{%d}: [%6.10f %6.10f %6.10f %6.10f...]", len(valuesTest), valuesTest[0], valuesTest[1], valuesTest[2], valuesTest[3])
 		logger.PrintFormatted("ValuesWant{%d}: [%6.10f %6.10f %6.10f %6.10f...]", len(valuesWant), valuesWant[0], valuesWant[1], valuesWant[2], valuesWant[3])
 
-		precisionStats := RtF.GetPrecisionStats(params, encoder, nil, valuesWant, valuesTest, logSlots, sigma)
-		fmt.Println(precisionStats.String())
+		precisionStats, err := RtF.GetPrecisionStats(params, encoder, nil, valuesWant, valuesTest, logSlots, sigma)
+		if err != nil {
+			return err
+		}
+		logger.PrintMessage(precisionStats.String())
 	}
+	return nil
 }
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/metrics"
+	"flhhe/src/utils"
+)
+
+func TestSplitMergeHalves(t *testing.T) {
+	slots := 8
+	values := make([]float64, NbHalves*slots)
+	for i := range values {
+		values[i] = float64(i + 1)
+	}
+
+	halves := SplitHalves(values, slots)
+	for half := range NbHalves {
+		if len(halves[half]) != slots {
+			t.Fatalf("half %d: got %d slots, want %d", half, len(halves[half]), slots)
+		}
+	}
+	merged := MergeHalves(halves)
+	if len(merged) != len(values) {
+		t.Fatalf("got %d values, want %d", len(merged), len(values))
+	}
+	for i, v := range values {
+		if merged[i] != complex(v, 0) {
+			t.Fatalf("value %d: got %v, want %v", i, merged[i], v)
+		}
+	}
+
+	// Shorter outputs are zero padded
+	halves = SplitHalves(values[:slots+1], slots)
+	if halves[1][0] != complex(values[slots], 0) || halves[1][1] != 0 {
+		t.Errorf("padding: got %v", halves[1][:2])
+	}
+}
+
+func TestDecryptOutput(t *testing.T) {
+	params := RtF.DefaultParams[RtF.PN13QP218].WithPlainModulus(RtF.RubatoParams[RtF.RUBATO128S].PlainModulus)
+	kgen := RtF.NewKeyGenerator(params)
+	sk, pk, err := kgen.GenKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := RtF.NewCKKSEncoder(params)
+	encryptor := RtF.NewCKKSEncryptorFromPk(params, pk)
+	decryptor := RtF.NewCKKSDecryptor(params, sk)
+
+	// The N values of an output, every one of them must be recovered
+	values := make([]float64, params.N())
+	for i := range values {
+		values[i] = utils.RandFloat64(-1, 1)
+	}
+
+	var ciphertexts [NbHalves]*RtF.Ciphertext
+	for half, slots := range SplitHalves(values, params.Slots()) {
+		plaintext := encoder.EncodeComplexNew(slots, params.LogSlots())
+		if ciphertexts[half], err = encryptor.EncryptNew(plaintext); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	have := DecryptOutput(params, ciphertexts, decryptor, encoder)
+	if len(have) != params.N() {
+		t.Fatalf("got %d values, want %d", len(have), params.N())
+	}
+	for i, v := range values {
+		if math.Abs(real(have[i])-v) > 1e-3 {
+			t.Fatalf("value %d: got %f, want %f", i, real(have[i]), v)
+		}
+	}
+}
+
+// toyKeysDealer runs the keys dealer of the INSECURE toy parameters under a temporary root
+func toyKeysDealer(t *testing.T, packing keys_dealer.Packing, bootstrap bool) (
+	string, *keys_dealer.RubatoParams, *keys_dealer.HHEComponents, RtF.MFVCipher) {
+	t.Helper()
+	rootPath := t.TempDir()
+	cipher := RtF.NewToyRubatoCipher(RtF.RUBATO128L, RtF.RtFToyN10)
+	rubatoParams, hheComponents, rubato, err := keys_dealer.RunKeysDealer(utils.NewLogger(false), rootPath, cipher, packing, bootstrap, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rootPath, rubatoParams, hheComponents, rubato
+}
+
+// TestTranscipher checks that the server transciphers a signed upload and records it, and skips the
+// uploads made for other parameters, tampered, of an unregistered client or replayed
+func TestTranscipher(t *testing.T) {
+	logger := utils.NewLogger(false).With(utils.LogKeyRole, utils.RoleServer)
+	rootPath, rubatoParams, hheComponents, rubato := toyKeysDealer(t, keys_dealer.PackingCoefficients, false)
+	seed := func(b byte) []byte { return bytes.Repeat([]byte{b}, RtF.PRNGSeedSize) }
+
+	do1 := signedUpload(t, rootPath, rubatoParams, "do1", 0, seed(1))
+	do2 := signedUpload(t, rootPath, rubatoParams, "do2", 0, seed(2))
+	mismatch := signedUpload(t, rootPath, rubatoParams, "do3", 0, seed(3))
+	mismatch.Nonces = mismatch.Nonces[:len(mismatch.Nonces)/2]
+	tampered := *signedUpload(t, rootPath, rubatoParams, "do4", 0, seed(4))
+	tampered.SymmCipher = []*RtF.PlaintextRingT{RtF.NewPlaintextRingT(rubatoParams.Params), tampered.SymmCipher[1]}
+	intruder := *do2
+	intruder.ClientID = "intruder"
+	verifier, err := LoadVerifier(filepath.Join(rootPath, configs.Keys), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = verifier.Record(do1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a client left, nothing is aggregated
+	if _, err = RunFLServer(logger, rootPath, []*client.FLClient{do1}, rubatoParams, hheComponents, rubato, verifier); !errors.Is(err, ErrReplay) {
+		t.Errorf("RunFLServer of a replay: got error %v, want %v", err, ErrReplay)
+	}
+
+	metrics.Default().Reset()
+	uploads := []*client.FLClient{do2, mismatch, &tampered, &intruder, do1}
+	transciphered, err := Transcipher(logger, rootPath, uploads, rubatoParams, hheComponents, rubato, verifier)
+	for _, want := range []error{ErrClientSkipped, RtF.ErrParamMismatch, ErrUnauthenticated, ErrReplay} {
+		if !errors.Is(err, want) {
+			t.Errorf("Transcipher: got error %v, want an error wrapping %v", err, want)
+		}
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 4 {
+		t.Errorf("Transcipher: got error %v, want the 4 clients skipped", err)
+	}
+	if fmt.Sprint(transciphered) != fmt.Sprint([]string{do2.ClientID}) {
+		t.Errorf("Transcipher: transciphered %v, want %v", transciphered, []string{do2.ClientID})
+	}
+
+	// The transciphered upload is recorded, its replay is rejected
+	if err = verifier.Verify(do2, rubatoParams); !errors.Is(err, ErrReplay) {
+		t.Errorf("Verify of a transciphered upload: got error %v, want %v", err, ErrReplay)
+	}
+
+	// Only the transciphered client has a latency and ciphertexts
+	report := metrics.Default().Report()
+	latencies := make(map[string]int)
+	for _, p := range report.Phases {
+		if p.Role == utils.RoleServer && p.Phase == "[Server] Total time to transcipher the client" {
+			latencies[p.ClientID] = p.Count
+		}
+	}
+	ciphertexts := make(map[string]float64)
+	for _, c := range report.Counters {
+		if c.Role == utils.RoleServer && c.Name == metrics.Ciphertexts {
+			ciphertexts[c.ClientID] = c.Value
+		}
+	}
+	for _, c := range uploads {
+		wantLatencies, wantCiphertexts := 0, 0.0
+		if c == do2 {
+			wantLatencies, wantCiphertexts = 1, float64(rubatoParams.OutputSize*rubatoParams.Packing.NbHalves())
+		}
+		if latencies[c.ClientID] != wantLatencies || ciphertexts[c.ClientID] != wantCiphertexts {
+			t.Errorf("metrics of client %s: %d transciphering latencies and %.0f ciphertexts, want %d and %.0f",
+				c.ClientID, latencies[c.ClientID], ciphertexts[c.ClientID], wantLatencies, wantCiphertexts)
+		}
+	}
+}
+
+// TestHEFedAvg checks the level and scale of the average against the CKKS plan of the aggregation,
+// and with the full bootstrapping against the output of the bootstrapping, and its precision
+func TestHEFedAvg(t *testing.T) {
+	logger := utils.NewLogger(false)
+	rootPath, rubatoParams, hheComponents, _ := toyKeysDealer(t, keys_dealer.PackingSlots, true)
+	params := rubatoParams.Params
+	hb := rubatoParams.HalfBsParams
+	pk := new(RtF.PublicKey)
+	if err := keys_dealer.Deserialize(pk, filepath.Join(rootPath, configs.Keys, configs.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+	encryptor := RtF.NewCKKSEncryptorFromPk(params, pk)
+
+	// The clients' ciphertexts as HalfBoot outputs them
+	clientIDs := []string{"do1", "do2", "do3"}
+	output := RtF.HalfBootOutput(hb, rubatoParams.PlainModulus)
+	indexes := CipherIndexes(rubatoParams)
+	want := make([][]complex128, len(indexes))
+	for j := range indexes {
+		want[j] = make([]complex128, params.Slots())
+	}
+	for _, id := range clientIDs {
+		cipherDir := filepath.Join(rootPath, configs.HEEncryptedWeights, id)
+		if err := os.MkdirAll(cipherDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for j, index := range indexes {
+			values := make([]complex128, params.Slots())
+			for i := range values {
+				values[i] = complex(utils.RandFloat64(-1, 1), 0)
+				want[j][i] += values[i] / complex(float64(len(clientIDs)), 0)
+			}
+			pt := RtF.NewPlaintextCKKS(params, output.Level, math.Exp2(output.LogScale))
+			hheComponents.CkksEncoder.EncodeComplex(pt, values, params.LogSlots())
+			ct, err := encryptor.EncryptNew(pt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = SaveCipher(logger, index, cipherDir, ct); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	plan, err := RtF.PlanCKKS(hb, output, FedAvgOps(len(clientIDs)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootstrapper := hheComponents.Bootstrapper
+	metrics.Default().Reset()
+	for _, bootstrap := range []bool{false, true} {
+		wantLevel, wantLogScale := plan.Final().Level, plan.Final().LogScale
+		hheComponents.Bootstrapper = nil
+		if bootstrap {
+			wantLevel, wantLogScale = len(rubatoParams.BtpParams.ResidualModuli)-1, math.Log2(hb.Scale)
+			hheComponents.Bootstrapper = bootstrapper
+		}
+		if err = HEFedAvg(logger, rootPath, clientIDs, rubatoParams, hheComponents); err != nil {
+			t.Fatal(err)
+		}
+		for j, index := range indexes {
+			ct, err := LoadCipher(logger, index, filepath.Join(rootPath, configs.HEEncryptedWeights, "avg"), params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ct.Level() != wantLevel || math.Abs(math.Log2(ct.Scale())-wantLogScale) > 0.5 {
+				t.Errorf("bootstrap=%t, ciphertext %d: level %d and scale 2^%.2f, want %d and 2^%.2f", bootstrap, index,
+					ct.Level(), math.Log2(ct.Scale()), wantLevel, wantLogScale)
+			}
+			have := hheComponents.CkksEncoder.DecodeComplex(hheComponents.CkksDecryptor.DecryptNew(ct), params.LogSlots())
+			stats, err := RtF.GetPrecisionStats(params, hheComponents.CkksEncoder, nil, want[j], have, params.LogSlots(), params.Sigma())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if real(stats.MinPrecision) < 14 {
+				t.Errorf("bootstrap=%t, ciphertext %d: min precision %.2f below 14", bootstrap, index, real(stats.MinPrecision))
+			}
+		}
+	}
+
+	// The aggregate counts its ciphertexts without a client ID
+	aggregate := 0.0
+	for _, c := range metrics.Default().Report().Counters {
+		if c.Role == utils.RoleServer && c.Name == metrics.Ciphertexts && c.ClientID == "" {
+			aggregate = c.Value
+		}
+	}
+	if aggregate != float64(2*len(indexes)) {
+		t.Errorf("metrics: %.0f aggregate ciphertexts over both runs, want %d", aggregate, 2*len(indexes))
+	}
+}
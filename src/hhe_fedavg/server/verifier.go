@@ -0,0 +1,129 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+)
+
+// ErrUnauthenticated is wrapped by the errors of the uploads of unregistered clients, unsigned or whose
+// signature doesn't match: forged or tampered with on the way
+var ErrUnauthenticated = errors.New("unauthenticated upload")
+
+// ErrReplay is wrapped by the errors of the uploads made for another round, or already accepted
+var ErrReplay = errors.New("replayed upload")
+
+// Verifier authenticates the client uploads before they are transciphered: their signature by the
+// identity key the client registered with the keys dealer, and that they are fresh, made for the
+// current round and not accepted before, which the ledger of the accepted uploads tells. An upload is
+// only recorded in the ledger once transciphered (Record), a client whose upload fails on the server
+// can send it again.
+type Verifier struct {
+	registry   keys_dealer.Registry
+	round      int
+	ledgerPath string
+	ledger     []LedgerEntry
+}
+
+// LedgerEntry an upload the server accepted
+type LedgerEntry struct {
+	ClientID  string `json:"client_id"`
+	Round     int    `json:"round"`
+	NonceSeed []byte `json:"nonce_seed"`
+}
+
+// NewVerifier returns the verifier of the uploads of the given round. The accepted uploads are recorded in
+// the ledger at ledgerPath, loaded if it exists, to reject their replays in the next runs of the server;
+// in memory only if ledgerPath is empty.
+func NewVerifier(registry keys_dealer.Registry, round int, ledgerPath string) (*Verifier, error) {
+	v := &Verifier{registry: registry, round: round, ledgerPath: ledgerPath}
+	if ledgerPath == "" {
+		return v, nil
+	}
+	data, err := os.ReadFile(ledgerPath)
+	if os.IsNotExist(err) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the upload ledger: %v", err)
+	}
+	if err = json.Unmarshal(data, &v.ledger); err != nil {
+		return nil, fmt.Errorf("%w: upload ledger: %v", RtF.ErrCorruptArtifact, err)
+	}
+	return v, nil
+}
+
+// LoadVerifier returns the verifier of the uploads of the given round with the registry of the keys
+// dealer in keysDir
+func LoadVerifier(keysDir string, round int, ledgerPath string) (*Verifier, error) {
+	registry, err := keys_dealer.LoadRegistry(keysDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewVerifier(registry, round, ledgerPath)
+}
+
+// Verify authenticates the upload of a client, without recording it. The errors wrap ErrUnauthenticated
+// or ErrReplay.
+func (v *Verifier) Verify(flClient *client.FLClient, rubatoParams *keys_dealer.RubatoParams) error {
+	key, ok := v.registry[flClient.ClientID]
+	if !ok {
+		return fmt.Errorf("%w: client %s isn't registered", ErrUnauthenticated, flClient.ClientID)
+	}
+	if err := flClient.Verify(key, rubatoParams.Packing, rubatoParams.Params); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	if flClient.Round != v.round {
+		return fmt.Errorf("%w: upload of round %d, the server is at round %d", ErrReplay, flClient.Round, v.round)
+	}
+	for _, entry := range v.ledger {
+		switch {
+		case entry.ClientID == flClient.ClientID && entry.Round == flClient.Round:
+			return fmt.Errorf("%w: client %s already uploaded for round %d", ErrReplay, flClient.ClientID, v.round)
+		case string(entry.NonceSeed) == string(flClient.NonceSeed):
+			return fmt.Errorf("%w: nonce seed of an upload of client %s in round %d", ErrReplay, entry.ClientID, entry.Round)
+		}
+	}
+	return nil
+}
+
+// Record records the upload of a client in the ledger, once Verify accepted it and the server
+// transciphered it
+func (v *Verifier) Record(flClient *client.FLClient) error {
+	v.ledger = append(v.ledger, LedgerEntry{ClientID: flClient.ClientID, Round: flClient.Round, NonceSeed: flClient.NonceSeed})
+	return v.save()
+}
+
+// Recorded reports whether the ledger recorded an upload of the client for the round of the verifier,
+// i.e. whether the server transciphered one in that round. The ciphertexts of the other clients under
+// configs.HEEncryptedWeights are left from an earlier round, or from an upload the server rejected.
+func (v *Verifier) Recorded(clientID string) bool {
+	for _, entry := range v.ledger {
+		if entry.ClientID == clientID && entry.Round == v.round {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the ledger, if it has a path
+func (v *Verifier) save() error {
+	if v.ledgerPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(v.ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(v.ledgerPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	return os.WriteFile(v.ledgerPath, data, 0644)
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"flhhe/configs"
+	"flhhe/src/RtF"
+	"flhhe/src/hhe_fedavg/client"
+	"flhhe/src/hhe_fedavg/keys_dealer"
+	"flhhe/src/utils"
+)
+
+// signedUpload returns the upload of a client registered under rootPath for the given round, signed
+// with its identity key, with two random plaintexts and the nonces of seed
+func signedUpload(t *testing.T, rootPath string, rubatoParams *keys_dealer.RubatoParams, clientID string, round int, seed []byte) *client.FLClient {
+	t.Helper()
+	params := rubatoParams.Params
+	if _, err := client.Register(utils.NewLogger(false), rootPath, clientID); err != nil {
+		t.Fatal(err)
+	}
+	key, err := client.LoadIdentityKey(rootPath, clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces, err := client.ExpandNonces(seed, params.FVSlots())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts := make([]*RtF.PlaintextRingT, 2)
+	for i := range pts {
+		pts[i] = RtF.NewPlaintextRingT(params)
+		for j := range pts[i].Value()[0].Coeffs[0] {
+			pts[i].Value()[0].Coeffs[0][j] = utils.RandUint64() % params.PlainModulus()
+		}
+	}
+	c := &client.FLClient{
+		ClientID:   clientID,
+		Round:      round,
+		NonceSeed:  seed,
+		Nonces:     nonces,
+		Counter:    make([]byte, client.NonceSize),
+		SymmCipher: pts,
+	}
+	if err = c.Sign(key, rubatoParams.Packing, params); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestVerifier(t *testing.T) {
+	packing := keys_dealer.PackingCoefficients
+	params := RtF.DefaultParams[RtF.PN13QP218].WithPlainModulus(RtF.RubatoParams[RtF.RUBATO128L].PlainModulus)
+	params.SetLogFVSlots(packing.LogFVSlots(params))
+	rubatoParams := &keys_dealer.RubatoParams{Params: params, Packing: packing}
+	rootPath := t.TempDir()
+	seed := func(b byte) []byte { return bytes.Repeat([]byte{b}, RtF.PRNGSeedSize) }
+
+	// The clients register before the verifier loads the registry
+	do1 := signedUpload(t, rootPath, rubatoParams, "do1", 0, seed(1))
+	do2 := signedUpload(t, rootPath, rubatoParams, "do2", 0, seed(2))
+	do1Again := signedUpload(t, rootPath, rubatoParams, "do1", 0, seed(3))
+	do3SameSeed := signedUpload(t, rootPath, rubatoParams, "do3", 0, seed(1))
+	do2NextRound := signedUpload(t, rootPath, rubatoParams, "do2", 1, seed(4))
+	registry, err := keys_dealer.LoadRegistry(filepath.Join(rootPath, configs.Keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(registry, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A verified upload isn't recorded until transciphered: it can be sent again until Record
+	for range 2 {
+		if err = verifier.Verify(do1, rubatoParams); err != nil {
+			t.Fatalf("Verify of a signed upload: %v", err)
+		}
+	}
+	if err = verifier.Record(do1); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := *do2
+	tampered.SymmCipher = []*RtF.PlaintextRingT{RtF.NewPlaintextRingT(params), do2.SymmCipher[1]}
+	unsigned := *do2
+	unsigned.Signature = nil
+	intruder := *do2
+	intruder.ClientID = "intruder"
+	for _, tc := range []struct {
+		name   string
+		upload *client.FLClient
+		want   error
+	}{
+		{"accepted upload", do1, ErrReplay},
+		{"new upload of an accepted client", do1Again, ErrReplay},
+		{"upload reusing a nonce seed", do3SameSeed, ErrReplay},
+		{"upload of another round", do2NextRound, ErrReplay},
+		{"tampered upload", &tampered, ErrUnauthenticated},
+		{"unsigned upload", &unsigned, ErrUnauthenticated},
+		{"upload of an unregistered client", &intruder, ErrUnauthenticated},
+	} {
+		if err = verifier.Verify(tc.upload, rubatoParams); !errors.Is(err, tc.want) {
+			t.Errorf("Verify of the %s: got error %v, want %v", tc.name, err, tc.want)
+		}
+	}
+	if err = verifier.Verify(do2, rubatoParams); err != nil {
+		t.Errorf("Verify of a signed upload: %v", err)
+	}
+	if !verifier.Recorded(do1.ClientID) || verifier.Recorded(do2.ClientID) {
+		t.Errorf("Recorded: got %t for the recorded client and %t for the verified one, want true and false",
+			verifier.Recorded(do1.ClientID), verifier.Recorded(do2.ClientID))
+	}
+
+	// The ledger outlives the verifier, but only holds the recorded uploads
+	ledgerPath := filepath.Join(rootPath, configs.UploadLedger)
+	for run, want := range []error{nil, nil, ErrReplay} {
+		v, err := NewVerifier(registry, 0, ledgerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = v.Verify(do2, rubatoParams); !errors.Is(err, want) {
+			t.Errorf("Verify in run %d of the server: got error %v, want %v", run, err, want)
+		}
+		if run == 1 {
+			if err = v.Record(do2); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	nextRound, err := NewVerifier(registry, 1, ledgerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextRound.Recorded(do2.ClientID) {
+		t.Error("Recorded: the upload of round 0 is recorded for round 1")
+	}
+}
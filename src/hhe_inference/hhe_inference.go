@@ -0,0 +1,33 @@
+// Command hhe_inference runs the private inference on the aggregated model: the evaluator computes the
+// MNIST network on an encrypted test set with the encrypted average weights produced by `just run-hhe`,
+// without the secret key, and the key holder decrypts the logits.
+package main
+
+import (
+	"flag"
+	"time"
+
+	"flhhe/src/RtF"
+	"flhhe/src/experiment"
+	"flhhe/src/hhe_fedavg/inference"
+	"flhhe/src/utils"
+)
+
+func main() {
+	configPath := flag.String("config", "", "experiment configuration (default "+experiment.DefaultConfig+")")
+	flag.Parse()
+
+	logger := utils.NewLogger(utils.DEBUG)
+	cfg, err := experiment.LoadOrDefault(*configPath)
+	utils.HandleError(err)
+	utils.HandleError(cfg.ApplyRuntime())
+	RtF.SetPRNGLogger(logger)
+	configCopy, err := cfg.Save("inference")
+	utils.HandleError(err)
+	logger.PrintFormatted("Experiment configuration saved to %s", configCopy)
+
+	t := time.Now()
+	_, err = inference.Run(logger, cfg, inference.DefaultActivationBound)
+	utils.HandleError(err)
+	logger.PrintRunningTime("Total time to run the encrypted inference", t)
+}
@@ -0,0 +1,281 @@
+// Package metrics records the metrics of a run: the duration of every protocol phase, the bytes
+// uploaded, the ciphertext counts and the peak memory, per role and client. The running times and
+// memory usages logged by the utils.Logger are recorded by the default registry, the roles add their
+// counters. The registry is exposed in the Prometheus text format on /metrics and saved as a JSON run
+// report, to compare the parameter sets across runs.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flhhe/src/utils"
+)
+
+// Namespace the prefix of the Prometheus metric names
+const Namespace = "flhhe"
+
+// Counters added by the roles
+const (
+	BytesUploaded = "bytes_uploaded" // the symmetric ciphertexts, nonces and counter saved by a client
+	Ciphertexts   = "ciphertexts"    // the ciphertexts produced: symmetric by a client, CKKS by the server
+)
+
+// Labels identify the role and the client of a phase or a counter, the client ID is empty for the work
+// shared by all the clients
+type Labels struct {
+	Role     string `json:"role,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// Phase the running times of a phase
+type Phase struct {
+	Labels
+	Phase      string  `json:"phase"`
+	Count      int     `json:"count"`
+	Seconds    float64 `json:"seconds"` // total
+	MaxSeconds float64 `json:"max_seconds"`
+}
+
+// Counter the total of a counter
+type Counter struct {
+	Labels
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// Report the JSON run report, the phases and counters are sorted by role, client and name
+type Report struct {
+	Start          time.Time `json:"start"`
+	Seconds        float64   `json:"seconds"`
+	PeakAllocBytes uint64    `json:"peak_alloc_bytes"` // peak of the bytes of allocated heap objects
+	PeakSysBytes   uint64    `json:"peak_sys_bytes"`   // peak of the bytes obtained from the OS
+	Phases         []Phase   `json:"phases"`
+	Counters       []Counter `json:"counters"`
+}
+
+type phaseKey struct {
+	Labels
+	phase string
+}
+
+type counterKey struct {
+	Labels
+	name string
+}
+
+// Registry records the metrics of a run, it's safe for concurrent use
+type Registry struct {
+	mu             sync.Mutex
+	start          time.Time
+	peakAllocBytes uint64
+	peakSysBytes   uint64
+	phases         map[phaseKey]*Phase
+	counters       map[counterKey]float64
+}
+
+// NewRegistry returns an empty registry, its run starts now
+func NewRegistry() *Registry {
+	return &Registry{
+		start:    time.Now(),
+		phases:   make(map[phaseKey]*Phase),
+		counters: make(map[counterKey]float64),
+	}
+}
+
+var defaultRegistry = NewRegistry()
+
+// The default registry records the phases of all the loggers
+func init() {
+	utils.SetPhaseObserver(defaultRegistry)
+}
+
+// Default returns the registry of the run, recording the phases logged by the utils.Logger
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Reset empties the registry, its run starts now
+func (r *Registry) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.start = time.Now()
+	r.peakAllocBytes, r.peakSysBytes = 0, 0
+	r.phases = make(map[phaseKey]*Phase)
+	r.counters = make(map[counterKey]float64)
+}
+
+// ObservePhase records a running time of a phase
+func (r *Registry) ObservePhase(role, clientID, phase string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := phaseKey{Labels{role, clientID}, phase}
+	p, ok := r.phases[key]
+	if !ok {
+		p = &Phase{Labels: key.Labels, Phase: phase}
+		r.phases[key] = p
+	}
+	p.Count++
+	p.Seconds += d.Seconds()
+	p.MaxSeconds = max(p.MaxSeconds, d.Seconds())
+}
+
+// ObserveMemory records the memory usage at the end of a phase in the peak memory
+func (r *Registry) ObserveMemory(_, _, _ string, m *runtime.MemStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observeMemory(m)
+}
+
+func (r *Registry) observeMemory(m *runtime.MemStats) {
+	r.peakAllocBytes = max(r.peakAllocBytes, m.Alloc)
+	r.peakSysBytes = max(r.peakSysBytes, m.Sys)
+}
+
+// Add adds v to a counter, BytesUploaded or Ciphertexts for instance
+func (r *Registry) Add(name, role, clientID string, v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[counterKey{Labels{role, clientID}, name}] += v
+}
+
+// Report returns the report of the run so far, sampling the memory usage
+func (r *Registry) Report() Report {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observeMemory(&m)
+	report := Report{
+		Start:          r.start,
+		Seconds:        time.Since(r.start).Seconds(),
+		PeakAllocBytes: r.peakAllocBytes,
+		PeakSysBytes:   r.peakSysBytes,
+		Phases:         make([]Phase, 0, len(r.phases)),
+		Counters:       make([]Counter, 0, len(r.counters)),
+	}
+	for _, p := range r.phases {
+		report.Phases = append(report.Phases, *p)
+	}
+	for key, v := range r.counters {
+		report.Counters = append(report.Counters, Counter{Labels: key.Labels, Name: key.name, Value: v})
+	}
+	sort.Slice(report.Phases, func(i, j int) bool {
+		a, b := report.Phases[i], report.Phases[j]
+		return a.Labels.less(b.Labels) || a.Labels == b.Labels && a.Phase < b.Phase
+	})
+	sort.Slice(report.Counters, func(i, j int) bool {
+		a, b := report.Counters[i], report.Counters[j]
+		return a.Name < b.Name || a.Name == b.Name && a.Labels.less(b.Labels)
+	})
+	return report
+}
+
+func (l Labels) less(other Labels) bool {
+	return l.Role < other.Role || l.Role == other.Role && l.ClientID < other.ClientID
+}
+
+// SaveReport writes the JSON report of the run
+func (r *Registry) SaveReport(path string) error {
+	data, err := json.MarshalIndent(r.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save the metrics report: %v", err)
+	}
+	return nil
+}
+
+// WritePrometheus writes the report of the run in the Prometheus text exposition format: the phases are
+// the summaries flhhe_phase_seconds (sum and count) with the gauge flhhe_phase_max_seconds, the counters
+// flhhe_<name>_total
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	report := r.Report()
+	buf := new(strings.Builder)
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(buf, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", Namespace, name, help, Namespace, name, kind)
+	}
+
+	metric("run_seconds", "gauge", "Time since the start of the run.")
+	fmt.Fprintf(buf, "%s_run_seconds %g\n", Namespace, report.Seconds)
+	metric("peak_alloc_bytes", "gauge", "Peak of the bytes of allocated heap objects.")
+	fmt.Fprintf(buf, "%s_peak_alloc_bytes %d\n", Namespace, report.PeakAllocBytes)
+	metric("peak_sys_bytes", "gauge", "Peak of the bytes of memory obtained from the OS.")
+	fmt.Fprintf(buf, "%s_peak_sys_bytes %d\n", Namespace, report.PeakSysBytes)
+
+	if len(report.Phases) > 0 {
+		metric("phase_seconds", "summary", "Running time of the protocol phases.")
+		for _, p := range report.Phases {
+			labels := p.Labels.prometheus("phase", p.Phase)
+			fmt.Fprintf(buf, "%s_phase_seconds_sum%s %g\n", Namespace, labels, p.Seconds)
+			fmt.Fprintf(buf, "%s_phase_seconds_count%s %d\n", Namespace, labels, p.Count)
+		}
+		metric("phase_max_seconds", "gauge", "Longest running time of the protocol phases.")
+		for _, p := range report.Phases {
+			fmt.Fprintf(buf, "%s_phase_max_seconds%s %g\n", Namespace, p.Labels.prometheus("phase", p.Phase), p.MaxSeconds)
+		}
+	}
+
+	for i, c := range report.Counters {
+		if i == 0 || c.Name != report.Counters[i-1].Name {
+			metric(c.Name+"_total", "counter", "Total of the "+strings.ReplaceAll(c.Name, "_", " ")+".")
+		}
+		fmt.Fprintf(buf, "%s_%s_total%s %g\n", Namespace, c.Name, c.Labels.prometheus(), c.Value)
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// prometheus formats the labels, the empty ones are left out
+func (l Labels) prometheus(extra ...string) string {
+	pairs := append([]string{utils.LogKeyRole, l.Role, utils.LogKeyClientID, l.ClientID}, extra...)
+	var labels []string
+	for i := 0; i < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			labels = append(labels, fmt.Sprintf("%s=%q", pairs[i], pairs[i+1]))
+		}
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Serve exposes the metrics of the registry on http://addr/metrics until the returned server is closed
+func (r *Registry) Serve(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	return server, nil
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"flhhe/src/utils"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.ObservePhase(utils.RoleServer, "do1", "HalfBoot", 2*time.Second)
+	r.ObservePhase(utils.RoleServer, "do1", "HalfBoot", time.Second)
+	r.ObservePhase(utils.RoleKeysDealer, "", "Rotation Keys Generation", time.Second)
+	r.Add(BytesUploaded, utils.RoleClient, "do1", 1000)
+	r.Add(BytesUploaded, utils.RoleClient, "do1", 24)
+	r.Add(Ciphertexts, utils.RoleServer, "", 4)
+
+	report := r.Report()
+	if len(report.Phases) != 2 || report.Phases[1] != (Phase{Labels{utils.RoleServer, "do1"}, "HalfBoot", 2, 3, 2}) {
+		t.Errorf("unexpected phases %+v", report.Phases)
+	}
+	if len(report.Counters) != 2 || report.Counters[0] != (Counter{Labels{utils.RoleClient, "do1"}, BytesUploaded, 1024}) {
+		t.Errorf("unexpected counters %+v", report.Counters)
+	}
+	if report.PeakAllocBytes == 0 || report.PeakSysBytes == 0 {
+		t.Errorf("no peak memory in %+v", report)
+	}
+
+	// Prometheus text format on /metrics
+	recorder := httptest.NewRecorder()
+	r.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE flhhe_phase_seconds summary\n",
+		`flhhe_phase_seconds_sum{role="server",client_id="do1",phase="HalfBoot"} 3` + "\n",
+		`flhhe_phase_seconds_count{role="server",client_id="do1",phase="HalfBoot"} 2` + "\n",
+		`flhhe_phase_max_seconds{role="keys_dealer",phase="Rotation Keys Generation"} 1` + "\n",
+		"# TYPE flhhe_bytes_uploaded_total counter\n",
+		`flhhe_bytes_uploaded_total{role="client",client_id="do1"} 1024` + "\n",
+		`flhhe_ciphertexts_total{role="server"} 4` + "\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics doesn't contain %q:\n%s", want, body)
+		}
+	}
+
+	// JSON run report
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	if err = r.SaveReport(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Report
+	if err = json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Phases) != 2 || saved.Phases[1].Seconds != 3 || saved.Counters[0].Value != 1024 {
+		t.Errorf("unexpected saved report %s", data)
+	}
+}
+
+// TestLoggerPhases checks that the default registry records the running times of the loggers, with
+// their role and client ID
+func TestLoggerPhases(t *testing.T) {
+	Default().Reset()
+	defer Default().Reset()
+	logger := utils.NewLogger(false).With(utils.LogKeyRole, utils.RoleClient, utils.LogKeyClientID, "do2")
+	logger.PrintRunningTime("Time to generate the keystream", time.Now())
+	logger.PrintMemUsage("Keystream")
+
+	phases := Default().Report().Phases
+	if len(phases) != 1 || phases[0].Labels != (Labels{utils.RoleClient, "do2"}) || phases[0].Phase != "Time to generate the keystream" {
+		t.Errorf("unexpected phases %+v", phases)
+	}
+}
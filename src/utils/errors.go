@@ -0,0 +1,7 @@
+package utils
+
+import "errors"
+
+// ErrCorruptArtifact a file of the pipeline can't be decoded. RtF.ErrCorruptArtifact is the same error,
+// errors.Is matches it whichever package returned it.
+var ErrCorruptArtifact = errors.New("corrupt artifact")
@@ -10,11 +10,12 @@ import (
 	"strings"
 )
 
-// HandleError checks the error and throws a panic if the error isn't nil
+// HandleError checks the error and throws a panic with it if it isn't nil. The library APIs return
+// their errors, HandleError is for the commands and the tests which can't go on without the result.
 func HandleError(err error) {
 	if err != nil {
 		fmt.Printf("|-> Error: %s\n", err.Error())
-		panic("=== Panic\n ")
+		panic(err)
 	}
 }
 
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// LogFormat is the output format of the loggers, selected at runtime by SetLogOutput
+type LogFormat string
+
+const (
+	LogText LogFormat = "text" // slog.TextHandler, key=value pairs
+	LogJSON LogFormat = "json" // slog.JSONHandler, one JSON object per line
+)
+
+// The keys of the fields of the log records
+const (
+	LogKeyRole     = "role"      // RoleKeysDealer, RoleClient or RoleServer
+	LogKeyClientID = "client_id" // the client of the record
+	LogKeyPhase    = "phase"     // the timed phase of PrintRunningTime and PrintMemUsage
+	LogKeySeconds  = "seconds"   // the running time of the phase
+)
+
+// The roles of the protocol, values of the role field
+const (
+	RoleKeysDealer = "keys_dealer"
+	RoleClient     = "client"
+	RoleServer     = "server"
+)
+
+// ParseLogFormat parses a log format, text if it's empty
+func ParseLogFormat(format string) (LogFormat, error) {
+	switch LogFormat(format) {
+	case "", LogText:
+		return LogText, nil
+	case LogJSON:
+		return LogJSON, nil
+	}
+	return "", fmt.Errorf("unknown log format %q, want %q or %q", format, LogText, LogJSON)
+}
+
+// PhaseObserver receives the running times and the memory usages logged by all the loggers, with the
+// role and client ID fields of the logger, empty if it has none (see metrics.Registry)
+type PhaseObserver interface {
+	ObservePhase(role, clientID, phase string, d time.Duration)
+	ObserveMemory(role, clientID, phase string, m *runtime.MemStats)
+}
+
+// logOutput is the handler all the loggers of NewLogger write to, text on the standard output by default,
+// and the observer of their phases
+var logOutput struct {
+	sync.RWMutex
+	handler  slog.Handler
+	observer PhaseObserver
+}
+
+func init() {
+	SetLogOutput(os.Stdout, LogText)
+}
+
+// SetLogOutput sets the writer and the format of the loggers created by NewLogger, including the ones
+// created before the call. The records of all the levels are written, each logger filters its own.
+func SetLogOutput(w io.Writer, format LogFormat) {
+	options := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var handler slog.Handler
+	if format == LogJSON {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	logOutput.Lock()
+	defer logOutput.Unlock()
+	logOutput.handler = handler
+}
+
+// SetPhaseObserver sets the observer of the running times and memory usages of the loggers, none if nil
+func SetPhaseObserver(observer PhaseObserver) {
+	logOutput.Lock()
+	defer logOutput.Unlock()
+	logOutput.observer = observer
+}
+
+// phaseObserver returns the observer set by SetPhaseObserver
+func phaseObserver() PhaseObserver {
+	logOutput.RLock()
+	defer logOutput.RUnlock()
+	return logOutput.observer
+}
+
+// outputHandler forwards the records of a level to the handler set by SetLogOutput when they are
+// written, with the attributes and groups of the logger
+type outputHandler struct {
+	level slog.Leveler
+	with  []func(slog.Handler) slog.Handler
+}
+
+func (h *outputHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *outputHandler) Handle(ctx context.Context, r slog.Record) error {
+	logOutput.RLock()
+	handler := logOutput.handler
+	logOutput.RUnlock()
+	for _, with := range h.with {
+		handler = with(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *outputHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.withHandler(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *outputHandler) WithGroup(name string) slog.Handler {
+	return h.withHandler(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *outputHandler) withHandler(with func(slog.Handler) slog.Handler) slog.Handler {
+	return &outputHandler{level: h.level, with: append(h.with[:len(h.with):len(h.with)], with)}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoggerJSON(t *testing.T) {
+	// The loggers created before SetLogOutput write to the new output, a logger without debug drops
+	// the messages
+	logger := NewLogger(false).With(LogKeyRole, "server", LogKeyClientID, "do1")
+	buf := new(bytes.Buffer)
+	SetLogOutput(buf, LogJSON)
+	defer SetLogOutput(os.Stdout, LogText)
+	logger.PrintMessage("dropped")
+	logger.PrintFormatted("dropped %d", 1)
+	logger.PrintRunningTime("Total time to load the keys: ", time.Now().Add(-time.Second))
+
+	var record map[string]any
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d records, want 1:\n%s", len(lines), buf)
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"level":        "INFO",
+		LogKeyRole:     "server",
+		LogKeyClientID: "do1",
+		LogKeyPhase:    "Total time to load the keys",
+	} {
+		if record[key] != want {
+			t.Errorf("%s: got %v, want %v", key, record[key], want)
+		}
+	}
+	if seconds, ok := record[LogKeySeconds].(float64); !ok || seconds < 1 {
+		t.Errorf("%s: got %v, want >= 1", LogKeySeconds, record[LogKeySeconds])
+	}
+
+	// A debug logger writes the messages, in text
+	buf.Reset()
+	SetLogOutput(buf, LogText)
+	NewLogger(true).PrintFormatted("Level: %d", 3)
+	if got := buf.String(); !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, `msg="Level: 3"`) {
+		t.Errorf("unexpected text record %q", got)
+	}
+
+	if _, err := ParseLogFormat("xml"); err == nil {
+		t.Error("ParseLogFormat(xml): no error")
+	}
+}
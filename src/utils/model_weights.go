@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"flhhe/configs"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -54,40 +55,40 @@ func Flatten2D(matrix [][]float64) []float64 {
 	return flattened
 }
 
-func OpenModelWeights(logger Logger, root string, weightFile string) ModelWeights {
-	var err error
+func OpenModelWeights(logger Logger, root string, weightFile string) (ModelWeights, error) {
 	weightDir := filepath.Join(root, configs.PlaintextWeights)
 
 	weightPath := filepath.Join(weightDir, weightFile)
 	logger.PrintFormatted("Loading weights from %s", weightPath)
 	weights := NewModelWeights()
-	err = weights.LoadWeights(weightPath)
-	HandleError(err)
+	if err := weights.LoadWeights(weightPath); err != nil {
+		return weights, err
+	}
 
-	return weights
+	return weights, nil
 }
 
 // SaveToJSON saves a slice of float64 values to a JSON file
-func SaveToJSON(logger Logger, weightDir string, filename string, data []float64) {
+func SaveToJSON(logger Logger, weightDir string, filename string, data []float64) error {
 	// Create a file
 	fileName := filepath.Join(weightDir, filename)
 	file, err := os.Create(fileName)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer file.Close()
 
 	// Create an encoder and write the data
 	encoder := json.NewEncoder(file)
-	err = encoder.Encode(data)
-	if err != nil {
-		panic(err)
+	if err = encoder.Encode(data); err != nil {
+		return fmt.Errorf("%s: %w", fileName, err)
 	}
 	logger.PrintFormatted("Saved data to %s", fileName)
+	return nil
 }
 
-// SaveComplexToJSON saves a slice of complex128 values to a JSON file
-func SaveComplexToJSON(logger Logger, weightDir string, filename string, data []complex128) {
+// SaveComplexToJSON saves the real parts of a slice of complex128 values to a JSON file
+func SaveComplexToJSON(logger Logger, weightDir string, filename string, data []complex128) error {
 	// Convert complex128 to float64 by taking the real part
 	floatData := make([]float64, len(data))
 	for i, val := range data {
@@ -95,5 +96,5 @@ func SaveComplexToJSON(logger Logger, weightDir string, filename string, data []
 	}
 
 	// Use existing saveToJSON function to save the float64 data
-	SaveToJSON(logger, weightDir, filename, floatData)
+	return SaveToJSON(logger, weightDir, filename, floatData)
 }
@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"math"
 	"reflect"
 	"runtime"
@@ -17,14 +19,27 @@ const MeMStat = true
 const PREFIX = ""
 const LONG_PREFIX = "->> "
 
+// logger adapts a *slog.Logger to the Logger interface: the messages are debug records, the headers
+// and the running times info records, the running times and memory usages with phase fields. The role
+// and client ID fields added by With label the phases given to the PhaseObserver.
 type logger struct {
-	debug bool
+	slog     *slog.Logger
+	role     string
+	clientID string
 }
 
+// NewLogger returns a logger writing to the output set by SetLogOutput, with the debug records if debug
 func NewLogger(debug bool) Logger {
-	return &logger{
-		debug: debug,
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
 	}
+	return NewSlogLogger(slog.New(&outputHandler{level: level}))
+}
+
+// NewSlogLogger returns the Logger adapter of a *slog.Logger
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &logger{slog: l}
 }
 
 type Logger interface {
@@ -37,39 +52,70 @@ type Logger interface {
 	PrintRunningTime(name string, t time.Time)
 	PrintSummarizedVector(name string, vec []uint64, numElements int)
 	PrintSummarizedMatrix(name string, mat [][]interface{}, numRows int, numElements int)
+	// With returns a logger adding the fields of args (key-value pairs or slog.Attr) to its records,
+	// e.g. With(LogKeyRole, RoleServer)
+	With(args ...any) Logger
+	// Slog returns the underlying *slog.Logger
+	Slog() *slog.Logger
 }
 
-func (l logger) PrintMessage(message string) {
-	if l.debug {
-		fmt.Print(PREFIX)
-		fmt.Printf("%s", message)
-		fmt.Println()
+func (l logger) With(args ...any) Logger {
+	with := &logger{slog: l.slog.With(args...), role: l.role, clientID: l.clientID}
+	for i := 0; i < len(args); i++ {
+		var attr slog.Attr
+		switch arg := args[i].(type) {
+		case slog.Attr:
+			attr = arg
+		case string:
+			if i+1 == len(args) {
+				continue
+			}
+			attr = slog.Any(arg, args[i+1])
+			i++
+		default:
+			continue
+		}
+		switch attr.Key {
+		case LogKeyRole:
+			with.role = attr.Value.String()
+		case LogKeyClientID:
+			with.clientID = attr.Value.String()
+		}
 	}
+	return with
+}
+
+func (l logger) Slog() *slog.Logger {
+	return l.slog
+}
+
+func (l logger) debug() bool {
+	return l.slog.Enabled(context.Background(), slog.LevelDebug)
+}
+
+func (l logger) PrintMessage(message string) {
+	l.slog.Debug(message)
 }
 
 func (l logger) PrintMessages(messages ...interface{}) {
-	if l.debug {
-		fmt.Print(PREFIX)
+	if l.debug() {
+		buf := new(strings.Builder)
 		for _, message := range messages {
-			fmt.Print(message)
+			fmt.Fprint(buf, message)
 		}
-		fmt.Println()
+		l.slog.Debug(buf.String())
 	}
 }
 
 func (l logger) PrintFormatted(format string, args ...interface{}) {
-	if l.debug {
-		fmt.Print(LONG_PREFIX)
-		fmt.Printf(format, args...)
-		fmt.Println()
+	if l.debug() {
+		l.slog.Debug(fmt.Sprintf(format, args...))
 	}
 }
 
 func (l logger) PrintDataLen(data []uint64) {
-	if l.debug {
-		fmt.Print(LONG_PREFIX)
-		fmt.Printf("Len: %d, Data: %d", len(data), data)
-		fmt.Println()
+	if l.debug() {
+		l.slog.Debug(fmt.Sprintf("Len: %d, Data: %d", len(data), data), "len", len(data))
 	}
 }
 
@@ -78,46 +124,12 @@ func (l logger) PrintDataLen(data []uint64) {
 //	fmt.Println(fmt.Sprintf("=== ----\t\t\t %s \t\t\t---- ===", header))
 //}
 
-// PrintHeader prints a nicely formatted header, auto-wrapping if too long.
+// PrintHeader logs a header, an info record: the start of a step of the protocol
 func (l logger) PrintHeader(header string) {
-	const totalWidth = 80 // Adjust total width as needed
-	const padding = 4     // Space between text and borders
-
-	// Split header into lines if too long
-	lines := splitIntoLines(header, totalWidth-(padding*2))
-
-	fmt.Println()
-	fmt.Println(strings.Repeat("=", totalWidth)) // Top border
-
-	for _, line := range lines {
-		paddingLeft := (totalWidth - len(line)) / 2
-		paddingRight := totalWidth - len(line) - paddingLeft
-		fmt.Println(strings.Repeat(" ", paddingLeft) + line + strings.Repeat(" ", paddingRight))
-	}
-
-	fmt.Println(strings.Repeat("=", totalWidth)) // Bottom border
-	//fmt.Println()
+	l.slog.Info(header)
 }
 
-// splitIntoLines splits a string into multiple lines based on max width.
-func splitIntoLines(text string, maxWidth int) []string {
-	var lines []string
-	for len(text) > maxWidth {
-		// Find the nearest space before maxWidth
-		splitAt := strings.LastIndex(text[:maxWidth], " ")
-		if splitAt == -1 {
-			splitAt = maxWidth // No spaces found, force break
-		}
-		lines = append(lines, strings.TrimSpace(text[:splitAt]))
-		text = strings.TrimSpace(text[splitAt:])
-	}
-	if len(text) > 0 {
-		lines = append(lines, text)
-	}
-	return lines
-}
-
-// PrintMemUsage outputs the followings
+// PrintMemUsage logs the followings, in MB
 // Alloc: the bytes of allocated heap objects.
 // TotalAlloc: the cumulative bytes allocated for heap objects
 // Sys: the total bytes of memory obtained from the OS
@@ -129,7 +141,6 @@ func (l logger) PrintMemUsage(name string) {
 	alloc := float64(m.Alloc) / mb
 	tAlloc := float64(m.TotalAlloc) / mb
 	mSys := float64(m.Sys) / mb
-	//numGC := m.NumGC
 	buf := new(strings.Builder)
 	width := 15 + 7
 	_, err := fmt.Fprintf(buf, "|-> %-*s", width, name)
@@ -140,14 +151,22 @@ func (l logger) PrintMemUsage(name string) {
 	prettyPrint(buf, tAlloc, "MB")
 	buf.WriteByte('\t')
 	prettyPrint(buf, mSys, "MB")
-	if MeMStat {
-		fmt.Println(buf)
+	if observer := phaseObserver(); observer != nil {
+		observer.ObserveMemory(l.role, l.clientID, name, &m)
 	}
+	l.slog.Info(buf.String(), LogKeyPhase, name, "alloc_mb", alloc, "total_alloc_mb", tAlloc, "sys_mb", mSys)
 }
 
+// PrintRunningTime logs the running time of a phase since t, an info record with the phase and seconds
+// fields
 func (l logger) PrintRunningTime(name string, t time.Time) {
-	fmt.Print(PREFIX)
-	fmt.Printf("%s running time: %f (s)\n", name, time.Now().Sub(t).Seconds())
+	d := time.Since(t)
+	seconds := d.Seconds()
+	phase := strings.TrimRight(name, ": ")
+	if observer := phaseObserver(); observer != nil {
+		observer.ObservePhase(l.role, l.clientID, phase, d)
+	}
+	l.slog.Info(fmt.Sprintf("%s running time: %f (s)", name, seconds), LogKeyPhase, phase, LogKeySeconds, seconds)
 }
 
 // Helps to print the MemStats
@@ -179,12 +198,11 @@ func prettyPrint(w io.Writer, x float64, unit string) {
 	HandleError(err)
 }
 
-// PrintSummarizedMatrix prints a summarized view of a matrix
+// PrintSummarizedMatrix logs a summarized view of a matrix, a debug record
 func (l logger) PrintSummarizedMatrix(name string, mat [][]interface{}, numRows int, numElements int) {
 	const summaryLength = 5
 	if len(mat) == 0 || len(mat[0]) == 0 {
-		fmt.Print(PREFIX)
-		fmt.Println("Matrix is empty!")
+		l.slog.Debug("Matrix is empty!", "name", name)
 		return
 	}
 	// Get the kind of the first element in the first row
@@ -200,37 +218,38 @@ func (l logger) PrintSummarizedMatrix(name string, mat [][]interface{}, numRows
 		return
 	}
 
-	if l.debug {
-		fmt.Printf("%s:\n", name)
+	if l.debug() {
+		buf := new(strings.Builder)
+		fmt.Fprintf(buf, "%s:", name)
 		for i := 0; i < numRows; i++ {
 			if i > summaryLength {
 				break
 			}
-			fmt.Printf("[%d][]: {", i)
+			fmt.Fprintf(buf, "\n[%d][]: {", i)
 			if numElements > 2*summaryLength {
 				for j := 0; j < summaryLength; j++ {
-					fmt.Printf(format, mat[i][j])
+					fmt.Fprintf(buf, format, mat[i][j])
 				}
-				fmt.Printf("... ")
+				buf.WriteString("... ")
 				for j := numElements - summaryLength; j < numElements; j++ {
-					fmt.Printf(format, mat[i][j])
+					fmt.Fprintf(buf, format, mat[i][j])
 				}
 			} else {
 				for j := 0; j < numElements; j++ {
-					fmt.Printf(format, mat[i][j])
+					fmt.Fprintf(buf, format, mat[i][j])
 				}
 			}
-			fmt.Printf("}\n")
+			buf.WriteString("}")
 		}
+		l.slog.Debug(buf.String(), "name", name)
 	}
 }
 
-// PrintSummarizedVector prints a summarized view of a vector
+// PrintSummarizedVector logs a summarized view of a vector, a debug record
 func (l logger) PrintSummarizedVector(name string, vec []uint64, numElements int) {
 	const summaryLength = 4
 	if len(vec) == 0 {
-		fmt.Print(PREFIX)
-		fmt.Println("Vector is empty!")
+		l.slog.Debug("Vector is empty!", "name", name)
 		return
 	}
 	// Get the kind of the first element in the first row
@@ -246,21 +265,23 @@ func (l logger) PrintSummarizedVector(name string, vec []uint64, numElements int
 		return
 	}
 
-	if l.debug {
-		fmt.Printf("[%s]: {", name)
+	if l.debug() {
+		buf := new(strings.Builder)
+		fmt.Fprintf(buf, "[%s]: {", name)
 		if numElements > 2*summaryLength {
 			for i := 0; i < summaryLength; i++ {
-				fmt.Printf(format, vec[i])
+				fmt.Fprintf(buf, format, vec[i])
 			}
-			fmt.Printf("... ")
+			buf.WriteString("... ")
 			for i := numElements - summaryLength; i < numElements; i++ {
-				fmt.Printf(format, vec[i])
+				fmt.Fprintf(buf, format, vec[i])
 			}
 		} else {
 			for i := 0; i < numElements; i++ {
-				fmt.Printf(format, vec[i])
+				fmt.Fprintf(buf, format, vec[i])
 			}
 		}
-		fmt.Printf("}\n")
+		buf.WriteString("}")
+		l.slog.Debug(buf.String(), "name", name)
 	}
 }
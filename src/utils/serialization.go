@@ -80,23 +80,24 @@ func UnMarshalJSON(data []byte) (object any, err error) {
 	}
 }
 
-func LoadFromJSON(logger Logger, weightDir string, filename string) []float64 {
+// LoadFromJSON loads a slice of float64 values from a JSON file, it returns an ErrCorruptArtifact error
+// if the file isn't a JSON array of numbers
+func LoadFromJSON(logger Logger, weightDir string, filename string) ([]float64, error) {
 	// Open the file
 	fileName := filepath.Join(weightDir, filename)
 	file, err := os.Open(fileName)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer file.Close()
 
 	// Create a decoder and read the data
 	var data []float64
 	decoder := json.NewDecoder(file)
-	err = decoder.Decode(&data)
-	if err != nil {
-		panic(err)
+	if err = decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorruptArtifact, fileName, err)
 	}
 	logger.PrintFormatted("Loaded data from %s", fileName)
 
-	return data
+	return data, nil
 }
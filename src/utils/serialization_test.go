@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFromJSON(t *testing.T) {
+	logger := NewLogger(false)
+	dir := t.TempDir()
+	weights := []float64{0.5, -1.25, 3}
+	if err := SaveToJSON(logger, dir, "weights.json", weights); err != nil {
+		t.Fatal(err)
+	}
+	have, err := LoadFromJSON(logger, dir, "weights.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(have, weights) {
+		t.Errorf("got %v, want %v", have, weights)
+	}
+
+	// A truncated file or one of other values is corrupt, a missing one isn't
+	for name, data := range map[string]string{"truncated.json": "[0.5, -1.2", "object.json": `{"weights": [0.5]}`} {
+		if err = os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = LoadFromJSON(logger, dir, name); !errors.Is(err, ErrCorruptArtifact) {
+			t.Errorf("%s: got error %v, want %v", name, err, ErrCorruptArtifact)
+		}
+	}
+	if _, err = LoadFromJSON(logger, dir, "missing.json"); err == nil || errors.Is(err, ErrCorruptArtifact) {
+		t.Errorf("missing file: got error %v, want a not found error", err)
+	}
+}